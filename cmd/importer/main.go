@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sso/internal/config"
+	"sso/internal/lib/passwords"
+	"sso/internal/services/importer"
+	"sso/internal/storage/sqlite"
+	"strings"
+
+	"github.com/jacute/prettylogger"
+)
+
+func main() {
+	var file, format string
+
+	flag.StringVar(&file, "file", "", "Path to the CSV or JSON Lines file with users")
+	flag.StringVar(&format, "format", "", "Input format: csv or jsonl, by default taken from the file extension")
+	cfg := config.MustLoad()
+
+	if file == "" {
+		panic("file is required")
+	}
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+
+	log := slog.New(
+		prettylogger.NewColoredHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
+	)
+
+	storage, err := sqlite.New(cfg.StoragePath)
+	if err != nil {
+		panic(err)
+	}
+	defer storage.Stop()
+
+	f, err := os.Open(file)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	passwordHasher, err := passwords.NewHasher(cfg.PasswordHashing)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := importer.New(log, storage, passwordHasher).Import(context.Background(), f, importer.Format(format))
+	for _, recErr := range res.Errors {
+		fmt.Printf("%s (%s)\n", recErr.Error(), recErr.Email)
+	}
+	if res.Failed > len(res.Errors) {
+		fmt.Printf("... and %d more failed records\n", res.Failed-len(res.Errors))
+	}
+	fmt.Printf("Imported %d users, %d failed\n", res.Imported, res.Failed)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sso/internal/config"
+	"sso/internal/services/snapshot"
+	"sso/internal/storage/sqlite"
+
+	"github.com/jacute/prettylogger"
+)
+
+func main() {
+	var mode, file string
+	var anonymize bool
+
+	flag.StringVar(&mode, "mode", "", "export or restore")
+	flag.StringVar(&file, "file", "", "Path to the snapshot archive")
+	flag.BoolVar(&anonymize, "anonymize", false, "Drop personal data, password hashes and app secrets from the export")
+	cfg := config.MustLoad()
+
+	if file == "" {
+		panic("file is required")
+	}
+
+	log := slog.New(
+		prettylogger.NewColoredHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
+	)
+
+	// The target database must be migrated, restore only fills it.
+	storage, err := sqlite.New(cfg.StoragePath)
+	if err != nil {
+		panic(err)
+	}
+	defer storage.Stop()
+
+	service := snapshot.New(log, storage, storage)
+
+	var counts snapshot.Counts
+	switch mode {
+	case "export":
+		f, err := os.Create(file)
+		if err != nil {
+			panic(err)
+		}
+		counts, err = service.Export(context.Background(), f, anonymize)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			panic(err)
+		}
+	case "restore":
+		f, err := os.Open(file)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		counts, err = service.Restore(context.Background(), f)
+		if err != nil {
+			panic(err)
+		}
+	default:
+		panic("mode must be export or restore")
+	}
+
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("%s: %d\n", kind, counts[kind])
+	}
+}
@@ -25,19 +25,18 @@ func main() {
 		slog.Any("config", cfg),
 	)
 
-	application := app.New(
-		log,
-		cfg.GRPC.Port,
-		cfg.StoragePath,
-		cfg.TokenTTL,
-	)
+	application := app.New(log, cfg)
 	go application.GrpcServer.MustRun()
+	go application.HTTPServer.MustRun()
+	application.Worker.Run()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 	sign := <-stop
 
 	application.GrpcServer.Stop()
+	application.HTTPServer.Stop()
+	application.Worker.Stop()
 
 	log.Info("Application stopped", slog.String("signal", sign.String()))
 }
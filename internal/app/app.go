@@ -1,31 +1,188 @@
 package app
 
 import (
+	"context"
 	"log/slog"
 	grpcapp "sso/internal/app/grpc"
+	httpapp "sso/internal/app/http"
+	workerapp "sso/internal/app/worker"
+	"sso/internal/config"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/mailer"
+	"sso/internal/lib/passwordpolicy"
+	"sso/internal/lib/passwords"
+	"sso/internal/lib/secretbox"
+	"sso/internal/lib/webauthn"
+	"sso/internal/services/account"
+	"sso/internal/services/admin"
+	"sso/internal/services/audit"
 	"sso/internal/services/auth"
+	ldapauth "sso/internal/services/auth/ldap"
+	"sso/internal/services/importer"
+	"sso/internal/services/invitation"
+	"sso/internal/services/mfa"
+	"sso/internal/services/organization"
+	"sso/internal/services/profile"
+	"sso/internal/services/rbac"
 	"sso/internal/storage/sqlite"
-	"time"
 )
 
 type App struct {
 	GrpcServer *grpcapp.App
+	HTTPServer *httpapp.App
+	Worker     *workerapp.App
 }
 
 func New(
 	log *slog.Logger,
-	grpcPort int,
-	storagePath string,
-	tokenTTL time.Duration,
+	cfg *config.Config,
 ) *App {
-	storage, err := sqlite.New(storagePath)
+	storage, err := sqlite.New(cfg.StoragePath)
 	if err != nil {
 		panic(err)
 	}
-	authService := auth.New(log, storage, storage, storage, tokenTTL)
-	grpcApp := grpcapp.New(log, authService, grpcPort)
+
+	sources := map[string]auth.CredentialVerifier{}
+	if cfg.LDAP.Enabled {
+		sources[ldapauth.Provider] = ldapauth.New(log, cfg.LDAP, storage)
+	}
+	realms, err := auth.NewRealms(cfg.Realms, sources)
+	if err != nil {
+		panic(err)
+	}
+
+	profileClaims, err := auth.NewProfileClaims(cfg.Profile.TokenClaims)
+	if err != nil {
+		panic(err)
+	}
+
+	identifierPolicy, err := auth.NewIdentifierPolicy(cfg.Identifiers)
+	if err != nil {
+		panic(err)
+	}
+
+	passwordHasher, err := passwords.NewHasher(cfg.PasswordHashing)
+	if err != nil {
+		panic(err)
+	}
+
+	passwordPolicies, err := passwordpolicy.New(cfg.PasswordPolicy, passwordHasher.MaxPasswordBytes())
+	if err != nil {
+		panic(err)
+	}
+
+	mail, err := mailer.New(cfg.Mailer)
+	if err != nil {
+		panic(err)
+	}
+
+	var mfaCipher mfa.Cipher
+	if cfg.MFA.EncryptionKey != "" {
+		box, err := secretbox.New(string(cfg.MFA.EncryptionKey))
+		if err != nil {
+			panic(err)
+		}
+		mfaCipher = box
+	} else {
+		log.Warn("mfa.encryption_key is not set, users can't enroll a second factor")
+	}
+
+	relyingParty, err := webauthn.New(cfg.WebAuthn)
+	if err != nil {
+		panic(err)
+	}
+
+	auditLog := audit.New(log, storage, cfg.Audit)
+	mfaService := mfa.New(log, storage, storage, mfaCipher, cfg.MFA, storage, relyingParty, storage)
+	loginThrottle := auth.NewLoginThrottle(storage, cfg.LoginThrottle)
+	accountService := account.New(
+		log, storage, storage, storage, storage, storage, storage, mail, cfg.Account, passwordPolicies, passwordHasher, auditLog,
+		storage, loginThrottle,
+	)
+	authService := auth.New(
+		log, storage, storage, storage, storage, storage, storage, storage, cfg.TokenTTL, realms, profileClaims, identifierPolicy,
+		mfaService, storage, cfg.MFA, mfaService, loginThrottle,
+		passwordPolicies, passwordHasher, cfg.Registration, accountService, auditLog,
+		storage, cfg.NewDevice, accountService,
+	)
+	profileService := profile.New(log, storage, storage)
+	adminService := admin.New(log, storage, storage, storage, storage, auditLog)
+	rbacService := rbac.New(log, storage, storage, storage, storage)
+	organizationService := organization.New(log, storage, storage)
+	invitationService := invitation.New(
+		log, storage, storage, storage, storage, storage, mail,
+		cfg.Account.InvitationTTL, cfg.Account.InvitationLink, passwordPolicies, passwordHasher,
+	)
+	importerService := importer.New(log, storage, passwordHasher)
+
+	clientIPs, err := clientip.NewResolver(cfg.GRPC.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	grpcApp := grpcapp.New(
+		log,
+		authService,
+		accountService,
+		profileService,
+		adminService,
+		rbacService,
+		organizationService,
+		invitationService,
+		importerService,
+		mfaService,
+		clientIPs,
+		auditLog,
+		cfg.GRPC.Port,
+	)
+	httpApp := httpapp.New(log, authService, mfaService, clientIPs, cfg.WebAuthn.Origins, cfg.HTTP.Port, cfg.HTTP.Timeout)
+
+	worker := workerapp.New(log, workerapp.Job{
+		Name:     "purge_deleted_accounts",
+		Interval: cfg.Account.PurgeInterval,
+		Run: func(ctx context.Context) error {
+			_, err := accountService.PurgeDeletedAccounts(ctx)
+			return err
+		},
+	}, workerapp.Job{
+		Name:     "delete_expired_mfa_challenges",
+		Interval: cfg.MFA.ChallengeTTL,
+		Run: func(ctx context.Context) error {
+			_, err := authService.PurgeExpiredChallenges(ctx)
+			return err
+		},
+	}, workerapp.Job{
+		Name:     "delete_expired_webauthn_challenges",
+		Interval: cfg.WebAuthn.ChallengeTTL,
+		Run: func(ctx context.Context) error {
+			_, err := mfaService.PurgeExpiredWebAuthnChallenges(ctx)
+			return err
+		},
+	}, workerapp.Job{
+		Name:     "delete_expired_login_throttles",
+		Interval: cfg.LoginThrottle.ResetAfter,
+		Run: func(ctx context.Context) error {
+			_, err := authService.PurgeExpiredLoginThrottles(ctx)
+			return err
+		},
+	}, workerapp.Job{
+		Name:     "delete_expired_audit_events",
+		Interval: cfg.Audit.PurgeInterval,
+		Run: func(ctx context.Context) error {
+			_, err := auditLog.PurgeExpired(ctx)
+			return err
+		},
+	}, workerapp.Job{
+		Name:     "delete_stale_known_devices",
+		Interval: cfg.NewDevice.PurgeInterval,
+		Run: func(ctx context.Context) error {
+			_, err := authService.PurgeStaleDevices(ctx)
+			return err
+		},
+	})
 
 	return &App{
 		GrpcServer: grpcApp,
+		HTTPServer: httpApp,
+		Worker:     worker,
 	}
 }
@@ -4,7 +4,12 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	admingrpc "sso/internal/grpc/admin"
 	authgrpc "sso/internal/grpc/auth"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/deviceid"
+	"sso/internal/lib/useragent"
+	"sso/internal/services/invitation"
 
 	"google.golang.org/grpc"
 )
@@ -15,10 +20,39 @@ type App struct {
 	port       int
 }
 
-func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
-	grpcServer := grpc.NewServer()
+func New(
+	log *slog.Logger,
+	authService authgrpc.Auth,
+	accountService authgrpc.Account,
+	profileService authgrpc.Profile,
+	adminService admingrpc.Admin,
+	rbacService admingrpc.RBAC,
+	organizationService admingrpc.Organization,
+	invitationService *invitation.Invitation,
+	importerService admingrpc.Importer,
+	mfaService authgrpc.MFA,
+	clientIPs *clientip.Resolver,
+	auditLog admingrpc.AuditLog,
+	port int,
+) *App {
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			clientIPs.UnaryServerInterceptor(),
+			useragent.UnaryServerInterceptor(),
+			deviceid.UnaryServerInterceptor(),
+			admingrpc.AuthInterceptor(authService),
+			admingrpc.AuditInterceptor(auditLog),
+		),
+		grpc.ChainStreamInterceptor(
+			clientIPs.StreamServerInterceptor(),
+			useragent.StreamServerInterceptor(),
+			admingrpc.StreamAuthInterceptor(authService),
+			admingrpc.StreamAuditInterceptor(auditLog),
+		),
+	)
 
-	authgrpc.Register(grpcServer, authService)
+	authgrpc.Register(grpcServer, authService, accountService, profileService, invitationService, mfaService)
+	admingrpc.Register(grpcServer, adminService, rbacService, organizationService, invitationService, importerService)
 
 	return &App{
 		log:        log,
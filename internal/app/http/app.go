@@ -0,0 +1,81 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	webauthnhttp "sso/internal/http/webauthn"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/deviceid"
+	"sso/internal/lib/useragent"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+}
+
+func New(
+	log *slog.Logger,
+	authService webauthnhttp.Auth,
+	mfaService webauthnhttp.MFA,
+	clientIPs *clientip.Resolver,
+	origins []string,
+	port int,
+	timeout time.Duration,
+) *App {
+	mux := http.NewServeMux()
+	webauthnhttp.Register(mux, authService, mfaService, origins)
+
+	return &App{
+		log: log,
+		httpServer: &http.Server{
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           clientIPs.Middleware(useragent.Middleware(deviceid.Middleware(mux))),
+			ReadHeaderTimeout: timeout,
+			ReadTimeout:       timeout,
+			WriteTimeout:      timeout,
+		},
+		port: port,
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "httpapp.Run"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("port", a.port),
+	)
+
+	log.Info("HTTP server listening")
+
+	if err := a.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *App) Stop() {
+	const op = "httpapp.Stop"
+
+	a.log.Info("Stopping HTTP server", slog.String("op", op))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		a.log.Error("Failed to stop HTTP server", slog.String("op", op), prettylogger.Err(err))
+	}
+}
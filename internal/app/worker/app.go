@@ -0,0 +1,88 @@
+package workerapp
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// Job is a task run periodically in the background.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type App struct {
+	log    *slog.Logger
+	jobs   []Job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(log *slog.Logger, jobs ...Job) *App {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &App{
+		log:    log,
+		jobs:   jobs,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Run starts every job in its own goroutine, it doesn't block.
+func (a *App) Run() {
+	const op = "workerapp.Run"
+
+	for _, job := range a.jobs {
+		if job.Interval <= 0 {
+			a.log.Info("Job disabled", slog.String("op", op), slog.String("job", job.Name))
+			continue
+		}
+
+		a.wg.Add(1)
+		go func(job Job) {
+			defer a.wg.Done()
+			a.loop(job)
+		}(job)
+	}
+}
+
+func (a *App) loop(job Job) {
+	const op = "workerapp.loop"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("job", job.Name),
+	)
+	log.Info("Job started", slog.Duration("interval", job.Interval))
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(a.ctx); err != nil && a.ctx.Err() == nil {
+			log.Error("Job failed", prettylogger.Err(err))
+		}
+
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop cancels the running jobs and waits for them to return.
+func (a *App) Stop() {
+	const op = "workerapp.Stop"
+
+	a.log.Info("Stopping background jobs", slog.String("op", op))
+
+	a.cancel()
+	a.wg.Wait()
+}
@@ -9,15 +9,295 @@ import (
 )
 
 type Config struct {
-	Env         string        `yaml:"env" env-default:"local"`
-	StoragePath string        `yaml:"storage_path" env-required:"true"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	GRPC        GRPCConfig    `yaml:"grpc"`
+	Env         string            `yaml:"env" env-default:"local"`
+	StoragePath string            `yaml:"storage_path" env-required:"true"`
+	TokenTTL    time.Duration     `yaml:"token_ttl" env-required:"true"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	HTTP        HTTPConfig        `yaml:"http"`
+	LDAP        LDAPConfig        `yaml:"ldap"`
+	Realms      RealmsConfig      `yaml:"realms"`
+	Identifiers IdentifiersConfig `yaml:"identifiers"`
+	Mailer      MailerConfig      `yaml:"mailer"`
+	Account     AccountConfig     `yaml:"account"`
+	Profile     ProfileConfig     `yaml:"profile"`
+	MFA         MFAConfig         `yaml:"mfa"`
+	WebAuthn    WebAuthnConfig    `yaml:"webauthn"`
+	// LoginThrottle slows down password guessing, see auth.LoginThrottle.
+	LoginThrottle   LoginThrottleConfig   `yaml:"login_throttle"`
+	PasswordPolicy  PasswordPolicyConfig  `yaml:"password_policy"`
+	PasswordHashing PasswordHashingConfig `yaml:"password_hashing"`
+	Registration    RegistrationConfig    `yaml:"registration"`
+	Audit           AuditConfig           `yaml:"audit"`
+	NewDevice       NewDeviceConfig       `yaml:"new_device"`
 }
 
 type GRPCConfig struct {
 	Port    int           `yaml:"port" env-default:"8081"`
-	Timeout time.Duration `yaml: "timeout"`
+	Timeout time.Duration `yaml:"timeout"`
+	// TrustedProxies lists the addresses and CIDR ranges of reverse proxies. Calls from them
+	// are attributed to the client IP in their x-forwarded-for or x-real-ip metadata,
+	// requests to the HTTP API in the headers of these names.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// HTTPConfig is the JSON API for browsers, it serves the WebAuthn ceremonies.
+type HTTPConfig struct {
+	Port    int           `yaml:"port" env-default:"8082"`
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+type LDAPConfig struct {
+	Enabled      bool          `yaml:"enabled" env-default:"false"`
+	URL          string        `yaml:"url"`
+	StartTLS     bool          `yaml:"start_tls" env-default:"false"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"5s"`
+	BindDN       string        `yaml:"bind_dn"`
+	BindPassword Secret        `yaml:"bind_password" env:"LDAP_BIND_PASSWORD"`
+	BaseDN       string        `yaml:"base_dn"`
+	// UserFilter is a fmt template, %[1]s is replaced with the escaped login.
+	UserFilter string           `yaml:"user_filter" env-default:"(&(objectClass=person)(|(mail=%[1]s)(uid=%[1]s)))"`
+	Attributes LDAPAttributeMap `yaml:"attributes"`
+}
+
+type LDAPAttributeMap struct {
+	Email    string   `yaml:"email" env-default:"mail"`
+	Username string   `yaml:"username" env-default:"uid"`
+	Groups   string   `yaml:"groups" env-default:"memberOf"`
+	Extra    []string `yaml:"extra"`
+}
+
+// RealmsConfig maps email domains to the identity source that owns them.
+type RealmsConfig struct {
+	Default string        `yaml:"default" env-default:"local"`
+	Domains []RealmConfig `yaml:"domains"`
+}
+
+type RealmConfig struct {
+	Domain string `yaml:"domain"`
+	Source string `yaml:"source"`
+	// PasswordFallback lets users of the domain log in with a local password when the source rejects them.
+	PasswordFallback bool `yaml:"password_fallback"`
+}
+
+// IdentifiersConfig decides which login identifiers (email, username, phone) apps accept.
+type IdentifiersConfig struct {
+	// Default applies to the apps without their own entry.
+	Default []string               `yaml:"default" env-default:"email,username,phone"`
+	Apps    []AppIdentifiersConfig `yaml:"apps"`
+}
+
+type AppIdentifiersConfig struct {
+	AppID   int32    `yaml:"app_id"`
+	Allowed []string `yaml:"allowed"`
+}
+
+type MailerConfig struct {
+	// Type is either "smtp" or "outbox", the latter writes messages to OutboxPath.
+	Type       string     `yaml:"type" env-default:"outbox"`
+	From       string     `yaml:"from" env-default:"sso@localhost"`
+	OutboxPath string     `yaml:"outbox_path" env-default:"./storage/outbox"`
+	SMTP       SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env-default:"localhost"`
+	Port     int    `yaml:"port" env-default:"587"`
+	Username string `yaml:"username"`
+	Password Secret `yaml:"password" env:"SMTP_PASSWORD"`
+}
+
+type ProfileConfig struct {
+	// TokenClaims lists the profile claims added to issued tokens: name, given_name,
+	// family_name, locale, zoneinfo, picture and app_metadata.
+	TokenClaims []string `yaml:"token_claims"`
+}
+
+type MFAConfig struct {
+	// Issuer names the service in authenticator apps.
+	Issuer string `yaml:"issuer" env-default:"SSO"`
+	// EncryptionKey is a base64 encoded 32 byte key the TOTP secrets are encrypted with.
+	// Without it users can't enroll a second factor.
+	EncryptionKey Secret `yaml:"encryption_key" env:"MFA_ENCRYPTION_KEY"`
+	// ChallengeTTL is how long a login waits for the second factor after the password check.
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+	// MaxAttempts is the number of wrong codes after which the login has to start over.
+	MaxAttempts int `yaml:"max_attempts" env-default:"5"`
+	// RecoveryCodes is the number of single-use recovery codes a user gets with the first second factor.
+	RecoveryCodes int `yaml:"recovery_codes" env-default:"10"`
+}
+
+// WebAuthnConfig is the relying party passkeys are registered with.
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are bound to, the origins must be on it or its subdomains.
+	RPID   string `yaml:"rp_id" env-default:"localhost"`
+	RPName string `yaml:"rp_name" env-default:"SSO"`
+	// Origins lists the web origins allowed to run the ceremonies, e.g. https://login.example.com.
+	Origins []string `yaml:"origins" env-default:"http://localhost:8080"`
+	// UserVerification is required, preferred or discouraged for passkeys used as a second factor,
+	// passwordless logins always require it.
+	UserVerification string `yaml:"user_verification" env-default:"preferred"`
+	// Attestation is none or direct, with direct authenticators send a packed attestation statement.
+	Attestation  string        `yaml:"attestation" env-default:"none"`
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+}
+
+// PasswordHashingConfig decides how new password hashes are made. The algorithm and its parameters
+// are stored in every hash, the ones made differently are rehashed on the next successful login.
+type PasswordHashingConfig struct {
+	// Algorithm is argon2id or bcrypt.
+	Algorithm string       `yaml:"algorithm" env-default:"argon2id"`
+	Argon2    Argon2Config `yaml:"argon2"`
+	Bcrypt    BcryptConfig `yaml:"bcrypt"`
+	// Pepper is a secret HMAC key passwords are mixed with before hashing. It is kept out
+	// of the database, so a leaked database alone is not enough to crack the hashes offline.
+	Pepper PepperConfig `yaml:"pepper"`
+}
+
+// PepperConfig lists the pepper keys, every hash stores the ID of the key it is made with.
+// To rotate add a new key and make it current, users move to it on their next successful login.
+// An old key can be removed once no hash uses it.
+type PepperConfig struct {
+	// CurrentKeyID is the key new hashes are made with, empty leaves new hashes unpeppered.
+	CurrentKeyID string            `yaml:"current_key_id" env:"PASSWORD_PEPPER_KEY_ID"`
+	Keys         []PepperKeyConfig `yaml:"keys"`
+}
+
+// PepperKeyConfig is a base64 encoded key of at least 32 bytes, such as the output of
+// `openssl rand -base64 32`, read from File or from the environment variable named by Env.
+type PepperKeyConfig struct {
+	ID   string `yaml:"id"`
+	File string `yaml:"file"`
+	Env  string `yaml:"env"`
+}
+
+type Argon2Config struct {
+	// Memory is in KiB.
+	Memory      int `yaml:"memory" env-default:"65536"`
+	Iterations  int `yaml:"iterations" env-default:"3"`
+	Parallelism int `yaml:"parallelism" env-default:"4"`
+	SaltLength  int `yaml:"salt_length" env-default:"16"`
+	KeyLength   int `yaml:"key_length" env-default:"32"`
+}
+
+type BcryptConfig struct {
+	Cost int `yaml:"cost" env-default:"12"`
+}
+
+// PasswordPolicyConfig decides which passwords users may set at registration, password change and reset.
+// Apps may override the rules, the fields they leave out keep the default value.
+type PasswordPolicyConfig struct {
+	PasswordRulesConfig `yaml:",inline"`
+	// BreachedPasswordsFile lists uppercase hex SHA-1 hashes of breached passwords sorted by hash,
+	// one per line with an optional ":count", as the ordered-by-hash Pwned Passwords download.
+	// Empty disables the check.
+	BreachedPasswordsFile string                    `yaml:"breached_passwords_file"`
+	Apps                  []AppPasswordPolicyConfig `yaml:"apps"`
+}
+
+type PasswordRulesConfig struct {
+	MinLength int `yaml:"min_length" env-default:"8"`
+	MaxLength int `yaml:"max_length" env-default:"128"`
+	// MinCharacterClasses is how many of lowercase letters, uppercase letters, digits and symbols
+	// a password must mix.
+	MinCharacterClasses int `yaml:"min_character_classes" env-default:"0"`
+	// MinStrength is the lowest accepted strength score, from 0 (guessable in a few tries)
+	// to 4 (very unguessable), as zxcvbn estimates it.
+	MinStrength int `yaml:"min_strength" env-default:"2"`
+	// BannedWords may not appear in passwords, neither may the email local part and the username of the user.
+	BannedWords    []string `yaml:"banned_words"`
+	RejectBreached bool     `yaml:"reject_breached" env-default:"true"`
+}
+
+type AppPasswordPolicyConfig struct {
+	AppID               int32 `yaml:"app_id"`
+	MinLength           *int  `yaml:"min_length"`
+	MaxLength           *int  `yaml:"max_length"`
+	MinCharacterClasses *int  `yaml:"min_character_classes"`
+	MinStrength         *int  `yaml:"min_strength"`
+	// BannedWords are banned in the app in addition to the default ones.
+	BannedWords    []string `yaml:"banned_words"`
+	RejectBreached *bool    `yaml:"reject_breached"`
+}
+
+// LoginThrottleConfig limits failed logins per account and per client IP. After the free attempts
+// every failure blocks further logins for BaseDelay, doubled with each failure up to MaxDelay.
+// After Lockout failures logins are blocked for LockoutDuration, 0 disables the lockout.
+// Failures are forgotten after ResetAfter without new ones.
+type LoginThrottleConfig struct {
+	Enabled             bool          `yaml:"enabled" env-default:"true"`
+	AccountFreeAttempts int           `yaml:"account_free_attempts" env-default:"5"`
+	AccountLockout      int           `yaml:"account_lockout" env-default:"20"`
+	IPFreeAttempts      int           `yaml:"ip_free_attempts" env-default:"20"`
+	IPLockout           int           `yaml:"ip_lockout" env-default:"100"`
+	BaseDelay           time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay            time.Duration `yaml:"max_delay" env-default:"5m"`
+	LockoutDuration     time.Duration `yaml:"lockout_duration" env-default:"15m"`
+	ResetAfter          time.Duration `yaml:"reset_after" env-default:"1h"`
+}
+
+type RegistrationConfig struct {
+	// EnumerationSafe makes Register answer the same, with user ID 0, whether the email is taken
+	// or not. The owner of a taken email is notified by email instead of the caller getting an error.
+	EnumerationSafe bool `yaml:"enumeration_safe" env-default:"false"`
+}
+
+// AuditConfig keeps the security audit log, see audit.Log. Events older than Retention are
+// deleted every PurgeInterval, Retention 0 keeps them forever.
+type AuditConfig struct {
+	Retention     time.Duration `yaml:"retention" env-default:"2160h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// NewDeviceConfig makes logins from new devices reported to the user by email, see auth.Auth.checkDevice.
+// A device is the device ID the client sent, its user agent and the network of its IP, IPv4PrefixLen
+// and IPv6PrefixLen bits long. Devices unused for ForgetAfter are forgotten, 0 keeps them forever.
+type NewDeviceConfig struct {
+	Enabled       bool `yaml:"enabled" env-default:"true"`
+	IPv4PrefixLen int  `yaml:"ipv4_prefix_len" env-default:"24"`
+	IPv6PrefixLen int  `yaml:"ipv6_prefix_len" env-default:"48"`
+	// RevokeLink is a fmt template, %s is replaced with the "this wasn't me" token that revokes
+	// the session of the login. Empty sends the notification without a link.
+	RevokeLink     string        `yaml:"revoke_link" env-default:"http://localhost:8080/revoke-login?token=%s"`
+	RevokeTokenTTL time.Duration `yaml:"revoke_token_ttl" env-default:"168h"`
+	ForgetAfter    time.Duration `yaml:"forget_after" env-default:"2160h"`
+	PurgeInterval  time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+type AccountConfig struct {
+	VerificationTokenTTL time.Duration `yaml:"verification_token_ttl" env-default:"24h"`
+	// VerificationLink is a fmt template, %s is replaced with the token.
+	VerificationLink string `yaml:"verification_link" env-default:"http://localhost:8080/verify-email?token=%s"`
+
+	PasswordResetTokenTTL time.Duration `yaml:"password_reset_token_ttl" env-default:"1h"`
+	// PasswordResetLink is a fmt template, %s is replaced with the token.
+	PasswordResetLink string `yaml:"password_reset_link" env-default:"http://localhost:8080/reset-password?token=%s"`
+
+	EmailChangeTokenTTL time.Duration `yaml:"email_change_token_ttl" env-default:"24h"`
+	// EmailChangeLink is a fmt template, %s is replaced with the token.
+	EmailChangeLink string `yaml:"email_change_link" env-default:"http://localhost:8080/confirm-email-change?token=%s"`
+
+	InvitationTTL time.Duration `yaml:"invitation_ttl" env-default:"168h"`
+	// InvitationLink is a fmt template, %s is replaced with the token.
+	InvitationLink string `yaml:"invitation_link" env-default:"http://localhost:8080/accept-invitation?token=%s"`
+
+	// SignInLink is sent to the owner of an email someone tries to register again.
+	SignInLink string `yaml:"sign_in_link" env-default:"http://localhost:8080/login"`
+
+	// DeletionGracePeriod is how long a deleted account is kept before its data is purged, zero purges at once.
+	DeletionGracePeriod time.Duration `yaml:"deletion_grace_period" env-default:"720h"`
+	// AnonymizeDeleted keeps purged users as anonymous rows instead of deleting them.
+	AnonymizeDeleted bool          `yaml:"anonymize_deleted" env-default:"false"`
+	PurgeInterval    time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// Secret is a config value that is masked when the config is logged.
+type Secret string
+
+func (s Secret) MarshalText() ([]byte, error) {
+	if s == "" {
+		return []byte{}, nil
+	}
+	return []byte("******"), nil
 }
 
 func MustLoad() *Config {
@@ -1,7 +1,10 @@
 package models
 
 type App struct {
-	ID     int
-	Name   string
-	Secret string
+	ID                   int
+	Name                 string
+	Secret               string
+	RequireVerifiedEmail bool
+	// OrgID limits the app to the members of the organization, 0 for none.
+	OrgID int64
 }
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Types of the audit events.
+const (
+	// AuditLogin is a login with a password, a second factor or a passkey.
+	AuditLogin = "login"
+	// AuditRegistration is a registration of a new user.
+	AuditRegistration = "registration"
+	// AuditPasswordChange is a password changed by the user, who knew the old one.
+	AuditPasswordChange = "password_change"
+	// AuditPasswordReset is a password set with a reset token.
+	AuditPasswordReset = "password_reset"
+	// AuditSessionRevocation is a revocation of the sessions, and so of the tokens, of the user.
+	AuditSessionRevocation = "session_revocation"
+	// AuditAdminAction is a call of the AdminService, Details holds the method.
+	AuditAdminAction = "admin_action"
+	// AuditNewDevice is a login from a device the user has not used before, reported to the user.
+	AuditNewDevice = "new_device"
+	// AuditIdentityLink is an external identity linked by the user, Details holds the source.
+	AuditIdentityLink = "identity_link"
+)
+
+// Outcomes of the audit events.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is an entry of the append-only security audit log.
+type AuditEvent struct {
+	ID      int64
+	Time    time.Time
+	Type    string
+	Outcome string
+	// ActorID is the user who acted, 0 if unknown, like for a failed login.
+	ActorID int64
+	// TargetUserID is the user acted on, the actor themselves for their own account.
+	TargetUserID int64
+	// Target is the login identifier the event was about when there is no known user.
+	Target    string
+	AppID     int32
+	IP        string
+	UserAgent string
+	// Details is the reason of a failure or more about the event, like the admin method called.
+	Details string
+}
+
+// AuditFilter narrows down an audit log query, zero fields match every event.
+type AuditFilter struct {
+	Type         string
+	Outcome      string
+	ActorID      int64
+	TargetUserID int64
+	AppID        int32
+	IP           string
+	Since        time.Time
+	Until        time.Time
+}
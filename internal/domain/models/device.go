@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// KnownDevice is a device a user logged in from: the device ID the client sent, the user agent
+// and the network of the client IP. The ID and the user agent are kept as hashes.
+type KnownDevice struct {
+	ID            int64
+	UserID        int64
+	DeviceIDHash  []byte
+	UserAgentHash []byte
+	IPPrefix      string
+	// UserAgent and LastIP are the ones of the last login, to show to the user.
+	UserAgent   string
+	LastIP      string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+	// SessionID is the session of the login that added the device.
+	SessionID       string
+	RevokeTokenHash []byte
+	RevokeExpiresAt time.Time
+}
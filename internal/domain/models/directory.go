@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UserFilter narrows down a user listing, zero fields match every user.
+type UserFilter struct {
+	Status        UserStatus
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	IsAdmin       *bool
+	EmailPrefix   string
+	// OrgID keeps the members of the organization.
+	OrgID int64
+	// Query matches a part of the email or of the profile names.
+	Query string
+}
+
+// UserEntry is a user as shown in the admin user directory.
+type UserEntry struct {
+	User
+	IsAdmin     bool
+	DisplayName string
+}
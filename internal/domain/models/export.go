@@ -0,0 +1,18 @@
+package models
+
+// UserData is everything stored about a user, as returned by a data export.
+type UserData struct {
+	User       User
+	IsAdmin    bool
+	Roles      []RoleAssignment
+	Orgs       []Organization
+	Profile    Profile
+	Identities []Identity
+	Sessions   []Session
+	Tokens     []VerificationToken
+	// TOTP is the authenticator app enrollment, zero if there is none.
+	TOTP                TOTP
+	WebAuthnCredentials []WebAuthnCredential
+	RecoveryCodes       []RecoveryCode
+	KnownDevices        []KnownDevice
+}
@@ -0,0 +1,24 @@
+package models
+
+// IdentifierType is a kind of login identifier a user can sign in with.
+type IdentifierType string
+
+const (
+	IdentifierEmail    IdentifierType = "email"
+	IdentifierUsername IdentifierType = "username"
+	IdentifierPhone    IdentifierType = "phone"
+)
+
+func (t IdentifierType) Valid() bool {
+	switch t {
+	case IdentifierEmail, IdentifierUsername, IdentifierPhone:
+		return true
+	}
+	return false
+}
+
+// Identifier is a normalized login identifier.
+type Identifier struct {
+	Type  IdentifierType
+	Value string
+}
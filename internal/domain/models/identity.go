@@ -0,0 +1,11 @@
+package models
+
+type Identity struct {
+	UserID     int64
+	Provider   string
+	Subject    string
+	Email      string
+	Username   string
+	Attributes map[string]string
+	Groups     []string
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationRevoked  InvitationStatus = "revoked"
+	InvitationExpired  InvitationStatus = "expired"
+)
+
+// Invitation lets the owner of Email join the organization, or none with OrgID 0,
+// and get the roles in the app, or in every app with GlobalAppID.
+type Invitation struct {
+	ID        int64
+	InviterID int64
+	Email     string
+	OrgID     int64
+	AppID     int
+	RoleIDs   []int64
+	Hash      []byte
+	ExpiresAt time.Time
+	// AcceptedBy is the user who accepted the invitation.
+	AcceptedBy int64
+	AcceptedAt time.Time
+	RevokedAt  time.Time
+	CreatedAt  time.Time
+}
+
+// Status reports the state of the invitation at the moment now.
+func (i Invitation) Status(now time.Time) InvitationStatus {
+	switch {
+	case !i.AcceptedAt.IsZero():
+		return InvitationAccepted
+	case !i.RevokedAt.IsZero():
+		return InvitationRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
+
+// InvitationFilter narrows ListInvitations, zero fields match everything.
+type InvitationFilter struct {
+	OrgID  int64
+	Email  string
+	Status InvitationStatus
+}
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// MFA methods a login challenge can be finished with.
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+	// MFAMethodRecoveryCode is a single-use code for users who lost their other second factors.
+	MFAMethodRecoveryCode = "recovery_code"
+)
+
+// TOTP is the authenticator app enrollment of a user, Secret is encrypted.
+type TOTP struct {
+	UserID      int64
+	Secret      []byte
+	ConfirmedAt time.Time
+	LastStep    int64
+	CreatedAt   time.Time
+}
+
+// Confirmed reports whether the enrollment was finished with a valid code and is used at login.
+func (t TOTP) Confirmed() bool {
+	return !t.ConfirmedAt.IsZero()
+}
+
+// RecoveryCode is a single-use recovery code of a user, only its hash is stored.
+type RecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  []byte
+	CreatedAt time.Time
+	UsedAt    time.Time
+}
+
+// Used reports whether the code was spent on a login.
+func (c RecoveryCode) Used() bool {
+	return !c.UsedAt.IsZero()
+}
+
+// MFAChallenge is a login waiting for a second factor.
+type MFAChallenge struct {
+	ID        string
+	UserID    int64
+	AppID     int
+	Attempts  int
+	ExpiresAt time.Time
+	// ThrottleKey is the account counter of the login that started the challenge,
+	// wrong codes are counted on it as wrong passwords are.
+	ThrottleKey string
+}
+
+// WebAuthnCredential is a passkey or security key of a user, PublicKey is its COSE_Key.
+type WebAuthnCredential struct {
+	ID                []byte
+	UserID            int64
+	Name              string
+	PublicKey         []byte
+	SignCount         uint32
+	AAGUID            []byte
+	AttestationFormat string
+	Transports        []string
+	CreatedAt         time.Time
+	LastUsedAt        time.Time
+}
+
+// Kinds of WebAuthn ceremonies.
+const (
+	WebAuthnRegistration = "registration"
+	// WebAuthnSecondFactor is an assertion that finishes an MFA challenge.
+	WebAuthnSecondFactor = "mfa"
+	// WebAuthnLogin is a passwordless login to the app.
+	WebAuthnLogin = "login"
+)
+
+// WebAuthnChallenge is a started ceremony, Challenge is its base64url value.
+type WebAuthnChallenge struct {
+	Challenge string
+	Kind      string
+	UserID    int64
+	AppID     int
+	ExpiresAt time.Time
+}
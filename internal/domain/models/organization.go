@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Organization is a tenant: it has its own members, roles and apps.
+type Organization struct {
+	ID   int64
+	Name string
+	Slug string
+	// IsolatedEmails makes the emails of the users registered in the organization unique
+	// within it instead of across the SSO. Such users can only sign in to its apps.
+	IsolatedEmails bool
+	CreatedAt      time.Time
+}
+
+// Membership makes the user a member of the organization.
+type Membership struct {
+	OrgID     int64
+	UserID    int64
+	CreatedAt time.Time
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Profile struct {
+	UserID      int64
+	DisplayName string
+	GivenName   string
+	FamilyName  string
+	Locale      string
+	Timezone    string
+	AvatarURL   string
+	// AppMetadata is a JSON object managed by admins and readable by apps.
+	AppMetadata json.RawMessage
+	// UserMetadata is a JSON object the user can edit.
+	UserMetadata json.RawMessage
+	UpdatedAt    time.Time
+}
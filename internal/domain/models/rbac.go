@@ -0,0 +1,37 @@
+package models
+
+// GlobalAppID scopes a role, a permission or a role assignment to every app.
+const GlobalAppID = 0
+
+// PermissionAdmin is the global permission behind IsAdmin.
+const PermissionAdmin = "admin"
+
+type Role struct {
+	ID int64
+	// OrgID makes the role belong to the organization, 0 for none.
+	OrgID       int64
+	AppID       int
+	Name        string
+	Description string
+	Permissions []string
+}
+
+type Permission struct {
+	ID          int64
+	AppID       int
+	Name        string
+	Description string
+}
+
+// RoleAssignment grants the role to the user in an app, or in every app with GlobalAppID.
+type RoleAssignment struct {
+	UserID int64
+	Role   Role
+	AppID  int
+}
+
+// RolePermission grants the permission to the role.
+type RolePermission struct {
+	RoleID       int64
+	PermissionID int64
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID        string
+	UserID    int64
+	AppID     int
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+// Active reports whether the session is neither revoked nor expired.
+func (s Session) Active() bool {
+	return s.RevokedAt.IsZero() && time.Now().Before(s.ExpiresAt)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LoginThrottle counts the failed logins of an account or a client IP, Key tells which one.
+type LoginThrottle struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	BlockedUntil  time.Time
+}
+
+// Blocked reports whether logins are blocked at the time now.
+func (t LoginThrottle) Blocked(now time.Time) bool {
+	return now.Before(t.BlockedUntil)
+}
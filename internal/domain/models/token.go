@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailChange       = "email_change"
+)
+
+type VerificationToken struct {
+	UserID    int64
+	Purpose   string
+	Hash      []byte
+	Email     string
+	ExpiresAt time.Time
+}
@@ -1,7 +1,48 @@
 package models
 
+import "time"
+
+type UserStatus string
+
+const (
+	UserStatusActive   UserStatus = "active"
+	UserStatusDisabled UserStatus = "disabled"
+	UserStatusLocked   UserStatus = "locked"
+	UserStatusPending  UserStatus = "pending"
+)
+
+func (s UserStatus) Valid() bool {
+	switch s {
+	case UserStatusActive, UserStatusDisabled, UserStatusLocked, UserStatusPending:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID           int64
-	Email        string
-	PasswordHash []byte
+	ID            int64
+	Email         string
+	PasswordHash  []byte
+	EmailVerified bool
+	// Username and Phone are optional login identifiers, stored normalized.
+	Username string
+	Phone    string
+	// OrgID is the organization the user was registered in, 0 for none.
+	OrgID     int64
+	CreatedAt time.Time
+	// DeletedAt is set once the user asked to delete the account, the data is purged after a grace period.
+	DeletedAt       time.Time
+	Status          UserStatus
+	StatusReason    string
+	StatusChangedAt time.Time
+	// LockedUntil is when a locked account unlocks by itself, zero means it stays locked.
+	LockedUntil time.Time
+}
+
+// CurrentStatus is the status with expired locks taken into account.
+func (u User) CurrentStatus(now time.Time) UserStatus {
+	if u.Status == UserStatusLocked && !u.LockedUntil.IsZero() && !now.Before(u.LockedUntil) {
+		return UserStatusActive
+	}
+	return u.Status
 }
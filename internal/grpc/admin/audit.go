@@ -0,0 +1,135 @@
+package admingrpc
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/services/admin"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuditLog records the admin calls in the security audit log, see audit.Log.
+type AuditLog interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// AuditInterceptor records every AdminService call let through by AuthInterceptor, which
+// must run before it: the admin, the user and the app of the request and the status code.
+func AuditInterceptor(auditLog AuditLog) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		session, ok := sessionFromContext(ctx)
+		if !isAdminMethod(info.FullMethod) || !ok {
+			return handler(ctx, req)
+		}
+
+		resp, err := handler(ctx, req)
+		auditLog.Record(ctx, adminEvent(session, info.FullMethod, req, err))
+		return resp, err
+	}
+}
+
+// StreamAuditInterceptor is AuditInterceptor for streaming calls, the request of which is not known.
+func StreamAuditInterceptor(auditLog AuditLog) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		session, ok := sessionFromContext(stream.Context())
+		if !isAdminMethod(info.FullMethod) || !ok {
+			return handler(srv, stream)
+		}
+
+		err := handler(srv, stream)
+		auditLog.Record(stream.Context(), adminEvent(session, info.FullMethod, nil, err))
+		return err
+	}
+}
+
+// adminEvent describes the call of the admin, err is the error the handler returned.
+func adminEvent(session models.Session, fullMethod string, req any, err error) models.AuditEvent {
+	event := models.AuditEvent{
+		Type:    models.AuditAdminAction,
+		Outcome: models.AuditSuccess,
+		ActorID: session.UserID,
+		Details: path.Base(fullMethod),
+	}
+	if r, ok := req.(interface{ GetUserId() int64 }); ok {
+		event.TargetUserID = r.GetUserId()
+	}
+	if r, ok := req.(interface{ GetAppId() int32 }); ok {
+		event.AppID = r.GetAppId()
+	}
+	if err != nil {
+		event.Outcome = models.AuditFailure
+		event.Details += ": " + status.Code(err).String()
+	}
+	return event
+}
+
+func (s *serverAPI) QueryAuditLog(ctx context.Context, req *ssov1.QueryAuditLogRequest) (*ssov1.QueryAuditLogResponse, error) {
+	eventType := req.GetType()
+	outcome := req.GetOutcome()
+	actorID := req.GetActorId()
+	targetUserID := req.GetTargetUserId()
+	appID := req.GetAppId()
+	ip := req.GetIp()
+	since := req.GetSince()
+	until := req.GetUntil()
+	pageSize := req.GetPageSize()
+
+	validator := validators.ToQueryAuditLogValidator(eventType, outcome, actorID, targetUserID, appID, ip, since, until, pageSize)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	filter := models.AuditFilter{
+		Type:         eventType,
+		Outcome:      outcome,
+		ActorID:      actorID,
+		TargetUserID: targetUserID,
+		AppID:        appID,
+		IP:           ip,
+		Since:        fromUnix(since),
+		Until:        fromUnix(until),
+	}
+
+	events, nextPageToken, err := s.admin.QueryAuditLog(ctx, filter, int(pageSize), req.GetPageToken())
+	if err != nil {
+		if errors.Is(err, admin.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	messages := make([]*ssov1.AuditEvent, 0, len(events))
+	for _, event := range events {
+		messages = append(messages, &ssov1.AuditEvent{
+			Id:           event.ID,
+			Time:         toUnix(event.Time),
+			Type:         event.Type,
+			Outcome:      event.Outcome,
+			ActorId:      event.ActorID,
+			TargetUserId: event.TargetUserID,
+			Target:       event.Target,
+			AppId:        event.AppID,
+			Ip:           event.IP,
+			UserAgent:    event.UserAgent,
+			Details:      event.Details,
+		})
+	}
+
+	return &ssov1.QueryAuditLogResponse{Events: messages, NextPageToken: nextPageToken}, nil
+}
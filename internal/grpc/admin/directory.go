@@ -0,0 +1,126 @@
+package admingrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/services/admin"
+	"time"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) GetUser(ctx context.Context, req *ssov1.GetUserRequest) (*ssov1.GetUserResponse, error) {
+	userID := req.GetUserId()
+
+	validator := validators.ToGetUserValidator(userID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	entry, err := s.admin.GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, admin.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.GetUserResponse{User: toUserMessage(entry)}, nil
+}
+
+func (s *serverAPI) ListUsers(ctx context.Context, req *ssov1.ListUsersRequest) (*ssov1.ListUsersResponse, error) {
+	pageSize := req.GetPageSize()
+	userStatus := req.GetStatus()
+	emailPrefix := req.GetEmailPrefix()
+	orgID := req.GetOrgId()
+
+	validator := validators.ToListUsersValidator(pageSize, userStatus, emailPrefix, orgID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	filter := models.UserFilter{
+		Status:        models.UserStatus(userStatus),
+		CreatedAfter:  fromUnix(req.GetCreatedAfter()),
+		CreatedBefore: fromUnix(req.GetCreatedBefore()),
+		IsAdmin:       req.IsAdmin,
+		EmailPrefix:   emailPrefix,
+		OrgID:         orgID,
+	}
+
+	entries, nextPageToken, err := s.admin.ListUsers(ctx, filter, int(pageSize), req.GetPageToken())
+	if err != nil {
+		if errors.Is(err, admin.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.ListUsersResponse{Users: toUserMessages(entries), NextPageToken: nextPageToken}, nil
+}
+
+func (s *serverAPI) SearchUsers(ctx context.Context, req *ssov1.SearchUsersRequest) (*ssov1.SearchUsersResponse, error) {
+	query := req.GetQuery()
+	pageSize := req.GetPageSize()
+	orgID := req.GetOrgId()
+
+	validator := validators.ToSearchUsersValidator(query, pageSize, orgID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	entries, nextPageToken, err := s.admin.SearchUsers(ctx, orgID, query, int(pageSize), req.GetPageToken())
+	if err != nil {
+		if errors.Is(err, admin.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.SearchUsersResponse{Users: toUserMessages(entries), NextPageToken: nextPageToken}, nil
+}
+
+func toUserMessages(entries []models.UserEntry) []*ssov1.User {
+	users := make([]*ssov1.User, 0, len(entries))
+	for _, entry := range entries {
+		users = append(users, toUserMessage(entry))
+	}
+	return users
+}
+
+func toUserMessage(entry models.UserEntry) *ssov1.User {
+	return &ssov1.User{
+		Id:              entry.ID,
+		Email:           entry.Email,
+		EmailVerified:   entry.EmailVerified,
+		Username:        entry.Username,
+		Phone:           entry.Phone,
+		OrgId:           entry.OrgID,
+		IsAdmin:         entry.IsAdmin,
+		DisplayName:     entry.DisplayName,
+		Status:          string(entry.Status),
+		StatusReason:    entry.StatusReason,
+		StatusChangedAt: toUnix(entry.StatusChangedAt),
+		LockedUntil:     toUnix(entry.LockedUntil),
+		CreatedAt:       toUnix(entry.CreatedAt),
+		DeletedAt:       toUnix(entry.DeletedAt),
+	}
+}
+
+func toUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(seconds int64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
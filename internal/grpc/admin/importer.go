@@ -0,0 +1,83 @@
+package admingrpc
+
+import (
+	"errors"
+	"io"
+	"sso/internal/lib/validators"
+	"sso/internal/services/importer"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ImportUsers creates users from CSV or JSON Lines sent in chunks, the format is set by the first message.
+// The records are imported as they arrive, so the input doesn't have to fit in memory.
+func (s *serverAPI) ImportUsers(stream ssov1.AdminService_ImportUsersServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.InvalidArgument, "Nothing to import")
+		}
+		return err
+	}
+
+	format := req.GetFormat()
+
+	validator := validators.ToImportUsersValidator(format)
+	if err := validator.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		data := req.GetData()
+		for {
+			if _, err := pw.Write(data); err != nil {
+				// The import is over and closed the reader.
+				return
+			}
+
+			req, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+			data = req.GetData()
+		}
+	}()
+
+	res, err := s.importer.Import(stream.Context(), pr, importer.Format(format))
+	pr.Close()
+	if err != nil {
+		if errors.Is(err, importer.ErrInvalidHeader) {
+			return status.Error(codes.InvalidArgument, errors.Unwrap(err).Error())
+		}
+		if stream.Context().Err() != nil {
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+		return status.Error(codes.Internal, "Internal error")
+	}
+
+	return stream.SendAndClose(toImportUsersResponse(res))
+}
+
+func toImportUsersResponse(res importer.Result) *ssov1.ImportUsersResponse {
+	errs := make([]*ssov1.ImportUserError, 0, len(res.Errors))
+	for _, recErr := range res.Errors {
+		errs = append(errs, &ssov1.ImportUserError{
+			Line:    int64(recErr.Line),
+			Email:   recErr.Email,
+			Message: recErr.Err.Error(),
+		})
+	}
+
+	return &ssov1.ImportUsersResponse{
+		Imported: int64(res.Imported),
+		Failed:   int64(res.Failed),
+		Errors:   errs,
+	}
+}
@@ -0,0 +1,114 @@
+package admingrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/bearer"
+	"sso/internal/services/auth"
+	"strings"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (models.Session, error)
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+// AuthInterceptor lets AdminService calls through only with the bearer token of an admin,
+// calls to other services are passed as is.
+func AuthInterceptor(authenticator Authenticator) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if !isAdminMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		session, err := authorize(ctx, authenticator)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(context.WithValue(ctx, sessionKey{}, session), req)
+	}
+}
+
+// StreamAuthInterceptor is AuthInterceptor for streaming calls.
+func StreamAuthInterceptor(authenticator Authenticator) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if !isAdminMethod(info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		session, err := authorize(stream.Context(), authenticator)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &sessionStream{
+			ServerStream: stream,
+			ctx:          context.WithValue(stream.Context(), sessionKey{}, session),
+		})
+	}
+}
+
+func isAdminMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+ssov1.AdminService_ServiceDesc.ServiceName+"/")
+}
+
+// authorize returns the session of the bearer token if it belongs to an admin.
+func authorize(ctx context.Context, authenticator Authenticator) (models.Session, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return models.Session{}, status.Error(codes.Unauthenticated, "Missing bearer token")
+	}
+
+	session, err := authenticator.Authenticate(ctx, token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return models.Session{}, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		return models.Session{}, status.Error(codes.Internal, "Internal error")
+	}
+
+	isAdmin, err := authenticator.IsAdmin(ctx, session.UserID)
+	if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+		return models.Session{}, status.Error(codes.Internal, "Internal error")
+	}
+	if !isAdmin {
+		return models.Session{}, status.Error(codes.PermissionDenied, "Permission denied")
+	}
+
+	return session, nil
+}
+
+// sessionStream passes the admin session to stream handlers through its context.
+type sessionStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *sessionStream) Context() context.Context {
+	return s.ctx
+}
+
+type sessionKey struct{}
+
+// sessionFromContext returns the session of the admin AuthInterceptor let the call through with.
+func sessionFromContext(ctx context.Context) (models.Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(models.Session)
+	return session, ok
+}
@@ -0,0 +1,125 @@
+package admingrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/services/invitation"
+	"time"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) CreateInvitation(ctx context.Context, req *ssov1.CreateInvitationRequest) (*ssov1.CreateInvitationResponse, error) {
+	email := req.GetEmail()
+	orgID := req.GetOrgId()
+	appID := req.GetAppId()
+	roleIDs := req.GetRoleIds()
+
+	validator := validators.ToInvitationValidator(email, orgID, appID, roleIDs)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	session, ok := sessionFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Missing bearer token")
+	}
+
+	inv, err := s.invitation.CreateInvitation(ctx, models.Invitation{
+		InviterID: session.UserID,
+		Email:     email,
+		OrgID:     orgID,
+		AppID:     int(appID),
+		RoleIDs:   roleIDs,
+	})
+	if err != nil {
+		return nil, invitationError(err)
+	}
+
+	return &ssov1.CreateInvitationResponse{Invitation: toInvitationMessage(inv)}, nil
+}
+
+func (s *serverAPI) ListInvitations(ctx context.Context, req *ssov1.ListInvitationsRequest) (*ssov1.ListInvitationsResponse, error) {
+	orgID := req.GetOrgId()
+	email := req.GetEmail()
+	invStatus := req.GetStatus()
+
+	validator := validators.ToListInvitationsValidator(orgID, email, invStatus)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	invs, err := s.invitation.ListInvitations(ctx, models.InvitationFilter{
+		OrgID:  orgID,
+		Email:  email,
+		Status: models.InvitationStatus(invStatus),
+	})
+	if err != nil {
+		return nil, invitationError(err)
+	}
+
+	resp := &ssov1.ListInvitationsResponse{Invitations: make([]*ssov1.Invitation, 0, len(invs))}
+	for _, inv := range invs {
+		resp.Invitations = append(resp.Invitations, toInvitationMessage(inv))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) RevokeInvitation(ctx context.Context, req *ssov1.RevokeInvitationRequest) (*ssov1.RevokeInvitationResponse, error) {
+	invitationID := req.GetInvitationId()
+
+	validator := validators.ToIDValidator(invitationID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.invitation.RevokeInvitation(ctx, invitationID); err != nil {
+		return nil, invitationError(err)
+	}
+
+	return &ssov1.RevokeInvitationResponse{}, nil
+}
+
+// invitationError maps the errors of the invitation service to gRPC statuses.
+func invitationError(err error) error {
+	switch {
+	case errors.Is(err, invitation.ErrInvitationNotFound):
+		return status.Error(codes.NotFound, "Invitation not found")
+	case errors.Is(err, invitation.ErrAppNotFound):
+		return status.Error(codes.NotFound, "App not found")
+	case errors.Is(err, invitation.ErrOrgNotFound):
+		return status.Error(codes.NotFound, "Organization not found")
+	case errors.Is(err, invitation.ErrRoleNotFound):
+		return status.Error(codes.NotFound, "Role not found")
+	case errors.Is(err, invitation.ErrOrgMismatch):
+		return status.Error(codes.InvalidArgument, "App belongs to another organization")
+	case errors.Is(err, invitation.ErrRoleMismatch):
+		return status.Error(codes.InvalidArgument, "Role belongs to another app or organization")
+	case errors.Is(err, invitation.ErrNotPending):
+		return status.Error(codes.FailedPrecondition, "Invitation is already accepted, revoked or expired")
+	default:
+		return status.Error(codes.Internal, "Internal error")
+	}
+}
+
+func toInvitationMessage(inv models.Invitation) *ssov1.Invitation {
+	return &ssov1.Invitation{
+		Id:         inv.ID,
+		InviterId:  inv.InviterID,
+		Email:      inv.Email,
+		OrgId:      inv.OrgID,
+		AppId:      int32(inv.AppID),
+		RoleIds:    inv.RoleIDs,
+		Status:     string(inv.Status(time.Now())),
+		ExpiresAt:  toUnix(inv.ExpiresAt),
+		AcceptedBy: inv.AcceptedBy,
+		AcceptedAt: toUnix(inv.AcceptedAt),
+		RevokedAt:  toUnix(inv.RevokedAt),
+		CreatedAt:  toUnix(inv.CreatedAt),
+	}
+}
@@ -0,0 +1,140 @@
+package admingrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/services/organization"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) CreateOrganization(ctx context.Context, req *ssov1.CreateOrganizationRequest) (*ssov1.CreateOrganizationResponse, error) {
+	name := req.GetName()
+	slug := req.GetSlug()
+
+	validator := validators.ToOrganizationValidator(name, slug)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	org, err := s.organization.CreateOrganization(ctx, models.Organization{
+		Name:           name,
+		Slug:           slug,
+		IsolatedEmails: req.GetIsolatedEmails(),
+	})
+	if err != nil {
+		return nil, organizationError(err)
+	}
+
+	return &ssov1.CreateOrganizationResponse{Organization: toOrganizationMessage(org)}, nil
+}
+
+func (s *serverAPI) GetOrganization(ctx context.Context, req *ssov1.GetOrganizationRequest) (*ssov1.GetOrganizationResponse, error) {
+	orgID := req.GetOrgId()
+
+	validator := validators.ToIDValidator(orgID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	org, err := s.organization.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, organizationError(err)
+	}
+
+	return &ssov1.GetOrganizationResponse{Organization: toOrganizationMessage(org)}, nil
+}
+
+func (s *serverAPI) ListOrganizations(ctx context.Context, req *ssov1.ListOrganizationsRequest) (*ssov1.ListOrganizationsResponse, error) {
+	orgs, err := s.organization.ListOrganizations(ctx)
+	if err != nil {
+		return nil, organizationError(err)
+	}
+
+	resp := &ssov1.ListOrganizationsResponse{Organizations: make([]*ssov1.Organization, 0, len(orgs))}
+	for _, org := range orgs {
+		resp.Organizations = append(resp.Organizations, toOrganizationMessage(org))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) DeleteOrganization(ctx context.Context, req *ssov1.DeleteOrganizationRequest) (*ssov1.DeleteOrganizationResponse, error) {
+	orgID := req.GetOrgId()
+
+	validator := validators.ToIDValidator(orgID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.organization.DeleteOrganization(ctx, orgID); err != nil {
+		return nil, organizationError(err)
+	}
+
+	return &ssov1.DeleteOrganizationResponse{}, nil
+}
+
+func (s *serverAPI) AddMember(ctx context.Context, req *ssov1.AddMemberRequest) (*ssov1.AddMemberResponse, error) {
+	orgID := req.GetOrgId()
+	userID := req.GetUserId()
+
+	validator := validators.ToMemberValidator(orgID, userID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.organization.AddMember(ctx, orgID, userID); err != nil {
+		return nil, organizationError(err)
+	}
+
+	return &ssov1.AddMemberResponse{}, nil
+}
+
+func (s *serverAPI) RemoveMember(ctx context.Context, req *ssov1.RemoveMemberRequest) (*ssov1.RemoveMemberResponse, error) {
+	orgID := req.GetOrgId()
+	userID := req.GetUserId()
+
+	validator := validators.ToMemberValidator(orgID, userID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.organization.RemoveMember(ctx, orgID, userID); err != nil {
+		return nil, organizationError(err)
+	}
+
+	return &ssov1.RemoveMemberResponse{}, nil
+}
+
+// organizationError maps the errors of the organization service to gRPC statuses.
+func organizationError(err error) error {
+	switch {
+	case errors.Is(err, organization.ErrOrgNotFound),
+		errors.Is(err, organization.ErrUserNotFound),
+		errors.Is(err, organization.ErrMemberNotFound):
+		return status.Error(codes.NotFound, errors.Unwrap(err).Error())
+	case errors.Is(err, organization.ErrOrgExists),
+		errors.Is(err, organization.ErrAlreadyMember):
+		return status.Error(codes.AlreadyExists, errors.Unwrap(err).Error())
+	case errors.Is(err, organization.ErrOrgNotEmpty),
+		errors.Is(err, organization.ErrIsolatedOrg),
+		errors.Is(err, organization.ErrHomeOrg):
+		return status.Error(codes.FailedPrecondition, errors.Unwrap(err).Error())
+	default:
+		return status.Error(codes.Internal, "Internal error")
+	}
+}
+
+func toOrganizationMessage(org models.Organization) *ssov1.Organization {
+	return &ssov1.Organization{
+		Id:             org.ID,
+		Name:           org.Name,
+		Slug:           org.Slug,
+		IsolatedEmails: org.IsolatedEmails,
+		CreatedAt:      toUnix(org.CreatedAt),
+	}
+}
@@ -0,0 +1,241 @@
+package admingrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/services/rbac"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) CreateRole(ctx context.Context, req *ssov1.CreateRoleRequest) (*ssov1.CreateRoleResponse, error) {
+	orgID := req.GetOrgId()
+	appID := req.GetAppId()
+	name := req.GetName()
+	description := req.GetDescription()
+
+	validator := validators.ToRoleValidator(orgID, appID, name, description)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	role, err := s.rbac.CreateRole(ctx, models.Role{OrgID: orgID, AppID: int(appID), Name: name, Description: description})
+	if err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &ssov1.CreateRoleResponse{Role: toRoleMessage(role)}, nil
+}
+
+func (s *serverAPI) DeleteRole(ctx context.Context, req *ssov1.DeleteRoleRequest) (*ssov1.DeleteRoleResponse, error) {
+	roleID := req.GetRoleId()
+
+	validator := validators.ToIDValidator(roleID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.rbac.DeleteRole(ctx, roleID); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &ssov1.DeleteRoleResponse{}, nil
+}
+
+func (s *serverAPI) ListRoles(ctx context.Context, req *ssov1.ListRolesRequest) (*ssov1.ListRolesResponse, error) {
+	roles, err := s.rbac.ListRoles(ctx, req.GetOrgId(), int(req.GetAppId()))
+	if err != nil {
+		return nil, rbacError(err)
+	}
+
+	resp := &ssov1.ListRolesResponse{Roles: make([]*ssov1.Role, 0, len(roles))}
+	for _, role := range roles {
+		resp.Roles = append(resp.Roles, toRoleMessage(role))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) CreatePermission(ctx context.Context, req *ssov1.CreatePermissionRequest) (*ssov1.CreatePermissionResponse, error) {
+	appID := req.GetAppId()
+	name := req.GetName()
+	description := req.GetDescription()
+
+	validator := validators.ToRoleValidator(0, appID, name, description)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	permission, err := s.rbac.CreatePermission(ctx, models.Permission{AppID: int(appID), Name: name, Description: description})
+	if err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &ssov1.CreatePermissionResponse{Permission: toPermissionMessage(permission)}, nil
+}
+
+func (s *serverAPI) DeletePermission(ctx context.Context, req *ssov1.DeletePermissionRequest) (*ssov1.DeletePermissionResponse, error) {
+	permissionID := req.GetPermissionId()
+
+	validator := validators.ToIDValidator(permissionID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.rbac.DeletePermission(ctx, permissionID); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &ssov1.DeletePermissionResponse{}, nil
+}
+
+func (s *serverAPI) ListPermissions(ctx context.Context, req *ssov1.ListPermissionsRequest) (*ssov1.ListPermissionsResponse, error) {
+	permissions, err := s.rbac.ListPermissions(ctx, int(req.GetAppId()))
+	if err != nil {
+		return nil, rbacError(err)
+	}
+
+	resp := &ssov1.ListPermissionsResponse{Permissions: make([]*ssov1.Permission, 0, len(permissions))}
+	for _, permission := range permissions {
+		resp.Permissions = append(resp.Permissions, toPermissionMessage(permission))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) GrantPermission(ctx context.Context, req *ssov1.GrantPermissionRequest) (*ssov1.GrantPermissionResponse, error) {
+	roleID := req.GetRoleId()
+	permissionID := req.GetPermissionId()
+
+	validator := validators.ToRolePermissionValidator(roleID, permissionID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.rbac.GrantPermission(ctx, roleID, permissionID); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &ssov1.GrantPermissionResponse{}, nil
+}
+
+func (s *serverAPI) RevokePermission(ctx context.Context, req *ssov1.RevokePermissionRequest) (*ssov1.RevokePermissionResponse, error) {
+	roleID := req.GetRoleId()
+	permissionID := req.GetPermissionId()
+
+	validator := validators.ToRolePermissionValidator(roleID, permissionID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.rbac.RevokePermission(ctx, roleID, permissionID); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &ssov1.RevokePermissionResponse{}, nil
+}
+
+func (s *serverAPI) AssignRole(ctx context.Context, req *ssov1.AssignRoleRequest) (*ssov1.AssignRoleResponse, error) {
+	userID := req.GetUserId()
+	roleID := req.GetRoleId()
+	appID := req.GetAppId()
+
+	validator := validators.ToRoleAssignmentValidator(userID, roleID, appID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.rbac.AssignRole(ctx, userID, roleID, int(appID)); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &ssov1.AssignRoleResponse{}, nil
+}
+
+func (s *serverAPI) UnassignRole(ctx context.Context, req *ssov1.UnassignRoleRequest) (*ssov1.UnassignRoleResponse, error) {
+	userID := req.GetUserId()
+	roleID := req.GetRoleId()
+	appID := req.GetAppId()
+
+	validator := validators.ToRoleAssignmentValidator(userID, roleID, appID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.rbac.UnassignRole(ctx, userID, roleID, int(appID)); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &ssov1.UnassignRoleResponse{}, nil
+}
+
+func (s *serverAPI) ListUserRoles(ctx context.Context, req *ssov1.ListUserRolesRequest) (*ssov1.ListUserRolesResponse, error) {
+	userID := req.GetUserId()
+
+	validator := validators.ToIDValidator(userID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	assignments, err := s.rbac.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, rbacError(err)
+	}
+
+	resp := &ssov1.ListUserRolesResponse{Assignments: make([]*ssov1.RoleAssignment, 0, len(assignments))}
+	for _, assignment := range assignments {
+		resp.Assignments = append(resp.Assignments, &ssov1.RoleAssignment{
+			Role:  toRoleMessage(assignment.Role),
+			AppId: int32(assignment.AppID),
+		})
+	}
+
+	return resp, nil
+}
+
+// rbacError maps the errors of the rbac service to gRPC statuses.
+func rbacError(err error) error {
+	switch {
+	case errors.Is(err, rbac.ErrRoleNotFound),
+		errors.Is(err, rbac.ErrPermissionNotFound),
+		errors.Is(err, rbac.ErrUserNotFound),
+		errors.Is(err, rbac.ErrAppNotFound),
+		errors.Is(err, rbac.ErrOrgNotFound):
+		return status.Error(codes.NotFound, errors.Unwrap(err).Error())
+	case errors.Is(err, rbac.ErrRoleExists),
+		errors.Is(err, rbac.ErrPermissionExists),
+		errors.Is(err, rbac.ErrRoleAlreadyAssigned):
+		return status.Error(codes.AlreadyExists, errors.Unwrap(err).Error())
+	case errors.Is(err, rbac.ErrAppMismatch):
+		return status.Error(codes.InvalidArgument, errors.Unwrap(err).Error())
+	case errors.Is(err, rbac.ErrBuiltIn),
+		errors.Is(err, rbac.ErrNotMember):
+		return status.Error(codes.FailedPrecondition, errors.Unwrap(err).Error())
+	default:
+		return status.Error(codes.Internal, "Internal error")
+	}
+}
+
+func toRoleMessage(role models.Role) *ssov1.Role {
+	return &ssov1.Role{
+		Id:          role.ID,
+		OrgId:       role.OrgID,
+		AppId:       int32(role.AppID),
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+	}
+}
+
+func toPermissionMessage(permission models.Permission) *ssov1.Permission {
+	return &ssov1.Permission{
+		Id:          permission.ID,
+		AppId:       int32(permission.AppID),
+		Name:        permission.Name,
+		Description: permission.Description,
+	}
+}
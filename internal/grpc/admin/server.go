@@ -0,0 +1,106 @@
+package admingrpc
+
+import (
+	"context"
+	"io"
+	"sso/internal/domain/models"
+	"sso/internal/services/importer"
+	"time"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc"
+)
+
+type Admin interface {
+	SetUserStatus(
+		ctx context.Context,
+		userID int64,
+		status models.UserStatus,
+		reason string,
+		lockedUntil time.Time,
+	) error
+	GetUser(
+		ctx context.Context,
+		userID int64,
+	) (models.UserEntry, error)
+	ListUsers(
+		ctx context.Context,
+		filter models.UserFilter,
+		pageSize int,
+		pageToken string,
+	) (users []models.UserEntry, nextPageToken string, err error)
+	SearchUsers(
+		ctx context.Context,
+		orgID int64,
+		query string,
+		pageSize int,
+		pageToken string,
+	) (users []models.UserEntry, nextPageToken string, err error)
+	QueryAuditLog(
+		ctx context.Context,
+		filter models.AuditFilter,
+		pageSize int,
+		pageToken string,
+	) (events []models.AuditEvent, nextPageToken string, err error)
+}
+
+type RBAC interface {
+	CreateRole(ctx context.Context, role models.Role) (models.Role, error)
+	DeleteRole(ctx context.Context, roleID int64) error
+	ListRoles(ctx context.Context, orgID int64, appID int) ([]models.Role, error)
+	CreatePermission(ctx context.Context, permission models.Permission) (models.Permission, error)
+	DeletePermission(ctx context.Context, permissionID int64) error
+	ListPermissions(ctx context.Context, appID int) ([]models.Permission, error)
+	GrantPermission(ctx context.Context, roleID int64, permissionID int64) error
+	RevokePermission(ctx context.Context, roleID int64, permissionID int64) error
+	AssignRole(ctx context.Context, userID int64, roleID int64, appID int) error
+	UnassignRole(ctx context.Context, userID int64, roleID int64, appID int) error
+	ListUserRoles(ctx context.Context, userID int64) ([]models.RoleAssignment, error)
+}
+
+type Organization interface {
+	CreateOrganization(ctx context.Context, org models.Organization) (models.Organization, error)
+	GetOrganization(ctx context.Context, orgID int64) (models.Organization, error)
+	ListOrganizations(ctx context.Context) ([]models.Organization, error)
+	DeleteOrganization(ctx context.Context, orgID int64) error
+	AddMember(ctx context.Context, orgID int64, userID int64) error
+	RemoveMember(ctx context.Context, orgID int64, userID int64) error
+}
+
+type Invitation interface {
+	CreateInvitation(ctx context.Context, inv models.Invitation) (models.Invitation, error)
+	ListInvitations(ctx context.Context, filter models.InvitationFilter) ([]models.Invitation, error)
+	RevokeInvitation(ctx context.Context, invitationID int64) error
+}
+
+type Importer interface {
+	Import(ctx context.Context, r io.Reader, format importer.Format) (importer.Result, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedAdminServiceServer
+	admin        Admin
+	rbac         RBAC
+	organization Organization
+	invitation   Invitation
+	importer     Importer
+}
+
+// Register registers the AdminService, every call must pass through AuthInterceptor
+// or StreamAuthInterceptor.
+func Register(
+	gRPC *grpc.Server,
+	admin Admin,
+	rbac RBAC,
+	organization Organization,
+	invitation Invitation,
+	importer Importer,
+) {
+	ssov1.RegisterAdminServiceServer(gRPC, &serverAPI{
+		admin:        admin,
+		rbac:         rbac,
+		organization: organization,
+		invitation:   invitation,
+		importer:     importer,
+	})
+}
@@ -0,0 +1,38 @@
+package admingrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/services/admin"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) SetUserStatus(ctx context.Context, req *ssov1.SetUserStatusRequest) (*ssov1.SetUserStatusResponse, error) {
+	userID := req.GetUserId()
+	userStatus := req.GetStatus()
+	reason := req.GetReason()
+
+	validator := validators.ToSetUserStatusValidator(userID, userStatus, reason)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	lockedUntil := fromUnix(req.GetLockedUntil())
+
+	if err := s.admin.SetUserStatus(ctx, userID, models.UserStatus(userStatus), reason, lockedUntil); err != nil {
+		if errors.Is(err, admin.ErrInvalidStatus) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid user status")
+		}
+		if errors.Is(err, admin.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.SetUserStatusResponse{}, nil
+}
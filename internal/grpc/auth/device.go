@@ -0,0 +1,32 @@
+package authgrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/validators"
+	"sso/internal/services/auth"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RevokeUnrecognizedLogin is the "this wasn't me" link of a new device notification:
+// it signs the user out of all sessions and forgets the device.
+func (s *serverAPI) RevokeUnrecognizedLogin(ctx context.Context, req *ssov1.RevokeUnrecognizedLoginRequest) (*ssov1.RevokeUnrecognizedLoginResponse, error) {
+	token := req.GetToken()
+
+	validator := validators.ToRevokeUnrecognizedLoginValidator(token)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.auth.RevokeUnrecognizedLogin(ctx, token); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired token")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.RevokeUnrecognizedLoginResponse{}, nil
+}
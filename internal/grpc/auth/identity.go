@@ -0,0 +1,49 @@
+package authgrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/validators"
+	"sso/internal/services/auth"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LinkIdentity links the caller's account in an external identity source, like LDAP,
+// to the signed-in user, so that the user can log in through that source.
+func (s *serverAPI) LinkIdentity(ctx context.Context, req *ssov1.LinkIdentityRequest) (*ssov1.LinkIdentityResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	source := req.GetSource()
+	login := req.GetLogin()
+	password := req.GetPassword()
+
+	validator := validators.ToLinkIdentityValidator(source, login, password)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.auth.LinkIdentity(ctx, session, source, login, password); err != nil {
+		if errors.Is(err, auth.ErrUnknownSource) {
+			return nil, status.Error(codes.InvalidArgument, "Unknown identity source")
+		}
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid credentials")
+		}
+		if errors.Is(err, auth.ErrIdentityLinked) {
+			return nil, status.Error(codes.AlreadyExists, "Identity is linked to another account")
+		}
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			return nil, throttledError(throttled.RetryAfter)
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.LinkIdentityResponse{}, nil
+}
@@ -0,0 +1,57 @@
+package authgrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/lib/bearer"
+	"sso/internal/lib/validators"
+	"sso/internal/services/invitation"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AcceptInvitation applies the invitation to the caller's account when called with a bearer token,
+// otherwise it creates the invited user with the password.
+func (s *serverAPI) AcceptInvitation(ctx context.Context, req *ssov1.AcceptInvitationRequest) (*ssov1.AcceptInvitationResponse, error) {
+	token := req.GetToken()
+	password := req.GetPassword()
+
+	validator := validators.ToAcceptInvitationValidator(token, password)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	var userID int64
+	if _, ok := bearer.FromIncomingContext(ctx); ok {
+		session, err := s.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		userID = session.UserID
+	}
+
+	userID, err := s.invitation.AcceptInvitation(ctx, token, userID, password)
+	if err != nil {
+		if violations, ok := passwordPolicyError(err, "password"); ok {
+			return nil, violations
+		}
+		switch {
+		case errors.Is(err, invitation.ErrInvalidToken):
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired token")
+		case errors.Is(err, invitation.ErrPasswordRequired):
+			return nil, status.Error(codes.InvalidArgument, "Password is required to create the account")
+		case errors.Is(err, invitation.ErrUserExists):
+			return nil, status.Error(codes.AlreadyExists, "User already exists, sign in to accept the invitation")
+		case errors.Is(err, invitation.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "User not found")
+		case errors.Is(err, invitation.ErrIsolatedOrg):
+			return nil, status.Error(codes.FailedPrecondition, "Organization with isolated emails only has its own users")
+		default:
+			return nil, status.Error(codes.Internal, "Internal error")
+		}
+	}
+
+	return &ssov1.AcceptInvitationResponse{UserId: userID}, nil
+}
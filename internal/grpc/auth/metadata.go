@@ -0,0 +1,43 @@
+package authgrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/bearer"
+	"sso/internal/services/auth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authenticate resolves the session of the caller from the bearer token.
+func (s *serverAPI) authenticate(ctx context.Context) (models.Session, error) {
+	token, ok := bearer.FromIncomingContext(ctx)
+	if !ok {
+		return models.Session{}, status.Error(codes.Unauthenticated, "Missing bearer token")
+	}
+
+	session, err := s.auth.Authenticate(ctx, token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return models.Session{}, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		return models.Session{}, status.Error(codes.Internal, "Internal error")
+	}
+
+	return session, nil
+}
+
+// requireAdmin rejects callers whose session doesn't belong to an admin.
+func (s *serverAPI) requireAdmin(ctx context.Context, session models.Session) error {
+	isAdmin, err := s.auth.IsAdmin(ctx, session.UserID)
+	if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+		return status.Error(codes.Internal, "Internal error")
+	}
+	if !isAdmin {
+		return status.Error(codes.PermissionDenied, "Permission denied")
+	}
+
+	return nil
+}
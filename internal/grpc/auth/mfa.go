@@ -0,0 +1,160 @@
+package authgrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/services/auth"
+	"sso/internal/services/mfa"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VerifyMFA finishes a login that returned an MFA challenge, the method defaults to totp.
+func (s *serverAPI) VerifyMFA(ctx context.Context, req *ssov1.VerifyMFARequest) (*ssov1.VerifyMFAResponse, error) {
+	challengeID := req.GetChallengeId()
+	method := req.GetMethod()
+	code := req.GetCode()
+	if method == "" {
+		method = models.MFAMethodTOTP
+	}
+
+	validator := validators.ToVerifyMFAValidator(challengeID, method, code)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	token, err := s.auth.VerifyMFA(ctx, challengeID, method, code)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidChallenge) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired MFA challenge")
+		}
+		if errors.Is(err, auth.ErrInvalidMFACode) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid code")
+		}
+		if errors.Is(err, auth.ErrMFAMethodDenied) {
+			return nil, status.Error(codes.InvalidArgument, "MFA method is not enabled")
+		}
+		if errors.Is(err, auth.ErrAccountInactive) {
+			return nil, status.Error(codes.PermissionDenied, "Account is not active")
+		}
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			return nil, throttledError(throttled.RetryAfter)
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.VerifyMFAResponse{Token: token}, nil
+}
+
+// EnrollTOTP returns a new authenticator app secret of the caller, ConfirmTOTP enables it
+// and, if it is the first second factor of the caller, returns the recovery codes.
+func (s *serverAPI) EnrollTOTP(ctx context.Context, req *ssov1.EnrollTOTPRequest) (*ssov1.EnrollTOTPResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, uri, err := s.mfa.EnrollTOTP(ctx, session)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.EnrollTOTPResponse{Secret: secret, Uri: uri}, nil
+}
+
+func (s *serverAPI) ConfirmTOTP(ctx context.Context, req *ssov1.ConfirmTOTPRequest) (*ssov1.ConfirmTOTPResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	code := req.GetCode()
+
+	validator := validators.ToTOTPCodeValidator(code)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	recoveryCodes, err := s.mfa.ConfirmTOTP(ctx, session, code)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.ConfirmTOTPResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// DisableTOTP removes the authenticator app of the caller, an enabled one only with a current code.
+func (s *serverAPI) DisableTOTP(ctx context.Context, req *ssov1.DisableTOTPRequest) (*ssov1.DisableTOTPResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	code := req.GetCode()
+
+	if err := s.mfa.DisableTOTP(ctx, session, code); err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.DisableTOTPResponse{}, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the caller, the old ones stop working.
+func (s *serverAPI) RegenerateRecoveryCodes(ctx context.Context, req *ssov1.RegenerateRecoveryCodesRequest) (*ssov1.RegenerateRecoveryCodesResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.mfa.RegenerateRecoveryCodes(ctx, session)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.RegenerateRecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the caller has left.
+func (s *serverAPI) CountRecoveryCodes(ctx context.Context, req *ssov1.CountRecoveryCodesRequest) (*ssov1.CountRecoveryCodesResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, err := s.mfa.RecoveryCodesRemaining(ctx, session)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.CountRecoveryCodesResponse{Remaining: int64(remaining)}, nil
+}
+
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		return status.Error(codes.InvalidArgument, "Invalid code")
+	case errors.Is(err, mfa.ErrTOTPEnabled):
+		return status.Error(codes.AlreadyExists, "TOTP is already enabled")
+	case errors.Is(err, mfa.ErrTOTPNotEnrolled), errors.Is(err, mfa.ErrMethodNotEnabled):
+		return status.Error(codes.FailedPrecondition, "TOTP is not enrolled")
+	case errors.Is(err, mfa.ErrMFANotEnabled):
+		return status.Error(codes.FailedPrecondition, "MFA is not enabled")
+	case errors.Is(err, mfa.ErrInvalidCredential):
+		return status.Error(codes.InvalidArgument, "Invalid WebAuthn credential")
+	case errors.Is(err, mfa.ErrCredentialExists):
+		return status.Error(codes.AlreadyExists, "Credential is already registered")
+	case errors.Is(err, mfa.ErrCredentialNotFound):
+		return status.Error(codes.NotFound, "Credential not found")
+	case errors.Is(err, mfa.ErrUnavailable):
+		return status.Error(codes.Unavailable, "MFA is not configured")
+	case errors.Is(err, mfa.ErrUserNotFound):
+		return status.Error(codes.NotFound, "User not found")
+	default:
+		return status.Error(codes.Internal, "Internal error")
+	}
+}
@@ -0,0 +1,126 @@
+package authgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/services/profile"
+	"time"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) GetProfile(ctx context.Context, req *ssov1.GetProfileRequest) (*ssov1.GetProfileResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := req.GetUserId()
+
+	validator := validators.ToGetProfileValidator(userID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if userID == 0 {
+		userID = session.UserID
+	}
+	if userID != session.UserID {
+		if err := s.requireAdmin(ctx, session); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := s.profile.GetProfile(ctx, userID)
+	if err != nil {
+		if errors.Is(err, profile.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.GetProfileResponse{Profile: toProfileMessage(p)}, nil
+}
+
+func (s *serverAPI) UpdateProfile(ctx context.Context, req *ssov1.UpdateProfileRequest) (*ssov1.UpdateProfileResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := req.GetUserId()
+	update := fromProfileMessage(req.GetProfile())
+	mask := req.GetUpdateMask()
+
+	validator := validators.ToUpdateProfileValidator(userID, update)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if userID == 0 {
+		userID = session.UserID
+	}
+	// app metadata is for apps to rely on, so users can't write it themselves
+	if len(mask) == 0 {
+		mask = profile.SetFields(update)
+	}
+	if userID != session.UserID || slices.Contains(mask, profile.FieldAppMetadata) {
+		if err := s.requireAdmin(ctx, session); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := s.profile.UpdateProfile(ctx, userID, update, mask)
+	if err != nil {
+		if errors.Is(err, profile.ErrUnknownField) || errors.Is(err, profile.ErrInvalidMetadata) {
+			return nil, status.Error(codes.InvalidArgument, errors.Unwrap(err).Error())
+		}
+		if errors.Is(err, profile.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.UpdateProfileResponse{Profile: toProfileMessage(p)}, nil
+}
+
+func toProfileMessage(p models.Profile) *ssov1.Profile {
+	return &ssov1.Profile{
+		UserId:       p.UserID,
+		DisplayName:  p.DisplayName,
+		GivenName:    p.GivenName,
+		FamilyName:   p.FamilyName,
+		Locale:       p.Locale,
+		Timezone:     p.Timezone,
+		AvatarUrl:    p.AvatarURL,
+		AppMetadata:  string(p.AppMetadata),
+		UserMetadata: string(p.UserMetadata),
+		UpdatedAt:    unixOrZero(p.UpdatedAt),
+	}
+}
+
+func fromProfileMessage(p *ssov1.Profile) models.Profile {
+	return models.Profile{
+		DisplayName:  p.GetDisplayName(),
+		GivenName:    p.GetGivenName(),
+		FamilyName:   p.GetFamilyName(),
+		Locale:       p.GetLocale(),
+		Timezone:     p.GetTimezone(),
+		AvatarURL:    p.GetAvatarUrl(),
+		AppMetadata:  json.RawMessage(p.GetAppMetadata()),
+		UserMetadata: json.RawMessage(p.GetUserMetadata()),
+	}
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
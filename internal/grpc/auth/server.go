@@ -3,77 +3,362 @@ package authgrpc
 import (
 	"context"
 	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/identifiers"
+	"sso/internal/lib/passwordpolicy"
 	"sso/internal/lib/validators"
+	"sso/internal/services/account"
 	"sso/internal/services/auth"
+	"strings"
+	"time"
 
 	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type Auth interface {
 	Login(
 		ctx context.Context,
-		email string,
+		login string,
 		password string,
 		appID int32,
+		realmHint string,
+	) (auth.LoginResult, error)
+	DiscoverRealm(
+		login string,
+		realmHint string,
+	) (source string)
+	VerifyMFA(
+		ctx context.Context,
+		challengeID string,
+		method string,
+		code string,
+	) (token string, err error)
+	BeginWebAuthnLogin(
+		ctx context.Context,
+		appID int32,
+		challengeID string,
+	) (options []byte, err error)
+	LoginWithPasskey(
+		ctx context.Context,
+		credential []byte,
 	) (token string, err error)
 	Register(
 		ctx context.Context,
+		orgID int64,
+		appID int32,
 		email string,
 		password string,
+		username string,
+		phone string,
 	) (userID int64, err error)
 	IsAdmin(
 		ctx context.Context,
 		userID int64,
 	) (bool, error)
+	HasPermission(
+		ctx context.Context,
+		userID int64,
+		appID int32,
+		permission string,
+	) (bool, error)
+	Authenticate(
+		ctx context.Context,
+		token string,
+	) (models.Session, error)
+	RevokeUnrecognizedLogin(
+		ctx context.Context,
+		token string,
+	) error
+	LinkIdentity(
+		ctx context.Context,
+		session models.Session,
+		source string,
+		login string,
+		password string,
+	) error
+}
+
+type Account interface {
+	SendVerification(
+		ctx context.Context,
+		orgID int64,
+		email string,
+	) error
+	VerifyEmail(
+		ctx context.Context,
+		token string,
+	) (userID int64, err error)
+	RequestPasswordReset(
+		ctx context.Context,
+		orgID int64,
+		email string,
+	) error
+	ResetPassword(
+		ctx context.Context,
+		appID int32,
+		token string,
+		password string,
+	) error
+	ChangePassword(
+		ctx context.Context,
+		session models.Session,
+		oldPassword string,
+		newPassword string,
+	) error
+	ChangeEmail(
+		ctx context.Context,
+		session models.Session,
+		newEmail string,
+		password string,
+	) error
+	ConfirmEmailChange(
+		ctx context.Context,
+		session models.Session,
+		token string,
+	) error
+	UpdateIdentifiers(
+		ctx context.Context,
+		session models.Session,
+		username string,
+		phone string,
+	) error
+	DeleteOwnAccount(
+		ctx context.Context,
+		session models.Session,
+		password string,
+	) (purgeAt time.Time, err error)
+	DeleteAccount(
+		ctx context.Context,
+		userID int64,
+	) (purgeAt time.Time, err error)
+	ExportUserData(
+		ctx context.Context,
+		userID int64,
+	) ([]byte, error)
+}
+
+type Profile interface {
+	GetProfile(
+		ctx context.Context,
+		userID int64,
+	) (models.Profile, error)
+	UpdateProfile(
+		ctx context.Context,
+		userID int64,
+		update models.Profile,
+		mask []string,
+	) (models.Profile, error)
+}
+
+type Invitation interface {
+	AcceptInvitation(
+		ctx context.Context,
+		token string,
+		userID int64,
+		password string,
+	) (int64, error)
+}
+
+type MFA interface {
+	EnrollTOTP(
+		ctx context.Context,
+		session models.Session,
+	) (secret string, uri string, err error)
+	ConfirmTOTP(
+		ctx context.Context,
+		session models.Session,
+		code string,
+	) (recoveryCodes []string, err error)
+	DisableTOTP(
+		ctx context.Context,
+		session models.Session,
+		code string,
+	) error
+	BeginWebAuthnRegistration(
+		ctx context.Context,
+		session models.Session,
+	) (options []byte, err error)
+	FinishWebAuthnRegistration(
+		ctx context.Context,
+		session models.Session,
+		name string,
+		credential []byte,
+	) (cred models.WebAuthnCredential, recoveryCodes []string, err error)
+	WebAuthnCredentials(
+		ctx context.Context,
+		session models.Session,
+	) ([]models.WebAuthnCredential, error)
+	DeleteWebAuthnCredential(
+		ctx context.Context,
+		session models.Session,
+		id string,
+	) error
+	RegenerateRecoveryCodes(
+		ctx context.Context,
+		session models.Session,
+	) ([]string, error)
+	RecoveryCodesRemaining(
+		ctx context.Context,
+		session models.Session,
+	) (int, error)
 }
 
 type serverAPI struct {
 	ssov1.UnimplementedAuthServer
-	auth Auth
+	auth       Auth
+	account    Account
+	profile    Profile
+	invitation Invitation
+	mfa        MFA
 }
 
-func Register(gRPC *grpc.Server, auth Auth) {
-	ssov1.RegisterAuthServer(gRPC, &serverAPI{auth: auth})
+func Register(gRPC *grpc.Server, auth Auth, account Account, profile Profile, invitation Invitation, mfa MFA) {
+	ssov1.RegisterAuthServer(gRPC, &serverAPI{auth: auth, account: account, profile: profile, invitation: invitation, mfa: mfa})
 }
 
+// Login takes an email, a username or a phone number in the email field. Users with a second
+// factor get an MFA challenge instead of the token, VerifyMFA finishes it.
 func (s *serverAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.LoginResponse, error) {
-	email := req.GetEmail()
+	login := req.GetEmail()
 	password := req.GetPassword()
 	appID := req.GetAppId()
+	realm := req.GetRealm()
 
-	validator := validators.ToLoginValidator(email, password, appID)
+	validator := validators.ToLoginValidator(login, password, appID, realm)
 	if err := validator.Validate(); err != nil {
 		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
 	}
 
-	token, err := s.auth.Login(ctx, email, password, appID)
+	res, err := s.auth.Login(ctx, login, password, appID, realm)
 	if err != nil {
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "Invalid credentials")
 		}
+		if errors.Is(err, auth.ErrIdentifierDenied) {
+			return nil, status.Error(codes.InvalidArgument, "App does not accept this type of login identifier")
+		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "Email is not verified")
+		}
+		if errors.Is(err, auth.ErrAccountInactive) {
+			return nil, status.Error(codes.PermissionDenied, "Account is not active")
+		}
+		if errors.Is(err, auth.ErrIdentityNotLinked) {
+			return nil, status.Error(codes.FailedPrecondition, "Account exists, sign in and link the identity to it with LinkIdentity first")
+		}
+		if errors.Is(err, auth.ErrNotMember) {
+			return nil, status.Error(codes.PermissionDenied, "User is not a member of the app organization")
+		}
+		if errors.Is(err, auth.ErrInvalidAppID) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid app ID")
+		}
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			return nil, throttledError(throttled.RetryAfter)
+		}
 		return nil, status.Error(codes.Internal, "Internal error")
 	}
 
-	return &ssov1.LoginResponse{Token: token}, nil
+	return &ssov1.LoginResponse{
+		Token:          res.Token,
+		MfaChallengeId: res.ChallengeID,
+		MfaMethods:     res.MFAMethods,
+	}, nil
+}
+
+// DiscoverRealm tells a login page which identity source checks the password of the login.
+func (s *serverAPI) DiscoverRealm(ctx context.Context, req *ssov1.DiscoverRealmRequest) (*ssov1.DiscoverRealmResponse, error) {
+	login := req.GetLogin()
+	realm := req.GetRealm()
+
+	validator := validators.ToDiscoverRealmValidator(login, realm)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	return &ssov1.DiscoverRealmResponse{Source: s.auth.DiscoverRealm(login, realm)}, nil
+}
+
+// throttledError is ResourceExhausted with a RetryInfo detail, rounded up to whole seconds.
+func throttledError(retryAfter time.Duration) error {
+	retryAfter = retryAfter.Truncate(time.Second) + time.Second
+	st, err := status.New(codes.ResourceExhausted, "Too many failed login attempts").WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)},
+	)
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "Too many failed login attempts")
+	}
+	return st.Err()
+}
+
+// passwordPolicyError turns a rejected password into InvalidArgument with a BadRequest detail,
+// one violation of the field per broken rule, and an ErrorInfo listing the rules for programs.
+func passwordPolicyError(err error, field string) (error, bool) {
+	var violation *passwordpolicy.ViolationError
+	if !errors.As(err, &violation) {
+		return nil, false
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	rules := make([]string, len(violation.Violations))
+	for i, v := range violation.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: v.Message,
+		})
+		rules[i] = v.Rule
+	}
+	info := &errdetails.ErrorInfo{
+		Reason:   "PASSWORD_POLICY_VIOLATED",
+		Domain:   "sso",
+		Metadata: map[string]string{"rules": strings.Join(rules, ",")},
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, violation.Error()).WithDetails(badRequest, info)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, violation.Error()), true
+	}
+	return st.Err(), true
 }
 
 func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*ssov1.RegisterResponse, error) {
 	email := req.GetEmail()
 	password := req.GetPassword()
+	orgID := req.GetOrgId()
+	appID := req.GetAppId()
+	username := req.GetUsername()
+	phone := req.GetPhone()
 
-	validator := validators.ToRegisterValidator(email, password)
+	validator := validators.ToRegisterValidator(email, password, orgID, appID, username, phone)
 	if err := validator.Validate(); err != nil {
 		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
 	}
 
-	userID, err := s.auth.Register(ctx, email, password)
+	userID, err := s.auth.Register(ctx, orgID, appID, email, password, username, phone)
 	if err != nil {
+		if violations, ok := passwordPolicyError(err, "password"); ok {
+			return nil, violations
+		}
 		if errors.Is(err, auth.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, "User already exists")
 		}
+		if errors.Is(err, auth.ErrUsernameTaken) {
+			return nil, status.Error(codes.AlreadyExists, "Username already taken")
+		}
+		if errors.Is(err, auth.ErrPhoneTaken) {
+			return nil, status.Error(codes.AlreadyExists, "Phone number already taken")
+		}
+		if errors.Is(err, auth.ErrInvalidIdentifier) {
+			return nil, status.Error(codes.InvalidArgument, identifierError(err))
+		}
+		if errors.Is(err, auth.ErrInvalidOrgID) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid organization ID")
+		}
 		return nil, status.Error(codes.Internal, "Internal error")
 	}
 
@@ -90,7 +375,7 @@ func (s *serverAPI) IsAdmin(ctx context.Context, req *ssov1.IsAdminRequest) (*ss
 
 	isAdmin, err := s.auth.IsAdmin(ctx, userID)
 	if err != nil {
-		if errors.Is(err, auth.ErrInvalidAppID) {
+		if errors.Is(err, auth.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "User not found")
 		}
 		return nil, status.Error(codes.Internal, "Internal error")
@@ -98,3 +383,318 @@ func (s *serverAPI) IsAdmin(ctx context.Context, req *ssov1.IsAdminRequest) (*ss
 
 	return &ssov1.IsAdminResponse{IsAdmin: isAdmin}, nil
 }
+
+func (s *serverAPI) HasPermission(ctx context.Context, req *ssov1.HasPermissionRequest) (*ssov1.HasPermissionResponse, error) {
+	userID := req.GetUserId()
+	appID := req.GetAppId()
+	permission := req.GetPermission()
+
+	validator := validators.ToHasPermissionValidator(userID, appID, permission)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	has, err := s.auth.HasPermission(ctx, userID, appID, permission)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		if errors.Is(err, auth.ErrInvalidAppID) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid app ID")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.HasPermissionResponse{HasPermission: has}, nil
+}
+
+func (s *serverAPI) SendVerification(ctx context.Context, req *ssov1.SendVerificationRequest) (*ssov1.SendVerificationResponse, error) {
+	email := req.GetEmail()
+	orgID := req.GetOrgId()
+
+	validator := validators.ToSendVerificationValidator(email, orgID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.account.SendVerification(ctx, orgID, email); err != nil {
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.SendVerificationResponse{}, nil
+}
+
+func (s *serverAPI) VerifyEmail(ctx context.Context, req *ssov1.VerifyEmailRequest) (*ssov1.VerifyEmailResponse, error) {
+	token := req.GetToken()
+
+	validator := validators.ToVerifyEmailValidator(token)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	userID, err := s.account.VerifyEmail(ctx, token)
+	if err != nil {
+		if errors.Is(err, account.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired token")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.VerifyEmailResponse{UserId: userID}, nil
+}
+
+func (s *serverAPI) RequestPasswordReset(ctx context.Context, req *ssov1.RequestPasswordResetRequest) (*ssov1.RequestPasswordResetResponse, error) {
+	email := req.GetEmail()
+	orgID := req.GetOrgId()
+
+	validator := validators.ToRequestPasswordResetValidator(email, orgID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.account.RequestPasswordReset(ctx, orgID, email); err != nil {
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.RequestPasswordResetResponse{}, nil
+}
+
+func (s *serverAPI) ResetPassword(ctx context.Context, req *ssov1.ResetPasswordRequest) (*ssov1.ResetPasswordResponse, error) {
+	token := req.GetToken()
+	password := req.GetPassword()
+	appID := req.GetAppId()
+
+	validator := validators.ToResetPasswordValidator(token, password, appID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.account.ResetPassword(ctx, appID, token, password); err != nil {
+		if violations, ok := passwordPolicyError(err, "password"); ok {
+			return nil, violations
+		}
+		if errors.Is(err, account.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired token")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.ResetPasswordResponse{}, nil
+}
+
+func (s *serverAPI) ChangePassword(ctx context.Context, req *ssov1.ChangePasswordRequest) (*ssov1.ChangePasswordResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	oldPassword := req.GetOldPassword()
+	newPassword := req.GetNewPassword()
+
+	validator := validators.ToChangePasswordValidator(oldPassword, newPassword)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.account.ChangePassword(ctx, session, oldPassword, newPassword); err != nil {
+		if violations, ok := passwordPolicyError(err, "new_password"); ok {
+			return nil, violations
+		}
+		if errors.Is(err, account.ErrInvalidPassword) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid password")
+		}
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			return nil, throttledError(throttled.RetryAfter)
+		}
+		if errors.Is(err, account.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.ChangePasswordResponse{}, nil
+}
+
+func (s *serverAPI) ChangeEmail(ctx context.Context, req *ssov1.ChangeEmailRequest) (*ssov1.ChangeEmailResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	newEmail := req.GetNewEmail()
+	password := req.GetPassword()
+
+	validator := validators.ToChangeEmailValidator(newEmail, password)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.account.ChangeEmail(ctx, session, newEmail, password); err != nil {
+		if errors.Is(err, account.ErrInvalidPassword) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid password")
+		}
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			return nil, throttledError(throttled.RetryAfter)
+		}
+		if errors.Is(err, account.ErrEmailTaken) {
+			return nil, status.Error(codes.AlreadyExists, "Email already taken")
+		}
+		if errors.Is(err, account.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.ChangeEmailResponse{}, nil
+}
+
+// UpdateIdentifiers sets the username and phone number the caller can log in with, empty values remove them.
+func (s *serverAPI) UpdateIdentifiers(ctx context.Context, req *ssov1.UpdateIdentifiersRequest) (*ssov1.UpdateIdentifiersResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	username := req.GetUsername()
+	phone := req.GetPhone()
+
+	validator := validators.ToUpdateIdentifiersValidator(username, phone)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.account.UpdateIdentifiers(ctx, session, username, phone); err != nil {
+		if errors.Is(err, identifiers.ErrInvalidUsername) || errors.Is(err, identifiers.ErrInvalidPhone) {
+			return nil, status.Error(codes.InvalidArgument, identifierError(err))
+		}
+		if errors.Is(err, account.ErrUsernameTaken) {
+			return nil, status.Error(codes.AlreadyExists, "Username already taken")
+		}
+		if errors.Is(err, account.ErrPhoneTaken) {
+			return nil, status.Error(codes.AlreadyExists, "Phone number already taken")
+		}
+		if errors.Is(err, account.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.UpdateIdentifiersResponse{}, nil
+}
+
+func (s *serverAPI) ConfirmEmailChange(ctx context.Context, req *ssov1.ConfirmEmailChangeRequest) (*ssov1.ConfirmEmailChangeResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token := req.GetToken()
+
+	validator := validators.ToConfirmEmailChangeValidator(token)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.account.ConfirmEmailChange(ctx, session, token); err != nil {
+		if errors.Is(err, account.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired token")
+		}
+		if errors.Is(err, account.ErrEmailTaken) {
+			return nil, status.Error(codes.AlreadyExists, "Email already taken")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.ConfirmEmailChangeResponse{}, nil
+}
+
+func (s *serverAPI) DeleteAccount(ctx context.Context, req *ssov1.DeleteAccountRequest) (*ssov1.DeleteAccountResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := req.GetUserId()
+	password := req.GetPassword()
+
+	validator := validators.ToDeleteAccountValidator(userID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	var purgeAt time.Time
+	if userID == 0 || userID == session.UserID {
+		// users deleting their own account confirm it with the password
+		if password == "" {
+			return nil, status.Error(codes.InvalidArgument, "Field 'Password' is required")
+		}
+		purgeAt, err = s.account.DeleteOwnAccount(ctx, session, password)
+	} else {
+		if err := s.requireAdmin(ctx, session); err != nil {
+			return nil, err
+		}
+		purgeAt, err = s.account.DeleteAccount(ctx, userID)
+	}
+	if err != nil {
+		if errors.Is(err, account.ErrInvalidPassword) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid password")
+		}
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			return nil, throttledError(throttled.RetryAfter)
+		}
+		if errors.Is(err, account.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.DeleteAccountResponse{PurgeAt: purgeAt.Unix()}, nil
+}
+
+func (s *serverAPI) ExportUserData(ctx context.Context, req *ssov1.ExportUserDataRequest) (*ssov1.ExportUserDataResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := req.GetUserId()
+
+	validator := validators.ToExportUserDataValidator(userID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if userID == 0 {
+		userID = session.UserID
+	}
+	if userID != session.UserID {
+		if err := s.requireAdmin(ctx, session); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := s.account.ExportUserData(ctx, userID)
+	if err != nil {
+		if errors.Is(err, account.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.ExportUserDataResponse{Data: data}, nil
+}
+
+// identifierError describes why a login identifier was rejected.
+func identifierError(err error) string {
+	switch {
+	case errors.Is(err, identifiers.ErrInvalidUsername):
+		return "Invalid username"
+	case errors.Is(err, identifiers.ErrInvalidPhone):
+		return "Invalid phone number"
+	default:
+		return "Invalid email"
+	}
+}
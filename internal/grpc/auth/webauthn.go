@@ -0,0 +1,166 @@
+package authgrpc
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/validators"
+	"sso/internal/lib/webauthn"
+	"sso/internal/services/auth"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BeginWebAuthnRegistration returns the options for navigator.credentials.create() of the caller,
+// FinishWebAuthnRegistration saves the credential the browser created with them and, if it is
+// the first second factor of the caller, returns the recovery codes.
+func (s *serverAPI) BeginWebAuthnRegistration(ctx context.Context, req *ssov1.BeginWebAuthnRegistrationRequest) (*ssov1.BeginWebAuthnRegistrationResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := s.mfa.BeginWebAuthnRegistration(ctx, session)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.BeginWebAuthnRegistrationResponse{Options: options}, nil
+}
+
+func (s *serverAPI) FinishWebAuthnRegistration(ctx context.Context, req *ssov1.FinishWebAuthnRegistrationRequest) (*ssov1.FinishWebAuthnRegistrationResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := req.GetName()
+	credential := req.GetCredential()
+
+	validator := validators.ToFinishWebAuthnRegistrationValidator(name, credential)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	cred, recoveryCodes, err := s.mfa.FinishWebAuthnRegistration(ctx, session, name, credential)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.FinishWebAuthnRegistrationResponse{
+		Credential:    toWebAuthnCredentialMessage(cred),
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+func (s *serverAPI) ListWebAuthnCredentials(ctx context.Context, req *ssov1.ListWebAuthnCredentialsRequest) (*ssov1.ListWebAuthnCredentialsResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := s.mfa.WebAuthnCredentials(ctx, session)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	resp := &ssov1.ListWebAuthnCredentialsResponse{
+		Credentials: make([]*ssov1.WebAuthnCredential, 0, len(creds)),
+	}
+	for _, cred := range creds {
+		resp.Credentials = append(resp.Credentials, toWebAuthnCredentialMessage(cred))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) DeleteWebAuthnCredential(ctx context.Context, req *ssov1.DeleteWebAuthnCredentialRequest) (*ssov1.DeleteWebAuthnCredentialResponse, error) {
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id := req.GetId()
+
+	validator := validators.ToWebAuthnCredentialIDValidator(id)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	if err := s.mfa.DeleteWebAuthnCredential(ctx, session, id); err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.DeleteWebAuthnCredentialResponse{}, nil
+}
+
+// BeginWebAuthnLogin returns the options for navigator.credentials.get(). With mfa_challenge_id
+// the assertion finishes the challenge with VerifyMFA and the webauthn method, with only
+// app_id it starts a passwordless login finished by FinishWebAuthnLogin.
+func (s *serverAPI) BeginWebAuthnLogin(ctx context.Context, req *ssov1.BeginWebAuthnLoginRequest) (*ssov1.BeginWebAuthnLoginResponse, error) {
+	appID := req.GetAppId()
+	challengeID := req.GetMfaChallengeId()
+
+	validator := validators.ToBeginWebAuthnLoginValidator(appID, challengeID)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	options, err := s.auth.BeginWebAuthnLogin(ctx, appID, challengeID)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidChallenge) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired MFA challenge")
+		}
+		if errors.Is(err, auth.ErrMFAMethodDenied) {
+			return nil, status.Error(codes.InvalidArgument, "MFA method is not enabled")
+		}
+		if errors.Is(err, auth.ErrInvalidAppID) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid app ID")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.BeginWebAuthnLoginResponse{Options: options}, nil
+}
+
+func (s *serverAPI) FinishWebAuthnLogin(ctx context.Context, req *ssov1.FinishWebAuthnLoginRequest) (*ssov1.FinishWebAuthnLoginResponse, error) {
+	credential := req.GetCredential()
+
+	validator := validators.ToFinishWebAuthnLoginValidator(credential)
+	if err := validator.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, validators.GetDetailedError(err))
+	}
+
+	token, err := s.auth.LoginWithPasskey(ctx, credential)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid credentials")
+		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "Email is not verified")
+		}
+		if errors.Is(err, auth.ErrAccountInactive) {
+			return nil, status.Error(codes.PermissionDenied, "Account is not active")
+		}
+		if errors.Is(err, auth.ErrNotMember) {
+			return nil, status.Error(codes.PermissionDenied, "User is not a member of the app organization")
+		}
+		if errors.Is(err, auth.ErrInvalidAppID) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid app ID")
+		}
+		return nil, status.Error(codes.Internal, "Internal error")
+	}
+
+	return &ssov1.FinishWebAuthnLoginResponse{Token: token}, nil
+}
+
+func toWebAuthnCredentialMessage(cred models.WebAuthnCredential) *ssov1.WebAuthnCredential {
+	return &ssov1.WebAuthnCredential{
+		Id:         webauthn.EncodeID(cred.ID),
+		Name:       cred.Name,
+		CreatedAt:  unixOrZero(cred.CreatedAt),
+		LastUsedAt: unixOrZero(cred.LastUsedAt),
+	}
+}
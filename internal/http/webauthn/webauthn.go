@@ -0,0 +1,296 @@
+// Package webauthnhttp serves the WebAuthn ceremonies to browsers as a JSON API. The options
+// are PublicKeyCredentialCreationOptionsJSON and PublicKeyCredentialRequestOptionsJSON and the
+// credentials are the toJSON() of what navigator.credentials returns, so a page needs no encoding.
+package webauthnhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/bearer"
+	"sso/internal/lib/validators"
+	"sso/internal/lib/webauthn"
+	"sso/internal/services/auth"
+	"sso/internal/services/mfa"
+	"time"
+)
+
+const maxBodySize = 64 << 10
+
+type Auth interface {
+	Authenticate(ctx context.Context, token string) (models.Session, error)
+	BeginWebAuthnLogin(ctx context.Context, appID int32, challengeID string) ([]byte, error)
+	LoginWithPasskey(ctx context.Context, credential []byte) (string, error)
+	VerifyMFA(ctx context.Context, challengeID string, method string, code string) (string, error)
+}
+
+type MFA interface {
+	BeginWebAuthnRegistration(ctx context.Context, session models.Session) ([]byte, error)
+	FinishWebAuthnRegistration(ctx context.Context, session models.Session, name string, credential []byte) (models.WebAuthnCredential, []string, error)
+}
+
+type handler struct {
+	auth    Auth
+	mfa     MFA
+	origins []string
+}
+
+// Register adds the endpoints to the mux. Browsers on the origins may call them cross-origin.
+func Register(mux *http.ServeMux, auth Auth, mfa MFA, origins []string) {
+	h := &handler{auth: auth, mfa: mfa, origins: origins}
+
+	mux.Handle("/webauthn/registration/options", h.cors(h.registrationOptions))
+	mux.Handle("/webauthn/registration", h.cors(h.register))
+	mux.Handle("/webauthn/login/options", h.cors(h.loginOptions))
+	mux.Handle("/webauthn/login", h.cors(h.login))
+}
+
+// cors answers preflight requests of the allowed origins and only lets POST through.
+func (h *handler) cors(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); origin != "" && slices.Contains(h.origins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		}
+
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+			next(w, r)
+		default:
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	})
+}
+
+// registrationOptions starts the registration of a credential of the user of the bearer token.
+func (h *handler) registrationOptions(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	options, err := h.mfa.BeginWebAuthnRegistration(r.Context(), session)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	writeRawJSON(w, options)
+}
+
+type registrationRequest struct {
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+type credentialResponse struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	CreatedAt     string   `json:"created_at"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+func (h *handler) register(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req registrationRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	validator := validators.ToFinishWebAuthnRegistrationValidator(req.Name, req.Credential)
+	if err := validator.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, validators.GetDetailedError(err))
+		return
+	}
+
+	cred, recoveryCodes, err := h.mfa.FinishWebAuthnRegistration(r.Context(), session, req.Name, req.Credential)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, credentialResponse{
+		ID:            webauthn.EncodeID(cred.ID),
+		Name:          cred.Name,
+		CreatedAt:     cred.CreatedAt.UTC().Format(time.RFC3339),
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+type loginOptionsRequest struct {
+	AppID          int32  `json:"app_id"`
+	MFAChallengeID string `json:"mfa_challenge_id"`
+}
+
+// loginOptions starts a passwordless login to the app, or with the MFA challenge of
+// a password login asks for a credential of its user.
+func (h *handler) loginOptions(w http.ResponseWriter, r *http.Request) {
+	var req loginOptionsRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	validator := validators.ToBeginWebAuthnLoginValidator(req.AppID, req.MFAChallengeID)
+	if err := validator.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, validators.GetDetailedError(err))
+		return
+	}
+
+	options, err := h.auth.BeginWebAuthnLogin(r.Context(), req.AppID, req.MFAChallengeID)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	writeRawJSON(w, options)
+}
+
+type loginRequest struct {
+	MFAChallengeID string          `json:"mfa_challenge_id"`
+	Credential     json.RawMessage `json:"credential"`
+}
+
+type loginResponse struct {
+	Token string `json:"token"`
+}
+
+// login finishes the login started with loginOptions and returns the token.
+func (h *handler) login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	var (
+		token string
+		err   error
+	)
+	if req.MFAChallengeID != "" {
+		validator := validators.ToVerifyMFAValidator(req.MFAChallengeID, models.MFAMethodWebAuthn, string(req.Credential))
+		if err := validator.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, validators.GetDetailedError(err))
+			return
+		}
+		token, err = h.auth.VerifyMFA(r.Context(), req.MFAChallengeID, models.MFAMethodWebAuthn, string(req.Credential))
+	} else {
+		validator := validators.ToFinishWebAuthnLoginValidator(req.Credential)
+		if err := validator.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, validators.GetDetailedError(err))
+			return
+		}
+		token, err = h.auth.LoginWithPasskey(r.Context(), req.Credential)
+	}
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, loginResponse{Token: token})
+}
+
+func (h *handler) authenticate(w http.ResponseWriter, r *http.Request) (models.Session, bool) {
+	token, ok := bearer.FromHeader(r.Header.Get("Authorization"))
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Missing bearer token")
+		return models.Session{}, false
+	}
+
+	session, err := h.auth.Authenticate(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			writeError(w, http.StatusUnauthorized, "Invalid token")
+			return models.Session{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "Internal error")
+		return models.Session{}, false
+	}
+
+	return session, true
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return false
+	}
+	return true
+}
+
+func writeAuthError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		writeError(w, http.StatusUnauthorized, "Invalid credentials")
+	case errors.Is(err, auth.ErrInvalidChallenge):
+		writeError(w, http.StatusBadRequest, "Invalid or expired MFA challenge")
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		writeError(w, http.StatusUnauthorized, "Invalid credential")
+	case errors.Is(err, auth.ErrMFAMethodDenied):
+		writeError(w, http.StatusBadRequest, "MFA method is not enabled")
+	case errors.Is(err, auth.ErrInvalidAppID):
+		writeError(w, http.StatusBadRequest, "Invalid app ID")
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		writeError(w, http.StatusForbidden, "Email is not verified")
+	case errors.Is(err, auth.ErrAccountInactive):
+		writeError(w, http.StatusForbidden, "Account is not active")
+	case errors.Is(err, auth.ErrNotMember):
+		writeError(w, http.StatusForbidden, "User is not a member of the app organization")
+	default:
+		writeError(w, http.StatusInternalServerError, "Internal error")
+	}
+}
+
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCredential):
+		writeError(w, http.StatusBadRequest, "Invalid WebAuthn credential")
+	case errors.Is(err, mfa.ErrCredentialExists):
+		writeError(w, http.StatusConflict, "Credential is already registered")
+	case errors.Is(err, mfa.ErrUserNotFound):
+		writeError(w, http.StatusNotFound, "User not found")
+	default:
+		writeError(w, http.StatusInternalServerError, "Internal error")
+	}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		code, b = http.StatusInternalServerError, []byte(`{"error":"Internal error"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+// writeRawJSON writes the options made by the services, they are JSON already.
+func writeRawJSON(w http.ResponseWriter, b []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,35 @@
+package bearer
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const authorizationHeader = "authorization"
+
+// FromIncomingContext returns the token from the "authorization: Bearer <token>" metadata.
+func FromIncomingContext(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return "", false
+	}
+
+	return FromHeader(values[0])
+}
+
+// FromHeader returns the token from the value of an "Authorization: Bearer <token>" HTTP header.
+func FromHeader(value string) (string, bool) {
+	scheme, token, found := strings.Cut(value, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
@@ -0,0 +1,300 @@
+// Package cbor implements the subset of CBOR (RFC 8949) that WebAuthn needs: integers,
+// byte and text strings, arrays, maps, booleans and null, all of definite length.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorSimple = 7
+
+	simpleFalse = 20
+	simpleTrue  = 21
+	simpleNull  = 22
+
+	maxDepth = 16
+)
+
+var (
+	ErrUnexpectedEnd = errors.New("cbor: unexpected end of data")
+	ErrUnsupported   = errors.New("cbor: unsupported item")
+)
+
+// Decode reads one data item from data and returns it with the bytes that follow it.
+// Integers are decoded as int64, byte strings as []byte, text as string, arrays as []any
+// and maps as map[any]any with int64 or string keys.
+func Decode(data []byte) (any, []byte, error) {
+	d := decoder{data: data}
+	v, err := d.item(0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return v, d.data[d.off:], nil
+}
+
+type decoder struct {
+	data []byte
+	off  int
+}
+
+func (d *decoder) item(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nesting is too deep", ErrUnsupported)
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflows int64", ErrUnsupported)
+		}
+		return int64(arg), nil
+	case majorNegInt:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflows int64", ErrUnsupported)
+		}
+		return -1 - int64(arg), nil
+	case majorBytes:
+		b, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case majorText:
+		b, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case majorArray:
+		if arg > uint64(len(d.data)-d.off) {
+			return nil, ErrUnexpectedEnd
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case majorMap:
+		if arg > uint64(len(d.data)-d.off) {
+			return nil, ErrUnexpectedEnd
+		}
+		m := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("%w: map key of type %T", ErrUnsupported, k)
+			}
+			if _, ok := m[k]; ok {
+				return nil, fmt.Errorf("%w: duplicate map key %v", ErrUnsupported, k)
+			}
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case majorSimple:
+		switch arg {
+		case simpleFalse:
+			return false, nil
+		case simpleTrue:
+			return true, nil
+		case simpleNull:
+			return nil, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: major type %d", ErrUnsupported, major)
+}
+
+// head reads the initial byte of an item and its argument.
+func (d *decoder) head() (byte, uint64, error) {
+	if d.off >= len(d.data) {
+		return 0, 0, ErrUnexpectedEnd
+	}
+	initial := d.data[d.off]
+	d.off++
+
+	major, info := initial>>5, initial&0x1f
+	if major == majorSimple && info > 23 {
+		// floats and break codes
+		return 0, 0, fmt.Errorf("%w: simple value %d", ErrUnsupported, info)
+	}
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		b, err := d.take(1 << (info - 24))
+		if err != nil {
+			return 0, 0, err
+		}
+		var arg uint64
+		for _, c := range b {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, arg, nil
+	default:
+		return 0, 0, fmt.Errorf("%w: indefinite length", ErrUnsupported)
+	}
+}
+
+func (d *decoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, ErrUnexpectedEnd
+	}
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+
+	return b, nil
+}
+
+// Encode writes v in the canonical form of CTAP2: the shortest heads and map keys sorted
+// by their encoding. It takes the types Decode returns, plus int and map[int]any.
+func Encode(v any) ([]byte, error) {
+	return appendItem(nil, v)
+}
+
+func appendItem(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, majorSimple<<5|simpleNull), nil
+	case bool:
+		if v {
+			return append(buf, majorSimple<<5|simpleTrue), nil
+		}
+		return append(buf, majorSimple<<5|simpleFalse), nil
+	case int:
+		return appendInt(buf, int64(v)), nil
+	case int64:
+		return appendInt(buf, v), nil
+	case []byte:
+		return append(appendHead(buf, majorBytes, uint64(len(v))), v...), nil
+	case string:
+		return append(appendHead(buf, majorText, uint64(len(v))), v...), nil
+	case []any:
+		buf = appendHead(buf, majorArray, uint64(len(v)))
+		for _, item := range v {
+			var err error
+			if buf, err = appendItem(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[any]any:
+		return appendMap(buf, len(v), func(fn func(k, v any) error) error {
+			for k, item := range v {
+				if err := fn(k, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case map[int]any:
+		return appendMap(buf, len(v), func(fn func(k, v any) error) error {
+			for k, item := range v {
+				if err := fn(k, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case map[string]any:
+		return appendMap(buf, len(v), func(fn func(k, v any) error) error {
+			for k, item := range v {
+				if err := fn(k, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		return nil, fmt.Errorf("%w: type %T", ErrUnsupported, v)
+	}
+}
+
+func appendMap(buf []byte, n int, each func(fn func(k, v any) error) error) ([]byte, error) {
+	type entry struct{ key, value []byte }
+	entries := make([]entry, 0, n)
+
+	err := each(func(k, v any) error {
+		key, err := appendItem(nil, k)
+		if err != nil {
+			return err
+		}
+		value, err := appendItem(nil, v)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: key, value: value})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].key, entries[j].key
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return string(a) < string(b)
+	})
+
+	buf = appendHead(buf, majorMap, uint64(n))
+	for _, e := range entries {
+		buf = append(buf, e.key...)
+		buf = append(buf, e.value...)
+	}
+
+	return buf, nil
+}
+
+func appendInt(buf []byte, v int64) []byte {
+	if v < 0 {
+		return appendHead(buf, majorNegInt, uint64(-1-v))
+	}
+	return appendHead(buf, majorUint, uint64(v))
+}
+
+func appendHead(buf []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(buf, major<<5|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(buf, major<<5|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major<<5|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major<<5|26), uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major<<5|27), arg)
+	}
+}
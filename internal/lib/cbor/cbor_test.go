@@ -0,0 +1,116 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode_RFCVectors(t *testing.T) {
+	// Appendix A of RFC 8949.
+	tests := []struct {
+		hex  string
+		want any
+	}{
+		{hex: "00", want: int64(0)},
+		{hex: "17", want: int64(23)},
+		{hex: "1818", want: int64(24)},
+		{hex: "1903e8", want: int64(1000)},
+		{hex: "1a000f4240", want: int64(1000000)},
+		{hex: "1b000000e8d4a51000", want: int64(1000000000000)},
+		{hex: "20", want: int64(-1)},
+		{hex: "3903e7", want: int64(-1000)},
+		{hex: "40", want: []byte{}},
+		{hex: "4401020304", want: []byte{1, 2, 3, 4}},
+		{hex: "6449455446", want: "IETF"},
+		{hex: "62c3bc", want: "ü"},
+		{hex: "f4", want: false},
+		{hex: "f5", want: true},
+		{hex: "f6", want: nil},
+		{hex: "8301820203820405", want: []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		{hex: "a201020304", want: map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+		{hex: "a26161016162820203", want: map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+	}
+
+	for _, tt := range tests {
+		data, err := hex.DecodeString(tt.hex)
+		require.NoError(t, err)
+
+		v, rest, err := Decode(data)
+		require.NoError(t, err, tt.hex)
+		assert.Equal(t, tt.want, v, tt.hex)
+		assert.Empty(t, rest, tt.hex)
+	}
+}
+
+func TestDecode_Rest(t *testing.T) {
+	v, rest, err := Decode([]byte{0x01, 0xff, 0xfe})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	assert.Equal(t, []byte{0xff, 0xfe}, rest)
+}
+
+func TestDecode_FailCases(t *testing.T) {
+	tests := map[string]string{
+		"Empty":              "",
+		"Truncated string":   "4401",
+		"Truncated argument": "19",
+		"Huge array":         "9b00ffffffffffffff",
+		"Indefinite length":  "5f42010243030405ff",
+		"Float":              "f93c00",
+		"Uint64 overflow":    "1bffffffffffffffff",
+		"Array map key":      "a1800102",
+		"Duplicate map key":  "a201020103",
+		"Tag":                "c11a514b67b0",
+	}
+
+	for name, h := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := hex.DecodeString(h)
+			require.NoError(t, err)
+
+			_, _, err = Decode(data)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEncode_Canonical(t *testing.T) {
+	data, err := Encode(map[int]any{
+		3:  int64(-7),
+		1:  2,
+		-1: 1,
+		-2: []byte{0xaa},
+	})
+	require.NoError(t, err)
+	// keys sorted by encoding: 1, 3, -1, -2
+	assert.Equal(t, "a40102032620012141aa", hex.EncodeToString(data))
+
+	v, _, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{int64(1): int64(2), int64(3): int64(-7), int64(-1): int64(1), int64(-2): []byte{0xaa}}, v)
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	in := map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": make([]byte, 300),
+		"list":     []any{true, false, nil, "x", int64(1 << 40)},
+	}
+
+	data, err := Encode(in)
+	require.NoError(t, err)
+
+	v, rest, err := Decode(data)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+
+	m := v.(map[any]any)
+	assert.Equal(t, "none", m["fmt"])
+	assert.Equal(t, map[any]any{}, m["attStmt"])
+	assert.Len(t, m["authData"], 300)
+	assert.Equal(t, []any{true, false, nil, "x", int64(1 << 40)}, m["list"])
+}
@@ -0,0 +1,179 @@
+// Package clientip finds the IP address of the client of a gRPC call or an HTTP request. Calls that
+// come through a trusted reverse proxy are attributed to the address the proxy forwarded in the metadata.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	forwardedForHeader = "x-forwarded-for"
+	realIPHeader       = "x-real-ip"
+)
+
+type ipKey struct{}
+
+// NewContext returns a copy of ctx carrying the client IP.
+func NewContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ipKey{}, ip)
+}
+
+// FromContext returns the client IP stored by NewContext, or "" if it is unknown.
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ipKey{}).(string)
+	return ip
+}
+
+// Resolver trusts the forwarded metadata only of the calls from the proxies it knows.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver accepts addresses and CIDR ranges of the trusted proxies.
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	const op = "clientip.NewResolver"
+
+	r := &Resolver{trusted: make([]netip.Prefix, 0, len(trustedProxies))}
+	for _, proxy := range trustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		r.trusted = append(r.trusted, prefix)
+	}
+
+	return r, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromIncomingContext returns the client IP of the call, or "" if the peer is not an IP address.
+// For a trusted peer it is the rightmost x-forwarded-for address that is not a trusted proxy,
+// clients can put anything to the left of it. Without x-forwarded-for x-real-ip is used.
+func (r *Resolver) FromIncomingContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	return r.resolve(p.Addr.String(), md.Get(forwardedForHeader), md.Get(realIPHeader))
+}
+
+// FromRequest is FromIncomingContext for an HTTP request, the headers are the same.
+func (r *Resolver) FromRequest(req *http.Request) string {
+	return r.resolve(req.RemoteAddr, req.Header.Values(forwardedForHeader), req.Header.Values(realIPHeader))
+}
+
+// resolve returns the client IP of a connection from the peer address, "host:port",
+// with the forwarded headers the peer sent.
+func (r *Resolver) resolve(peerAddr string, forwarded []string, realIP []string) string {
+	addrPort, err := netip.ParseAddrPort(peerAddr)
+	if err != nil {
+		return ""
+	}
+	client := addrPort.Addr().Unmap()
+
+	if !r.isTrusted(client) {
+		return client.String()
+	}
+
+	if len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = addr.Unmap()
+			if !r.isTrusted(client) {
+				break
+			}
+		}
+		return client.String()
+	}
+	if len(realIP) > 0 {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(realIP[0])); err == nil {
+			return addr.Unmap().String()
+		}
+	}
+
+	return client.String()
+}
+
+// UnaryServerInterceptor stores the client IP of every call in its context, see FromContext.
+func (r *Resolver) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(NewContext(ctx, r.FromIncomingContext(ctx)), req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func (r *Resolver) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &contextStream{
+			ServerStream: stream,
+			ctx:          NewContext(stream.Context(), r.FromIncomingContext(stream.Context())),
+		})
+	}
+}
+
+// contextStream passes the client IP to stream handlers through its context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// Middleware stores the client IP of every HTTP request in its context, see FromContext.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(NewContext(req.Context(), r.FromRequest(req))))
+	})
+}
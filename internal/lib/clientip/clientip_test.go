@@ -0,0 +1,107 @@
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func callFrom(addr string, md metadata.MD) context.Context {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: tcpAddr})
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func TestResolver(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8", "192.0.2.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{
+			name: "direct client",
+			ctx:  callFrom("203.0.113.7:5000", nil),
+			want: "203.0.113.7",
+		},
+		{
+			name: "forwarded metadata of an untrusted peer is ignored",
+			ctx:  callFrom("203.0.113.7:5000", metadata.Pairs("x-forwarded-for", "198.51.100.1")),
+			want: "203.0.113.7",
+		},
+		{
+			name: "trusted proxy",
+			ctx:  callFrom("10.1.2.3:5000", metadata.Pairs("x-forwarded-for", "198.51.100.1")),
+			want: "198.51.100.1",
+		},
+		{
+			name: "spoofed hops left of the client",
+			ctx:  callFrom("10.1.2.3:5000", metadata.Pairs("x-forwarded-for", "1.1.1.1, 198.51.100.1, 192.0.2.1")),
+			want: "198.51.100.1",
+		},
+		{
+			name: "x-real-ip",
+			ctx:  callFrom("192.0.2.1:5000", metadata.Pairs("x-real-ip", "198.51.100.2")),
+			want: "198.51.100.2",
+		},
+		{
+			name: "malformed forwarded address",
+			ctx:  callFrom("10.1.2.3:5000", metadata.Pairs("x-forwarded-for", "unknown")),
+			want: "10.1.2.3",
+		},
+		{
+			name: "IPv4-mapped IPv6 peer",
+			ctx:  callFrom("[::ffff:203.0.113.7]:5000", nil),
+			want: "203.0.113.7",
+		},
+		{
+			name: "no peer",
+			ctx:  context.Background(),
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.FromIncomingContext(tt.ctx))
+		})
+	}
+}
+
+func TestResolver_FromRequest(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/webauthn/login/finish", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	assert.Equal(t, "198.51.100.1", r.FromRequest(req))
+
+	req.RemoteAddr = "203.0.113.7:5000"
+	assert.Equal(t, "203.0.113.7", r.FromRequest(req), "forwarded header of an untrusted client is ignored")
+}
+
+func TestNewResolver_InvalidProxy(t *testing.T) {
+	_, err := NewResolver([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+
+	_, err = NewResolver([]string{"proxy.local"})
+	assert.Error(t, err)
+}
+
+func TestContext(t *testing.T) {
+	assert.Equal(t, "", FromContext(context.Background()))
+	assert.Equal(t, "198.51.100.1", FromContext(NewContext(context.Background(), "198.51.100.1")))
+}
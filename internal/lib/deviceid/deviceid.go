@@ -0,0 +1,79 @@
+// Package deviceid passes the device ID the client of a gRPC call or an HTTP request sent to the services.
+// A device ID is an opaque value the client keeps between logins, like a random ID stored by an app
+// or a browser cookie. It is only compared, never trusted: a client can send any.
+package deviceid
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	header = "x-device-id"
+	cookie = "device_id"
+)
+
+// maxLen cuts long device IDs, they are hashed anyway.
+const maxLen = 128
+
+type deviceIDKey struct{}
+
+// NewContext returns a copy of ctx carrying the device ID.
+func NewContext(ctx context.Context, deviceID string) context.Context {
+	return context.WithValue(ctx, deviceIDKey{}, deviceID)
+}
+
+// FromContext returns the device ID stored by NewContext, or "" if the client sent none.
+func FromContext(ctx context.Context) string {
+	deviceID, _ := ctx.Value(deviceIDKey{}).(string)
+	return deviceID
+}
+
+// FromIncomingContext returns the device ID the client of the call sent in the x-device-id metadata.
+func FromIncomingContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(header); len(values) > 0 {
+		return normalize(values[0])
+	}
+	return ""
+}
+
+// FromRequest returns the device ID of the HTTP request: the device_id cookie,
+// or the X-Device-Id header for clients without cookies.
+func FromRequest(r *http.Request) string {
+	if c, err := r.Cookie(cookie); err == nil && c.Value != "" {
+		return normalize(c.Value)
+	}
+	return normalize(r.Header.Get(header))
+}
+
+func normalize(deviceID string) string {
+	deviceID = strings.TrimSpace(deviceID)
+	if len(deviceID) > maxLen {
+		deviceID = deviceID[:maxLen]
+	}
+	return deviceID
+}
+
+// UnaryServerInterceptor stores the device ID of every call in its context, see FromContext.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(NewContext(ctx, FromIncomingContext(ctx)), req)
+	}
+}
+
+// Middleware stores the device ID of every HTTP request in its context, see FromContext.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), FromRequest(r))))
+	})
+}
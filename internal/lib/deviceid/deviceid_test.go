@@ -0,0 +1,33 @@
+package deviceid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestFromIncomingContext(t *testing.T) {
+	assert.Equal(t, "", FromIncomingContext(context.Background()))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-device-id", " 3f2a9c "))
+	assert.Equal(t, "3f2a9c", FromIncomingContext(ctx))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-device-id", strings.Repeat("a", 1000)))
+	assert.Len(t, FromIncomingContext(ctx), maxLen)
+}
+
+func TestFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, "", FromRequest(req))
+
+	req.Header.Set("X-Device-Id", "from-header")
+	assert.Equal(t, "from-header", FromRequest(req))
+
+	req.AddCookie(&http.Cookie{Name: "device_id", Value: "from-cookie"})
+	assert.Equal(t, "from-cookie", FromRequest(req), "the cookie wins over the header")
+}
@@ -0,0 +1,98 @@
+// Package identifiers detects and normalizes login identifiers: emails, usernames and E.164 phone numbers.
+package identifiers
+
+import (
+	"errors"
+	"regexp"
+	"sso/internal/domain/models"
+	"strings"
+)
+
+var (
+	ErrInvalidEmail    = errors.New("Invalid email")
+	ErrInvalidUsername = errors.New("Invalid username")
+	ErrInvalidPhone    = errors.New("Invalid phone number")
+)
+
+var (
+	// Usernames start with a letter, so they can't be mistaken for phone numbers.
+	usernameRe = regexp.MustCompile(`^[a-z][a-z0-9._-]{2,31}$`)
+	phoneRe    = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+)
+
+// Parse detects the type of login and normalizes it: logins with "@" are emails,
+// the ones starting with "+" are phone numbers and the rest are usernames.
+func Parse(login string) (models.Identifier, error) {
+	login = strings.TrimSpace(login)
+
+	var t models.IdentifierType
+	switch {
+	case strings.Contains(login, "@"):
+		t = models.IdentifierEmail
+	case strings.HasPrefix(login, "+"):
+		t = models.IdentifierPhone
+	default:
+		t = models.IdentifierUsername
+	}
+
+	value, err := Normalize(t, login)
+	if err != nil {
+		return models.Identifier{}, err
+	}
+
+	return models.Identifier{Type: t, Value: value}, nil
+}
+
+// Normalize returns the canonical form of the identifier of the type, the one it is stored and looked up by.
+func Normalize(t models.IdentifierType, value string) (string, error) {
+	switch t {
+	case models.IdentifierEmail:
+		return NormalizeEmail(value)
+	case models.IdentifierUsername:
+		return NormalizeUsername(value)
+	case models.IdentifierPhone:
+		return NormalizePhone(value)
+	default:
+		return "", errors.New("unknown identifier type")
+	}
+}
+
+// NormalizeEmail trims the email and lowercases its domain, the local part is kept as is.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 {
+		return "", ErrInvalidEmail
+	}
+
+	return email[:at+1] + strings.ToLower(email[at+1:]), nil
+}
+
+// NormalizeUsername lowercases the username: 3 to 32 letters, digits, dots, dashes and underscores
+// starting with a letter.
+func NormalizeUsername(username string) (string, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernameRe.MatchString(username) {
+		return "", ErrInvalidUsername
+	}
+
+	return username, nil
+}
+
+// NormalizePhone returns the phone number in E.164 form, dropping the spaces, dashes,
+// dots and parentheses it may be written with. The country code with "+" is required.
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, phone)
+	if !phoneRe.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+
+	return phone, nil
+}
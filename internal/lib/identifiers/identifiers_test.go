@@ -0,0 +1,45 @@
+package identifiers
+
+import (
+	"sso/internal/domain/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		login string
+		want  models.Identifier
+	}{
+		{login: " Alice@Corp.Example ", want: models.Identifier{Type: models.IdentifierEmail, Value: "Alice@corp.example"}},
+		{login: "Alice_01", want: models.Identifier{Type: models.IdentifierUsername, Value: "alice_01"}},
+		{login: "+1 (555) 123-45.67", want: models.Identifier{Type: models.IdentifierPhone, Value: "+15551234567"}},
+	}
+
+	for _, c := range cases {
+		got, err := Parse(c.login)
+		require.NoError(t, err, c.login)
+		assert.Equal(t, c.want, got, c.login)
+	}
+}
+
+func TestParse_FailCases(t *testing.T) {
+	cases := map[string]error{
+		"@corp.example":     ErrInvalidEmail,
+		"alice@":            ErrInvalidEmail,
+		"al":                ErrInvalidUsername,
+		"15551234567":       ErrInvalidUsername,
+		"alice smith":       ErrInvalidUsername,
+		"+0551234567":       ErrInvalidPhone,
+		"+1555":             ErrInvalidPhone,
+		"+1555123456789012": ErrInvalidPhone,
+		"":                  ErrInvalidUsername,
+	}
+
+	for login, want := range cases {
+		_, err := Parse(login)
+		assert.ErrorIs(t, err, want, login)
+	}
+}
@@ -1,20 +1,36 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"sso/internal/domain/models"
-	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	UserID    int64
+	AppID     int
+	Email     string
+	SessionID string
+}
+
+// NewToken issues a token for the session, it expires together with the session.
+// Extra claims are added as is but can't override the standard ones.
+func NewToken(user models.User, app models.App, session models.Session, extra map[string]any) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 
 	claims := token.Claims.(jwt.MapClaims)
+	for name, value := range extra {
+		claims[name] = value
+	}
 	claims["userID"] = user.ID
 	claims["appID"] = app.ID
 	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(duration).Unix()
+	claims["sid"] = session.ID
+	claims["exp"] = session.ExpiresAt.Unix()
 
 	tokenString, err := token.SignedString([]byte(app.Secret))
 	if err != nil {
@@ -23,3 +39,56 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 
 	return tokenString, nil
 }
+
+// ParseToken verifies a token issued by NewToken. The signing secret is looked up
+// by the app ID claim, which is trusted only once the signature checks out.
+// Errors of the secret lookup are returned as is, any other failure is ErrInvalidToken.
+func ParseToken(tokenString string, appSecret func(appID int) (string, error)) (Claims, error) {
+	var lookupErr error
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		appID, ok := claims["appID"].(float64)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+
+		secret, err := appSecret(int(appID))
+		if err != nil {
+			lookupErr = err
+			return nil, err
+		}
+
+		return []byte(secret), nil
+	})
+	if lookupErr != nil {
+		return Claims{}, lookupErr
+	}
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	userID, _ := claims["userID"].(float64)
+	appID, _ := claims["appID"].(float64)
+	email, _ := claims["email"].(string)
+	sessionID, _ := claims["sid"].(string)
+	if userID <= 0 || sessionID == "" {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return Claims{
+		UserID:    int64(userID),
+		AppID:     int(appID),
+		Email:     email,
+		SessionID: sessionID,
+	}, nil
+}
@@ -0,0 +1,183 @@
+// Package ldaptest provides an in-process LDAP server for tests.
+//
+// It understands simple binds and searches, which is enough to exercise a
+// bind-and-search authentication flow without a real directory.
+package ldaptest
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+type Server struct {
+	// URL is the ldap:// address the server listens on.
+	URL string
+
+	listener net.Listener
+	mu       sync.Mutex
+	entries  []Entry
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a random local port holding the given entries.
+func NewServer(entries ...Entry) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("ldaptest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{
+		URL:      "ldap://" + l.Addr().String(),
+		listener: l,
+		entries:  entries,
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+func (s *Server) AddEntry(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			responses = []*ber.Packet{s.bind(op)}
+		case ldap.ApplicationSearchRequest:
+			responses = s.search(op)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			responses = []*ber.Packet{result(ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform)}
+		}
+
+		for _, response := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+			envelope.AppendChild(response)
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) bind(op *ber.Packet) *ber.Packet {
+	dn := op.Children[1].Data.String()
+	password := op.Children[2].Data.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) && entry.Password != "" && entry.Password == password {
+			return result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+		}
+	}
+
+	return result(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials)
+}
+
+// search returns every entry that has at least one attribute value used as an
+// equality assertion in the filter. Filter logic itself is not evaluated.
+func (s *Server) search(op *ber.Packet) []*ber.Packet {
+	filter, err := ldap.DecompileFilter(op.Children[6])
+	if err != nil {
+		return []*ber.Packet{result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var responses []*ber.Packet
+	for _, entry := range s.entries {
+		if !matches(entry, filter) {
+			continue
+		}
+
+		response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "Object Name"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for name, values := range entry.Attributes {
+			attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, value := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+			}
+			attribute.AppendChild(set)
+			attributes.AppendChild(attribute)
+		}
+		response.AppendChild(attributes)
+		responses = append(responses, response)
+	}
+
+	return append(responses, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+}
+
+func matches(entry Entry, filter string) bool {
+	for name, values := range entry.Attributes {
+		for _, value := range values {
+			if strings.Contains(strings.ToLower(filter), strings.ToLower("("+name+"="+ldap.EscapeFilter(value)+")")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func result(tag ber.Tag, code uint16) *ber.Packet {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(code), "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return response
+}
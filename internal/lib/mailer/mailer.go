@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"sso/internal/config"
+	"time"
+)
+
+const (
+	TypeSMTP   = "smtp"
+	TypeOutbox = "outbox"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New creates the mailer selected in config.
+func New(cfg config.MailerConfig) (Mailer, error) {
+	const op = "mailer.New"
+
+	switch cfg.Type {
+	case TypeSMTP:
+		return NewSMTP(cfg.From, cfg.SMTP), nil
+	case TypeOutbox:
+		return NewOutbox(cfg.From, cfg.OutboxPath)
+	default:
+		return nil, fmt.Errorf("%s: unknown mailer type %q", op, cfg.Type)
+	}
+}
+
+// render formats msg as an RFC 5322 plain text message.
+func render(from string, msg Message) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	return b.Bytes()
+}
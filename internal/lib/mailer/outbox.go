@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Outbox writes every message to a .eml file in a local directory instead of sending it.
+// It is meant for development and tests, which can read the messages back.
+type Outbox struct {
+	from string
+	dir  string
+}
+
+func NewOutbox(from string, dir string) (*Outbox, error) {
+	const op = "mailer.NewOutbox"
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Outbox{from: from, dir: dir}, nil
+}
+
+func (m *Outbox) Send(ctx context.Context, msg Message) error {
+	const op = "mailer.Outbox.Send"
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	name := fmt.Sprintf("%d-%s-%s.eml", time.Now().UnixNano(), safeName(msg.To), hex.EncodeToString(suffix))
+	if err := os.WriteFile(filepath.Join(m.dir, name), render(m.from, msg), 0o640); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '@', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
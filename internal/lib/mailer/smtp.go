@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sso/internal/config"
+	"strconv"
+)
+
+// SMTP sends messages through an SMTP relay.
+type SMTP struct {
+	from string
+	cfg  config.SMTPConfig
+}
+
+func NewSMTP(from string, cfg config.SMTPConfig) *SMTP {
+	return &SMTP{from: from, cfg: cfg}
+}
+
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	const op = "mailer.SMTP.Send"
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, string(m.cfg.Password), m.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+
+	// net/smtp has no context support, so the message is sent in the background
+	// and the caller stops waiting once ctx is done.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.from, []string{msg.To}, render(m.from, msg))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
@@ -0,0 +1,130 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+const sha1HexLen = sha1.Size * 2
+
+// BreachedList looks passwords up in a local file of breached password SHA-1 hashes, see
+// config.PasswordPolicyConfig. The file is sorted by hash, so a lookup is a binary search
+// that reads a few lines and does not need the file in memory.
+type BreachedList struct {
+	path string
+}
+
+// OpenBreachedList checks that the file at path can be read.
+func OpenBreachedList(path string) (*BreachedList, error) {
+	const op = "passwordpolicy.OpenBreachedList"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &BreachedList{path: path}, nil
+}
+
+// Contains reports whether the password is in the list.
+func (l *BreachedList) Contains(password string) (bool, error) {
+	const op = "passwordpolicy.BreachedList.Contains"
+
+	sum := sha1.Sum([]byte(password))
+	hash := make([]byte, sha1HexLen)
+	hex.Encode(hash, sum[:])
+	hash = bytes.ToUpper(hash)
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	found, err := search(f, info.Size(), hash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return found, nil
+}
+
+// search finds the line starting with hash in the sorted file of the given size. It bisects
+// byte offsets: the line after an offset is compared, the line the offset falls into is skipped.
+func search(r io.ReaderAt, size int64, hash []byte) (bool, error) {
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := lineAfter(r, size, mid)
+		if err != nil {
+			return false, err
+		}
+		if line == nil {
+			hi = mid
+			continue
+		}
+
+		switch bytes.Compare(lineHash(line), hash) {
+		case 0:
+			return true, nil
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+
+	// The bisection never looks at the first line, it has no line break before it.
+	first, err := lineAt(r, size, 0)
+	if err != nil {
+		return false, err
+	}
+	return first != nil && bytes.Equal(lineHash(first), hash), nil
+}
+
+// lineAfter returns the first full line that starts after offset, nil at the end of the file.
+func lineAfter(r io.ReaderAt, size int64, offset int64) ([]byte, error) {
+	reader := bufio.NewReader(io.NewSectionReader(r, offset, size-offset))
+	skipped, err := reader.ReadBytes('\n')
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lineAt(r, size, offset+int64(len(skipped)))
+}
+
+// lineAt returns the line starting at offset without the line break, nil at the end of the file.
+func lineAt(r io.ReaderAt, size int64, offset int64) ([]byte, error) {
+	if offset >= size {
+		return nil, nil
+	}
+	line, err := bufio.NewReader(io.NewSectionReader(r, offset, size-offset)).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// lineHash returns the hash of a "HASH" or "HASH:count" line in uppercase.
+func lineHash(line []byte) []byte {
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return bytes.ToUpper(bytes.TrimSpace(line))
+}
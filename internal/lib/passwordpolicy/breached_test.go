@@ -0,0 +1,74 @@
+package passwordpolicy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeBreachedList writes the passwords as an ordered-by-hash Pwned Passwords file.
+func writeBreachedList(t *testing.T, passwords ...string) string {
+	t.Helper()
+
+	lines := make([]string, len(passwords))
+	for i, password := range passwords {
+		lines[i] = fmt.Sprintf("%s:%d", sha1Hex(password), i+1)
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600))
+	return path
+}
+
+func TestBreachedList(t *testing.T) {
+	var breached []string
+	for i := 0; i < 500; i++ {
+		breached = append(breached, fmt.Sprintf("breached-%d", i))
+	}
+	list, err := OpenBreachedList(writeBreachedList(t, breached...))
+	require.NoError(t, err)
+
+	for _, password := range breached {
+		found, err := list.Contains(password)
+		require.NoError(t, err)
+		require.True(t, found, password)
+	}
+	for i := 0; i < 500; i++ {
+		found, err := list.Contains(fmt.Sprintf("safe-%d", i))
+		require.NoError(t, err)
+		require.False(t, found)
+	}
+}
+
+func TestBreachedList_SmallFiles(t *testing.T) {
+	list, err := OpenBreachedList(writeBreachedList(t, "only"))
+	require.NoError(t, err)
+	found, err := list.Contains("only")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+	list, err = OpenBreachedList(empty)
+	require.NoError(t, err)
+	found, err = list.Contains("only")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, err = OpenBreachedList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
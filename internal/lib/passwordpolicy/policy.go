@@ -0,0 +1,297 @@
+// Package passwordpolicy decides which passwords users may set. A password is checked for
+// length, the mix of character classes, a zxcvbn-style strength estimate, banned words and
+// a local list of breached passwords, every rule it breaks is reported as a Violation.
+package passwordpolicy
+
+import (
+	"errors"
+	"fmt"
+	"sso/internal/config"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules of the policy, they name the violations.
+const (
+	RuleMinLength        = "min_length"
+	RuleMaxLength        = "max_length"
+	RuleCharacterClasses = "character_classes"
+	RuleStrength         = "strength"
+	RuleBannedWord       = "banned_word"
+	RuleBreached         = "breached"
+)
+
+// minBannedInputLen is the shortest user input that is banned, shorter email local parts are too common.
+const minBannedInputLen = 4
+
+var ErrViolated = errors.New("Password does not meet the policy")
+
+// Violation is a rule the password breaks with a message for the user.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// ViolationError lists every rule the password breaks, it wraps ErrViolated.
+type ViolationError struct {
+	Violations []Violation
+}
+
+func (e *ViolationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrViolated, strings.Join(messages, "; "))
+}
+
+func (e *ViolationError) Unwrap() error {
+	return ErrViolated
+}
+
+// Policy is the set of rules of one app.
+type Policy struct {
+	rules    config.PasswordRulesConfig
+	banned   []string
+	breached *BreachedList
+	// maxBytes is the longest password in bytes the password hasher takes, 0 for no limit.
+	maxBytes int
+}
+
+// Policies holds the default policy and the ones of the apps that override it.
+// A nil Policies accepts any password.
+type Policies struct {
+	defaultPolicy *Policy
+	apps          map[int32]*Policy
+}
+
+// New makes the policies of the config, maxBytes caps the length of the passwords in bytes
+// on top of max_length for hashers that can't take longer ones, see passwords.Hasher.MaxPasswordBytes.
+func New(cfg config.PasswordPolicyConfig, maxBytes int) (*Policies, error) {
+	const op = "passwordpolicy.New"
+
+	var breached *BreachedList
+	if cfg.BreachedPasswordsFile != "" {
+		var err error
+		breached, err = OpenBreachedList(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	defaultPolicy, err := newPolicy(cfg.PasswordRulesConfig, breached, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: default: %w", op, err)
+	}
+	p := &Policies{
+		defaultPolicy: defaultPolicy,
+		apps:          make(map[int32]*Policy, len(cfg.Apps)),
+	}
+
+	for _, app := range cfg.Apps {
+		if _, ok := p.apps[app.AppID]; ok {
+			return nil, fmt.Errorf("%s: duplicated app %d", op, app.AppID)
+		}
+		p.apps[app.AppID], err = newPolicy(overrideRules(cfg.PasswordRulesConfig, app), breached, maxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: app %d: %w", op, app.AppID, err)
+		}
+	}
+
+	return p, nil
+}
+
+func overrideRules(rules config.PasswordRulesConfig, app config.AppPasswordPolicyConfig) config.PasswordRulesConfig {
+	if app.MinLength != nil {
+		rules.MinLength = *app.MinLength
+	}
+	if app.MaxLength != nil {
+		rules.MaxLength = *app.MaxLength
+	}
+	if app.MinCharacterClasses != nil {
+		rules.MinCharacterClasses = *app.MinCharacterClasses
+	}
+	if app.MinStrength != nil {
+		rules.MinStrength = *app.MinStrength
+	}
+	if app.RejectBreached != nil {
+		rules.RejectBreached = *app.RejectBreached
+	}
+	rules.BannedWords = append(append([]string(nil), rules.BannedWords...), app.BannedWords...)
+	return rules
+}
+
+func newPolicy(rules config.PasswordRulesConfig, breached *BreachedList, maxBytes int) (*Policy, error) {
+	switch {
+	case rules.MinLength < 1:
+		return nil, fmt.Errorf("min_length must be positive")
+	case rules.MaxLength < rules.MinLength:
+		return nil, fmt.Errorf("max_length is less than min_length")
+	case rules.MinCharacterClasses < 0 || rules.MinCharacterClasses > 4:
+		return nil, fmt.Errorf("min_character_classes must be from 0 to 4")
+	case rules.MinStrength < 0 || rules.MinStrength > 4:
+		return nil, fmt.Errorf("min_strength must be from 0 to 4")
+	}
+
+	p := &Policy{rules: rules, maxBytes: maxBytes}
+	for _, word := range rules.BannedWords {
+		if word = normalize(word); word != "" {
+			p.banned = append(p.banned, word)
+		}
+	}
+	if rules.RejectBreached {
+		p.breached = breached
+	}
+
+	return p, nil
+}
+
+// For returns the policy of the app, appID 0 or an app without its own policy gets the default one.
+func (p *Policies) For(appID int32) *Policy {
+	if p == nil {
+		return nil
+	}
+	if policy, ok := p.apps[appID]; ok {
+		return policy
+	}
+	return p.defaultPolicy
+}
+
+// Check checks the password against the policy of the app, see Policy.Check.
+func (p *Policies) Check(appID int32, password string, userInputs ...string) error {
+	return p.For(appID).Check(password, userInputs...)
+}
+
+// Check returns a ViolationError listing the rules the password breaks, or an error
+// if the breached password list can't be read. userInputs are the email, the username
+// and other data of the user the password must not be based on.
+func (p *Policy) Check(password string, userInputs ...string) error {
+	if p == nil {
+		return nil
+	}
+
+	var violations []Violation
+	length := utf8.RuneCountInString(password)
+	if length < p.rules.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.rules.MinLength),
+		})
+	}
+	if length > p.rules.MaxLength {
+		// Longer passwords are not checked further, the strength estimate gets slow.
+		violations = append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d characters long", p.rules.MaxLength),
+		})
+		return &ViolationError{Violations: violations}
+	}
+	if p.maxBytes > 0 && len(password) > p.maxBytes {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", p.maxBytes),
+		})
+		return &ViolationError{Violations: violations}
+	}
+
+	if classes := characterClasses(password); classes < p.rules.MinCharacterClasses {
+		violations = append(violations, Violation{
+			Rule: RuleCharacterClasses,
+			Message: fmt.Sprintf(
+				"Password must mix at least %d of lowercase letters, uppercase letters, digits and symbols",
+				p.rules.MinCharacterClasses,
+			),
+		})
+	}
+
+	inputs := userWords(userInputs)
+	if word, ok := p.bannedWord(password, inputs); ok {
+		violations = append(violations, Violation{
+			Rule:    RuleBannedWord,
+			Message: fmt.Sprintf("Password must not contain %q", word),
+		})
+	}
+
+	if Score(password, append(inputs, p.banned...)...) < p.rules.MinStrength {
+		violations = append(violations, Violation{
+			Rule:    RuleStrength,
+			Message: "Password is too easy to guess",
+		})
+	}
+
+	if p.breached != nil {
+		breached, err := p.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, Violation{
+				Rule:    RuleBreached,
+				Message: "Password appeared in a data breach",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// bannedWord returns the banned word or user input the password contains.
+func (p *Policy) bannedWord(password string, inputs []string) (string, bool) {
+	lower := strings.ToLower(password)
+	unleeted := leet.Replace(lower)
+	for _, words := range [][]string{inputs, p.banned} {
+		for _, word := range words {
+			if strings.Contains(lower, word) || strings.Contains(unleeted, word) {
+				return word, true
+			}
+		}
+	}
+	return "", false
+}
+
+// userWords returns the user inputs worth banning, of an email only the local part is.
+func userWords(userInputs []string) []string {
+	var words []string
+	for _, input := range userInputs {
+		input = normalize(input)
+		if local, _, ok := strings.Cut(input, "@"); ok {
+			input = local
+		}
+		if utf8.RuneCountInString(input) >= minBannedInputLen {
+			words = append(words, input)
+		}
+	}
+	return words
+}
+
+func normalize(word string) string {
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
@@ -0,0 +1,151 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"sso/internal/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func defaultRules() config.PasswordRulesConfig {
+	return config.PasswordRulesConfig{
+		MinLength:      8,
+		MaxLength:      64,
+		MinStrength:    2,
+		BannedWords:    []string{"Acme"},
+		RejectBreached: true,
+	}
+}
+
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var violation *ViolationError
+	require.True(t, errors.As(err, &violation), "unexpected error: %v", err)
+	require.ErrorIs(t, err, ErrViolated)
+
+	rules := make([]string, len(violation.Violations))
+	for i, v := range violation.Violations {
+		rules[i] = v.Rule
+	}
+	return rules
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		password string
+		maxScore int
+		minScore int
+	}{
+		{password: "password", maxScore: 0},
+		{password: "P@ssw0rd", maxScore: 1},
+		{password: "qwertyuiop", maxScore: 1},
+		{password: "abcdefgh", maxScore: 1},
+		{password: "aaaaaaaaaaaa", maxScore: 1},
+		{password: "1987198719871987", maxScore: 1},
+		{password: "iloveyou2024", maxScore: 2},
+		{password: "xK9#mQ2$vL", minScore: 4, maxScore: 4},
+		{password: "correct horse battery staple", minScore: 4, maxScore: 4},
+	}
+
+	for _, tt := range tests {
+		score := Score(tt.password)
+		assert.GreaterOrEqual(t, score, tt.minScore, tt.password)
+		assert.LessOrEqual(t, score, tt.maxScore, tt.password)
+	}
+
+	assert.Less(t, Score("johnsmith1", "johnsmith"), Score("johnsmith1"), "user inputs are guessed first")
+}
+
+func TestPolicy_Check(t *testing.T) {
+	rules := defaultRules()
+	rules.MinCharacterClasses = 3
+	policies, err := New(config.PasswordPolicyConfig{
+		PasswordRulesConfig:   rules,
+		BreachedPasswordsFile: writeBreachedList(t, "Breached-Passw0rd!"),
+	}, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "strong", password: "Vq7-mountain-Kettle", want: nil},
+		{name: "short", password: "Xy7!a", want: []string{RuleMinLength, RuleStrength}},
+		{name: "long", password: "Vq7-" + string(make([]byte, 70)), want: []string{RuleMaxLength}},
+		{name: "one class", password: "zqwxkvbnmlpj", want: []string{RuleCharacterClasses}},
+		{name: "common", password: "Password1", want: []string{RuleStrength}},
+		{name: "banned word", password: "Acm3-Rocket-91", want: []string{RuleBannedWord}},
+		{name: "email local part", password: "J.Doe-Rocket-91", want: []string{RuleBannedWord}},
+		{name: "breached", password: "Breached-Passw0rd!", want: []string{RuleBreached}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policies.Check(0, tt.password, "j.doe@example.com", "jdoe")
+			assert.Equal(t, tt.want, violatedRules(t, err))
+		})
+	}
+}
+
+func TestPolicies_AppOverride(t *testing.T) {
+	minLength, rejectBreached := 12, false
+	policies, err := New(config.PasswordPolicyConfig{
+		PasswordRulesConfig:   defaultRules(),
+		BreachedPasswordsFile: writeBreachedList(t, "Breached-Passw0rd!"),
+		Apps: []config.AppPasswordPolicyConfig{{
+			AppID:          2,
+			MinLength:      &minLength,
+			BannedWords:    []string{"rocket"},
+			RejectBreached: &rejectBreached,
+		}},
+	}, 0)
+	require.NoError(t, err)
+
+	assert.NoError(t, policies.Check(1, "Vq7-Rocket"))
+	assert.Equal(t, []string{RuleMinLength, RuleBannedWord, RuleStrength}, violatedRules(t, policies.Check(2, "Vq7-Rocket")))
+	assert.Equal(t, []string{RuleBannedWord}, violatedRules(t, policies.Check(2, "Vq7-Acme-Kettle")), "default banned words apply")
+	assert.NoError(t, policies.Check(2, "Breached-Passw0rd!"))
+	assert.Equal(t, []string{RuleBreached}, violatedRules(t, policies.Check(1, "Breached-Passw0rd!")))
+
+	var nilPolicies *Policies
+	assert.NoError(t, nilPolicies.Check(1, "x"))
+}
+
+func TestPolicy_CheckMaxBytes(t *testing.T) {
+	rules := defaultRules()
+	rules.MaxLength = 128
+	policies, err := New(config.PasswordPolicyConfig{PasswordRulesConfig: rules}, 72)
+	require.NoError(t, err)
+
+	password := strings.Repeat("Vq7-mountain-Kettle ", 5)
+	require.Len(t, password, 100)
+	assert.Equal(t, []string{RuleMaxLength}, violatedRules(t, policies.Check(0, password)), "bcrypt takes at most 72 bytes")
+	assert.NoError(t, policies.Check(0, password[:72]))
+	assert.Equal(t, []string{RuleMaxLength}, violatedRules(t, policies.Check(0, strings.Repeat("Ключ-Чайник-7 ", 4))), "the limit is in bytes")
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	rules := defaultRules()
+	rules.MaxLength = 4
+	_, err := New(config.PasswordPolicyConfig{PasswordRulesConfig: rules}, 0)
+	assert.Error(t, err)
+
+	rules = defaultRules()
+	rules.MinStrength = 5
+	_, err = New(config.PasswordPolicyConfig{PasswordRulesConfig: rules}, 0)
+	assert.Error(t, err)
+
+	_, err = New(config.PasswordPolicyConfig{
+		PasswordRulesConfig: defaultRules(),
+		Apps:                []config.AppPasswordPolicyConfig{{AppID: 1}, {AppID: 1}},
+	}, 0)
+	assert.Error(t, err)
+}
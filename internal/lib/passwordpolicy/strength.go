@@ -0,0 +1,239 @@
+package passwordpolicy
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// The strength estimate follows zxcvbn: the password is split into the cheapest sequence
+// of patterns an attacker would try (dictionary words, keyboard walks, sequences, repeats,
+// years) and characters guessed by brute force, the guesses of the parts are multiplied.
+// Everything is kept as log10 of the number of guesses.
+
+//go:embed common.txt
+var commonPasswords string
+
+// commonRanks maps the most common passwords to their rank, a password of rank n takes n guesses.
+var commonRanks = func() map[string]int {
+	ranks := make(map[string]int)
+	for i, word := range strings.Fields(commonPasswords) {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}()
+
+// keyboardRows are the walks along a qwerty keyboard, both directions are checked.
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm", "qazwsxedcrfvtgbyhnujmikolp"}
+
+// leet maps the substitutions undone before the dictionary lookup.
+var leet = strings.NewReplacer("4", "a", "@", "a", "8", "b", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t")
+
+const (
+	minMatchLen        = 3
+	bruteforceLog10    = 1 // log10 of the guesses per brute forced character
+	minYear, maxYear   = 1900, 2050
+	referenceYear      = 2025
+	minYearSpaceLog10  = 1.3 // at least 20 years around the reference year
+	dictionaryCaseCost = math.Ln2 / math.Ln10
+)
+
+// Score rates how hard the password is to guess, from 0 (in a few tries) to 4 (very unguessable),
+// with the thresholds of zxcvbn. userInputs, like the email and the username of the user,
+// are checked as the most likely dictionary words.
+func Score(password string, userInputs ...string) int {
+	guesses := guessesLog10(password, userInputs)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	}
+	return 4
+}
+
+// guessesLog10 returns log10 of the guesses of the cheapest split of the password into patterns.
+func guessesLog10(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+
+	ranks := make(map[string]int, len(userInputs))
+	for i, input := range userInputs {
+		if input = strings.ToLower(input); len([]rune(input)) >= minMatchLen {
+			ranks[input] = i + 1
+		}
+	}
+	matches := findMatches(runes, ranks)
+
+	// best[j] is the cheapest guess count of the first j characters.
+	best := make([]float64, n+1)
+	for j := 1; j <= n; j++ {
+		best[j] = best[j-1] + bruteforceLog10
+		for _, m := range matches[j-1] {
+			best[j] = min(best[j], best[m.start]+m.guesses)
+		}
+	}
+
+	return best[n]
+}
+
+// match is a pattern covering the characters from start to the end it is indexed by.
+type match struct {
+	start   int
+	guesses float64
+}
+
+// findMatches returns the patterns in the password indexed by their last character.
+func findMatches(runes []rune, userRanks map[string]int) [][]match {
+	n := len(runes)
+	matches := make([][]match, n)
+	add := func(start, end int, guesses float64) {
+		matches[end] = append(matches[end], match{start: start, guesses: guesses})
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + minMatchLen; j <= n; j++ {
+			token := runes[i:j]
+			if guesses, ok := dictionaryGuesses(token, userRanks); ok {
+				add(i, j-1, guesses)
+			}
+			if guesses, ok := yearGuesses(token); ok {
+				add(i, j-1, guesses)
+			}
+			if guesses, ok := repeatGuesses(token); ok {
+				add(i, j-1, guesses)
+			}
+			if guesses, ok := sequenceGuesses(token); ok {
+				add(i, j-1, guesses)
+			}
+			if guesses, ok := keyboardGuesses(token); ok {
+				add(i, j-1, guesses)
+			}
+		}
+	}
+
+	return matches
+}
+
+func dictionaryGuesses(token []rune, userRanks map[string]int) (float64, bool) {
+	word := strings.ToLower(string(token))
+	guesses, found := math.Inf(1), false
+	for _, candidate := range []struct {
+		word  string
+		extra float64
+	}{
+		{word, 0},
+		{reverse(word), dictionaryCaseCost},
+		{leet.Replace(word), dictionaryCaseCost},
+	} {
+		rank, ok := userRanks[candidate.word]
+		if !ok {
+			rank, ok = commonRanks[candidate.word]
+		}
+		if ok {
+			found = true
+			guesses = min(guesses, math.Log10(float64(rank))+candidate.extra)
+		}
+	}
+	if !found {
+		return 0, false
+	}
+
+	if word != string(token) {
+		guesses += dictionaryCaseCost
+	}
+	return max(guesses, 1), true
+}
+
+func yearGuesses(token []rune) (float64, bool) {
+	if len(token) != 4 {
+		return 0, false
+	}
+	year := 0
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+		year = year*10 + int(r-'0')
+	}
+	if year < minYear || year > maxYear {
+		return 0, false
+	}
+	return max(math.Log10(math.Abs(float64(year-referenceYear))), minYearSpaceLog10), true
+}
+
+// repeatGuesses matches a block repeated at least twice, "aaa" or "abcabc".
+func repeatGuesses(token []rune) (float64, bool) {
+	n := len(token)
+	for size := 1; size <= n/2; size++ {
+		if n%size != 0 {
+			continue
+		}
+		repeated := true
+		for i := size; i < n && repeated; i++ {
+			repeated = token[i] == token[i-size]
+		}
+		if repeated {
+			block := guessesLog10(string(token[:size]), nil)
+			return block + math.Log10(float64(n/size)), true
+		}
+	}
+	return 0, false
+}
+
+// sequenceGuesses matches characters with a constant step of 1 or 2, "abcd", "9753".
+func sequenceGuesses(token []rune) (float64, bool) {
+	step := token[1] - token[0]
+	if step == 0 || step < -2 || step > 2 {
+		return 0, false
+	}
+	for i := 2; i < len(token); i++ {
+		if token[i]-token[i-1] != step {
+			return 0, false
+		}
+	}
+
+	base := 26.0
+	switch first := token[0]; {
+	case first == 'a' || first == 'z' || first == '0' || first == '1' || first == '9':
+		base = 4
+	case unicode.IsDigit(first):
+		base = 10
+	case unicode.IsUpper(first):
+		base = 52
+	}
+	guesses := math.Log10(base * float64(len(token)))
+	if step < 0 {
+		guesses += math.Log10(2)
+	}
+	return guesses, true
+}
+
+// keyboardGuesses matches a walk along a keyboard row, "qwerty", "lkjh".
+func keyboardGuesses(token []rune) (float64, bool) {
+	walk := strings.ToLower(string(token))
+	for _, row := range keyboardRows {
+		if strings.Contains(row, walk) || strings.Contains(row, reverse(walk)) {
+			return math.Log10(float64(len(row)) * float64(len(token))), true
+		}
+	}
+	return 0, false
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
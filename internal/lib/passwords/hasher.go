@@ -0,0 +1,160 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"sso/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms new hashes can be made with, see config.PasswordHashingConfig.
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// bcryptMaxPasswordLen is the longest password in bytes bcrypt takes, it rejects longer ones.
+const bcryptMaxPasswordLen = 72
+
+// Bounds of the argon2 parameters besides the limits of imported hashes, below
+// them argon2 is either undefined or too weak to be worth it.
+const (
+	minArgon2SaltLen = 8
+	minArgon2KeyLen  = 16
+)
+
+var ErrInvalidConfig = errors.New("Invalid password hashing config")
+
+// Hasher makes password hashes with the configured algorithm, parameters and pepper, all are
+// stored in the hash, and tells which stored hashes are made differently and need a rehash.
+type Hasher struct {
+	algorithm string
+	argon2    config.Argon2Config
+	bcrypt    config.BcryptConfig
+	// peppers are the pepper keys by ID, new hashes are peppered with pepperKeyID.
+	peppers     map[string][]byte
+	pepperKeyID string
+}
+
+func NewHasher(cfg config.PasswordHashingConfig) (*Hasher, error) {
+	switch cfg.Algorithm {
+	case AlgorithmArgon2id:
+		a := cfg.Argon2
+		switch {
+		case a.Parallelism < 1 || a.Parallelism > 255:
+			return nil, fmt.Errorf("%w: argon2 parallelism must be from 1 to 255", ErrInvalidConfig)
+		case a.Memory < 8*a.Parallelism || a.Memory > maxArgon2Memory:
+			return nil, fmt.Errorf("%w: argon2 memory must be from %d to %d KiB", ErrInvalidConfig, 8*a.Parallelism, maxArgon2Memory)
+		case a.Iterations < 1 || a.Iterations > maxArgon2Time:
+			return nil, fmt.Errorf("%w: argon2 iterations must be from 1 to %d", ErrInvalidConfig, maxArgon2Time)
+		case a.SaltLength < minArgon2SaltLen:
+			return nil, fmt.Errorf("%w: argon2 salt must be at least %d bytes", ErrInvalidConfig, minArgon2SaltLen)
+		case a.KeyLength < minArgon2KeyLen || a.KeyLength > maxKeyLen:
+			return nil, fmt.Errorf("%w: argon2 key must be from %d to %d bytes", ErrInvalidConfig, minArgon2KeyLen, maxKeyLen)
+		}
+	case AlgorithmBcrypt:
+		if cfg.Bcrypt.Cost < bcrypt.MinCost || cfg.Bcrypt.Cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("%w: bcrypt cost must be from %d to %d", ErrInvalidConfig, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidConfig, cfg.Algorithm)
+	}
+
+	peppers, err := loadPeppers(cfg.Pepper)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Hasher{
+		algorithm:   cfg.Algorithm,
+		argon2:      cfg.Argon2,
+		bcrypt:      cfg.Bcrypt,
+		peppers:     peppers,
+		pepperKeyID: cfg.Pepper.CurrentKeyID,
+	}, nil
+}
+
+// Hash hashes the password with the configured algorithm and the current pepper.
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	if h.pepperKeyID == "" {
+		return h.hash(password)
+	}
+
+	hash, err := h.hash(pepperPassword(h.peppers[h.pepperKeyID], password))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(pepperPrefix+h.pepperKeyID), hash...), nil
+}
+
+func (h *Hasher) hash(password string) ([]byte, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		return bcrypt.GenerateFromPassword([]byte(password), h.bcrypt.Cost)
+	}
+
+	a := h.argon2
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey([]byte(password), salt, uint32(a.Iterations), uint32(a.Memory), uint8(a.Parallelism), uint32(a.KeyLength))
+
+	return []byte(fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+// MaxPasswordBytes returns the longest password in bytes the hasher takes, 0 for no limit.
+// A peppered password is hashed as its HMAC of a fixed length, so only bcrypt without a pepper is limited.
+func (h *Hasher) MaxPasswordBytes() int {
+	if h.algorithm == AlgorithmBcrypt && h.pepperKeyID == "" {
+		return bcryptMaxPasswordLen
+	}
+	return 0
+}
+
+// Compare checks the password against a hash of any of the supported types, see Compare,
+// peppered with any of the configured keys.
+func (h *Hasher) Compare(hash []byte, password string) error {
+	keyID, hash := splitPepper(hash)
+	if keyID == "" {
+		return Compare(hash, password)
+	}
+
+	key, ok := h.peppers[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownPepper, keyID)
+	}
+	return Compare(hash, pepperPassword(key, password))
+}
+
+// NeedsRehash reports whether the hash is made with another algorithm, other parameters
+// or another pepper than the configured ones, including imported hashes of other types.
+func (h *Hasher) NeedsRehash(hash []byte) bool {
+	keyID, hash := splitPepper(hash)
+	if keyID != h.pepperKeyID {
+		return true
+	}
+
+	parsed, err := parse(string(hash))
+	if err != nil {
+		return true
+	}
+
+	switch h.algorithm {
+	case AlgorithmBcrypt:
+		return parsed.typ != HashBcrypt || parsed.params["cost"] != h.bcrypt.Cost
+	default:
+		a := h.argon2
+		return parsed.id != AlgorithmArgon2id || !maps.Equal(parsed.params, map[string]int{
+			"m": a.Memory, "t": a.Iterations, "p": a.Parallelism, "salt": a.SaltLength, "key": a.KeyLength,
+		})
+	}
+}
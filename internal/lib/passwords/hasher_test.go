@@ -0,0 +1,128 @@
+package passwords
+
+import (
+	"sso/internal/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func argon2Config() config.PasswordHashingConfig {
+	return config.PasswordHashingConfig{
+		Algorithm: AlgorithmArgon2id,
+		Argon2:    config.Argon2Config{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		Bcrypt:    config.BcryptConfig{Cost: bcrypt.MinCost},
+	}
+}
+
+func bcryptHasher(t *testing.T) *Hasher {
+	t.Helper()
+
+	cfg := argon2Config()
+	cfg.Algorithm = AlgorithmBcrypt
+	h, err := NewHasher(cfg)
+	require.NoError(t, err)
+	return h
+}
+
+func TestHasher_Argon2id(t *testing.T) {
+	h, err := NewHasher(argon2Config())
+	require.NoError(t, err)
+
+	hash, err := h.Hash(password)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$argon2id$v=19$m=1024,t=1,p=1$"), string(hash))
+	assert.NoError(t, h.Compare(hash, password))
+	assert.ErrorIs(t, h.Compare(hash, "wrong password"), ErrMismatch)
+	assert.False(t, h.NeedsRehash(hash))
+
+	again, err := h.Hash(password)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again, "every hash has its own salt")
+
+	stored, err := Import(HashArgon2, string(hash))
+	require.NoError(t, err, "the hashes can be imported into another instance")
+	assert.Equal(t, hash, stored)
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	argon2Hasher, err := NewHasher(argon2Config())
+	require.NoError(t, err)
+	argon2Hash, err := argon2Hasher.Hash(password)
+	require.NoError(t, err)
+	bcryptHash, err := bcryptHasher(t).Hash(password)
+	require.NoError(t, err)
+
+	stronger := argon2Config()
+	stronger.Argon2.Iterations = 2
+	strongerHasher, err := NewHasher(stronger)
+	require.NoError(t, err)
+
+	costlier := argon2Config()
+	costlier.Algorithm = AlgorithmBcrypt
+	costlier.Bcrypt.Cost = bcrypt.MinCost + 1
+	costlierHasher, err := NewHasher(costlier)
+	require.NoError(t, err)
+
+	assert.True(t, argon2Hasher.NeedsRehash(bcryptHash), "other algorithm")
+	assert.True(t, bcryptHasher(t).NeedsRehash(argon2Hash), "other algorithm")
+	assert.True(t, strongerHasher.NeedsRehash(argon2Hash), "other argon2 parameters")
+	assert.True(t, costlierHasher.NeedsRehash(bcryptHash), "other bcrypt cost")
+	assert.False(t, argon2Hasher.NeedsRehash([]byte(foreignHashes(t)[HashArgon2])), "imported argon2id with the same parameters")
+	assert.True(t, argon2Hasher.NeedsRehash([]byte(foreignHashes(t)[HashScrypt])), "imported hash")
+	assert.True(t, argon2Hasher.NeedsRehash([]byte("not a hash")))
+}
+
+func TestHasher_MaxPasswordBytes(t *testing.T) {
+	long := strings.Repeat("long password ", 8)[:100]
+
+	h := bcryptHasher(t)
+	assert.Equal(t, 72, h.MaxPasswordBytes())
+	_, err := h.Hash(long)
+	assert.Error(t, err, "the policy has to reject what bcrypt can't hash")
+
+	argon2Hasher, err := NewHasher(argon2Config())
+	require.NoError(t, err)
+	assert.Zero(t, argon2Hasher.MaxPasswordBytes())
+
+	t.Setenv("TEST_PEPPER", pepperKey(t))
+	cfg := pepperedConfig("1", config.PepperKeyConfig{ID: "1", Env: "TEST_PEPPER"})
+	cfg.Algorithm = AlgorithmBcrypt
+	peppered, err := NewHasher(cfg)
+	require.NoError(t, err)
+	assert.Zero(t, peppered.MaxPasswordBytes(), "the pepper HMAC has a fixed length")
+	hash, err := peppered.Hash(long)
+	require.NoError(t, err)
+	assert.NoError(t, peppered.Compare(hash, long))
+}
+
+func TestNewHasher_FailCases(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(cfg *config.PasswordHashingConfig)
+	}{
+		{name: "Unknown algorithm", modify: func(cfg *config.PasswordHashingConfig) { cfg.Algorithm = "md5" }},
+		{name: "Argon2 memory", modify: func(cfg *config.PasswordHashingConfig) { cfg.Argon2.Memory = 4 }},
+		{name: "Argon2 memory limit", modify: func(cfg *config.PasswordHashingConfig) { cfg.Argon2.Memory = maxArgon2Memory + 1 }},
+		{name: "Argon2 iterations", modify: func(cfg *config.PasswordHashingConfig) { cfg.Argon2.Iterations = 0 }},
+		{name: "Argon2 parallelism", modify: func(cfg *config.PasswordHashingConfig) { cfg.Argon2.Parallelism = 256 }},
+		{name: "Argon2 salt", modify: func(cfg *config.PasswordHashingConfig) { cfg.Argon2.SaltLength = 4 }},
+		{name: "Argon2 key", modify: func(cfg *config.PasswordHashingConfig) { cfg.Argon2.KeyLength = 8 }},
+		{name: "Bcrypt cost", modify: func(cfg *config.PasswordHashingConfig) {
+			cfg.Algorithm = AlgorithmBcrypt
+			cfg.Bcrypt.Cost = bcrypt.MaxCost + 1
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := argon2Config()
+			c.modify(&cfg)
+			_, err := NewHasher(cfg)
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+		})
+	}
+}
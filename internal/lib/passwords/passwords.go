@@ -0,0 +1,326 @@
+// Package passwords makes and checks password hashes: the argon2id or bcrypt hashes the service
+// creates with a Hasher, optionally peppered, and the hashes users are imported with from other
+// systems, which are kept as is until the first successful login.
+//
+// Imported hashes are stored in these forms, the salts and keys of the "$" separated ones are base64
+// with or without padding ("." may stand for "+" as in passlib):
+//
+//	bcrypt  $2a$10$...  ($2b$ and $2y$ too)
+//	argon2  $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>  ($argon2i$ too)
+//	scrypt  $scrypt$ln=15,r=8,p=1$<salt>$<key>
+//	pbkdf2  $pbkdf2-sha256$i=600000$<salt>$<key>  (sha1 and sha512 too)
+//	sha     $sha256$<salt>$<hex of sha256(salt + password)>  (sha1 and sha512 too)
+package passwords
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+type HashType string
+
+const (
+	HashBcrypt    HashType = "bcrypt"
+	HashArgon2    HashType = "argon2"
+	HashScrypt    HashType = "scrypt"
+	HashPBKDF2    HashType = "pbkdf2"
+	HashSaltedSHA HashType = "sha"
+)
+
+func (t HashType) Valid() bool {
+	switch t {
+	case HashBcrypt, HashArgon2, HashScrypt, HashPBKDF2, HashSaltedSHA:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrUnknownHashType = errors.New("Unknown hash type")
+	ErrInvalidHash     = errors.New("Invalid password hash")
+	ErrMismatch        = errors.New("Password does not match")
+)
+
+// Limits on the cost parameters of imported hashes, so a crafted hash can't make a login
+// take all the memory or CPU of the server.
+const (
+	maxArgon2Memory     = 1 << 20 // KiB
+	maxArgon2Time       = 64
+	maxScryptLogN       = 20
+	maxScryptRP         = 1 << 10
+	maxScryptMemory     = 1 << 30 // bytes
+	maxPBKDF2Iterations = 10_000_000
+	maxKeyLen           = 128
+)
+
+// Import checks that the hash is a well-formed hash of the type and returns it the way it is stored.
+func Import(t HashType, hash string) ([]byte, error) {
+	if !t.Valid() {
+		return nil, ErrUnknownHashType
+	}
+
+	hash = strings.TrimSpace(hash)
+	parsed, err := parse(hash)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.typ != t {
+		return nil, fmt.Errorf("%w: not a %s hash", ErrInvalidHash, t)
+	}
+
+	return []byte(hash), nil
+}
+
+// Compare checks the password against a hash of any of the supported types.
+func Compare(hash []byte, password string) error {
+	parsed, err := parse(string(hash))
+	if err != nil {
+		return err
+	}
+	if !parsed.match([]byte(password)) {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+type parsedHash struct {
+	typ HashType
+	// id is the scheme of the hash, as "argon2id" or "2a".
+	id string
+	// params are the cost parameters a Hasher compares with its own.
+	params map[string]int
+	match  func(password []byte) bool
+}
+
+func parse(hash string) (parsedHash, error) {
+	fields := strings.Split(hash, "$")
+	if len(fields) < 3 || fields[0] != "" {
+		return parsedHash{}, ErrInvalidHash
+	}
+
+	var (
+		parsed parsedHash
+		err    error
+	)
+	switch id := fields[1]; id {
+	case "2a", "2b", "2y":
+		parsed, err = parseBcrypt(hash)
+	case "argon2id", "argon2i":
+		parsed, err = parseArgon2(id, fields[2:])
+	case "scrypt":
+		parsed, err = parseScrypt(fields[2:])
+	case "pbkdf2-sha1", "pbkdf2-sha256", "pbkdf2-sha512":
+		parsed, err = parsePBKDF2(strings.TrimPrefix(id, "pbkdf2-"), fields[2:])
+	case "sha1", "sha256", "sha512":
+		parsed, err = parseSaltedSHA(id, fields[2:])
+	default:
+		return parsedHash{}, fmt.Errorf("%w: unknown scheme %q", ErrInvalidHash, id)
+	}
+	if err != nil {
+		return parsedHash{}, fmt.Errorf("%w: %s", ErrInvalidHash, err)
+	}
+
+	return parsed, nil
+}
+
+func parseBcrypt(hash string) (parsedHash, error) {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return parsedHash{}, err
+	}
+
+	return parsedHash{
+		typ:    HashBcrypt,
+		id:     strings.Split(hash, "$")[1],
+		params: map[string]int{"cost": cost},
+		match: func(password []byte) bool {
+			return bcrypt.CompareHashAndPassword([]byte(hash), password) == nil
+		},
+	}, nil
+}
+
+func parseArgon2(id string, fields []string) (parsedHash, error) {
+	if len(fields) == 4 {
+		if fields[0] != "v=19" {
+			return parsedHash{}, fmt.Errorf("unsupported argon2 version %q", fields[0])
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 3 {
+		return parsedHash{}, errors.New("malformed argon2 hash")
+	}
+
+	p, err := params(fields[0], "m", "t", "p")
+	if err != nil {
+		return parsedHash{}, err
+	}
+	if p["m"] > maxArgon2Memory || p["t"] > maxArgon2Time || p["p"] > 255 {
+		return parsedHash{}, errors.New("argon2 parameters are too large")
+	}
+	salt, key, err := saltAndKey(fields[1], fields[2])
+	if err != nil {
+		return parsedHash{}, err
+	}
+
+	derive := argon2.IDKey
+	if id == "argon2i" {
+		derive = argon2.Key
+	}
+
+	return parsedHash{
+		typ:    HashArgon2,
+		id:     id,
+		params: map[string]int{"m": p["m"], "t": p["t"], "p": p["p"], "salt": len(salt), "key": len(key)},
+		match: func(password []byte) bool {
+			derived := derive(password, salt, uint32(p["t"]), uint32(p["m"]), uint8(p["p"]), uint32(len(key)))
+			return subtle.ConstantTimeCompare(derived, key) == 1
+		},
+	}, nil
+}
+
+func parseScrypt(fields []string) (parsedHash, error) {
+	if len(fields) != 3 {
+		return parsedHash{}, errors.New("malformed scrypt hash")
+	}
+
+	p, err := params(fields[0], "ln", "r", "p")
+	if err != nil {
+		return parsedHash{}, err
+	}
+	if p["ln"] > maxScryptLogN || p["r"] > maxScryptRP || p["p"] > maxScryptRP ||
+		p["r"]*p["p"] > maxScryptRP || 128*p["r"]<<p["ln"] > maxScryptMemory {
+		return parsedHash{}, errors.New("scrypt parameters are too large")
+	}
+	salt, key, err := saltAndKey(fields[1], fields[2])
+	if err != nil {
+		return parsedHash{}, err
+	}
+
+	return parsedHash{
+		typ: HashScrypt,
+		id:  "scrypt",
+		match: func(password []byte) bool {
+			derived, err := scrypt.Key(password, salt, 1<<p["ln"], p["r"], p["p"], len(key))
+			return err == nil && subtle.ConstantTimeCompare(derived, key) == 1
+		},
+	}, nil
+}
+
+func parsePBKDF2(digest string, fields []string) (parsedHash, error) {
+	if len(fields) != 3 {
+		return parsedHash{}, errors.New("malformed pbkdf2 hash")
+	}
+
+	iterations, err := strconv.Atoi(strings.TrimPrefix(fields[0], "i="))
+	if err != nil || iterations <= 0 || iterations > maxPBKDF2Iterations {
+		return parsedHash{}, fmt.Errorf("invalid pbkdf2 iterations %q", fields[0])
+	}
+	salt, key, err := saltAndKey(fields[1], fields[2])
+	if err != nil {
+		return parsedHash{}, err
+	}
+	h := digestFunc(digest)
+
+	return parsedHash{
+		typ: HashPBKDF2,
+		id:  "pbkdf2-" + digest,
+		match: func(password []byte) bool {
+			derived := pbkdf2.Key(password, salt, iterations, len(key), h)
+			return subtle.ConstantTimeCompare(derived, key) == 1
+		},
+	}, nil
+}
+
+func parseSaltedSHA(digest string, fields []string) (parsedHash, error) {
+	if len(fields) != 2 || fields[0] == "" {
+		return parsedHash{}, errors.New("malformed salted sha hash")
+	}
+
+	salt := []byte(fields[0])
+	sum, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return parsedHash{}, errors.New("digest is not hex")
+	}
+	h := digestFunc(digest)
+	if len(sum) != h().Size() {
+		return parsedHash{}, fmt.Errorf("digest is not %s", digest)
+	}
+
+	return parsedHash{
+		typ: HashSaltedSHA,
+		id:  digest,
+		match: func(password []byte) bool {
+			d := h()
+			d.Write(salt)
+			d.Write(password)
+			return subtle.ConstantTimeCompare(d.Sum(nil), sum) == 1
+		},
+	}, nil
+}
+
+func digestFunc(name string) func() hash.Hash {
+	switch name {
+	case "sha1":
+		return sha1.New
+	case "sha512":
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+// params parses comma separated key=value cost parameters, all the keys are required to be positive integers.
+func params(s string, keys ...string) (map[string]int, error) {
+	res := make(map[string]int, len(keys))
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed parameter %q", kv)
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid parameter %q", kv)
+		}
+		res[k] = n
+	}
+	for _, k := range keys {
+		if _, ok := res[k]; !ok {
+			return nil, fmt.Errorf("missing parameter %q", k)
+		}
+	}
+
+	return res, nil
+}
+
+func saltAndKey(salt string, key string) ([]byte, []byte, error) {
+	s, err := decodeBase64(salt)
+	if err != nil || len(s) == 0 {
+		return nil, nil, errors.New("invalid salt")
+	}
+	k, err := decodeBase64(key)
+	if err != nil || len(k) == 0 || len(k) > maxKeyLen {
+		return nil, nil, errors.New("invalid key")
+	}
+
+	return s, k, nil
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.ReplaceAll(strings.TrimRight(s, "="), ".", "+")
+	return base64.RawStdEncoding.DecodeString(s)
+}
@@ -0,0 +1,88 @@
+package passwords
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const password = "correct horse battery staple"
+
+var salt = []byte("0123456789abcdef")
+
+func b64(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func foreignHashes(t *testing.T) map[HashType]string {
+	t.Helper()
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	scryptKey, err := scrypt.Key([]byte(password), salt, 1<<10, 8, 1, 32)
+	require.NoError(t, err)
+	shaSum := sha256.Sum256(append([]byte("pepper"), password...))
+
+	return map[HashType]string{
+		HashBcrypt: string(bcryptHash),
+		HashArgon2: fmt.Sprintf("$argon2id$v=19$m=1024,t=1,p=1$%s$%s",
+			b64(salt), b64(argon2.IDKey([]byte(password), salt, 1, 1024, 1, 32))),
+		HashScrypt: fmt.Sprintf("$scrypt$ln=10,r=8,p=1$%s$%s", b64(salt), b64(scryptKey)),
+		HashPBKDF2: fmt.Sprintf("$pbkdf2-sha1$i=1000$%s$%s==",
+			b64(salt), b64(pbkdf2.Key([]byte(password), salt, 1000, 20, sha1.New))),
+		HashSaltedSHA: "$sha256$pepper$" + hex.EncodeToString(shaSum[:]),
+	}
+}
+
+func TestImportCompare(t *testing.T) {
+	for typ, hash := range foreignHashes(t) {
+		t.Run(string(typ), func(t *testing.T) {
+			stored, err := Import(typ, hash)
+			require.NoError(t, err)
+
+			assert.NoError(t, Compare(stored, password))
+			assert.ErrorIs(t, Compare(stored, "wrong password"), ErrMismatch)
+			assert.Equal(t, typ != HashBcrypt, bcryptHasher(t).NeedsRehash(stored))
+		})
+	}
+}
+
+func TestImport_FailCases(t *testing.T) {
+	hashes := foreignHashes(t)
+
+	cases := []struct {
+		name string
+		typ  HashType
+		hash string
+		err  error
+	}{
+		{name: "Unknown type", typ: "md5", hash: hashes[HashSaltedSHA], err: ErrUnknownHashType},
+		{name: "Type mismatch", typ: HashScrypt, hash: hashes[HashArgon2], err: ErrInvalidHash},
+		{name: "Not a hash", typ: HashBcrypt, hash: "password", err: ErrInvalidHash},
+		{name: "Unknown scheme", typ: HashSaltedSHA, hash: "$md5$salt$00", err: ErrInvalidHash},
+		{name: "Argon2 memory", typ: HashArgon2, hash: "$argon2id$v=19$m=4194304,t=1,p=1$c2FsdA$a2V5", err: ErrInvalidHash},
+		{name: "Argon2 version", typ: HashArgon2, hash: "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5", err: ErrInvalidHash},
+		{name: "Scrypt cost", typ: HashScrypt, hash: "$scrypt$ln=30,r=8,p=1$c2FsdA$a2V5", err: ErrInvalidHash},
+		{name: "Missing parameter", typ: HashScrypt, hash: "$scrypt$ln=10,r=8$c2FsdA$a2V5", err: ErrInvalidHash},
+		{name: "PBKDF2 iterations", typ: HashPBKDF2, hash: "$pbkdf2-sha256$i=0$c2FsdA$a2V5", err: ErrInvalidHash},
+		{name: "Bad base64", typ: HashPBKDF2, hash: "$pbkdf2-sha256$i=1000$c2FsdA$!!!", err: ErrInvalidHash},
+		{name: "Short digest", typ: HashSaltedSHA, hash: "$sha256$salt$abcd", err: ErrInvalidHash},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Import(c.typ, c.hash)
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
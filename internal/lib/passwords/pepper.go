@@ -0,0 +1,88 @@
+package passwords
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sso/internal/config"
+	"strings"
+)
+
+// A peppered hash is the hash of the password HMAC prefixed with the ID of the pepper key:
+//
+//	$pepper$<key id>$argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+const pepperPrefix = "$pepper$"
+
+const minPepperKeyLen = 32
+
+var pepperKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,32}$`)
+
+var ErrUnknownPepper = errors.New("Password hash is peppered with an unknown key")
+
+// loadPeppers reads the pepper keys from their files or environment variables.
+func loadPeppers(cfg config.PepperConfig) (map[string][]byte, error) {
+	peppers := make(map[string][]byte, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		if !pepperKeyIDPattern.MatchString(k.ID) {
+			return nil, fmt.Errorf("%w: invalid pepper key ID %q", ErrInvalidConfig, k.ID)
+		}
+		if _, ok := peppers[k.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicated pepper key %q", ErrInvalidConfig, k.ID)
+		}
+
+		var encoded string
+		switch {
+		case k.File != "" && k.Env == "":
+			content, err := os.ReadFile(k.File)
+			if err != nil {
+				return nil, fmt.Errorf("%w: pepper key %q: %w", ErrInvalidConfig, k.ID, err)
+			}
+			encoded = string(content)
+		case k.Env != "" && k.File == "":
+			encoded = os.Getenv(k.Env)
+		default:
+			return nil, fmt.Errorf("%w: pepper key %q needs either a file or an env variable", ErrInvalidConfig, k.ID)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) < minPepperKeyLen {
+			return nil, fmt.Errorf(
+				"%w: pepper key %q must be at least %d bytes encoded in base64", ErrInvalidConfig, k.ID, minPepperKeyLen,
+			)
+		}
+		peppers[k.ID] = key
+	}
+
+	if _, ok := peppers[cfg.CurrentKeyID]; cfg.CurrentKeyID != "" && !ok {
+		return nil, fmt.Errorf("%w: current pepper key %q is not configured", ErrInvalidConfig, cfg.CurrentKeyID)
+	}
+
+	return peppers, nil
+}
+
+// pepperPassword mixes the password with the pepper key. The MAC is encoded in base64,
+// so it has no zero bytes and fits in the 72 bytes bcrypt hashes.
+func pepperPassword(key []byte, password string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// splitPepper returns the pepper key ID of a peppered hash and the hash without the prefix,
+// an unpeppered hash is returned as is with an empty key ID.
+func splitPepper(hash []byte) (string, []byte) {
+	rest, ok := bytes.CutPrefix(hash, []byte(pepperPrefix))
+	if !ok {
+		return "", hash
+	}
+	keyID, _, ok := bytes.Cut(rest, []byte("$"))
+	if !ok || len(keyID) == 0 {
+		return "", hash
+	}
+	return string(keyID), rest[len(keyID):]
+}
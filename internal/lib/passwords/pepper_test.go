@@ -0,0 +1,119 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sso/internal/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pepperKey(t *testing.T) string {
+	t.Helper()
+
+	key := make([]byte, minPepperKeyLen)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func pepperedConfig(current string, keys ...config.PepperKeyConfig) config.PasswordHashingConfig {
+	cfg := argon2Config()
+	cfg.Pepper = config.PepperConfig{CurrentKeyID: current, Keys: keys}
+	return cfg
+}
+
+func TestHasher_Pepper(t *testing.T) {
+	t.Setenv("TEST_PEPPER_2024", pepperKey(t))
+	file := filepath.Join(t.TempDir(), "pepper-2025")
+	require.NoError(t, os.WriteFile(file, []byte(pepperKey(t)+"\n"), 0o600))
+	old := config.PepperKeyConfig{ID: "2024", Env: "TEST_PEPPER_2024"}
+	current := config.PepperKeyConfig{ID: "2025", File: file}
+
+	oldHasher, err := NewHasher(pepperedConfig("2024", old))
+	require.NoError(t, err)
+	hash, err := oldHasher.Hash(password)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$pepper$2024$argon2id$v=19$"), string(hash))
+	assert.NoError(t, oldHasher.Compare(hash, password))
+	assert.ErrorIs(t, oldHasher.Compare(hash, "wrong password"), ErrMismatch)
+	assert.False(t, oldHasher.NeedsRehash(hash))
+
+	_, inner := splitPepper(hash)
+	assert.ErrorIs(t, Compare(inner, password), ErrMismatch, "the hash alone does not match the password")
+
+	rotated, err := NewHasher(pepperedConfig("2025", old, current))
+	require.NoError(t, err)
+	assert.NoError(t, rotated.Compare(hash, password), "old peppers are accepted during the rotation")
+	assert.True(t, rotated.NeedsRehash(hash), "hashes move to the current pepper")
+	rehashed, err := rotated.Hash(password)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(rehashed), "$pepper$2025$"), string(rehashed))
+	assert.False(t, rotated.NeedsRehash(rehashed))
+
+	retired, err := NewHasher(pepperedConfig("2025", current))
+	require.NoError(t, err)
+	assert.ErrorIs(t, retired.Compare(hash, password), ErrUnknownPepper)
+
+	unpeppered, err := NewHasher(argon2Config())
+	require.NoError(t, err)
+	plain, err := unpeppered.Hash(password)
+	require.NoError(t, err)
+	assert.True(t, rotated.NeedsRehash(plain), "unpeppered hashes get peppered")
+	assert.NoError(t, rotated.Compare(plain, password))
+	assert.True(t, unpeppered.NeedsRehash(hash))
+}
+
+func TestNewHasher_PepperFailCases(t *testing.T) {
+	t.Setenv("TEST_PEPPER", pepperKey(t))
+	t.Setenv("TEST_PEPPER_SHORT", base64.StdEncoding.EncodeToString([]byte("short")))
+
+	cases := []struct {
+		name string
+		cfg  config.PasswordHashingConfig
+	}{
+		{
+			name: "Unknown current key",
+			cfg:  pepperedConfig("2025", config.PepperKeyConfig{ID: "2024", Env: "TEST_PEPPER"}),
+		},
+		{
+			name: "Short key",
+			cfg:  pepperedConfig("2025", config.PepperKeyConfig{ID: "2025", Env: "TEST_PEPPER_SHORT"}),
+		},
+		{
+			name: "Unset env",
+			cfg:  pepperedConfig("2025", config.PepperKeyConfig{ID: "2025", Env: "TEST_PEPPER_UNSET"}),
+		},
+		{
+			name: "Missing file",
+			cfg:  pepperedConfig("2025", config.PepperKeyConfig{ID: "2025", File: filepath.Join(t.TempDir(), "missing")}),
+		},
+		{
+			name: "File and env",
+			cfg:  pepperedConfig("2025", config.PepperKeyConfig{ID: "2025", Env: "TEST_PEPPER", File: "pepper"}),
+		},
+		{
+			name: "Invalid key ID",
+			cfg:  pepperedConfig("", config.PepperKeyConfig{ID: "a$b", Env: "TEST_PEPPER"}),
+		},
+		{
+			name: "Duplicated key ID",
+			cfg: pepperedConfig("",
+				config.PepperKeyConfig{ID: "2025", Env: "TEST_PEPPER"},
+				config.PepperKeyConfig{ID: "2025", Env: "TEST_PEPPER"},
+			),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewHasher(c.cfg)
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+		})
+	}
+}
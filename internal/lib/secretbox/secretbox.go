@@ -0,0 +1,66 @@
+// Package secretbox encrypts small secrets, such as TOTP keys, before they are stored.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+// KeySize is the key size, the box uses AES-256-GCM.
+const KeySize = 32
+
+var (
+	ErrInvalidKey = errors.New("secretbox: key must be 32 bytes encoded in base64")
+	ErrDecrypt    = errors.New("secretbox: message is corrupted or was sealed with another key")
+)
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New creates a box with a base64 encoded key, such as the output of `openssl rand -base64 32`.
+func New(key string) (*Box, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts the plaintext under a random nonce, which is prepended to the result.
+func (b *Box) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(plaintext)+b.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return b.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts a message sealed by Seal.
+func (b *Box) Open(sealed []byte) ([]byte, error) {
+	if len(sealed) < b.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
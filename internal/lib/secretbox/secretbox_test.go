@@ -0,0 +1,50 @@
+package secretbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, KeySize))
+}
+
+func TestBox(t *testing.T) {
+	box, err := New(testKey(1))
+	require.NoError(t, err)
+
+	sealed, err := box.Seal([]byte("secret"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "secret")
+
+	again, err := box.Seal([]byte("secret"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every message gets its own nonce")
+
+	plaintext, err := box.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+
+	other, err := New(testKey(2))
+	require.NoError(t, err)
+	_, err = other.Open(sealed)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = box.Open(sealed)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = box.Open([]byte("short"))
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestNew_InvalidKey(t *testing.T) {
+	for _, key := range []string{"", "not base64", base64.StdEncoding.EncodeToString([]byte("16 bytes is AES1"))} {
+		_, err := New(key)
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
@@ -0,0 +1,38 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const size = 32
+
+// New generates a random URL-safe token and the hash to store instead of it.
+func New() (token string, hash []byte, err error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	return token, Hash(token), nil
+}
+
+// Hash returns the hash a token is stored and looked up by.
+func Hash(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// ID generates a random identifier, such as a session ID, that is not a secret on its own.
+func ID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
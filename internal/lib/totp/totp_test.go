@@ -0,0 +1,77 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFCVectors(t *testing.T) {
+	// The RFC lists 8 digit codes, these are their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, code, tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	current := Step(now)
+
+	for _, step := range []int64{current - 1, current, current + 1} {
+		code, err := Code(secret, step)
+		require.NoError(t, err)
+
+		matched, err := Validate(secret, code, now)
+		require.NoError(t, err)
+		assert.Equal(t, step, matched)
+	}
+
+	for _, step := range []int64{current - 2, current + 2} {
+		code, err := Code(secret, step)
+		require.NoError(t, err)
+
+		_, err = Validate(secret, code, now)
+		assert.ErrorIs(t, err, ErrInvalidCode)
+	}
+
+	_, err = Validate(secret, "12345", now)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	_, err = Validate("not base32!", "123456", now)
+	assert.ErrorIs(t, err, ErrInvalidSecret)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Acme SSO", "alice@example.com", "JBSWY3DPEHPK3PXP")
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Acme SSO:alice@example.com", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "Acme SSO", u.Query().Get("issuer"))
+}
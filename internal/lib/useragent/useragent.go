@@ -0,0 +1,93 @@
+// Package useragent passes the user agent of the client of a gRPC call or an HTTP request to the services.
+package useragent
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const header = "user-agent"
+
+// maxLen cuts long user agents, they are stored with every audit event.
+const maxLen = 512
+
+type userAgentKey struct{}
+
+// NewContext returns a copy of ctx carrying the user agent.
+func NewContext(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentKey{}, userAgent)
+}
+
+// FromContext returns the user agent stored by NewContext, or "" if it is unknown.
+func FromContext(ctx context.Context) string {
+	userAgent, _ := ctx.Value(userAgentKey{}).(string)
+	return userAgent
+}
+
+// FromIncomingContext returns the user agent the client of the call sent in the metadata.
+// gRPC appends its own version to it, like "my-app/1.0 grpc-go/1.60.0".
+func FromIncomingContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return normalize(strings.Join(md.Get(header), " "))
+}
+
+func normalize(userAgent string) string {
+	userAgent = strings.ToValidUTF8(strings.TrimSpace(userAgent), "")
+	if len(userAgent) > maxLen {
+		cut := maxLen
+		for cut > 0 && !utf8.RuneStart(userAgent[cut]) {
+			cut--
+		}
+		userAgent = userAgent[:cut]
+	}
+	return userAgent
+}
+
+// UnaryServerInterceptor stores the user agent of every call in its context, see FromContext.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(NewContext(ctx, FromIncomingContext(ctx)), req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &contextStream{
+			ServerStream: stream,
+			ctx:          NewContext(stream.Context(), FromIncomingContext(stream.Context())),
+		})
+	}
+}
+
+// contextStream passes the user agent to stream handlers through its context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// Middleware stores the user agent of every HTTP request in its context, see FromContext.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), normalize(r.UserAgent()))))
+	})
+}
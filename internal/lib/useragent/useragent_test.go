@@ -0,0 +1,40 @@
+package useragent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestFromIncomingContext(t *testing.T) {
+	assert.Equal(t, "", FromIncomingContext(context.Background()))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-agent", "my-app/1.0 grpc-go/1.60.0"))
+	assert.Equal(t, "my-app/1.0 grpc-go/1.60.0", FromIncomingContext(ctx))
+}
+
+func TestNormalize_CutsLongUserAgents(t *testing.T) {
+	userAgent := normalize(strings.Repeat("я", maxLen))
+
+	assert.LessOrEqual(t, len(userAgent), maxLen)
+	assert.True(t, utf8.ValidString(userAgent), "a rune is not cut in half")
+}
+
+func TestMiddleware(t *testing.T) {
+	var got string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "Mozilla/5.0", got)
+}
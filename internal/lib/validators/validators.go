@@ -2,14 +2,19 @@ package validators
 
 import (
 	"fmt"
+	"regexp"
+	"sso/internal/domain/models"
 
 	validator "github.com/go-playground/validator/v10"
 )
 
+// LoginValidator checks login requests, Email holds a login identifier of any type.
+// The password length is not checked: passwords set before the policy, like imported ones, can be shorter.
 type LoginValidator struct {
-	Email    string `validate:"required,email"`
-	Password string `validate:"required,min=8"`
+	Email    string `validate:"required,max=254"`
+	Password string `validate:"required"`
 	AppID    int32  `validate:"required,gt=0"`
+	Realm    string `validate:"max=253"`
 }
 
 func (v *LoginValidator) Validate() error {
@@ -17,17 +22,41 @@ func (v *LoginValidator) Validate() error {
 	return validate.Struct(v)
 }
 
-func ToLoginValidator(email string, password string, appId int32) *LoginValidator {
+func ToLoginValidator(login string, password string, appId int32, realm string) *LoginValidator {
 	return &LoginValidator{
-		Email:    email,
+		Email:    login,
 		Password: password,
 		AppID:    appId,
+		Realm:    realm,
+	}
+}
+
+type DiscoverRealmValidator struct {
+	Login string `validate:"required,max=254"`
+	Realm string `validate:"max=253"`
+}
+
+func (v *DiscoverRealmValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToDiscoverRealmValidator(login string, realm string) *DiscoverRealmValidator {
+	return &DiscoverRealmValidator{
+		Login: login,
+		Realm: realm,
 	}
 }
 
+// RegisterValidator checks the shape of registration requests, the password policy
+// of the app is checked by the service.
 type RegisterValidator struct {
 	Email    string `validate:"required,email"`
-	Password string `validate:"required,min=8"`
+	Password string `validate:"required"`
+	OrgID    int64  `validate:"gte=0"`
+	AppID    int32  `validate:"gte=0"`
+	Username string `validate:"max=64"`
+	Phone    string `validate:"max=32"`
 }
 
 func (v *RegisterValidator) Validate() error {
@@ -35,10 +64,14 @@ func (v *RegisterValidator) Validate() error {
 	return validate.Struct(v)
 }
 
-func ToRegisterValidator(email string, password string) *RegisterValidator {
+func ToRegisterValidator(email string, password string, orgID int64, appID int32, username string, phone string) *RegisterValidator {
 	return &RegisterValidator{
 		Email:    email,
 		Password: password,
+		OrgID:    orgID,
+		AppID:    appID,
+		Username: username,
+		Phone:    phone,
 	}
 }
 
@@ -57,6 +90,684 @@ func ToIsAdminValidator(userID int64) *IsAdminValidator {
 	}
 }
 
+type SendVerificationValidator struct {
+	Email string `validate:"required,email"`
+	OrgID int64  `validate:"gte=0"`
+}
+
+func (v *SendVerificationValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToSendVerificationValidator(email string, orgID int64) *SendVerificationValidator {
+	return &SendVerificationValidator{
+		Email: email,
+		OrgID: orgID,
+	}
+}
+
+type VerifyEmailValidator struct {
+	Token string `validate:"required,max=128"`
+}
+
+func (v *VerifyEmailValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToVerifyEmailValidator(token string) *VerifyEmailValidator {
+	return &VerifyEmailValidator{
+		Token: token,
+	}
+}
+
+type RequestPasswordResetValidator struct {
+	Email string `validate:"required,email"`
+	OrgID int64  `validate:"gte=0"`
+}
+
+func (v *RequestPasswordResetValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToRequestPasswordResetValidator(email string, orgID int64) *RequestPasswordResetValidator {
+	return &RequestPasswordResetValidator{
+		Email: email,
+		OrgID: orgID,
+	}
+}
+
+type ResetPasswordValidator struct {
+	Token    string `validate:"required,max=128"`
+	Password string `validate:"required"`
+	AppID    int32  `validate:"gte=0"`
+}
+
+func (v *ResetPasswordValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToResetPasswordValidator(token string, password string, appID int32) *ResetPasswordValidator {
+	return &ResetPasswordValidator{
+		Token:    token,
+		Password: password,
+		AppID:    appID,
+	}
+}
+
+type ChangePasswordValidator struct {
+	OldPassword string `validate:"required"`
+	NewPassword string `validate:"required"`
+}
+
+func (v *ChangePasswordValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToChangePasswordValidator(oldPassword string, newPassword string) *ChangePasswordValidator {
+	return &ChangePasswordValidator{
+		OldPassword: oldPassword,
+		NewPassword: newPassword,
+	}
+}
+
+type ChangeEmailValidator struct {
+	NewEmail string `validate:"required,email"`
+	Password string `validate:"required"`
+}
+
+func (v *ChangeEmailValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToChangeEmailValidator(newEmail string, password string) *ChangeEmailValidator {
+	return &ChangeEmailValidator{
+		NewEmail: newEmail,
+		Password: password,
+	}
+}
+
+type UpdateIdentifiersValidator struct {
+	Username string `validate:"max=64"`
+	Phone    string `validate:"max=32"`
+}
+
+func (v *UpdateIdentifiersValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToUpdateIdentifiersValidator(username string, phone string) *UpdateIdentifiersValidator {
+	return &UpdateIdentifiersValidator{
+		Username: username,
+		Phone:    phone,
+	}
+}
+
+type ConfirmEmailChangeValidator struct {
+	Token string `validate:"required,max=128"`
+}
+
+func (v *ConfirmEmailChangeValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToConfirmEmailChangeValidator(token string) *ConfirmEmailChangeValidator {
+	return &ConfirmEmailChangeValidator{
+		Token: token,
+	}
+}
+
+// DeleteAccountValidator allows a zero UserID, it means the caller's own account.
+type DeleteAccountValidator struct {
+	UserID int64 `validate:"gte=0"`
+}
+
+func (v *DeleteAccountValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToDeleteAccountValidator(userID int64) *DeleteAccountValidator {
+	return &DeleteAccountValidator{
+		UserID: userID,
+	}
+}
+
+// ExportUserDataValidator allows a zero UserID, it means the caller's own account.
+type ExportUserDataValidator struct {
+	UserID int64 `validate:"gte=0"`
+}
+
+func (v *ExportUserDataValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToExportUserDataValidator(userID int64) *ExportUserDataValidator {
+	return &ExportUserDataValidator{
+		UserID: userID,
+	}
+}
+
+// GetProfileValidator allows a zero UserID, it means the caller's own profile.
+type GetProfileValidator struct {
+	UserID int64 `validate:"gte=0"`
+}
+
+func (v *GetProfileValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToGetProfileValidator(userID int64) *GetProfileValidator {
+	return &GetProfileValidator{
+		UserID: userID,
+	}
+}
+
+// UpdateProfileValidator checks the format of the profile fields, metadata is checked by the profile service.
+type UpdateProfileValidator struct {
+	UserID      int64  `validate:"gte=0"`
+	DisplayName string `validate:"max=128"`
+	GivenName   string `validate:"max=128"`
+	FamilyName  string `validate:"max=128"`
+	Locale      string `validate:"omitempty,bcp47_language_tag"`
+	Timezone    string `validate:"omitempty,timezone"`
+	AvatarURL   string `validate:"omitempty,max=2048,http_url"`
+}
+
+func (v *UpdateProfileValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToUpdateProfileValidator(userID int64, profile models.Profile) *UpdateProfileValidator {
+	return &UpdateProfileValidator{
+		UserID:      userID,
+		DisplayName: profile.DisplayName,
+		GivenName:   profile.GivenName,
+		FamilyName:  profile.FamilyName,
+		Locale:      profile.Locale,
+		Timezone:    profile.Timezone,
+		AvatarURL:   profile.AvatarURL,
+	}
+}
+
+type SetUserStatusValidator struct {
+	UserID int64  `validate:"required,gt=0"`
+	Status string `validate:"required,oneof=active disabled locked pending"`
+	Reason string `validate:"max=512"`
+}
+
+func (v *SetUserStatusValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToSetUserStatusValidator(userID int64, status string, reason string) *SetUserStatusValidator {
+	return &SetUserStatusValidator{
+		UserID: userID,
+		Status: status,
+		Reason: reason,
+	}
+}
+
+type GetUserValidator struct {
+	UserID int64 `validate:"required,gt=0"`
+}
+
+func (v *GetUserValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToGetUserValidator(userID int64) *GetUserValidator {
+	return &GetUserValidator{
+		UserID: userID,
+	}
+}
+
+type ListUsersValidator struct {
+	PageSize    int32  `validate:"gte=0"`
+	Status      string `validate:"omitempty,oneof=active disabled locked pending"`
+	EmailPrefix string `validate:"max=254"`
+	OrgID       int64  `validate:"gte=0"`
+}
+
+func (v *ListUsersValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToListUsersValidator(pageSize int32, status string, emailPrefix string, orgID int64) *ListUsersValidator {
+	return &ListUsersValidator{
+		PageSize:    pageSize,
+		Status:      status,
+		EmailPrefix: emailPrefix,
+		OrgID:       orgID,
+	}
+}
+
+type SearchUsersValidator struct {
+	Query    string `validate:"required,min=2,max=254"`
+	PageSize int32  `validate:"gte=0"`
+	OrgID    int64  `validate:"gte=0"`
+}
+
+func (v *SearchUsersValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToSearchUsersValidator(query string, pageSize int32, orgID int64) *SearchUsersValidator {
+	return &SearchUsersValidator{
+		Query:    query,
+		PageSize: pageSize,
+		OrgID:    orgID,
+	}
+}
+
+type QueryAuditLogValidator struct {
+	Type         string `validate:"omitempty,oneof=login registration password_change password_reset session_revocation admin_action new_device identity_link"`
+	Outcome      string `validate:"omitempty,oneof=success failure"`
+	ActorID      int64  `validate:"gte=0"`
+	TargetUserID int64  `validate:"gte=0"`
+	AppID        int32  `validate:"gte=0"`
+	IP           string `validate:"omitempty,ip"`
+	Since        int64  `validate:"gte=0"`
+	Until        int64  `validate:"gte=0"`
+	PageSize     int32  `validate:"gte=0"`
+}
+
+func (v *QueryAuditLogValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToQueryAuditLogValidator(
+	eventType string,
+	outcome string,
+	actorID int64,
+	targetUserID int64,
+	appID int32,
+	ip string,
+	since int64,
+	until int64,
+	pageSize int32,
+) *QueryAuditLogValidator {
+	return &QueryAuditLogValidator{
+		Type:         eventType,
+		Outcome:      outcome,
+		ActorID:      actorID,
+		TargetUserID: targetUserID,
+		AppID:        appID,
+		IP:           ip,
+		Since:        since,
+		Until:        until,
+		PageSize:     pageSize,
+	}
+}
+
+type HasPermissionValidator struct {
+	UserID     int64  `validate:"required,gt=0"`
+	AppID      int32  `validate:"gte=0"`
+	Permission string `validate:"required,max=128"`
+}
+
+func (v *HasPermissionValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToHasPermissionValidator(userID int64, appID int32, permission string) *HasPermissionValidator {
+	return &HasPermissionValidator{
+		UserID:     userID,
+		AppID:      appID,
+		Permission: permission,
+	}
+}
+
+// RoleValidator checks roles and permissions being created, names are
+// lowercase words joined by dashes, dots, colons or underscores, like "billing-viewer".
+type RoleValidator struct {
+	OrgID       int64  `validate:"gte=0"`
+	AppID       int32  `validate:"gte=0"`
+	Name        string `validate:"required,max=64,rbac_name"`
+	Description string `validate:"max=256"`
+}
+
+func (v *RoleValidator) Validate() error {
+	validate := validator.New()
+	validate.RegisterValidation("rbac_name", validateRBACName)
+	return validate.Struct(v)
+}
+
+func ToRoleValidator(orgID int64, appID int32, name string, description string) *RoleValidator {
+	return &RoleValidator{
+		OrgID:       orgID,
+		AppID:       appID,
+		Name:        name,
+		Description: description,
+	}
+}
+
+var rbacNameRe = regexp.MustCompile(`^[a-z0-9]+([-_.:][a-z0-9]+)*$`)
+
+func validateRBACName(fl validator.FieldLevel) bool {
+	return rbacNameRe.MatchString(fl.Field().String())
+}
+
+type RoleAssignmentValidator struct {
+	UserID int64 `validate:"required,gt=0"`
+	RoleID int64 `validate:"required,gt=0"`
+	AppID  int32 `validate:"gte=0"`
+}
+
+func (v *RoleAssignmentValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToRoleAssignmentValidator(userID int64, roleID int64, appID int32) *RoleAssignmentValidator {
+	return &RoleAssignmentValidator{
+		UserID: userID,
+		RoleID: roleID,
+		AppID:  appID,
+	}
+}
+
+type RolePermissionValidator struct {
+	RoleID       int64 `validate:"required,gt=0"`
+	PermissionID int64 `validate:"required,gt=0"`
+}
+
+func (v *RolePermissionValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToRolePermissionValidator(roleID int64, permissionID int64) *RolePermissionValidator {
+	return &RolePermissionValidator{
+		RoleID:       roleID,
+		PermissionID: permissionID,
+	}
+}
+
+// IDValidator checks a single required ID, like the one of a role or a permission being deleted.
+type IDValidator struct {
+	ID int64 `validate:"required,gt=0"`
+}
+
+func (v *IDValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToIDValidator(id int64) *IDValidator {
+	return &IDValidator{
+		ID: id,
+	}
+}
+
+// OrganizationValidator checks organizations being created, slugs are
+// lowercase words joined by dashes, like "acme-corp".
+type OrganizationValidator struct {
+	Name string `validate:"required,max=128"`
+	Slug string `validate:"required,max=64,slug"`
+}
+
+func (v *OrganizationValidator) Validate() error {
+	validate := validator.New()
+	validate.RegisterValidation("slug", validateSlug)
+	return validate.Struct(v)
+}
+
+func ToOrganizationValidator(name string, slug string) *OrganizationValidator {
+	return &OrganizationValidator{
+		Name: name,
+		Slug: slug,
+	}
+}
+
+var slugRe = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func validateSlug(fl validator.FieldLevel) bool {
+	return slugRe.MatchString(fl.Field().String())
+}
+
+type MemberValidator struct {
+	OrgID  int64 `validate:"required,gt=0"`
+	UserID int64 `validate:"required,gt=0"`
+}
+
+func (v *MemberValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToMemberValidator(orgID int64, userID int64) *MemberValidator {
+	return &MemberValidator{
+		OrgID:  orgID,
+		UserID: userID,
+	}
+}
+
+type InvitationValidator struct {
+	Email   string  `validate:"required,email"`
+	OrgID   int64   `validate:"gte=0"`
+	AppID   int32   `validate:"gte=0"`
+	RoleIDs []int64 `validate:"max=32,dive,gt=0"`
+}
+
+func (v *InvitationValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToInvitationValidator(email string, orgID int64, appID int32, roleIDs []int64) *InvitationValidator {
+	return &InvitationValidator{
+		Email:   email,
+		OrgID:   orgID,
+		AppID:   appID,
+		RoleIDs: roleIDs,
+	}
+}
+
+type ListInvitationsValidator struct {
+	OrgID  int64  `validate:"gte=0"`
+	Email  string `validate:"omitempty,email"`
+	Status string `validate:"omitempty,oneof=pending accepted revoked expired"`
+}
+
+func (v *ListInvitationsValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToListInvitationsValidator(orgID int64, email string, status string) *ListInvitationsValidator {
+	return &ListInvitationsValidator{
+		OrgID:  orgID,
+		Email:  email,
+		Status: status,
+	}
+}
+
+// AcceptInvitationValidator checks an invitation being accepted, the password
+// is only needed when the invitation creates the account and is checked by the policy then.
+type AcceptInvitationValidator struct {
+	Token    string `validate:"required"`
+	Password string
+}
+
+func (v *AcceptInvitationValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToAcceptInvitationValidator(token string, password string) *AcceptInvitationValidator {
+	return &AcceptInvitationValidator{
+		Token:    token,
+		Password: password,
+	}
+}
+
+type ImportUsersValidator struct {
+	Format string `validate:"required,oneof=csv jsonl"`
+}
+
+func (v *ImportUsersValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToImportUsersValidator(format string) *ImportUsersValidator {
+	return &ImportUsersValidator{
+		Format: format,
+	}
+}
+
+type VerifyMFAValidator struct {
+	ChallengeID string `validate:"required,max=64"`
+	Method      string `validate:"required,oneof=totp webauthn recovery_code"`
+	// Code of the webauthn method is the JSON of the assertion.
+	Code string `validate:"required,max=16384"`
+}
+
+func (v *VerifyMFAValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToVerifyMFAValidator(challengeID string, method string, code string) *VerifyMFAValidator {
+	return &VerifyMFAValidator{
+		ChallengeID: challengeID,
+		Method:      method,
+		Code:        code,
+	}
+}
+
+// TOTPCodeValidator checks the codes of authenticator apps.
+type TOTPCodeValidator struct {
+	Code string `validate:"required,numeric,len=6"`
+}
+
+func (v *TOTPCodeValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToTOTPCodeValidator(code string) *TOTPCodeValidator {
+	return &TOTPCodeValidator{
+		Code: code,
+	}
+}
+
+type FinishWebAuthnRegistrationValidator struct {
+	Name       string `validate:"max=64"`
+	Credential []byte `validate:"required,max=65536"`
+}
+
+func (v *FinishWebAuthnRegistrationValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToFinishWebAuthnRegistrationValidator(name string, credential []byte) *FinishWebAuthnRegistrationValidator {
+	return &FinishWebAuthnRegistrationValidator{
+		Name:       name,
+		Credential: credential,
+	}
+}
+
+type WebAuthnCredentialIDValidator struct {
+	ID string `validate:"required,max=1366,base64rawurl"`
+}
+
+func (v *WebAuthnCredentialIDValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToWebAuthnCredentialIDValidator(id string) *WebAuthnCredentialIDValidator {
+	return &WebAuthnCredentialIDValidator{
+		ID: id,
+	}
+}
+
+// BeginWebAuthnLoginValidator takes an app for a passwordless login or an MFA challenge of Login.
+type BeginWebAuthnLoginValidator struct {
+	AppID       int32  `validate:"required_without=ChallengeID,omitempty,gt=0"`
+	ChallengeID string `validate:"max=64"`
+}
+
+func (v *BeginWebAuthnLoginValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToBeginWebAuthnLoginValidator(appID int32, challengeID string) *BeginWebAuthnLoginValidator {
+	return &BeginWebAuthnLoginValidator{
+		AppID:       appID,
+		ChallengeID: challengeID,
+	}
+}
+
+type FinishWebAuthnLoginValidator struct {
+	Credential []byte `validate:"required,max=16384"`
+}
+
+func (v *FinishWebAuthnLoginValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToFinishWebAuthnLoginValidator(credential []byte) *FinishWebAuthnLoginValidator {
+	return &FinishWebAuthnLoginValidator{
+		Credential: credential,
+	}
+}
+
+type RevokeUnrecognizedLoginValidator struct {
+	Token string `validate:"required,max=128"`
+}
+
+func (v *RevokeUnrecognizedLoginValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToRevokeUnrecognizedLoginValidator(token string) *RevokeUnrecognizedLoginValidator {
+	return &RevokeUnrecognizedLoginValidator{
+		Token: token,
+	}
+}
+
+type LinkIdentityValidator struct {
+	Source   string `validate:"required,max=64"`
+	Login    string `validate:"required,max=254"`
+	Password string `validate:"required"`
+}
+
+func (v *LinkIdentityValidator) Validate() error {
+	validate := validator.New()
+	return validate.Struct(v)
+}
+
+func ToLinkIdentityValidator(source string, login string, password string) *LinkIdentityValidator {
+	return &LinkIdentityValidator{
+		Source:   source,
+		Login:    login,
+		Password: password,
+	}
+}
+
 func GetDetailedError(err error) string {
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
 		firstError := validationErrors[0]
@@ -0,0 +1,110 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+)
+
+// Attestation statement formats.
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// oidAAGUID is the extension of packed attestation certificates holding the AAGUID.
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifyAttestation checks the attestation statement of a registration against its credential key.
+// A packed statement with a certificate is checked for the requirements of the spec, but the
+// certificate is not chained to a vendor root: without a metadata service there is nothing to trust.
+func verifyAttestation(reg *Registration, key PublicKey) error {
+	switch reg.format {
+	case FormatNone:
+		if len(reg.attStmt) != 0 {
+			return fmt.Errorf("%w: none attestation with a statement", ErrInvalidResponse)
+		}
+		return nil
+	case FormatPacked:
+		return verifyPacked(reg, key)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAttestation, reg.format)
+	}
+}
+
+func verifyPacked(reg *Registration, key PublicKey) error {
+	alg, ok := reg.attStmt["alg"].(int64)
+	if !ok {
+		return fmt.Errorf("%w: packed attestation without alg", ErrInvalidResponse)
+	}
+	sig, ok := reg.attStmt["sig"].([]byte)
+	if !ok {
+		return fmt.Errorf("%w: packed attestation without sig", ErrInvalidResponse)
+	}
+	if _, ok := reg.attStmt["ecdaaKeyId"]; ok {
+		return fmt.Errorf("%w: ECDAA", ErrUnsupportedAttestation)
+	}
+
+	clientDataHash := sha256.Sum256(reg.clientDataJSON)
+	signed := append(append([]byte{}, reg.authData...), clientDataHash[:]...)
+
+	x5c, ok := reg.attStmt["x5c"]
+	if !ok {
+		// self attestation, signed with the credential key itself
+		if alg != key.Alg {
+			return fmt.Errorf("%w: self attestation algorithm %d does not match the key", ErrVerification, alg)
+		}
+		return key.Verify(signed, sig)
+	}
+
+	chain, ok := x5c.([]any)
+	if !ok || len(chain) == 0 {
+		return fmt.Errorf("%w: empty x5c", ErrInvalidResponse)
+	}
+	der, ok := chain[0].([]byte)
+	if !ok {
+		return fmt.Errorf("%w: malformed x5c", ErrInvalidResponse)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("%w: attestation certificate: %w", ErrInvalidResponse, err)
+	}
+
+	if err := verifySignature(alg, cert.PublicKey, signed, sig); err != nil {
+		return err
+	}
+
+	return checkAttestationCertificate(cert, reg.AuthData.AAGUID)
+}
+
+// checkAttestationCertificate applies the requirements of packed attestation certificates, §8.2.1.
+func checkAttestationCertificate(cert *x509.Certificate, aaguid []byte) error {
+	subject := cert.Subject
+	switch {
+	case cert.Version != 3:
+		return fmt.Errorf("%w: attestation certificate version %d", ErrVerification, cert.Version)
+	case len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "":
+		return fmt.Errorf("%w: incomplete attestation certificate subject", ErrVerification)
+	case len(subject.OrganizationalUnit) != 1 || subject.OrganizationalUnit[0] != "Authenticator Attestation":
+		return fmt.Errorf("%w: attestation certificate OU", ErrVerification)
+	case !cert.BasicConstraintsValid || cert.IsCA:
+		return fmt.Errorf("%w: attestation certificate is a CA", ErrVerification)
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidAAGUID) {
+			continue
+		}
+		if ext.Critical {
+			return fmt.Errorf("%w: critical AAGUID extension", ErrVerification)
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(ext.Value, &value); err != nil || !bytes.Equal(value, aaguid) {
+			return fmt.Errorf("%w: AAGUID does not match the attestation certificate", ErrVerification)
+		}
+	}
+
+	return nil
+}
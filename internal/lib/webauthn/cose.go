@@ -0,0 +1,153 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"sso/internal/lib/cbor"
+)
+
+// COSE algorithms of credential keys, RFC 9053.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// Algorithms lists the supported algorithms in the order of preference sent to authenticators.
+var Algorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters.
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+
+	minRSABits = 2048
+)
+
+// PublicKey is a credential public key decoded from its COSE form.
+type PublicKey struct {
+	Alg int64
+	key crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key, it accepts only the keys of Algorithms.
+func ParsePublicKey(data []byte) (PublicKey, error) {
+	v, rest, err := cbor.Decode(data)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("%w: public key: %w", ErrInvalidResponse, err)
+	}
+	if len(rest) != 0 {
+		return PublicKey{}, fmt.Errorf("%w: public key: trailing data", ErrInvalidResponse)
+	}
+
+	return publicKeyFromMap(v)
+}
+
+func publicKeyFromMap(v any) (PublicKey, error) {
+	m, ok := v.(map[any]any)
+	if !ok {
+		return PublicKey{}, fmt.Errorf("%w: public key is not a map", ErrInvalidResponse)
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case alg == AlgES256 && kty == ktyEC2:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return PublicKey{}, fmt.Errorf("%w: invalid P-256 key", ErrInvalidResponse)
+		}
+		// ecdh checks that the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return PublicKey{}, fmt.Errorf("%w: invalid P-256 key: %w", ErrInvalidResponse, err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return PublicKey{Alg: alg, key: key}, nil
+	case alg == AlgEdDSA && kty == ktyOKP:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return PublicKey{}, fmt.Errorf("%w: invalid Ed25519 key", ErrInvalidResponse)
+		}
+		return PublicKey{Alg: alg, key: ed25519.PublicKey(x)}, nil
+	case alg == AlgRS256 && kty == ktyRSA:
+		n, _ := m[int64(coseN)].([]byte)
+		e, _ := m[int64(coseE)].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return PublicKey{}, fmt.Errorf("%w: invalid RSA exponent", ErrInvalidResponse)
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSABits || key.E < 3 {
+			return PublicKey{}, fmt.Errorf("%w: weak RSA key", ErrInvalidResponse)
+		}
+		return PublicKey{Alg: alg, key: key}, nil
+	}
+
+	return PublicKey{}, fmt.Errorf("%w: key type %d with algorithm %d", ErrUnsupportedAlgorithm, kty, alg)
+}
+
+// Verify checks the signature of data made with the key.
+func (k PublicKey) Verify(data []byte, sig []byte) error {
+	return verifySignature(k.Alg, k.key, data, sig)
+}
+
+// verifySignature checks a signature of the COSE algorithm alg, which an attestation
+// certificate key must match.
+func verifySignature(alg int64, key crypto.PublicKey, data []byte, sig []byte) error {
+	switch alg {
+	case AlgES256:
+		key, ok := key.(*ecdsa.PublicKey)
+		if !ok || key.Curve != elliptic.P256() {
+			return fmt.Errorf("%w: key does not match ES256", ErrVerification)
+		}
+		digest := sha256.Sum256(data)
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return fmt.Errorf("%w: bad signature", ErrVerification)
+		}
+	case AlgEdDSA:
+		key, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key does not match EdDSA", ErrVerification)
+		}
+		if !ed25519.Verify(key, data, sig) {
+			return fmt.Errorf("%w: bad signature", ErrVerification)
+		}
+	case AlgRS256:
+		key, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key does not match RS256", ErrVerification)
+		}
+		digest := sha256.Sum256(data)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrVerification)
+		}
+	default:
+		return fmt.Errorf("%w: algorithm %d", ErrUnsupportedAlgorithm, alg)
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package webauthn
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sso/internal/lib/cbor"
+	"strings"
+)
+
+// Flags of the authenticator data.
+const (
+	FlagUserPresent    byte = 0x01
+	FlagUserVerified   byte = 0x04
+	FlagBackupEligible byte = 0x08
+	FlagBackedUp       byte = 0x10
+	flagAttestedData   byte = 0x40
+	flagExtensions     byte = 0x80
+
+	rpIDHashSize = 32
+	aaguidSize   = 16
+	// maxCredentialIDSize is the limit of the WebAuthn spec.
+	maxCredentialIDSize = 1023
+)
+
+// AuthenticatorData is what the authenticator signs, the attested credential
+// is present only in registrations.
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	// PublicKey is the COSE_Key of the new credential.
+	PublicKey []byte
+}
+
+func (d AuthenticatorData) Has(flag byte) bool {
+	return d.Flags&flag == flag
+}
+
+func parseAuthenticatorData(data []byte) (AuthenticatorData, error) {
+	const minSize = rpIDHashSize + 1 + 4
+	if len(data) < minSize {
+		return AuthenticatorData{}, fmt.Errorf("%w: authenticator data is too short", ErrInvalidResponse)
+	}
+
+	d := AuthenticatorData{
+		RPIDHash:  data[:rpIDHashSize],
+		Flags:     data[rpIDHashSize],
+		SignCount: binary.BigEndian.Uint32(data[rpIDHashSize+1:]),
+	}
+	rest := data[minSize:]
+
+	if d.Has(flagAttestedData) {
+		if len(rest) < aaguidSize+2 {
+			return AuthenticatorData{}, fmt.Errorf("%w: attested credential data is too short", ErrInvalidResponse)
+		}
+		d.AAGUID = rest[:aaguidSize]
+		idLen := int(binary.BigEndian.Uint16(rest[aaguidSize:]))
+		rest = rest[aaguidSize+2:]
+		if idLen > maxCredentialIDSize || len(rest) < idLen {
+			return AuthenticatorData{}, fmt.Errorf("%w: invalid credential ID length", ErrInvalidResponse)
+		}
+		d.CredentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, after, err := cbor.Decode(rest)
+		if err != nil {
+			return AuthenticatorData{}, fmt.Errorf("%w: credential public key: %w", ErrInvalidResponse, err)
+		}
+		d.PublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if d.Has(flagExtensions) {
+		ext, after, err := cbor.Decode(rest)
+		if err != nil {
+			return AuthenticatorData{}, fmt.Errorf("%w: extensions: %w", ErrInvalidResponse, err)
+		}
+		if _, ok := ext.(map[any]any); !ok {
+			return AuthenticatorData{}, fmt.Errorf("%w: extensions are not a map", ErrInvalidResponse)
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return AuthenticatorData{}, fmt.Errorf("%w: trailing authenticator data", ErrInvalidResponse)
+	}
+
+	return d, nil
+}
+
+// ClientData is the clientDataJSON the browser passes to the authenticator.
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func parseClientData(data []byte) (ClientData, error) {
+	var c ClientData
+	if err := json.Unmarshal(data, &c); err != nil {
+		return ClientData{}, fmt.Errorf("%w: client data: %w", ErrInvalidResponse, err)
+	}
+	return c, nil
+}
+
+// credentialJSON is a PublicKeyCredential serialized by its toJSON() method,
+// binary fields are base64url encoded.
+type credentialJSON struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+		AuthenticatorData string   `json:"authenticatorData"`
+		Signature         string   `json:"signature"`
+		UserHandle        string   `json:"userHandle"`
+	} `json:"response"`
+}
+
+func parseCredentialJSON(data []byte) (credentialJSON, []byte, []byte, error) {
+	var c credentialJSON
+	if err := json.Unmarshal(data, &c); err != nil {
+		return credentialJSON{}, nil, nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if c.Type != "public-key" {
+		return credentialJSON{}, nil, nil, fmt.Errorf("%w: credential type %q", ErrInvalidResponse, c.Type)
+	}
+
+	id, err := decodeField("rawId", c.RawID)
+	if err != nil {
+		return credentialJSON{}, nil, nil, err
+	}
+	if len(id) == 0 || len(id) > maxCredentialIDSize || c.ID != EncodeID(id) {
+		return credentialJSON{}, nil, nil, fmt.Errorf("%w: invalid credential ID", ErrInvalidResponse)
+	}
+
+	clientData, err := decodeField("clientDataJSON", c.Response.ClientDataJSON)
+	if err != nil {
+		return credentialJSON{}, nil, nil, err
+	}
+
+	return c, id, clientData, nil
+}
+
+func decodeField(name string, value string) ([]byte, error) {
+	b, err := DecodeID(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidResponse, name, err)
+	}
+	return b, nil
+}
+
+// EncodeID encodes binary values of the ceremonies, such as credential IDs and
+// challenges, as unpadded base64url.
+func EncodeID(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeID decodes base64url with or without padding.
+func DecodeID(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Registration is a parsed response to a creation ceremony.
+type Registration struct {
+	CredentialID []byte
+	ClientData   ClientData
+	AuthData     AuthenticatorData
+	Transports   []string
+
+	clientDataJSON []byte
+	authData       []byte
+	format         string
+	attStmt        map[any]any
+}
+
+// ParseRegistration decodes the JSON of the credential returned by navigator.credentials.create().
+func ParseRegistration(data []byte) (*Registration, error) {
+	c, id, clientDataJSON, err := parseCredentialJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	clientData, err := parseClientData(clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	attObj, err := decodeField("attestationObject", c.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	v, rest, err := cbor.Decode(attObj)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %w", ErrInvalidResponse, err)
+	}
+	m, ok := v.(map[any]any)
+	if !ok || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+	format, _ := m["fmt"].(string)
+	attStmt, _ := m["attStmt"].(map[any]any)
+	rawAuthData, _ := m["authData"].([]byte)
+	if format == "" || attStmt == nil || rawAuthData == nil {
+		return nil, fmt.Errorf("%w: incomplete attestation object", ErrInvalidResponse)
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if !authData.Has(flagAttestedData) {
+		return nil, fmt.Errorf("%w: no attested credential", ErrInvalidResponse)
+	}
+
+	return &Registration{
+		CredentialID:   id,
+		ClientData:     clientData,
+		AuthData:       authData,
+		Transports:     c.Response.Transports,
+		clientDataJSON: clientDataJSON,
+		authData:       rawAuthData,
+		format:         format,
+		attStmt:        attStmt,
+	}, nil
+}
+
+// Assertion is a parsed response to a request ceremony.
+type Assertion struct {
+	CredentialID []byte
+	ClientData   ClientData
+	AuthData     AuthenticatorData
+	// UserHandle is set by discoverable credentials.
+	UserHandle []byte
+
+	clientDataJSON []byte
+	authData       []byte
+	signature      []byte
+}
+
+// ParseAssertion decodes the JSON of the credential returned by navigator.credentials.get().
+func ParseAssertion(data []byte) (*Assertion, error) {
+	c, id, clientDataJSON, err := parseCredentialJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	clientData, err := parseClientData(clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := decodeField("authenticatorData", c.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := decodeField("signature", c.Response.Signature)
+	if err != nil {
+		return nil, err
+	}
+	userHandle, err := decodeField("userHandle", c.Response.UserHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		CredentialID:   id,
+		ClientData:     clientData,
+		AuthData:       authData,
+		UserHandle:     userHandle,
+		clientDataJSON: clientDataJSON,
+		authData:       rawAuthData,
+		signature:      signature,
+	}, nil
+}
@@ -0,0 +1,303 @@
+// Package webauthn implements the relying party side of WebAuthn Level 2: the options of
+// the registration and authentication ceremonies and the verification of their responses.
+// It supports the none and packed attestation formats and ES256, EdDSA and RS256 keys.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sso/internal/config"
+	"strings"
+	"time"
+)
+
+// User verification requirements.
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+
+	challengeSize = 32
+)
+
+var (
+	ErrInvalidResponse        = errors.New("webauthn: invalid response")
+	ErrVerification           = errors.New("webauthn: verification failed")
+	ErrUnsupportedAlgorithm   = errors.New("webauthn: unsupported algorithm")
+	ErrUnsupportedAttestation = errors.New("webauthn: unsupported attestation format")
+	// ErrSignCount means the signature counter went back, the authenticator may be cloned.
+	ErrSignCount = errors.New("webauthn: signature counter did not increase")
+)
+
+// RelyingParty runs the ceremonies for one RP ID.
+type RelyingParty struct {
+	id               string
+	name             string
+	origins          []string
+	rpIDHash         [32]byte
+	userVerification string
+	attestation      string
+	timeout          time.Duration
+}
+
+func New(cfg config.WebAuthnConfig) (*RelyingParty, error) {
+	if cfg.RPID == "" {
+		return nil, errors.New("webauthn: empty rp_id")
+	}
+	if len(cfg.Origins) == 0 {
+		return nil, errors.New("webauthn: no origins")
+	}
+	for _, origin := range cfg.Origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" || u.Path != "" {
+			return nil, fmt.Errorf("webauthn: invalid origin %q", origin)
+		}
+		host := u.Hostname()
+		if host != cfg.RPID && !strings.HasSuffix(host, "."+cfg.RPID) {
+			return nil, fmt.Errorf("webauthn: origin %q is not on %s", origin, cfg.RPID)
+		}
+	}
+	switch cfg.UserVerification {
+	case UserVerificationRequired, UserVerificationPreferred, UserVerificationDiscouraged:
+	default:
+		return nil, fmt.Errorf("webauthn: invalid user_verification %q", cfg.UserVerification)
+	}
+	switch cfg.Attestation {
+	case "none", "direct":
+	default:
+		return nil, fmt.Errorf("webauthn: invalid attestation %q", cfg.Attestation)
+	}
+
+	return &RelyingParty{
+		id:               cfg.RPID,
+		name:             cfg.RPName,
+		origins:          cfg.Origins,
+		rpIDHash:         sha256.Sum256([]byte(cfg.RPID)),
+		userVerification: cfg.UserVerification,
+		attestation:      cfg.Attestation,
+		timeout:          cfg.ChallengeTTL,
+	}, nil
+}
+
+// Timeout is how long the user has to finish a ceremony.
+func (rp *RelyingParty) Timeout() time.Duration {
+	return rp.timeout
+}
+
+// NewChallenge returns the random challenge of a ceremony.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CredentialDescriptor names an existing credential in the options.
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+func Descriptor(id []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: "public-key", ID: EncodeID(id), Transports: transports}
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is PublicKeyCredentialCreationOptionsJSON, browsers turn it into the argument
+// of navigator.credentials.create() with PublicKeyCredential.parseCreationOptionsFromJSON().
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is PublicKeyCredentialRequestOptionsJSON, the argument of
+// navigator.credentials.get(). Without allowed credentials the browser offers discoverable ones.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// CreationOptions asks for a discoverable credential when the authenticator can make one,
+// so it can be used for passwordless logins. exclude lists the credentials the user already has.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user UserEntity, exclude []CredentialDescriptor) CreationOptions {
+	params := make([]CredentialParameter, 0, len(Algorithms))
+	for _, alg := range Algorithms {
+		params = append(params, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+
+	return CreationOptions{
+		Challenge:          EncodeID(challenge),
+		RP:                 RelyingPartyEntity{ID: rp.id, Name: rp.name},
+		User:               user,
+		PubKeyCredParams:   params,
+		Timeout:            rp.timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: rp.userVerification,
+		},
+		Attestation: rp.attestation,
+	}
+}
+
+// RequestOptions asks for one of the allowed credentials, or any discoverable one if there are none.
+// Passwordless logins pass requireUV, second factors use the configured requirement.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor, requireUV bool) RequestOptions {
+	uv := rp.userVerification
+	if requireUV {
+		uv = UserVerificationRequired
+	}
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+
+	return RequestOptions{
+		Challenge:        EncodeID(challenge),
+		Timeout:          rp.timeout.Milliseconds(),
+		RPID:             rp.id,
+		AllowCredentials: allow,
+		UserVerification: uv,
+	}
+}
+
+// Credential is a verified new credential.
+type Credential struct {
+	ID         []byte
+	PublicKey  []byte
+	SignCount  uint32
+	AAGUID     []byte
+	Format     string
+	Transports []string
+}
+
+// VerifyRegistration checks a registration made for challenge, §7.1 of the spec.
+func (rp *RelyingParty) VerifyRegistration(reg *Registration, challenge []byte) (Credential, error) {
+	if err := rp.checkClientData(reg.ClientData, typeCreate, challenge); err != nil {
+		return Credential{}, err
+	}
+	if err := rp.checkAuthData(reg.AuthData, rp.userVerification == UserVerificationRequired); err != nil {
+		return Credential{}, err
+	}
+	if !bytes.Equal(reg.AuthData.CredentialID, reg.CredentialID) {
+		return Credential{}, fmt.Errorf("%w: credential ID does not match the authenticator data", ErrVerification)
+	}
+
+	key, err := ParsePublicKey(reg.AuthData.PublicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := verifyAttestation(reg, key); err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:         reg.CredentialID,
+		PublicKey:  reg.AuthData.PublicKey,
+		SignCount:  reg.AuthData.SignCount,
+		AAGUID:     reg.AuthData.AAGUID,
+		Format:     reg.format,
+		Transports: reg.Transports,
+	}, nil
+}
+
+// VerifyAssertion checks an assertion made for challenge with the stored credential key and
+// returns the new value of the signature counter, §7.2 of the spec. A counter that did not grow
+// past signCount fails with ErrSignCount, unless the authenticator does not count at all.
+func (rp *RelyingParty) VerifyAssertion(a *Assertion, challenge []byte, publicKey []byte, signCount uint32, requireUV bool) (uint32, error) {
+	if err := rp.checkClientData(a.ClientData, typeGet, challenge); err != nil {
+		return 0, err
+	}
+	if err := rp.checkAuthData(a.AuthData, requireUV || rp.userVerification == UserVerificationRequired); err != nil {
+		return 0, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(a.clientDataJSON)
+	signed := append(append([]byte{}, a.authData...), clientDataHash[:]...)
+	if err := key.Verify(signed, a.signature); err != nil {
+		return 0, err
+	}
+
+	if (a.AuthData.SignCount != 0 || signCount != 0) && a.AuthData.SignCount <= signCount {
+		return 0, fmt.Errorf("%w: %d after %d", ErrSignCount, a.AuthData.SignCount, signCount)
+	}
+
+	return a.AuthData.SignCount, nil
+}
+
+func (rp *RelyingParty) checkClientData(c ClientData, typ string, challenge []byte) error {
+	if c.Type != typ {
+		return fmt.Errorf("%w: client data type %q", ErrVerification, c.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Challenge), []byte(EncodeID(challenge))) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrVerification)
+	}
+	if !slices.Contains(rp.origins, c.Origin) {
+		return fmt.Errorf("%w: origin %q is not allowed", ErrVerification, c.Origin)
+	}
+	if c.CrossOrigin {
+		return fmt.Errorf("%w: cross-origin ceremony", ErrVerification)
+	}
+	return nil
+}
+
+func (rp *RelyingParty) checkAuthData(d AuthenticatorData, requireUV bool) error {
+	if !bytes.Equal(d.RPIDHash, rp.rpIDHash[:]) {
+		return fmt.Errorf("%w: RP ID hash mismatch", ErrVerification)
+	}
+	if !d.Has(FlagUserPresent) {
+		return fmt.Errorf("%w: user is not present", ErrVerification)
+	}
+	if requireUV && !d.Has(FlagUserVerified) {
+		return fmt.Errorf("%w: user is not verified", ErrVerification)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"sso/internal/config"
+	"sso/internal/lib/webauthntest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const origin = "https://login.example.com"
+
+func newRP(t *testing.T, uv string) *RelyingParty {
+	t.Helper()
+
+	rp, err := New(config.WebAuthnConfig{
+		RPID:             "example.com",
+		RPName:           "SSO",
+		Origins:          []string{origin},
+		UserVerification: uv,
+		Attestation:      "direct",
+		ChallengeTTL:     time.Minute,
+	})
+	require.NoError(t, err)
+
+	return rp
+}
+
+func register(t *testing.T, rp *RelyingParty, authn *webauthntest.Authenticator) ([]byte, *Registration) {
+	t.Helper()
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options, err := json.Marshal(rp.CreationOptions(challenge, UserEntity{ID: EncodeID([]byte{0, 0, 0, 7}), Name: "alice"}, nil))
+	require.NoError(t, err)
+
+	resp, err := authn.Register(options)
+	require.NoError(t, err)
+	reg, err := ParseRegistration(resp)
+	require.NoError(t, err)
+
+	return challenge, reg
+}
+
+func assertion(t *testing.T, rp *RelyingParty, authn *webauthntest.Authenticator) ([]byte, *Assertion) {
+	t.Helper()
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options, err := json.Marshal(rp.RequestOptions(challenge, nil, false))
+	require.NoError(t, err)
+
+	resp, err := authn.Assert(options)
+	require.NoError(t, err)
+	a, err := ParseAssertion(resp)
+	require.NoError(t, err)
+
+	return challenge, a
+}
+
+func TestVerifyRegistration_Formats(t *testing.T) {
+	rp := newRP(t, UserVerificationPreferred)
+
+	for name, att := range map[string]webauthntest.Attestation{
+		"None":  webauthntest.AttestationNone,
+		"Self":  webauthntest.AttestationSelf,
+		"Basic": webauthntest.AttestationBasic,
+	} {
+		t.Run(name, func(t *testing.T) {
+			authn := webauthntest.New(origin)
+			authn.Attestation = att
+
+			challenge, reg := register(t, rp, authn)
+			cred, err := rp.VerifyRegistration(reg, challenge)
+			require.NoError(t, err)
+
+			assert.Equal(t, authn.CredentialID, cred.ID)
+			assert.Equal(t, webauthntest.AAGUID, cred.AAGUID)
+			assert.Equal(t, []string{"internal"}, cred.Transports)
+			_, err = ParsePublicKey(cred.PublicKey)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifyRegistration_FailCases(t *testing.T) {
+	rp := newRP(t, UserVerificationRequired)
+
+	t.Run("Wrong challenge", func(t *testing.T) {
+		_, reg := register(t, rp, webauthntest.New(origin))
+		other, err := NewChallenge()
+		require.NoError(t, err)
+
+		_, err = rp.VerifyRegistration(reg, other)
+		assert.ErrorIs(t, err, ErrVerification)
+	})
+
+	t.Run("Foreign origin", func(t *testing.T) {
+		challenge, reg := register(t, rp, webauthntest.New("https://evil.example.net"))
+
+		_, err := rp.VerifyRegistration(reg, challenge)
+		assert.ErrorIs(t, err, ErrVerification)
+	})
+
+	t.Run("User not verified", func(t *testing.T) {
+		authn := webauthntest.New(origin)
+		authn.UserVerified = false
+		challenge, reg := register(t, rp, authn)
+
+		_, err := rp.VerifyRegistration(reg, challenge)
+		assert.ErrorIs(t, err, ErrVerification)
+	})
+
+	t.Run("Assertion as registration", func(t *testing.T) {
+		authn := webauthntest.New(origin)
+		register(t, rp, authn)
+		challenge, err := NewChallenge()
+		require.NoError(t, err)
+		options, err := json.Marshal(rp.RequestOptions(challenge, nil, false))
+		require.NoError(t, err)
+		resp, err := authn.Assert(options)
+		require.NoError(t, err)
+
+		_, err = ParseRegistration(resp)
+		assert.ErrorIs(t, err, ErrInvalidResponse)
+	})
+
+	t.Run("Forged self attestation", func(t *testing.T) {
+		authn := webauthntest.New(origin)
+		authn.Attestation = webauthntest.AttestationSelf
+		challenge, reg := register(t, rp, authn)
+		reg.attStmt["sig"] = []byte("forged")
+
+		_, err := rp.VerifyRegistration(reg, challenge)
+		assert.ErrorIs(t, err, ErrVerification)
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		challenge, reg := register(t, rp, webauthntest.New(origin))
+		reg.format = "tpm"
+
+		_, err := rp.VerifyRegistration(reg, challenge)
+		assert.ErrorIs(t, err, ErrUnsupportedAttestation)
+	})
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		_, err := ParseRegistration([]byte(`{"type":"public-key","id":"AA","rawId":"AA","response":{}}`))
+		assert.ErrorIs(t, err, ErrInvalidResponse)
+	})
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	rp := newRP(t, UserVerificationPreferred)
+	authn := webauthntest.New(origin)
+	challenge, reg := register(t, rp, authn)
+	cred, err := rp.VerifyRegistration(reg, challenge)
+	require.NoError(t, err)
+
+	challenge, a := assertion(t, rp, authn)
+	assert.Equal(t, cred.ID, a.CredentialID)
+	assert.Equal(t, []byte{0, 0, 0, 7}, a.UserHandle)
+
+	count, err := rp.VerifyAssertion(a, challenge, cred.PublicKey, cred.SignCount, true)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), count)
+
+	t.Run("Replayed counter", func(t *testing.T) {
+		_, err := rp.VerifyAssertion(a, challenge, cred.PublicKey, count, false)
+		assert.ErrorIs(t, err, ErrSignCount)
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		other := webauthntest.New(origin)
+		c, r := register(t, rp, other)
+		otherCred, err := rp.VerifyRegistration(r, c)
+		require.NoError(t, err)
+
+		_, err = rp.VerifyAssertion(a, challenge, otherCred.PublicKey, 0, false)
+		assert.ErrorIs(t, err, ErrVerification)
+	})
+
+	t.Run("User verification required", func(t *testing.T) {
+		authn.UserVerified = false
+		challenge, a := assertion(t, rp, authn)
+
+		_, err := rp.VerifyAssertion(a, challenge, cred.PublicKey, count, true)
+		assert.ErrorIs(t, err, ErrVerification)
+
+		_, err = rp.VerifyAssertion(a, challenge, cred.PublicKey, count, false)
+		assert.NoError(t, err)
+	})
+}
+
+func TestNew_FailCases(t *testing.T) {
+	valid := config.WebAuthnConfig{RPID: "example.com", Origins: []string{origin}, UserVerification: "preferred", Attestation: "none"}
+
+	cases := map[string]func(cfg *config.WebAuthnConfig){
+		"Empty RP ID":             func(cfg *config.WebAuthnConfig) { cfg.RPID = "" },
+		"No origins":              func(cfg *config.WebAuthnConfig) { cfg.Origins = nil },
+		"Origin off the RP ID":    func(cfg *config.WebAuthnConfig) { cfg.Origins = []string{"https://notexample.com"} },
+		"Origin with a path":      func(cfg *config.WebAuthnConfig) { cfg.Origins = []string{origin + "/login"} },
+		"Unknown verification":    func(cfg *config.WebAuthnConfig) { cfg.UserVerification = "always" },
+		"Unsupported attestation": func(cfg *config.WebAuthnConfig) { cfg.Attestation = "enterprise" },
+	}
+
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := valid
+			mutate(&cfg)
+
+			_, err := New(cfg)
+			assert.Error(t, err)
+		})
+	}
+}
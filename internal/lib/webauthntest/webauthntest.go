@@ -0,0 +1,284 @@
+// Package webauthntest provides a software WebAuthn authenticator for tests.
+//
+// It answers the JSON options of the ceremonies with the JSON of the credential a browser
+// would return, signing with a P-256 key the way a platform authenticator does.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sso/internal/lib/cbor"
+	"time"
+)
+
+type Attestation int
+
+const (
+	// AttestationNone sends no attestation statement.
+	AttestationNone Attestation = iota
+	// AttestationSelf signs a packed statement with the credential key.
+	AttestationSelf
+	// AttestationBasic signs a packed statement with a key of an attestation certificate.
+	AttestationBasic
+)
+
+const (
+	flagUP = 0x01
+	flagUV = 0x04
+	flagAT = 0x40
+
+	algES256 = -7
+)
+
+// AAGUID identifies the model of the test authenticator.
+var AAGUID = []byte("sso-test-authn-1")
+
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// Authenticator holds one credential, created by Register.
+type Authenticator struct {
+	Origin      string
+	Attestation Attestation
+	// UserVerified sets the UV flag, as after a PIN or biometric check.
+	UserVerified bool
+	// SignCount is the signature counter, assertions increase it by one.
+	SignCount uint32
+
+	CredentialID []byte
+	UserHandle   []byte
+	key          *ecdsa.PrivateKey
+}
+
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin, UserVerified: true}
+}
+
+type creationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID string `json:"id"`
+	} `json:"rp"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+}
+
+type requestOptions struct {
+	Challenge        string `json:"challenge"`
+	RPID             string `json:"rpId"`
+	AllowCredentials []struct {
+		ID string `json:"id"`
+	} `json:"allowCredentials"`
+}
+
+// Register creates a new credential for the creation options and returns the registration JSON.
+func (a *Authenticator) Register(options []byte) ([]byte, error) {
+	var opts creationOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	userHandle, err := decode(opts.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	a.key, a.CredentialID, a.UserHandle = key, id, userHandle
+
+	publicKey, err := cbor.Encode(map[int]any{
+		1:  2, // EC2
+		3:  algES256,
+		-1: 1, // P-256
+		-2: key.X.FillBytes(make([]byte, 32)),
+		-3: key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authData(opts.RP.ID, flagAT)
+	authData = append(authData, AAGUID...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, publicKey...)
+
+	clientData, err := a.clientData("webauthn.create", opts.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	format, attStmt, err := a.attest(authData, clientData)
+	if err != nil {
+		return nil, err
+	}
+	attObj, err := cbor.Encode(map[string]any{
+		"fmt":      format,
+		"attStmt":  attStmt,
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    encode(id),
+		"rawId": encode(id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(clientData),
+			"attestationObject": encode(attObj),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// Assert signs the challenge of the request options and returns the assertion JSON.
+func (a *Authenticator) Assert(options []byte) ([]byte, error) {
+	if a.key == nil {
+		return nil, errors.New("webauthntest: no credential")
+	}
+
+	var opts requestOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+	if len(opts.AllowCredentials) > 0 {
+		allowed := false
+		for _, c := range opts.AllowCredentials {
+			allowed = allowed || c.ID == encode(a.CredentialID)
+		}
+		if !allowed {
+			return nil, errors.New("webauthntest: credential is not allowed")
+		}
+	}
+
+	a.SignCount++
+	authData := a.authData(opts.RPID, 0)
+	clientData, err := a.clientData("webauthn.get", opts.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sign(a.key, authData, clientData)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    encode(a.CredentialID),
+		"rawId": encode(a.CredentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authData),
+			"signature":         encode(sig),
+			"userHandle":        encode(a.UserHandle),
+		},
+	})
+}
+
+func (a *Authenticator) authData(rpID string, flags byte) []byte {
+	flags |= flagUP
+	if a.UserVerified {
+		flags |= flagUV
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *Authenticator) clientData(typ string, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) attest(authData []byte, clientData []byte) (string, map[string]any, error) {
+	switch a.Attestation {
+	case AttestationSelf:
+		sig, err := sign(a.key, authData, clientData)
+		if err != nil {
+			return "", nil, err
+		}
+		return "packed", map[string]any{"alg": algES256, "sig": sig}, nil
+	case AttestationBasic:
+		key, cert, err := attestationCertificate()
+		if err != nil {
+			return "", nil, err
+		}
+		sig, err := sign(key, authData, clientData)
+		if err != nil {
+			return "", nil, err
+		}
+		return "packed", map[string]any{"alg": algES256, "sig": sig, "x5c": []any{cert}}, nil
+	default:
+		return "none", map[string]any{}, nil
+	}
+}
+
+// attestationCertificate makes a certificate that meets the requirements of packed attestation.
+func attestationCertificate() (*ecdsa.PrivateKey, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	aaguid, err := asn1.Marshal(AAGUID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"SSO Tests"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "SSO Test Authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: oidAAGUID, Value: aaguid}},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("webauthntest: certificate: %w", err)
+	}
+
+	return key, cert, nil
+}
+
+func sign(key *ecdsa.PrivateKey, authData []byte, clientData []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,143 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/identifiers"
+	"sso/internal/lib/mailer"
+	"time"
+)
+
+// Account is the self-service side of user management: everything a user does
+// with their own account besides logging in.
+type Account struct {
+	log            *slog.Logger
+	userProvider   UserProvider
+	userUpdater    UserUpdater
+	tokenStorage   TokenStorage
+	sessionRevoker SessionRevoker
+	userRemover    UserRemover
+	dataProvider   UserDataProvider
+	mailer         mailer.Mailer
+	cfg            config.AccountConfig
+	passwordPolicy PasswordPolicy
+	passwordHasher PasswordHasher
+	auditLog       AuditLog
+	orgProvider    OrgProvider
+	throttle       PasswordThrottle
+}
+
+type UserProvider interface {
+	User(ctx context.Context, orgID int64, email string) (models.User, error)
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+}
+
+type UserUpdater interface {
+	SetEmailVerified(ctx context.Context, userID int64, email string) error
+	UpdatePassword(ctx context.Context, userID int64, passwordHash []byte) error
+	UpdateEmail(ctx context.Context, userID int64, email string) error
+	UpdateIdentifiers(ctx context.Context, userID int64, username string, phone string) error
+}
+
+type TokenStorage interface {
+	SaveVerificationToken(ctx context.Context, token models.VerificationToken) error
+	VerificationToken(ctx context.Context, purpose string, hash []byte) (models.VerificationToken, error)
+	UseVerificationToken(ctx context.Context, purpose string, hash []byte) (models.VerificationToken, error)
+}
+
+// PasswordPolicy rejects weak passwords with a *passwordpolicy.ViolationError, see package passwordpolicy.
+type PasswordPolicy interface {
+	Check(appID int32, password string, userInputs ...string) error
+}
+
+// PasswordHasher makes the hashes of new passwords and checks stored ones, see passwords.Hasher.
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Compare(hash []byte, password string) error
+}
+
+// AuditLog records password changes and session revocations in the security audit log, see audit.Log.
+type AuditLog interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// OrgProvider returns the email scope of an organization the throttle counters are keyed in, see auth.OrgProvider.
+type OrgProvider interface {
+	EmailScope(ctx context.Context, orgID int64) (int64, error)
+}
+
+// PasswordThrottle counts wrong passwords of signed in users together with failed logins, see auth.LoginThrottle.
+type PasswordThrottle interface {
+	CheckPassword(ctx context.Context, scope int64, email string, ip string) error
+	FailPassword(ctx context.Context, scope int64, email string, ip string) error
+	SucceedPassword(ctx context.Context, scope int64, email string, ip string) error
+}
+
+type UserRemover interface {
+	ScheduleUserDeletion(ctx context.Context, userID int64) error
+	UsersToPurge(ctx context.Context, deletedBefore time.Time) ([]int64, error)
+	PurgeUser(ctx context.Context, userID int64, anonymize bool) error
+}
+
+type UserDataProvider interface {
+	UserData(ctx context.Context, userID int64) (models.UserData, error)
+}
+
+type SessionRevoker interface {
+	// RevokeSessions revokes all sessions of the user except exceptID.
+	RevokeSessions(ctx context.Context, userID int64, exceptID string) (int64, error)
+}
+
+var (
+	ErrInvalidToken    = errors.New("Invalid or expired token")
+	ErrInvalidPassword = errors.New("Invalid password")
+	ErrEmailTaken      = errors.New("Email already taken")
+	ErrUserNotFound    = errors.New("User not found")
+	ErrUsernameTaken   = errors.New("Username already taken")
+	ErrPhoneTaken      = errors.New("Phone number already taken")
+)
+
+// normalizeEmail returns the email the way it is stored, malformed emails are left as is to fail the lookup.
+func normalizeEmail(email string) string {
+	if normalized, err := identifiers.NormalizeEmail(email); err == nil {
+		return normalized
+	}
+	return email
+}
+
+func New(
+	log *slog.Logger,
+	userProvider UserProvider,
+	userUpdater UserUpdater,
+	tokenStorage TokenStorage,
+	sessionRevoker SessionRevoker,
+	userRemover UserRemover,
+	dataProvider UserDataProvider,
+	mailer mailer.Mailer,
+	cfg config.AccountConfig,
+	passwordPolicy PasswordPolicy,
+	passwordHasher PasswordHasher,
+	auditLog AuditLog,
+	orgProvider OrgProvider,
+	throttle PasswordThrottle,
+) *Account {
+	return &Account{
+		log:            log,
+		userProvider:   userProvider,
+		userUpdater:    userUpdater,
+		tokenStorage:   tokenStorage,
+		sessionRevoker: sessionRevoker,
+		userRemover:    userRemover,
+		dataProvider:   dataProvider,
+		mailer:         mailer,
+		cfg:            cfg,
+		passwordPolicy: passwordPolicy,
+		passwordHasher: passwordHasher,
+		auditLog:       auditLog,
+		orgProvider:    orgProvider,
+		throttle:       throttle,
+	}
+}
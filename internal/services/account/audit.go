@@ -0,0 +1,36 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+)
+
+// auditPassword records a password change or reset of the user, err is nil for a successful one.
+func (a *Account) auditPassword(ctx context.Context, eventType string, userID int64, appID int32, err error) {
+	event := models.AuditEvent{
+		Type:         eventType,
+		Outcome:      models.AuditSuccess,
+		ActorID:      userID,
+		TargetUserID: userID,
+		AppID:        appID,
+	}
+	if err != nil {
+		event.Outcome = models.AuditFailure
+		event.Details = err.Error()
+	}
+	a.auditLog.Record(ctx, event)
+}
+
+// auditRevocation records the revocation of the sessions of the user the action caused,
+// actorID is 0 when the action may be done by the user or an admin.
+func (a *Account) auditRevocation(ctx context.Context, actorID int64, userID int64, appID int32, action string, revoked int64) {
+	a.auditLog.Record(ctx, models.AuditEvent{
+		Type:         models.AuditSessionRevocation,
+		Outcome:      models.AuditSuccess,
+		ActorID:      actorID,
+		TargetUserID: userID,
+		AppID:        appID,
+		Details:      fmt.Sprintf("%s: %d sessions revoked", action, revoked),
+	})
+}
@@ -0,0 +1,280 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/identifiers"
+	"sso/internal/lib/mailer"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+// ChangePassword replaces the password of an authenticated user after checking the old one
+// and revokes all sessions but the current one. The new password must meet the policy of the session app.
+func (a *Account) ChangePassword(
+	ctx context.Context,
+	session models.Session,
+	oldPassword string,
+	newPassword string,
+) error {
+	const op = "account.ChangePassword"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Changing password")
+
+	user, err := a.checkPassword(ctx, session.UserID, oldPassword)
+	if err != nil {
+		log.Warn("Failed to check old password", prettylogger.Err(err))
+		if errors.Is(err, ErrInvalidPassword) {
+			a.auditPassword(ctx, models.AuditPasswordChange, session.UserID, int32(session.AppID), err)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.checkPasswordPolicy(log, int32(session.AppID), newPassword, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passwordHash, err := a.passwordHasher.Hash(newPassword)
+	if err != nil {
+		log.Error("Failed to generate password hash", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userUpdater.UpdatePassword(ctx, session.UserID, passwordHash); err != nil {
+		log.Error("Failed to update password", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditPassword(ctx, models.AuditPasswordChange, session.UserID, int32(session.AppID), nil)
+
+	revoked, err := a.sessionRevoker.RevokeSessions(ctx, session.UserID, session.ID)
+	if err != nil {
+		log.Error("Failed to revoke sessions", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	a.auditRevocation(ctx, session.UserID, session.UserID, int32(session.AppID), models.AuditPasswordChange, revoked)
+
+	log.Info("Password changed", slog.Int64("revoked_sessions", revoked))
+
+	return nil
+}
+
+// ChangeEmail starts an email change of an authenticated user: it checks the password
+// and sends a confirmation link to the new address. The email is switched by ConfirmEmailChange.
+func (a *Account) ChangeEmail(
+	ctx context.Context,
+	session models.Session,
+	newEmail string,
+	password string,
+) error {
+	const op = "account.ChangeEmail"
+	newEmail = normalizeEmail(newEmail)
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+		slog.String("new_email", newEmail),
+	)
+	log.Info("Changing email")
+
+	user, err := a.checkPassword(ctx, session.UserID, password)
+	if err != nil {
+		log.Warn("Failed to check password", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = a.userProvider.User(ctx, user.OrgID, newEmail)
+	if err == nil {
+		log.Warn("Email already taken")
+		return fmt.Errorf("%s: %w", op, ErrEmailTaken)
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := a.issueToken(ctx, user.ID, models.TokenPurposeEmailChange, newEmail, a.cfg.EmailChangeTokenTTL)
+	if err != nil {
+		log.Error("Failed to issue email change token", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf(
+			"Follow the link to use this address for your account instead of %s:\n\n%s\n\nThe link expires in %s. If you did not request it, ignore this message.\n",
+			user.Email, fmt.Sprintf(a.cfg.EmailChangeLink, token), a.cfg.EmailChangeTokenTTL,
+		),
+	})
+	if err != nil {
+		log.Error("Failed to send email change confirmation", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Email change confirmation sent")
+
+	return nil
+}
+
+// UpdateIdentifiers replaces the username and phone number the authenticated user can log in with,
+// empty values remove them.
+func (a *Account) UpdateIdentifiers(ctx context.Context, session models.Session, username string, phone string) error {
+	const op = "account.UpdateIdentifiers"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Updating login identifiers")
+
+	var err error
+	if username != "" {
+		if username, err = identifiers.NormalizeUsername(username); err != nil {
+			log.Warn("Invalid username")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if phone != "" {
+		if phone, err = identifiers.NormalizePhone(phone); err != nil {
+			log.Warn("Invalid phone number")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := a.userUpdater.UpdateIdentifiers(ctx, session.UserID, username, phone); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUsernameTaken):
+			log.Warn("Username already taken")
+			return fmt.Errorf("%s: %w", op, ErrUsernameTaken)
+		case errors.Is(err, storage.ErrPhoneTaken):
+			log.Warn("Phone number already taken")
+			return fmt.Errorf("%s: %w", op, ErrPhoneTaken)
+		case errors.Is(err, storage.ErrUserNotFound):
+			log.Warn("User not found")
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to update login identifiers", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Login identifiers updated")
+
+	return nil
+}
+
+// ConfirmEmailChange consumes an email change token of the authenticated user, switches the email,
+// notifies the old address and revokes all sessions but the current one.
+func (a *Account) ConfirmEmailChange(ctx context.Context, session models.Session, token string) error {
+	const op = "account.ConfirmEmailChange"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Confirming email change")
+
+	// The token of another user is rejected before it is used, otherwise anyone signed in
+	// could burn the pending email change of that user.
+	pending, err := a.tokenStorage.VerificationToken(ctx, models.TokenPurposeEmailChange, tokens.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Warn("Invalid email change token")
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to get email change token", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if pending.UserID != session.UserID {
+		log.Warn("Email change token belongs to another user")
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	t, err := a.tokenStorage.UseVerificationToken(ctx, models.TokenPurposeEmailChange, tokens.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Warn("Invalid email change token")
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to use email change token", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, session.UserID)
+	if err != nil {
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userUpdater.UpdateEmail(ctx, user.ID, t.Email); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Warn("Email already taken")
+			return fmt.Errorf("%s: %w", op, ErrEmailTaken)
+		}
+		log.Error("Failed to update email", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := a.sessionRevoker.RevokeSessions(ctx, user.ID, session.ID)
+	if err != nil {
+		log.Error("Failed to revoke sessions", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	a.auditRevocation(ctx, user.ID, user.ID, int32(session.AppID), "email_change", revoked)
+
+	log.Info("Email changed", slog.Int64("revoked_sessions", revoked))
+
+	err = a.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your email was changed",
+		Body: fmt.Sprintf(
+			"The email of your account was changed from %s to %s.\n\nIf you did not do it, reset your password and contact support.\n",
+			user.Email, t.Email,
+		),
+	})
+	if err != nil {
+		// The change is already done, a lost notification must not fail it.
+		log.Error("Failed to notify old email", prettylogger.Err(err))
+	}
+
+	return nil
+}
+
+// checkPassword returns the user if password matches the stored hash, imported hashes included.
+// Wrong passwords count as failed logins with the user's email, so a stolen session does not
+// let anyone guess the password faster than the login does.
+func (a *Account) checkPassword(ctx context.Context, userID int64, password string) (models.User, error) {
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+
+	scope, err := a.orgProvider.EmailScope(ctx, user.OrgID)
+	if err != nil {
+		return models.User{}, err
+	}
+	ip := clientip.FromContext(ctx)
+	if err := a.throttle.CheckPassword(ctx, scope, user.Email, ip); err != nil {
+		return models.User{}, err
+	}
+
+	if err := a.passwordHasher.Compare(user.PasswordHash, password); err != nil {
+		if err := a.throttle.FailPassword(ctx, scope, user.Email, ip); err != nil {
+			a.log.Error("Failed to count wrong password", slog.Int64("user_id", userID), prettylogger.Err(err))
+		}
+		return models.User{}, ErrInvalidPassword
+	}
+	if err := a.throttle.SucceedPassword(ctx, scope, user.Email, ip); err != nil {
+		a.log.Error("Failed to reset wrong passwords", slog.Int64("user_id", userID), prettylogger.Err(err))
+	}
+
+	return user, nil
+}
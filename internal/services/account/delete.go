@@ -0,0 +1,103 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// DeleteOwnAccount deletes the account of the authenticated user after checking the password.
+func (a *Account) DeleteOwnAccount(ctx context.Context, session models.Session, password string) (time.Time, error) {
+	const op = "account.DeleteOwnAccount"
+
+	if _, err := a.checkPassword(ctx, session.UserID, password); err != nil {
+		a.log.Warn(
+			"Failed to check password",
+			slog.String("op", op),
+			slog.Int64("user_id", session.UserID),
+			prettylogger.Err(err),
+		)
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purgeAt, err := a.DeleteAccount(ctx, session.UserID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purgeAt, nil
+}
+
+// DeleteAccount revokes all sessions of the user and schedules the account for purging
+// after the grace period. It returns when the data will be purged.
+func (a *Account) DeleteAccount(ctx context.Context, userID int64) (time.Time, error) {
+	const op = "account.DeleteAccount"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+	log.Info("Deleting account")
+
+	if err := a.userRemover.ScheduleUserDeletion(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return time.Time{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to schedule deletion", prettylogger.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := a.sessionRevoker.RevokeSessions(ctx, userID, "")
+	if err != nil {
+		log.Error("Failed to revoke sessions", prettylogger.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+	a.auditRevocation(ctx, 0, userID, 0, "account_deletion", revoked)
+
+	if a.cfg.DeletionGracePeriod <= 0 {
+		if err := a.userRemover.PurgeUser(ctx, userID, a.cfg.AnonymizeDeleted); err != nil {
+			log.Error("Failed to purge user", prettylogger.Err(err))
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Info("Account purged", slog.Int64("revoked_sessions", revoked))
+		return time.Now(), nil
+	}
+
+	purgeAt := time.Now().Add(a.cfg.DeletionGracePeriod)
+	log.Info("Account scheduled for deletion", slog.Time("purge_at", purgeAt), slog.Int64("revoked_sessions", revoked))
+
+	return purgeAt, nil
+}
+
+// PurgeDeletedAccounts purges the accounts whose grace period is over and returns how many were purged.
+func (a *Account) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	const op = "account.PurgeDeletedAccounts"
+	log := a.log.With(slog.String("op", op))
+
+	ids, err := a.userRemover.UsersToPurge(ctx, time.Now().Add(-a.cfg.DeletionGracePeriod))
+	if err != nil {
+		log.Error("Failed to get users to purge", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purged := 0
+	for _, id := range ids {
+		if err := a.userRemover.PurgeUser(ctx, id, a.cfg.AnonymizeDeleted); err != nil {
+			log.Error("Failed to purge user", slog.Int64("user_id", id), prettylogger.Err(err))
+			return purged, fmt.Errorf("%s: %w", op, err)
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		log.Info("Deleted accounts purged", slog.Int("count", purged))
+	}
+
+	return purged, nil
+}
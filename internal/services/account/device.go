@@ -0,0 +1,59 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/mailer"
+	"strings"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// NotifyNewDevice tells the user that their account was logged in to from a device they have not
+// used before, with the "this wasn't me" link that signs the user out everywhere if there is one.
+func (a *Account) NotifyNewDevice(ctx context.Context, user models.User, device models.KnownDevice, revokeLink string) error {
+	const op = "account.NotifyNewDevice"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+	)
+
+	var body strings.Builder
+	fmt.Fprintf(
+		&body,
+		"Your account was just logged in to from a device you have not used before.\n\nTime: %s\nIP address: %s\nDevice: %s\n\nIf it was you, ignore this message.",
+		device.FirstSeenAt.UTC().Format(time.RFC1123),
+		orUnknown(device.LastIP),
+		orUnknown(device.UserAgent),
+	)
+	if revokeLink != "" {
+		fmt.Fprintf(&body, " If it was not you, sign out of all devices here:\n\n%s\n\nand", revokeLink)
+	} else {
+		body.WriteString(" If it was not you,")
+	}
+	body.WriteString(" reset your password right away, someone knows it.\n")
+
+	err := a.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "New sign-in to your account",
+		Body:    body.String(),
+	})
+	if err != nil {
+		log.Error("Failed to send new device email", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("New device email sent")
+
+	return nil
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
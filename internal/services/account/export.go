@@ -0,0 +1,263 @@
+package account
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/webauthn"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// userExport is the JSON document returned by ExportUserData. Secrets such as
+// password and token hashes are left out, everything else stored about the user is included.
+type userExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	User       userRecord       `json:"user"`
+	IsAdmin    bool             `json:"is_admin"`
+	Roles      []roleRecord     `json:"roles"`
+	Orgs       []orgRecord      `json:"organizations"`
+	Profile    profileRecord    `json:"profile"`
+	Identities []identityRecord `json:"identities"`
+	Sessions   []sessionRecord  `json:"sessions"`
+	Tokens     []tokenRecord    `json:"pending_tokens"`
+	MFA        mfaRecord        `json:"mfa"`
+	Devices    []deviceRecord   `json:"known_devices"`
+}
+
+type userRecord struct {
+	ID                  int64      `json:"id"`
+	Email               string     `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	Username            string     `json:"username,omitempty"`
+	Phone               string     `json:"phone,omitempty"`
+	HasPassword         bool       `json:"has_password"`
+	OrgID               int64      `json:"org_id,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	Status              string     `json:"status"`
+	StatusReason        string     `json:"status_reason,omitempty"`
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty"`
+}
+
+type profileRecord struct {
+	DisplayName  string          `json:"display_name,omitempty"`
+	GivenName    string          `json:"given_name,omitempty"`
+	FamilyName   string          `json:"family_name,omitempty"`
+	Locale       string          `json:"locale,omitempty"`
+	Timezone     string          `json:"timezone,omitempty"`
+	AvatarURL    string          `json:"avatar_url,omitempty"`
+	AppMetadata  json.RawMessage `json:"app_metadata"`
+	UserMetadata json.RawMessage `json:"user_metadata"`
+}
+
+type roleRecord struct {
+	Name  string `json:"name"`
+	OrgID int64  `json:"org_id,omitempty"`
+	AppID int    `json:"app_id"`
+}
+
+type orgRecord struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type identityRecord struct {
+	Provider   string            `json:"provider"`
+	Subject    string            `json:"subject"`
+	Username   string            `json:"username,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Groups     []string          `json:"groups,omitempty"`
+}
+
+type sessionRecord struct {
+	ID        string     `json:"id"`
+	AppID     int        `json:"app_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// mfaRecord lists the second factors of the user, their secrets are left out.
+type mfaRecord struct {
+	TOTP          *totpRecord          `json:"totp,omitempty"`
+	WebAuthn      []webAuthnRecord     `json:"webauthn,omitempty"`
+	RecoveryCodes []recoveryCodeRecord `json:"recovery_codes,omitempty"`
+}
+
+type totpRecord struct {
+	EnrolledAt  time.Time  `json:"enrolled_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+}
+
+type webAuthnRecord struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	AAGUID     string     `json:"aaguid,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type recoveryCodeRecord struct {
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// deviceRecord is a device the user logged in from, the hashes it is recognized by are left out.
+type deviceRecord struct {
+	UserAgent   string    `json:"user_agent,omitempty"`
+	Network     string    `json:"network,omitempty"`
+	LastIP      string    `json:"last_ip,omitempty"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+type tokenRecord struct {
+	Purpose   string    `json:"purpose"`
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExportUserData returns everything stored about the user as a JSON document.
+func (a *Account) ExportUserData(ctx context.Context, userID int64) ([]byte, error) {
+	const op = "account.ExportUserData"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+	log.Info("Exporting user data")
+
+	data, err := a.dataProvider.UserData(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to get user data", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	export := userExport{
+		ExportedAt: time.Now().UTC(),
+		User: userRecord{
+			ID:                  data.User.ID,
+			Email:               data.User.Email,
+			EmailVerified:       data.User.EmailVerified,
+			Username:            data.User.Username,
+			Phone:               data.User.Phone,
+			HasPassword:         len(data.User.PasswordHash) > 0,
+			OrgID:               data.User.OrgID,
+			CreatedAt:           data.User.CreatedAt,
+			Status:              string(data.User.Status),
+			StatusReason:        data.User.StatusReason,
+			DeletionRequestedAt: optionalTime(data.User.DeletedAt),
+		},
+		IsAdmin: data.IsAdmin,
+		Profile: profileRecord{
+			DisplayName:  data.Profile.DisplayName,
+			GivenName:    data.Profile.GivenName,
+			FamilyName:   data.Profile.FamilyName,
+			Locale:       data.Profile.Locale,
+			Timezone:     data.Profile.Timezone,
+			AvatarURL:    data.Profile.AvatarURL,
+			AppMetadata:  data.Profile.AppMetadata,
+			UserMetadata: data.Profile.UserMetadata,
+		},
+		Roles:      make([]roleRecord, 0, len(data.Roles)),
+		Orgs:       make([]orgRecord, 0, len(data.Orgs)),
+		Identities: make([]identityRecord, 0, len(data.Identities)),
+		Sessions:   make([]sessionRecord, 0, len(data.Sessions)),
+		Tokens:     make([]tokenRecord, 0, len(data.Tokens)),
+		Devices:    make([]deviceRecord, 0, len(data.KnownDevices)),
+	}
+	for _, assignment := range data.Roles {
+		export.Roles = append(export.Roles, roleRecord{
+			Name:  assignment.Role.Name,
+			OrgID: assignment.Role.OrgID,
+			AppID: assignment.AppID,
+		})
+	}
+	for _, org := range data.Orgs {
+		export.Orgs = append(export.Orgs, orgRecord{
+			ID:   org.ID,
+			Name: org.Name,
+			Slug: org.Slug,
+		})
+	}
+	for _, identity := range data.Identities {
+		export.Identities = append(export.Identities, identityRecord{
+			Provider:   identity.Provider,
+			Subject:    identity.Subject,
+			Username:   identity.Username,
+			Attributes: identity.Attributes,
+			Groups:     identity.Groups,
+		})
+	}
+	for _, session := range data.Sessions {
+		export.Sessions = append(export.Sessions, sessionRecord{
+			ID:        session.ID,
+			AppID:     session.AppID,
+			ExpiresAt: session.ExpiresAt,
+			RevokedAt: optionalTime(session.RevokedAt),
+		})
+	}
+	for _, token := range data.Tokens {
+		export.Tokens = append(export.Tokens, tokenRecord{
+			Purpose:   token.Purpose,
+			Email:     token.Email,
+			ExpiresAt: token.ExpiresAt,
+		})
+	}
+	for _, device := range data.KnownDevices {
+		export.Devices = append(export.Devices, deviceRecord{
+			UserAgent:   device.UserAgent,
+			Network:     device.IPPrefix,
+			LastIP:      device.LastIP,
+			FirstSeenAt: device.FirstSeenAt,
+			LastSeenAt:  device.LastSeenAt,
+		})
+	}
+
+	if data.TOTP.UserID != 0 {
+		export.MFA.TOTP = &totpRecord{
+			EnrolledAt:  data.TOTP.CreatedAt,
+			ConfirmedAt: optionalTime(data.TOTP.ConfirmedAt),
+		}
+	}
+	for _, cred := range data.WebAuthnCredentials {
+		export.MFA.WebAuthn = append(export.MFA.WebAuthn, webAuthnRecord{
+			ID:         webauthn.EncodeID(cred.ID),
+			Name:       cred.Name,
+			AAGUID:     hex.EncodeToString(cred.AAGUID),
+			CreatedAt:  cred.CreatedAt,
+			LastUsedAt: optionalTime(cred.LastUsedAt),
+		})
+	}
+	for _, code := range data.RecoveryCodes {
+		export.MFA.RecoveryCodes = append(export.MFA.RecoveryCodes, recoveryCodeRecord{
+			CreatedAt: code.CreatedAt,
+			UsedAt:    optionalTime(code.UsedAt),
+		})
+	}
+
+	b, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		log.Error("Failed to marshal user data", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("User data exported")
+
+	return b, nil
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
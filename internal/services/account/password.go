@@ -0,0 +1,158 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/mailer"
+	"sso/internal/lib/passwordpolicy"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+// RequestPasswordReset emails a short-lived password reset link to the user with the email
+// in the organization, orgID 0 looks in the shared scope.
+// It succeeds for unknown emails too, so the result does not reveal which emails are registered.
+// The link is issued and mailed in the background, so the response takes as long for any email.
+func (a *Account) RequestPasswordReset(ctx context.Context, orgID int64, email string) error {
+	const op = "account.RequestPasswordReset"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", orgID),
+		slog.String("email", email),
+	)
+	log.Info("Requesting password reset")
+
+	user, err := a.userProvider.User(ctx, orgID, normalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return nil
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go a.sendPasswordReset(context.WithoutCancel(ctx), log, user)
+
+	return nil
+}
+
+func (a *Account) sendPasswordReset(ctx context.Context, log *slog.Logger, user models.User) {
+	token, err := a.issueToken(ctx, user.ID, models.TokenPurposePasswordReset, user.Email, a.cfg.PasswordResetTokenTTL)
+	if err != nil {
+		log.Error("Failed to issue password reset token", prettylogger.Err(err))
+		return
+	}
+
+	err = a.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Follow the link to choose a new password:\n\n%s\n\nThe link expires in %s and can be used once. If you did not request it, ignore this message, your password stays the same.\n",
+			fmt.Sprintf(a.cfg.PasswordResetLink, token), a.cfg.PasswordResetTokenTTL,
+		),
+	})
+	if err != nil {
+		log.Error("Failed to send password reset email", prettylogger.Err(err))
+		return
+	}
+
+	log.Info("Password reset email sent", slog.Int64("user_id", user.ID))
+}
+
+// ResetPassword consumes a password reset token, sets the new password and revokes all sessions of the user.
+// The token is only valid while the user still has the email it was sent to.
+// The password must meet the policy of the app, appID 0 stands for the default policy. A rejected
+// password leaves the token valid, so the user can try another one.
+func (a *Account) ResetPassword(ctx context.Context, appID int32, token string, password string) error {
+	const op = "account.ResetPassword"
+	log := a.log.With(slog.String("op", op))
+	log.Info("Resetting password")
+
+	pending, err := a.tokenStorage.VerificationToken(ctx, models.TokenPurposePasswordReset, tokens.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Warn("Invalid password reset token")
+			a.auditPassword(ctx, models.AuditPasswordReset, 0, appID, ErrInvalidToken)
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to get password reset token", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.UserByID(ctx, pending.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// A link sent to a former email must not take over the account after the email changed.
+	if pending.Email != user.Email {
+		log.Warn("Password reset token was sent to another email", slog.Int64("user_id", user.ID))
+		a.auditPassword(ctx, models.AuditPasswordReset, user.ID, appID, ErrInvalidToken)
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	if err := a.checkPasswordPolicy(log, appID, password, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	t, err := a.tokenStorage.UseVerificationToken(ctx, models.TokenPurposePasswordReset, tokens.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Warn("Invalid password reset token")
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to use password reset token", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", t.UserID))
+
+	passwordHash, err := a.passwordHasher.Hash(password)
+	if err != nil {
+		log.Error("Failed to generate password hash", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userUpdater.UpdatePassword(ctx, t.UserID, passwordHash); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to update password", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditPassword(ctx, models.AuditPasswordReset, t.UserID, appID, nil)
+
+	revoked, err := a.sessionRevoker.RevokeSessions(ctx, t.UserID, "")
+	if err != nil {
+		log.Error("Failed to revoke sessions", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	a.auditRevocation(ctx, t.UserID, t.UserID, appID, models.AuditPasswordReset, revoked)
+
+	log.Info("Password reset", slog.Int64("revoked_sessions", revoked))
+
+	return nil
+}
+
+// checkPasswordPolicy checks a new password of the user against the policy of the app.
+func (a *Account) checkPasswordPolicy(log *slog.Logger, appID int32, password string, user models.User) error {
+	err := a.passwordPolicy.Check(appID, password, user.Email, user.Username)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, passwordpolicy.ErrViolated) {
+		log.Warn("Password does not meet the policy", prettylogger.Err(err))
+		return err
+	}
+	log.Error("Failed to check password policy", prettylogger.Err(err))
+	return err
+}
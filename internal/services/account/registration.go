@@ -0,0 +1,51 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/mailer"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+// NotifyRegistrationAttempt tells the user with the email in the organization that someone tried
+// to register it again, Register does it instead of failing in the enumeration-safe mode.
+func (a *Account) NotifyRegistrationAttempt(ctx context.Context, orgID int64, email string) error {
+	const op = "account.NotifyRegistrationAttempt"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", orgID),
+		slog.String("email", email),
+	)
+	log.Info("Notifying about registration attempt")
+
+	user, err := a.userProvider.User(ctx, orgID, normalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return nil
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "You already have an account",
+		Body: fmt.Sprintf(
+			"Someone tried to create an account with your email address, but you already have one. Sign in here:\n\n%s\n\nIf you forgot your password, you can reset it on the sign-in page. If it was not you, ignore this message, nobody got access to your account.\n",
+			a.cfg.SignInLink,
+		),
+	})
+	if err != nil {
+		log.Error("Failed to send registration attempt email", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Registration attempt email sent", slog.Int64("user_id", user.ID))
+
+	return nil
+}
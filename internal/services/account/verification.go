@@ -0,0 +1,122 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/mailer"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// SendVerification emails a one-time verification link to the user with the email in the organization,
+// orgID 0 looks in the shared scope.
+// Unknown and already verified emails are ignored, so the result does not reveal which emails are registered.
+// The link is issued and mailed in the background, so the response takes as long for any email.
+func (a *Account) SendVerification(ctx context.Context, orgID int64, email string) error {
+	const op = "account.SendVerification"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", orgID),
+		slog.String("email", email),
+	)
+	log.Info("Sending verification email")
+
+	user, err := a.userProvider.User(ctx, orgID, normalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return nil
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if user.EmailVerified {
+		log.Info("Email already verified")
+		return nil
+	}
+
+	go a.sendVerification(context.WithoutCancel(ctx), log, user)
+
+	return nil
+}
+
+func (a *Account) sendVerification(ctx context.Context, log *slog.Logger, user models.User) {
+	token, err := a.issueToken(ctx, user.ID, models.TokenPurposeEmailVerification, user.Email, a.cfg.VerificationTokenTTL)
+	if err != nil {
+		log.Error("Failed to issue verification token", prettylogger.Err(err))
+		return
+	}
+
+	err = a.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"Follow the link to confirm your email address:\n\n%s\n\nThe link expires in %s. If you did not request it, ignore this message.\n",
+			fmt.Sprintf(a.cfg.VerificationLink, token), a.cfg.VerificationTokenTTL,
+		),
+	})
+	if err != nil {
+		log.Error("Failed to send verification email", prettylogger.Err(err))
+		return
+	}
+
+	log.Info("Verification email sent", slog.Int64("user_id", user.ID))
+}
+
+// VerifyEmail consumes a verification token and marks the email it was issued for as verified.
+func (a *Account) VerifyEmail(ctx context.Context, token string) (int64, error) {
+	const op = "account.VerifyEmail"
+	log := a.log.With(slog.String("op", op))
+	log.Info("Verifying email")
+
+	t, err := a.tokenStorage.UseVerificationToken(ctx, models.TokenPurposeEmailVerification, tokens.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Warn("Invalid verification token")
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to use verification token", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userUpdater.SetEmailVerified(ctx, t.UserID, t.Email); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			// The user changed the email after the token was issued.
+			log.Warn("Email of verification token no longer matches", slog.Int64("user_id", t.UserID))
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to mark email as verified", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Email verified", slog.Int64("user_id", t.UserID))
+
+	return t.UserID, nil
+}
+
+// issueToken stores the hash of a new one-time token and returns the token itself.
+func (a *Account) issueToken(ctx context.Context, userID int64, purpose string, email string, ttl time.Duration) (string, error) {
+	token, hash, err := tokens.New()
+	if err != nil {
+		return "", err
+	}
+
+	err = a.tokenStorage.SaveVerificationToken(ctx, models.VerificationToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		Hash:      hash,
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
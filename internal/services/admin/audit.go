@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+
+	"github.com/jacute/prettylogger"
+)
+
+// QueryAuditLog returns a page of the audit events matching the filter, newest first.
+// The returned page token is passed back to get the next page, it is empty on the last page.
+func (a *Admin) QueryAuditLog(
+	ctx context.Context,
+	filter models.AuditFilter,
+	pageSize int,
+	pageToken string,
+) ([]models.AuditEvent, string, error) {
+	const op = "admin.QueryAuditLog"
+	log := a.log.With(slog.String("op", op))
+
+	beforeID, err := decodePageToken(pageToken)
+	if err != nil {
+		log.Info("Invalid page token", prettylogger.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = min(pageSize, MaxPageSize)
+
+	// one extra row tells whether there is a next page
+	events, err := a.auditReader.AuditEvents(ctx, filter, beforeID, pageSize+1)
+	if err != nil {
+		log.Error("Failed to query audit log", prettylogger.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	nextPageToken := ""
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		nextPageToken = encodePageToken(events[pageSize-1].ID)
+	}
+
+	return events, nextPageToken, nil
+}
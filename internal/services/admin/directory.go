@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strconv"
+
+	"github.com/jacute/prettylogger"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+func (a *Admin) GetUser(ctx context.Context, userID int64) (models.UserEntry, error) {
+	const op = "admin.GetUser"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	entry, err := a.userDirectory.UserEntry(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return models.UserEntry{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return models.UserEntry{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entry, nil
+}
+
+// ListUsers returns a page of users matching the filter ordered by ID. The returned page token
+// is passed back to get the next page, it is empty on the last page.
+func (a *Admin) ListUsers(
+	ctx context.Context,
+	filter models.UserFilter,
+	pageSize int,
+	pageToken string,
+) ([]models.UserEntry, string, error) {
+	const op = "admin.ListUsers"
+	log := a.log.With(slog.String("op", op))
+
+	afterID, err := decodePageToken(pageToken)
+	if err != nil {
+		log.Info("Invalid page token", prettylogger.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	pageSize = min(pageSize, MaxPageSize)
+
+	// one extra row tells whether there is a next page
+	entries, err := a.userDirectory.ListUsers(ctx, filter, afterID, pageSize+1)
+	if err != nil {
+		log.Error("Failed to list users", prettylogger.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	nextPageToken := ""
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		nextPageToken = encodePageToken(entries[pageSize-1].ID)
+	}
+
+	return entries, nextPageToken, nil
+}
+
+// SearchUsers is ListUsers matching the query against the login identifiers and the profile names,
+// a non-zero orgID limits the search to the members of the organization.
+func (a *Admin) SearchUsers(
+	ctx context.Context,
+	orgID int64,
+	query string,
+	pageSize int,
+	pageToken string,
+) ([]models.UserEntry, string, error) {
+	const op = "admin.SearchUsers"
+
+	entries, nextPageToken, err := a.ListUsers(ctx, models.UserFilter{OrgID: orgID, Query: query}, pageSize, pageToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nextPageToken, nil
+}
+
+func encodePageToken(lastID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastID, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	lastID, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if lastID < 0 {
+		return 0, fmt.Errorf("negative id %d", lastID)
+	}
+
+	return lastID, nil
+}
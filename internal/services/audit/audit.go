@@ -0,0 +1,90 @@
+// Package audit keeps the security audit log: logins, registrations, password changes,
+// session revocations and admin actions, with who did them, to whom, from where and how it ended.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/useragent"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// Log records audit events. Recording never fails the action it records: a storage error
+// is logged instead. A nil Log records nothing.
+type Log struct {
+	log     *slog.Logger
+	storage Storage
+	cfg     config.AuditConfig
+}
+
+type Storage interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error)
+	DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+func New(log *slog.Logger, storage Storage, cfg config.AuditConfig) *Log {
+	return &Log{
+		log:     log,
+		storage: storage,
+		cfg:     cfg,
+	}
+}
+
+// Record appends the event to the log. The time, the client IP and the user agent
+// are taken from now and the context of the call unless the event has them.
+func (l *Log) Record(ctx context.Context, event models.AuditEvent) {
+	if l == nil {
+		return
+	}
+	const op = "audit.Record"
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.IP == "" {
+		event.IP = clientip.FromContext(ctx)
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = useragent.FromContext(ctx)
+	}
+
+	// The action is done already, the event is kept even if the call is canceled now.
+	if _, err := l.storage.SaveAuditEvent(context.WithoutCancel(ctx), event); err != nil {
+		l.log.Error(
+			"Failed to record audit event",
+			slog.String("op", op),
+			slog.String("type", event.Type),
+			slog.String("outcome", event.Outcome),
+			slog.Int64("actor_id", event.ActorID),
+			slog.Int64("target_user_id", event.TargetUserID),
+			prettylogger.Err(err),
+		)
+	}
+}
+
+// PurgeExpired deletes the events older than audit.retention and returns how many were deleted.
+func (l *Log) PurgeExpired(ctx context.Context) (int64, error) {
+	const op = "audit.PurgeExpired"
+	log := l.log.With(slog.String("op", op))
+
+	if l.cfg.Retention <= 0 {
+		return 0, nil
+	}
+
+	deleted, err := l.storage.DeleteAuditEventsBefore(ctx, time.Now().Add(-l.cfg.Retention))
+	if err != nil {
+		log.Error("Failed to delete expired audit events", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if deleted > 0 {
+		log.Info("Expired audit events deleted", slog.Int64("count", deleted))
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/useragent"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type storageMock struct {
+	events []models.AuditEvent
+	before time.Time
+	err    error
+}
+
+func (m *storageMock) SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.events = append(m.events, event)
+	return int64(len(m.events)), nil
+}
+
+func (m *storageMock) DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	m.before = before
+	return 1, nil
+}
+
+func newLog(storage Storage, cfg config.AuditConfig) *Log {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), storage, cfg)
+}
+
+func TestRecord_FillsClientFromContext(t *testing.T) {
+	storage := &storageMock{}
+	l := newLog(storage, config.AuditConfig{})
+
+	ctx := useragent.NewContext(clientip.NewContext(context.Background(), "198.51.100.1"), "my-app/1.0")
+	l.Record(ctx, models.AuditEvent{Type: models.AuditLogin, Outcome: models.AuditSuccess, ActorID: 1})
+
+	require.Len(t, storage.events, 1)
+	event := storage.events[0]
+	assert.Equal(t, "198.51.100.1", event.IP)
+	assert.Equal(t, "my-app/1.0", event.UserAgent)
+	assert.WithinDuration(t, time.Now(), event.Time, time.Second)
+}
+
+func TestRecord_StorageErrorIsNotFatal(t *testing.T) {
+	l := newLog(&storageMock{err: errors.New("disk full")}, config.AuditConfig{})
+
+	assert.NotPanics(t, func() {
+		l.Record(context.Background(), models.AuditEvent{Type: models.AuditLogin, Outcome: models.AuditFailure})
+	})
+
+	var nilLog *Log
+	assert.NotPanics(t, func() {
+		nilLog.Record(context.Background(), models.AuditEvent{Type: models.AuditLogin})
+	})
+}
+
+func TestPurgeExpired(t *testing.T) {
+	storage := &storageMock{}
+	l := newLog(storage, config.AuditConfig{Retention: 24 * time.Hour})
+
+	deleted, err := l.PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), storage.before, time.Second)
+
+	storage.before = time.Time{}
+	deleted, err = newLog(storage, config.AuditConfig{}).PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, deleted, "retention 0 keeps the events forever")
+	assert.True(t, storage.before.IsZero())
+}
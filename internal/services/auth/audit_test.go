@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditLogMock struct {
+	events []models.AuditEvent
+}
+
+func (m *auditLogMock) Record(ctx context.Context, event models.AuditEvent) {
+	m.events = append(m.events, event)
+}
+
+func TestLogin_Audited(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	audit := a.auditLog.(*auditLogMock)
+
+	_, err := a.Login(ctx, "bob@example.com", "wrong-password", 1, "")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = a.Login(ctx, "bob@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+
+	res, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, "000000")
+	require.ErrorIs(t, err, ErrInvalidMFACode)
+	_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, validCode)
+	require.NoError(t, err)
+
+	assert.Equal(t, []models.AuditEvent{
+		{
+			Type: models.AuditLogin, Outcome: models.AuditFailure, Target: "bob@example.com", AppID: 1,
+			Details: ErrInvalidCredentials.Error(),
+		},
+		{
+			Type: models.AuditLogin, Outcome: models.AuditSuccess, ActorID: 2, TargetUserID: 2,
+			Target: "bob@example.com", AppID: 1, Details: "pwd",
+		},
+		{
+			Type: models.AuditLogin, Outcome: models.AuditFailure, TargetUserID: 1, AppID: 1,
+			Details: ErrInvalidMFACode.Error(),
+		},
+		{
+			Type: models.AuditLogin, Outcome: models.AuditSuccess, ActorID: 1, TargetUserID: 1, AppID: 1,
+			Details: "pwd otp",
+		},
+	}, audit.events, "the password check of alice is not a finished login")
+}
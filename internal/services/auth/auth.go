@@ -5,33 +5,61 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sso/internal/config"
 	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/identifiers"
 	"sso/internal/lib/jwt"
+	"sso/internal/lib/passwordpolicy"
+	"sso/internal/lib/tokens"
 	"sso/internal/storage"
+	"strings"
 	"time"
 
 	"github.com/jacute/prettylogger"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type Auth struct {
-	log          *slog.Logger
-	userSaver    UserSaver
-	userProvider UserProvider
-	appProvider  AppProvider
-	tokenTTL     time.Duration
+	log              *slog.Logger
+	userSaver        UserSaver
+	userProvider     UserProvider
+	appProvider      AppProvider
+	sessionStorage   SessionStorage
+	profileProvider  ProfileProvider
+	roleProvider     RoleProvider
+	orgProvider      OrgProvider
+	passwordVerifier *PasswordVerifier
+	realms           *Realms
+	profileClaims    ProfileClaims
+	identifiers      *IdentifierPolicy
+	tokenTTL         time.Duration
+	mfa              MFAVerifier
+	challenges       ChallengeStorage
+	mfaCfg           config.MFAConfig
+	passkeys         Passkeys
+	throttle         *LoginThrottle
+	passwordPolicy   PasswordPolicy
+	passwordHasher   PasswordHasher
+	registration     config.RegistrationConfig
+	notifier         RegistrationNotifier
+	auditLog         AuditLog
+	devices          DeviceStorage
+	deviceCfg        config.NewDeviceConfig
+	deviceNotifier   DeviceNotifier
 }
 
 type UserSaver interface {
 	SaveUser(
 		ctx context.Context,
-		email string,
-		passwordHash []byte,
+		user models.User,
 	) (userID int64, err error)
+	UpdatePassword(ctx context.Context, userID int64, passwordHash []byte) error
 }
 
 type UserProvider interface {
-	User(ctx context.Context, email string) (models.User, error)
+	User(ctx context.Context, orgID int64, email string) (models.User, error)
+	UserByIdentifier(ctx context.Context, orgID int64, id models.Identifier) (models.User, error)
+	UserByID(ctx context.Context, userID int64) (models.User, error)
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
 }
 
@@ -39,11 +67,80 @@ type AppProvider interface {
 	App(ctx context.Context, appID int32) (models.App, error)
 }
 
+type ProfileProvider interface {
+	Profile(ctx context.Context, userID int64) (models.Profile, error)
+}
+
+type RoleProvider interface {
+	UserRoles(ctx context.Context, userID int64, orgID int64, appID int) ([]models.Role, error)
+	HasPermission(ctx context.Context, userID int64, orgID int64, appID int, permission string) (bool, error)
+}
+
+type OrgProvider interface {
+	IsMember(ctx context.Context, orgID int64, userID int64) (bool, error)
+	EmailScope(ctx context.Context, orgID int64) (int64, error)
+}
+
+type SessionStorage interface {
+	SaveSession(ctx context.Context, session models.Session) error
+	Session(ctx context.Context, id string) (models.Session, error)
+	RevokeSession(ctx context.Context, id string) error
+	RevokeSessions(ctx context.Context, userID int64, exceptID string) (int64, error)
+}
+
+// PasswordPolicy rejects weak passwords with a *passwordpolicy.ViolationError, see package passwordpolicy.
+type PasswordPolicy interface {
+	Check(appID int32, password string, userInputs ...string) error
+}
+
+// PasswordHasher makes the hashes of new passwords and checks stored ones, see passwords.Hasher.
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Compare(hash []byte, password string) error
+	// NeedsRehash reports whether the hash is made with outdated parameters or another algorithm.
+	NeedsRehash(hash []byte) bool
+}
+
+// RegistrationNotifier tells the owner of an email that someone tried to register it again.
+type RegistrationNotifier interface {
+	NotifyRegistrationAttempt(ctx context.Context, orgID int64, email string) error
+}
+
+// AuditLog records logins and registrations in the security audit log, see audit.Log.
+type AuditLog interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// CredentialVerifier authenticates a login and password pair against an identity source
+// and returns the local user it belongs to. Wrong credentials must be reported as ErrInvalidCredentials,
+// an existing local user the identity is not linked to as ErrIdentityNotLinked.
+type CredentialVerifier interface {
+	Verify(ctx context.Context, login string, password string) (models.User, error)
+	// Link authenticates like Verify and links the identity to the local user userID.
+	// An identity linked to another user must be reported as ErrIdentityLinked.
+	Link(ctx context.Context, userID int64, login string, password string) error
+}
+
 var (
 	ErrUserNotFound       = errors.New("User not found")
 	ErrUserExists         = errors.New("User already exists")
 	ErrInvalidCredentials = errors.New("Invalid credentials")
 	ErrInvalidAppID       = errors.New("Invalid app ID")
+	ErrInvalidOrgID       = errors.New("Invalid organization ID")
+	ErrNotMember          = errors.New("User is not a member of the app organization")
+	ErrEmailNotVerified   = errors.New("Email is not verified")
+	ErrInvalidToken       = errors.New("Invalid token")
+	ErrAccountInactive    = errors.New("Account is not active")
+	ErrInvalidIdentifier  = errors.New("Invalid login identifier")
+	ErrUsernameTaken      = errors.New("Username already taken")
+	ErrPhoneTaken         = errors.New("Phone number already taken")
+	ErrIdentifierDenied   = errors.New("App does not accept this type of login identifier")
+	ErrInvalidChallenge   = errors.New("Invalid or expired MFA challenge")
+	ErrInvalidMFACode     = errors.New("Invalid MFA code")
+	ErrMFAMethodDenied    = errors.New("MFA method is not enabled")
+	ErrIdentityNotLinked  = errors.New("Account exists, sign in and link the identity to it first")
+	ErrIdentityLinked     = errors.New("Identity is linked to another account")
+	ErrUnknownSource      = errors.New("Unknown identity source")
 )
 
 func New(
@@ -51,101 +148,460 @@ func New(
 	userSaver UserSaver,
 	userProvider UserProvider,
 	appProvider AppProvider,
+	sessionStorage SessionStorage,
+	profileProvider ProfileProvider,
+	roleProvider RoleProvider,
+	orgProvider OrgProvider,
 	tokenTTL time.Duration,
+	realms *Realms,
+	profileClaims ProfileClaims,
+	identifiers *IdentifierPolicy,
+	mfa MFAVerifier,
+	challenges ChallengeStorage,
+	mfaCfg config.MFAConfig,
+	passkeys Passkeys,
+	throttle *LoginThrottle,
+	passwordPolicy PasswordPolicy,
+	passwordHasher PasswordHasher,
+	registration config.RegistrationConfig,
+	notifier RegistrationNotifier,
+	auditLog AuditLog,
+	devices DeviceStorage,
+	deviceCfg config.NewDeviceConfig,
+	deviceNotifier DeviceNotifier,
 ) *Auth {
 	return &Auth{
-		log:          log,
-		userSaver:    userSaver,
-		userProvider: userProvider,
-		appProvider:  appProvider,
-		tokenTTL:     tokenTTL,
+		log:              log,
+		userSaver:        userSaver,
+		userProvider:     userProvider,
+		appProvider:      appProvider,
+		sessionStorage:   sessionStorage,
+		profileProvider:  profileProvider,
+		roleProvider:     roleProvider,
+		orgProvider:      orgProvider,
+		passwordVerifier: NewPasswordVerifier(log, userProvider, userSaver, passwordHasher),
+		realms:           realms,
+		profileClaims:    profileClaims,
+		identifiers:      identifiers,
+		tokenTTL:         tokenTTL,
+		mfa:              mfa,
+		challenges:       challenges,
+		mfaCfg:           mfaCfg,
+		passkeys:         passkeys,
+		throttle:         throttle,
+		passwordPolicy:   passwordPolicy,
+		passwordHasher:   passwordHasher,
+		registration:     registration,
+		notifier:         notifier,
+		auditLog:         auditLog,
+		devices:          devices,
+		deviceCfg:        deviceCfg,
+		deviceNotifier:   deviceNotifier,
 	}
 }
 
-// Login checks if the user with given credentials exists
+// LoginResult is the outcome of a password check: a token, or for users with a second factor
+// the challenge to finish with VerifyMFA and the methods it accepts.
+type LoginResult struct {
+	Token       string
+	ChallengeID string
+	MFAMethods  []string
+}
+
+// Login checks if the user with given credentials exists. The login is an email,
+// a username or an E.164 phone number, of a type the app accepts; realmHint routes logins
+// without an email domain to a realm, see Realms.lookup. Wrong passwords
+// and wrong second factor codes are counted per account and client IP, too many of them
+// block the login for a while with a LoginThrottledError.
 func (a *Auth) Login(
 	ctx context.Context,
-	email string,
+	login string,
 	password string,
 	appID int32,
-) (string, error) {
+	realmHint string,
+) (LoginResult, error) {
 	const op = "auth.Login"
 	log := a.log.With(
 		slog.String("op", op),
-		slog.String("email", email),
+		slog.String("login", login),
 	)
 	log.Info("Attempting to login user")
 
-	user, err := a.userProvider.User(ctx, email)
+	id, err := identifiers.Parse(login)
 	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Warn("User not found")
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
-		}
-		log.Error("Failed to get user", prettylogger.Err(err))
-		return "", fmt.Errorf("%s: %w", op, err)
-	}
-
-	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
-		log.Info("Invalid password", prettylogger.Err(err))
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		log.Info("Invalid login identifier", prettylogger.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 	}
 
 	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
 		if errors.Is(err, storage.ErrAppNotFound) {
 			log.Info("Invalid app_id", prettylogger.Err(err))
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+			return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
 		}
 		log.Info("Failed to get app", prettylogger.Err(err))
-		return "", fmt.Errorf("%s: %w", op, err)
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !a.identifiers.Allowed(appID, id.Type) {
+		log.Info("Identifier type is not allowed by the app", slog.String("type", string(id.Type)))
+		a.auditLogin(ctx, 0, login, appID, nil, ErrIdentifierDenied)
+		return LoginResult{}, fmt.Errorf("%s: %w", op, ErrIdentifierDenied)
+	}
+
+	// The user is the same whichever app of the shared scope the login comes through.
+	scope, err := a.emailScope(ctx, app.OrgID)
+	if err != nil {
+		log.Error("Failed to get email scope", prettylogger.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	ip := clientip.FromContext(ctx)
+	attempt, err := a.loginAttempt(ctx, app.OrgID, scope, id, realmHint, ip)
+	if err != nil {
+		log.Error("Failed to get the login account", prettylogger.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.throttle.Check(ctx, attempt); err != nil {
+		if errors.Is(err, ErrLoginThrottled) {
+			log.Warn("Login is throttled", slog.String("ip", ip), prettylogger.Err(err))
+			a.auditLogin(ctx, 0, login, appID, nil, err)
+			return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("Failed to check login throttle", prettylogger.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	user, err := a.verify(ctx, app.OrgID, id.Value, realmHint, password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			log.Info("Invalid credentials", slog.String("ip", ip), prettylogger.Err(err))
+			if err := a.throttle.Fail(ctx, attempt); err != nil {
+				log.Error("Failed to count failed login", prettylogger.Err(err))
+			}
+			a.auditLogin(ctx, 0, login, appID, nil, ErrInvalidCredentials)
+			return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		if errors.Is(err, ErrIdentityNotLinked) {
+			log.Info("Identity is not linked to the existing user", prettylogger.Err(err))
+			a.auditLogin(ctx, 0, login, appID, nil, ErrIdentityNotLinked)
+			return LoginResult{}, fmt.Errorf("%s: %w", op, ErrIdentityNotLinked)
+		}
+		log.Error("Failed to verify credentials", prettylogger.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.admit(ctx, log, user, app); err != nil {
+		a.auditLogin(ctx, user.ID, login, appID, nil, err)
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	methods, err := a.mfa.Methods(ctx, user.ID)
+	if err != nil {
+		log.Error("Failed to get MFA methods", prettylogger.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(methods) > 0 {
+		// The failures are forgotten only after the second factor, see VerifyMFA.
+		challenge, err := a.newChallenge(ctx, user, app, attempt.account)
+		if err != nil {
+			log.Error("Failed to create MFA challenge", prettylogger.Err(err))
+			return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Info("Password accepted, waiting for the second factor", slog.Int64("user_id", user.ID))
+
+		return LoginResult{ChallengeID: challenge.ID, MFAMethods: methods}, nil
+	}
+
+	amr := []string{amrPassword}
+	token, err := a.issueToken(ctx, user, app, amr)
+	if err != nil {
+		log.Error("Failed to issue token", prettylogger.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.throttle.Succeed(ctx, attempt); err != nil {
+		log.Error("Failed to reset failed logins", prettylogger.Err(err))
+	}
 	log.Info("User logged in successfully")
+	a.auditLogin(ctx, user.ID, login, appID, amr, nil)
+
+	return LoginResult{Token: token}, nil
+}
+
+// emailScope returns the scope the login identifiers of the users of the organization are unique in.
+func (a *Auth) emailScope(ctx context.Context, orgID int64) (int64, error) {
+	if orgID == 0 {
+		return 0, nil
+	}
+	return a.orgProvider.EmailScope(ctx, orgID)
+}
+
+// admit checks that the user may log in to the app: the account is active,
+// belongs to the organization of the app and has the email verified if the app requires it.
+func (a *Auth) admit(ctx context.Context, log *slog.Logger, user models.User, app models.App) error {
+	if !user.DeletedAt.IsZero() {
+		log.Info("User is deleted")
+		return ErrInvalidCredentials
+	}
+	if status := user.CurrentStatus(time.Now()); status != models.UserStatusActive {
+		log.Info("Account is not active", slog.String("status", string(status)))
+		return fmt.Errorf("%w: %s", ErrAccountInactive, status)
+	}
+
+	if app.OrgID != 0 {
+		member, err := a.orgProvider.IsMember(ctx, app.OrgID, user.ID)
+		if err != nil {
+			log.Error("Failed to check membership", prettylogger.Err(err))
+			return err
+		}
+		if !member {
+			log.Info("User is not a member of the app organization", slog.Int64("org_id", app.OrgID))
+			return ErrNotMember
+		}
+	}
+
+	if app.RequireVerifiedEmail && !user.EmailVerified {
+		log.Info("App requires verified email")
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// auditLogin records a login to the app that passed with the amr methods, or failed with err.
+// Before the credentials are checked the user is not known, userID is 0 and only the login is recorded.
+func (a *Auth) auditLogin(ctx context.Context, userID int64, login string, appID int32, amr []string, err error) {
+	event := models.AuditEvent{
+		Type:         models.AuditLogin,
+		Outcome:      models.AuditSuccess,
+		ActorID:      userID,
+		TargetUserID: userID,
+		Target:       login,
+		AppID:        appID,
+		Details:      strings.Join(amr, " "),
+	}
+	if err != nil {
+		// Whoever failed to log in is not known to be the user.
+		event.Outcome = models.AuditFailure
+		event.ActorID = 0
+		event.Details = err.Error()
+	}
+	a.auditLog.Record(ctx, event)
+}
+
+// issueToken starts a session of the user in the app and returns its token. amr lists
+// the authentication methods the user passed, as in the OpenID Connect claim of this name.
+// A login from a new device is reported to the user, see checkDevice.
+func (a *Auth) issueToken(ctx context.Context, user models.User, app models.App, amr []string) (string, error) {
+	claims, err := a.claims(ctx, user, app)
+	if err != nil {
+		return "", fmt.Errorf("claims: %w", err)
+	}
+	claims["amr"] = amr
+
+	session, err := a.newSession(ctx, user, app)
+	if err != nil {
+		return "", fmt.Errorf("session: %w", err)
+	}
+	a.log.Info("Session started", slog.String("session_id", session.ID), slog.Int64("user_id", user.ID))
+
+	// The login is not failed for a device that could not be checked.
+	if err := a.checkDevice(ctx, user, session); err != nil {
+		a.log.Error("Failed to check device", slog.Int64("user_id", user.ID), prettylogger.Err(err))
+	}
 
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	token, err := jwt.NewToken(user, app, session, claims)
 	if err != nil {
-		log.Error("Failed to generate token", prettylogger.Err(err))
-		return "", fmt.Errorf("%s: %s", op, a.tokenTTL)
+		return "", fmt.Errorf("token: %w", err)
 	}
 
 	return token, nil
 }
 
-// Register registers new user and returns user ID.
+func (a *Auth) newSession(ctx context.Context, user models.User, app models.App) (models.Session, error) {
+	id, err := tokens.ID()
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	session := models.Session{
+		ID:        id,
+		UserID:    user.ID,
+		AppID:     app.ID,
+		ExpiresAt: time.Now().Add(a.tokenTTL),
+	}
+	if err := a.sessionStorage.SaveSession(ctx, session); err != nil {
+		return models.Session{}, err
+	}
+
+	return session, nil
+}
+
+// Authenticate checks a token issued by Login and returns its session,
+// which must still be active and belong to the user the token was issued to.
+func (a *Auth) Authenticate(ctx context.Context, token string) (models.Session, error) {
+	const op = "auth.Authenticate"
+	log := a.log.With(slog.String("op", op))
+
+	claims, err := jwt.ParseToken(token, func(appID int) (string, error) {
+		app, err := a.appProvider.App(ctx, int32(appID))
+		if err != nil {
+			return "", err
+		}
+		return app.Secret, nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrInvalidToken) || errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("Invalid token", prettylogger.Err(err))
+			return models.Session{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to parse token", prettylogger.Err(err))
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := a.sessionStorage.Session(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Warn("Session not found", slog.String("session_id", claims.SessionID))
+			return models.Session{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to get session", prettylogger.Err(err))
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !session.Active() || session.UserID != claims.UserID || session.AppID != claims.AppID {
+		log.Info("Session is not active", slog.String("session_id", session.ID))
+		return models.Session{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	return session, nil
+}
+
+// Register registers new user in the organization, or outside of any with orgID 0, and returns user ID.
+// The username and phone are optional login identifiers. The password must meet the policy of the app
+// the user registers in, appID 0 stands for the default policy.
+//
+// In the enumeration-safe mode, see config.RegistrationConfig, the user ID is always 0 and a taken
+// email is not reported: its owner is notified instead, in the background so the response time
+// does not differ either. Taken usernames and phone numbers are still reported.
 func (a *Auth) Register(
 	ctx context.Context,
+	orgID int64,
+	appID int32,
 	email string,
 	password string,
+	username string,
+	phone string,
 ) (int64, error) {
 	const op = "auth.Register"
 
 	log := a.log.With(
 		slog.String("op", op),
+		slog.Int64("org_id", orgID),
 		slog.String("email", email),
 	)
 	log.Info("Registering user")
 
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	user, err := newUser(orgID, email, username, phone)
+	if err != nil {
+		log.Warn("Invalid login identifier", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w: %w", op, ErrInvalidIdentifier, err)
+	}
+
+	if err := a.passwordPolicy.Check(appID, password, user.Email, user.Username); err != nil {
+		if errors.Is(err, passwordpolicy.ErrViolated) {
+			log.Warn("Password does not meet the policy", prettylogger.Err(err))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("Failed to check password policy", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user.PasswordHash, err = a.passwordHasher.Hash(password)
 	if err != nil {
 		log.Error("Failed to generate password hash", prettylogger.Err(err))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	userID, err := a.userSaver.SaveUser(ctx, email, passwordHash)
+	userID, err := a.userSaver.SaveUser(ctx, user)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			log.Warn("User already exists", prettylogger.Err(err))
+			a.auditRegistration(ctx, 0, user.Email, appID, ErrUserExists)
+			if a.registration.EnumerationSafe {
+				go a.notifyRegistrationAttempt(context.WithoutCancel(ctx), log, orgID, user.Email)
+				return 0, nil
+			}
 			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
+		if errors.Is(err, storage.ErrUsernameTaken) {
+			log.Warn("Username already taken")
+			a.auditRegistration(ctx, 0, user.Email, appID, ErrUsernameTaken)
+			return 0, fmt.Errorf("%s: %w", op, ErrUsernameTaken)
+		}
+		if errors.Is(err, storage.ErrPhoneTaken) {
+			log.Warn("Phone number already taken")
+			a.auditRegistration(ctx, 0, user.Email, appID, ErrPhoneTaken)
+			return 0, fmt.Errorf("%s: %w", op, ErrPhoneTaken)
+		}
+		if errors.Is(err, storage.ErrOrgNotFound) {
+			log.Warn("Organization not found", slog.Int64("org_id", orgID))
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidOrgID)
+		}
 		log.Error("Failed to save user", prettylogger.Err(err))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 	log.Info("User registered", slog.Int64("user_id", userID))
+	a.auditRegistration(ctx, userID, user.Email, appID, nil)
 
+	if a.registration.EnumerationSafe {
+		return 0, nil
+	}
 	return userID, nil
 }
 
+// auditRegistration records a registration of the email in the app, err is nil for a successful one.
+func (a *Auth) auditRegistration(ctx context.Context, userID int64, email string, appID int32, err error) {
+	event := models.AuditEvent{
+		Type:         models.AuditRegistration,
+		Outcome:      models.AuditSuccess,
+		ActorID:      userID,
+		TargetUserID: userID,
+		Target:       email,
+		AppID:        appID,
+	}
+	if err != nil {
+		event.Outcome = models.AuditFailure
+		event.Details = err.Error()
+	}
+	a.auditLog.Record(ctx, event)
+}
+
+func (a *Auth) notifyRegistrationAttempt(ctx context.Context, log *slog.Logger, orgID int64, email string) {
+	if err := a.notifier.NotifyRegistrationAttempt(ctx, orgID, email); err != nil {
+		log.Error("Failed to notify the owner of the email", prettylogger.Err(err))
+	}
+}
+
+// newUser builds a user with normalized login identifiers, empty username and phone are left out.
+func newUser(orgID int64, email string, username string, phone string) (models.User, error) {
+	user := models.User{OrgID: orgID}
+
+	var err error
+	if user.Email, err = identifiers.NormalizeEmail(email); err != nil {
+		return models.User{}, err
+	}
+	if username != "" {
+		if user.Username, err = identifiers.NormalizeUsername(username); err != nil {
+			return models.User{}, err
+		}
+	}
+	if phone != "" {
+		if user.Phone, err = identifiers.NormalizePhone(phone); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	return user, nil
+}
+
 func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "auth.IsAdmin"
 
@@ -169,3 +625,44 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	return isAdmin, nil
 }
+
+// HasPermission reports whether the user holds the permission in the app through any of their roles.
+// Roles of an organization count in its apps and, for its users, in the shared apps.
+func (a *Auth) HasPermission(ctx context.Context, userID int64, appID int32, permission string) (bool, error) {
+	const op = "auth.HasPermission"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", int(appID)),
+		slog.String("permission", permission),
+	)
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("App not found")
+			return false, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+		log.Error("Failed to get app", prettylogger.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	has, err := a.roleProvider.HasPermission(ctx, userID, tenant(user, app), int(app.ID), permission)
+	if err != nil {
+		log.Error("Failed to check permission", prettylogger.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Checked permission", slog.Bool("has_permission", has))
+
+	return has, nil
+}
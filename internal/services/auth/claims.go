@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sso/internal/domain/models"
+)
+
+// tenant is the organization a user acts in within the app: the organization of the app,
+// or the one the user was registered in for the shared apps.
+func tenant(user models.User, app models.App) int64 {
+	if app.OrgID != 0 {
+		return app.OrgID
+	}
+	return user.OrgID
+}
+
+// claims returns the claims added to the token on top of the standard ones:
+// the organization, the roles of the user in the app and the configured profile fields.
+func (a *Auth) claims(ctx context.Context, user models.User, app models.App) (map[string]any, error) {
+	claims := map[string]any{}
+
+	orgID := tenant(user, app)
+	if orgID != 0 {
+		claims["org_id"] = orgID
+	}
+
+	if len(a.profileClaims) > 0 {
+		profile, err := a.profileProvider.Profile(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("profile: %w", err)
+		}
+		for name, value := range a.profileClaims.values(profile) {
+			claims[name] = value
+		}
+	}
+
+	roles, err := a.roleProvider.UserRoles(ctx, user.ID, orgID, app.ID)
+	if err != nil {
+		return nil, fmt.Errorf("roles: %w", err)
+	}
+	if len(roles) > 0 {
+		names := make([]string, 0, len(roles))
+		for _, role := range roles {
+			names = append(names, role.Name)
+		}
+		claims["roles"] = names
+	}
+
+	return claims, nil
+}
+
+// profileClaimValues maps the supported token claims to profile fields,
+// names follow the OpenID Connect standard claims where there is one.
+var profileClaimValues = map[string]func(p models.Profile) any{
+	"name":        func(p models.Profile) any { return p.DisplayName },
+	"given_name":  func(p models.Profile) any { return p.GivenName },
+	"family_name": func(p models.Profile) any { return p.FamilyName },
+	"locale":      func(p models.Profile) any { return p.Locale },
+	"zoneinfo":    func(p models.Profile) any { return p.Timezone },
+	"picture":     func(p models.Profile) any { return p.AvatarURL },
+	"app_metadata": func(p models.Profile) any {
+		if len(p.AppMetadata) == 0 {
+			return ""
+		}
+		return json.RawMessage(p.AppMetadata)
+	},
+}
+
+// ProfileClaims is the set of profile fields added to issued tokens.
+type ProfileClaims []string
+
+func NewProfileClaims(names []string) (ProfileClaims, error) {
+	const op = "auth.NewProfileClaims"
+
+	for _, name := range names {
+		if _, ok := profileClaimValues[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown profile claim %q", op, name)
+		}
+	}
+
+	return ProfileClaims(names), nil
+}
+
+// values returns the claims of the profile, empty fields are left out.
+func (c ProfileClaims) values(profile models.Profile) map[string]any {
+	claims := make(map[string]any, len(c))
+	for _, name := range c {
+		value := profileClaimValues[name](profile)
+		if value == "" {
+			continue
+		}
+		claims[name] = value
+	}
+
+	return claims
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"encoding/json"
+	"sso/internal/domain/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProfileClaims(t *testing.T) {
+	_, err := NewProfileClaims([]string{"name", "picture", "app_metadata"})
+	require.NoError(t, err)
+
+	_, err = NewProfileClaims([]string{"name", "password"})
+	require.Error(t, err)
+}
+
+func TestProfileClaims_Values(t *testing.T) {
+	claims, err := NewProfileClaims([]string{"name", "zoneinfo", "locale", "app_metadata"})
+	require.NoError(t, err)
+
+	values := claims.values(models.Profile{
+		DisplayName: "Jane Doe",
+		Timezone:    "Europe/Berlin",
+		AvatarURL:   "https://example.com/jane.png",
+		AppMetadata: json.RawMessage(`{"plan":"pro"}`),
+	})
+
+	assert.Equal(t, map[string]any{
+		"name":         "Jane Doe",
+		"zoneinfo":     "Europe/Berlin",
+		"app_metadata": json.RawMessage(`{"plan":"pro"}`),
+	}, values)
+}
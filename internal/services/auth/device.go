@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/deviceid"
+	"sso/internal/lib/tokens"
+	"sso/internal/lib/useragent"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// DeviceStorage keeps the devices the users logged in from, see checkDevice.
+type DeviceStorage interface {
+	TouchKnownDevice(ctx context.Context, device models.KnownDevice) error
+	CountKnownDevices(ctx context.Context, userID int64) (int, error)
+	SaveKnownDevice(ctx context.Context, device models.KnownDevice) error
+	// UseDeviceRevokeToken forgets the device the unexpired revoke token was issued for and returns it.
+	UseDeviceRevokeToken(ctx context.Context, hash []byte) (models.KnownDevice, error)
+	// ForgetDevice forgets the device ID of the user in every network and user agent.
+	ForgetDevice(ctx context.Context, userID int64, deviceIDHash []byte) (int64, error)
+	DeleteStaleKnownDevices(ctx context.Context, before time.Time) (int64, error)
+}
+
+// DeviceNotifier tells the user about a login from a new device. revokeLink is the "this wasn't me"
+// link that revokes the sessions of the user, empty if it is not configured.
+type DeviceNotifier interface {
+	NotifyNewDevice(ctx context.Context, user models.User, device models.KnownDevice, revokeLink string) error
+}
+
+// checkDevice remembers the device the session was started from and, unless it is the first
+// device of the user, reports the login from it to the user. A device is the device ID the client
+// sent, its user agent and the network of its IP: the same device in another network is new again.
+func (a *Auth) checkDevice(ctx context.Context, user models.User, session models.Session) error {
+	if !a.deviceCfg.Enabled {
+		return nil
+	}
+
+	now := time.Now()
+	ip := clientip.FromContext(ctx)
+	userAgent := useragent.FromContext(ctx)
+	device := models.KnownDevice{
+		UserID:        user.ID,
+		DeviceIDHash:  tokens.Hash(deviceid.FromContext(ctx)),
+		UserAgentHash: tokens.Hash(userAgent),
+		IPPrefix:      a.ipPrefix(ip),
+		UserAgent:     userAgent,
+		LastIP:        ip,
+		FirstSeenAt:   now,
+		LastSeenAt:    now,
+		SessionID:     session.ID,
+	}
+
+	err := a.devices.TouchKnownDevice(ctx, device)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrKnownDeviceNotFound) {
+		return fmt.Errorf("touch device: %w", err)
+	}
+
+	known, err := a.devices.CountKnownDevices(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("count devices: %w", err)
+	}
+	// There is nothing to compare the first device with, the user has just registered
+	// or logged in for the first time since the devices are remembered.
+	firstDevice := known == 0
+
+	var revokeLink string
+	if !firstDevice && a.deviceCfg.RevokeLink != "" {
+		token, hash, err := tokens.New()
+		if err != nil {
+			return fmt.Errorf("revoke token: %w", err)
+		}
+		device.RevokeTokenHash = hash
+		device.RevokeExpiresAt = now.Add(a.deviceCfg.RevokeTokenTTL)
+		revokeLink = fmt.Sprintf(a.deviceCfg.RevokeLink, token)
+	}
+
+	if err := a.devices.SaveKnownDevice(ctx, device); err != nil {
+		if errors.Is(err, storage.ErrKnownDeviceExists) {
+			// A concurrent login from the device saved and reported it.
+			return nil
+		}
+		return fmt.Errorf("save device: %w", err)
+	}
+	if firstDevice {
+		return nil
+	}
+
+	a.auditLog.Record(ctx, models.AuditEvent{
+		Type:         models.AuditNewDevice,
+		Outcome:      models.AuditSuccess,
+		ActorID:      user.ID,
+		TargetUserID: user.ID,
+		AppID:        int32(session.AppID),
+		Details:      device.IPPrefix,
+	})
+	go a.notifyNewDevice(context.WithoutCancel(ctx), user, device, revokeLink)
+
+	return nil
+}
+
+func (a *Auth) notifyNewDevice(ctx context.Context, user models.User, device models.KnownDevice, revokeLink string) {
+	if err := a.deviceNotifier.NotifyNewDevice(ctx, user, device, revokeLink); err != nil {
+		a.log.Error(
+			"Failed to notify the user about a new device",
+			slog.Int64("user_id", user.ID),
+			prettylogger.Err(err),
+		)
+	}
+}
+
+// ipPrefix returns the network of the IP, an unknown IP is its own network.
+func (a *Auth) ipPrefix(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+
+	bits := a.deviceCfg.IPv6PrefixLen
+	if addr.Is4() {
+		bits = a.deviceCfg.IPv4PrefixLen
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+
+	return prefix.String()
+}
+
+// RevokeUnrecognizedLogin handles the "this wasn't me" link of a login from a new device: whoever
+// logged in knows the password and may have started more sessions, so all sessions of the user are
+// revoked, and the device is forgotten in every network, so its next login is reported again.
+// The token works once.
+func (a *Auth) RevokeUnrecognizedLogin(ctx context.Context, token string) error {
+	const op = "auth.RevokeUnrecognizedLogin"
+	log := a.log.With(slog.String("op", op))
+
+	device, err := a.devices.UseDeviceRevokeToken(ctx, tokens.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrKnownDeviceNotFound) {
+			log.Info("Invalid or expired revoke token")
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to use revoke token", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", device.UserID), slog.String("session_id", device.SessionID))
+
+	// Without a device ID the device is only its user agent and network, the token forgot that entry.
+	if !bytes.Equal(device.DeviceIDHash, tokens.Hash("")) {
+		if _, err := a.devices.ForgetDevice(ctx, device.UserID, device.DeviceIDHash); err != nil {
+			log.Error("Failed to forget device", prettylogger.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	revoked, err := a.sessionStorage.RevokeSessions(ctx, device.UserID, "")
+	if err != nil {
+		log.Error("Failed to revoke sessions", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("Unrecognized login revoked", slog.Int64("revoked_sessions", revoked))
+
+	a.auditLog.Record(ctx, models.AuditEvent{
+		Type:         models.AuditSessionRevocation,
+		Outcome:      models.AuditSuccess,
+		ActorID:      device.UserID,
+		TargetUserID: device.UserID,
+		Details:      fmt.Sprintf("unrecognized_login: %d sessions revoked", revoked),
+	})
+
+	return nil
+}
+
+// PurgeStaleDevices forgets the devices not used for new_device.forget_after, a login from one
+// of them is reported again. It runs periodically in the background.
+func (a *Auth) PurgeStaleDevices(ctx context.Context) (int64, error) {
+	const op = "auth.PurgeStaleDevices"
+	log := a.log.With(slog.String("op", op))
+
+	if !a.deviceCfg.Enabled || a.deviceCfg.ForgetAfter <= 0 {
+		return 0, nil
+	}
+
+	deleted, err := a.devices.DeleteStaleKnownDevices(ctx, time.Now().Add(-a.deviceCfg.ForgetAfter))
+	if err != nil {
+		log.Error("Failed to delete stale devices", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if deleted > 0 {
+		log.Info("Stale devices deleted", slog.Int64("count", deleted))
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/deviceid"
+	"sso/internal/lib/useragent"
+	"sso/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type deviceStorageMock struct {
+	devices []models.KnownDevice
+}
+
+func (m *deviceStorageMock) find(device models.KnownDevice) int {
+	for i, d := range m.devices {
+		if d.UserID == device.UserID && bytes.Equal(d.DeviceIDHash, device.DeviceIDHash) &&
+			bytes.Equal(d.UserAgentHash, device.UserAgentHash) && d.IPPrefix == device.IPPrefix {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *deviceStorageMock) TouchKnownDevice(ctx context.Context, device models.KnownDevice) error {
+	i := m.find(device)
+	if i < 0 {
+		return storage.ErrKnownDeviceNotFound
+	}
+	m.devices[i].LastSeenAt = device.LastSeenAt
+	m.devices[i].LastIP = device.LastIP
+	return nil
+}
+
+func (m *deviceStorageMock) CountKnownDevices(ctx context.Context, userID int64) (int, error) {
+	count := 0
+	for _, d := range m.devices {
+		if d.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *deviceStorageMock) SaveKnownDevice(ctx context.Context, device models.KnownDevice) error {
+	if m.find(device) >= 0 {
+		return storage.ErrKnownDeviceExists
+	}
+	m.devices = append(m.devices, device)
+	return nil
+}
+
+func (m *deviceStorageMock) UseDeviceRevokeToken(ctx context.Context, hash []byte) (models.KnownDevice, error) {
+	for i, d := range m.devices {
+		if d.RevokeTokenHash != nil && bytes.Equal(d.RevokeTokenHash, hash) && time.Now().Before(d.RevokeExpiresAt) {
+			m.devices = append(m.devices[:i], m.devices[i+1:]...)
+			return d, nil
+		}
+	}
+	return models.KnownDevice{}, storage.ErrKnownDeviceNotFound
+}
+
+func (m *deviceStorageMock) ForgetDevice(ctx context.Context, userID int64, deviceIDHash []byte) (int64, error) {
+	var kept []models.KnownDevice
+	for _, d := range m.devices {
+		if d.UserID != userID || !bytes.Equal(d.DeviceIDHash, deviceIDHash) {
+			kept = append(kept, d)
+		}
+	}
+	deleted := int64(len(m.devices) - len(kept))
+	m.devices = kept
+	return deleted, nil
+}
+
+func (m *deviceStorageMock) DeleteStaleKnownDevices(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+type deviceNotifierMock struct {
+	notified chan string
+}
+
+func (m *deviceNotifierMock) NotifyNewDevice(ctx context.Context, user models.User, device models.KnownDevice, revokeLink string) error {
+	m.notified <- revokeLink
+	return nil
+}
+
+var revokeTokenRe = regexp.MustCompile(`token=(.+)$`)
+
+func newDeviceAuth(t *testing.T) (*Auth, *deviceStorageMock, *deviceNotifierMock) {
+	t.Helper()
+
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	devices := &deviceStorageMock{}
+	notifier := &deviceNotifierMock{notified: make(chan string, 1)}
+	a.devices = devices
+	a.deviceNotifier = notifier
+	a.deviceCfg = config.NewDeviceConfig{
+		Enabled:        true,
+		IPv4PrefixLen:  24,
+		IPv6PrefixLen:  48,
+		RevokeLink:     "https://sso.example/revoke-login?token=%s",
+		RevokeTokenTTL: time.Hour,
+	}
+
+	return a, devices, notifier
+}
+
+func deviceContext(deviceID string, ip string) context.Context {
+	ctx := clientip.NewContext(context.Background(), ip)
+	ctx = useragent.NewContext(ctx, "Mozilla/5.0")
+	return deviceid.NewContext(ctx, deviceID)
+}
+
+func TestLogin_NewDeviceNotified(t *testing.T) {
+	a, devices, notifier := newDeviceAuth(t)
+
+	_, err := a.Login(deviceContext("laptop", "198.51.100.7"), "bob@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	_, err = a.Login(deviceContext("laptop", "198.51.100.9"), "bob@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	require.Len(t, devices.devices, 1, "the same device in the same network is known")
+	assert.Equal(t, "198.51.100.9", devices.devices[0].LastIP)
+	assert.Empty(t, notifier.notified, "the first device is not reported")
+
+	_, err = a.Login(deviceContext("phone", "203.0.113.5"), "bob@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	require.Len(t, devices.devices, 2)
+
+	var revokeLink string
+	select {
+	case revokeLink = <-notifier.notified:
+	case <-time.After(time.Second):
+		t.Fatal("new device is not reported")
+	}
+	match := revokeTokenRe.FindStringSubmatch(revokeLink)
+	require.Len(t, match, 2)
+
+	audit := a.auditLog.(*auditLogMock)
+	require.NotEmpty(t, audit.events)
+	assert.Equal(t, models.AuditNewDevice, audit.events[len(audit.events)-2].Type, "recorded before the login")
+
+	// The phone logs in again from another network, the login is reported and the device known there too.
+	_, err = a.Login(deviceContext("phone", "192.0.2.5"), "bob@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	<-notifier.notified
+	require.Len(t, devices.devices, 3)
+
+	require.NoError(t, a.RevokeUnrecognizedLogin(context.Background(), match[1]))
+	sessions := a.sessionStorage.(*sessionStorageMock).sessions
+	require.Len(t, sessions, 4)
+	for _, session := range sessions {
+		assert.False(t, session.Active(), "all sessions of the user are revoked")
+	}
+	require.Len(t, devices.devices, 1, "the device is forgotten in every network")
+	assert.Equal(t, "198.51.100.0/24", devices.devices[0].IPPrefix)
+
+	_, err = a.Login(deviceContext("phone", "192.0.2.5"), "bob@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	select {
+	case <-notifier.notified:
+	case <-time.After(time.Second):
+		t.Fatal("the forgotten device is not reported again")
+	}
+
+	err = a.RevokeUnrecognizedLogin(context.Background(), match[1])
+	assert.ErrorIs(t, err, ErrInvalidToken, "the token works once")
+}
+
+func TestIPPrefix(t *testing.T) {
+	a := &Auth{deviceCfg: config.NewDeviceConfig{IPv4PrefixLen: 24, IPv6PrefixLen: 48}}
+
+	assert.Equal(t, "198.51.100.0/24", a.ipPrefix("198.51.100.7"))
+	assert.Equal(t, "198.51.100.0/24", a.ipPrefix("::ffff:198.51.100.7"))
+	assert.Equal(t, "2001:db8:1::/48", a.ipPrefix("2001:db8:1:2::1"))
+	assert.Equal(t, "", a.ipPrefix(""))
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/passwordpolicy"
+	"sso/internal/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHasher counts the password checks, which take the time a login can leak.
+type countingHasher struct {
+	PasswordHasher
+	compared int
+}
+
+func (h *countingHasher) Compare(hash []byte, password string) error {
+	h.compared++
+	return h.PasswordHasher.Compare(hash, password)
+}
+
+func TestPasswordVerifier_UnknownUserComparesDummyHash(t *testing.T) {
+	users := &userProviderMock{users: map[string]models.User{
+		"bob@example.com":   {ID: 2, Email: "bob@example.com"},
+		"carol@example.com": {ID: 3, Email: "carol@example.com", PasswordHash: importedHash(localPassword)},
+	}}
+	hasher := &countingHasher{PasswordHasher: testHasher(t)}
+	verifier := NewPasswordVerifier(slog.New(slog.NewTextHandler(io.Discard, nil)), users, &userSaverMock{}, hasher)
+
+	for _, login := range []string{"nobody@example.com", "bob@example.com", "carol@example.com"} {
+		hasher.compared = 0
+		_, err := verifier.Verify(context.Background(), 0, login, "wrong-password")
+		assert.ErrorIs(t, err, ErrInvalidCredentials, login)
+		assert.Equal(t, 1, hasher.compared, "%s: one password check whether the user exists or not", login)
+	}
+}
+
+type registrationSaverMock struct {
+	userSaverMock
+	emails map[string]int64
+}
+
+func (m *registrationSaverMock) SaveUser(ctx context.Context, user models.User) (int64, error) {
+	if _, ok := m.emails[user.Email]; ok {
+		return 0, storage.ErrUserExists
+	}
+	m.emails[user.Email] = int64(len(m.emails) + 1)
+	return m.emails[user.Email], nil
+}
+
+type notifierMock struct {
+	notified chan string
+}
+
+func (m *notifierMock) NotifyRegistrationAttempt(ctx context.Context, orgID int64, email string) error {
+	m.notified <- email
+	return nil
+}
+
+func newRegistrationAuth(t *testing.T, enumerationSafe bool) (*Auth, *notifierMock) {
+	t.Helper()
+
+	notifier := &notifierMock{notified: make(chan string, 1)}
+	var policies *passwordpolicy.Policies
+	auth := New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		&registrationSaverMock{emails: map[string]int64{}}, nil, nil, nil, nil, nil, nil, time.Hour, nil, nil, nil,
+		nil, nil, config.MFAConfig{}, nil, nil, policies, testHasher(t),
+		config.RegistrationConfig{EnumerationSafe: enumerationSafe}, notifier, &auditLogMock{},
+		nil, config.NewDeviceConfig{}, nil,
+	)
+
+	return auth, notifier
+}
+
+func TestRegister_EnumerationSafe(t *testing.T) {
+	auth, notifier := newRegistrationAuth(t, true)
+
+	userID, err := auth.Register(context.Background(), 0, 0, "alice@example.com", localPassword, "", "")
+	require.NoError(t, err)
+	assert.Zero(t, userID, "the user ID would tell new emails from taken ones")
+
+	userID, err = auth.Register(context.Background(), 0, 0, "alice@Example.COM", localPassword, "", "")
+	require.NoError(t, err, "a taken email is not reported")
+	assert.Zero(t, userID)
+
+	select {
+	case email := <-notifier.notified:
+		assert.Equal(t, "alice@example.com", email)
+	case <-time.After(time.Second):
+		t.Fatal("the owner of the email is not notified")
+	}
+}
+
+func TestRegister_ReportsTakenEmail(t *testing.T) {
+	auth, notifier := newRegistrationAuth(t, false)
+
+	userID, err := auth.Register(context.Background(), 0, 0, "alice@example.com", localPassword, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+
+	_, err = auth.Register(context.Background(), 0, 0, "alice@example.com", localPassword, "", "")
+	assert.ErrorIs(t, err, ErrUserExists)
+	assert.Empty(t, notifier.notified)
+}
+
+func TestRegister_LongPasswordWithBcrypt(t *testing.T) {
+	auth, _ := newRegistrationAuth(t, false)
+	hasher := testHasher(t)
+	policies, err := passwordpolicy.New(config.PasswordPolicyConfig{
+		PasswordRulesConfig: config.PasswordRulesConfig{MinLength: 8, MaxLength: 128},
+	}, hasher.MaxPasswordBytes())
+	require.NoError(t, err)
+	auth.passwordPolicy = policies
+
+	_, err = auth.Register(context.Background(), 0, 0, "alice@example.com", strings.Repeat("a long passphrase ", 6)[:100], "", "")
+	var violation *passwordpolicy.ViolationError
+	require.ErrorAs(t, err, &violation, "a password bcrypt can't hash is a policy violation, not an internal error")
+	assert.Equal(t, passwordpolicy.RuleMaxLength, violation.Violations[0].Rule)
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+)
+
+// IdentifierPolicy decides which login identifier types each app accepts.
+type IdentifierPolicy struct {
+	defaultTypes []models.IdentifierType
+	apps         map[int32][]models.IdentifierType
+}
+
+func NewIdentifierPolicy(cfg config.IdentifiersConfig) (*IdentifierPolicy, error) {
+	const op = "auth.NewIdentifierPolicy"
+
+	p := &IdentifierPolicy{apps: make(map[int32][]models.IdentifierType, len(cfg.Apps))}
+
+	var err error
+	p.defaultTypes, err = identifierTypes(cfg.Default)
+	if err != nil {
+		return nil, fmt.Errorf("%s: default: %w", op, err)
+	}
+	for _, app := range cfg.Apps {
+		if _, ok := p.apps[app.AppID]; ok {
+			return nil, fmt.Errorf("%s: duplicated app %d", op, app.AppID)
+		}
+		p.apps[app.AppID], err = identifierTypes(app.Allowed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: app %d: %w", op, app.AppID, err)
+		}
+	}
+
+	return p, nil
+}
+
+func identifierTypes(names []string) ([]models.IdentifierType, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no identifier types allowed")
+	}
+
+	types := make([]models.IdentifierType, 0, len(names))
+	for _, name := range names {
+		t := models.IdentifierType(name)
+		if !t.Valid() {
+			return nil, fmt.Errorf("unknown identifier type %q", name)
+		}
+		types = append(types, t)
+	}
+
+	return types, nil
+}
+
+// Allowed reports whether the app accepts logins with the identifier type.
+// A nil policy allows every type.
+func (p *IdentifierPolicy) Allowed(appID int32, t models.IdentifierType) bool {
+	if p == nil {
+		return true
+	}
+
+	types, ok := p.apps[appID]
+	if !ok {
+		types = p.defaultTypes
+	}
+
+	return slices.Contains(types, t)
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentifierPolicy_Allowed(t *testing.T) {
+	policy, err := NewIdentifierPolicy(config.IdentifiersConfig{
+		Default: []string{"email", "username"},
+		Apps:    []config.AppIdentifiersConfig{{AppID: 2, Allowed: []string{"phone"}}},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, policy.Allowed(1, models.IdentifierUsername))
+	assert.False(t, policy.Allowed(1, models.IdentifierPhone))
+	assert.True(t, policy.Allowed(2, models.IdentifierPhone))
+	assert.False(t, policy.Allowed(2, models.IdentifierEmail))
+}
+
+func TestNewIdentifierPolicy_FailCases(t *testing.T) {
+	cases := map[string]config.IdentifiersConfig{
+		"Unknown type":   {Default: []string{"email", "nickname"}},
+		"Empty default":  {},
+		"Empty app list": {Default: []string{"email"}, Apps: []config.AppIdentifiersConfig{{AppID: 1}}},
+		"Duplicated app": {Default: []string{"email"}, Apps: []config.AppIdentifiersConfig{
+			{AppID: 1, Allowed: []string{"email"}},
+			{AppID: 1, Allowed: []string{"phone"}},
+		}},
+	}
+
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewIdentifierPolicy(cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package ldapauth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"sso/internal/storage"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jacute/prettylogger"
+)
+
+const Provider = "ldap"
+
+type IdentitySaver interface {
+	SaveIdentity(ctx context.Context, identity models.Identity) (models.User, error)
+	LinkIdentity(ctx context.Context, userID int64, identity models.Identity) error
+}
+
+// Verifier authenticates users with an LDAP bind and syncs the directory entry into a local user.
+type Verifier struct {
+	log           *slog.Logger
+	cfg           config.LDAPConfig
+	identitySaver IdentitySaver
+}
+
+func New(log *slog.Logger, cfg config.LDAPConfig, identitySaver IdentitySaver) *Verifier {
+	return &Verifier{
+		log:           log,
+		cfg:           cfg,
+		identitySaver: identitySaver,
+	}
+}
+
+// Verify looks up the entry matching login with the service account, binds as that entry
+// with the given password and saves its attributes and group membership locally.
+func (v *Verifier) Verify(ctx context.Context, login string, password string) (models.User, error) {
+	const op = "ldapauth.Verify"
+	log := v.log.With(
+		slog.String("op", op),
+		slog.String("login", login),
+	)
+
+	identity, err := v.authenticate(log, login, password)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := v.identitySaver.SaveIdentity(ctx, identity)
+	if err != nil {
+		if errors.Is(err, storage.ErrIdentityNotLinked) {
+			log.Info("LDAP entry matches a local user it is not linked to", slog.String("dn", identity.Subject))
+			return models.User{}, fmt.Errorf("%s: %w", op, auth.ErrIdentityNotLinked)
+		}
+		log.Error("Failed to sync LDAP user", prettylogger.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("LDAP user synced", slog.Int64("user_id", user.ID), slog.String("dn", identity.Subject))
+
+	return user, nil
+}
+
+// Link authenticates like Verify and links the entry to the local user userID.
+func (v *Verifier) Link(ctx context.Context, userID int64, login string, password string) error {
+	const op = "ldapauth.Link"
+	log := v.log.With(
+		slog.String("op", op),
+		slog.String("login", login),
+		slog.Int64("user_id", userID),
+	)
+
+	identity, err := v.authenticate(log, login, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := v.identitySaver.LinkIdentity(ctx, userID, identity); err != nil {
+		if errors.Is(err, storage.ErrIdentityLinked) {
+			return fmt.Errorf("%s: %w", op, auth.ErrIdentityLinked)
+		}
+		log.Error("Failed to link LDAP user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("LDAP user linked", slog.String("dn", identity.Subject))
+
+	return nil
+}
+
+// authenticate binds as the entry matching login with the given password and returns its identity.
+func (v *Verifier) authenticate(log *slog.Logger, login string, password string) (models.Identity, error) {
+	// An empty password would turn the bind into an unauthenticated one, which always succeeds.
+	if password == "" {
+		return models.Identity{}, auth.ErrInvalidCredentials
+	}
+
+	conn, err := v.connect()
+	if err != nil {
+		log.Error("Failed to connect to LDAP server", prettylogger.Err(err))
+		return models.Identity{}, err
+	}
+	defer conn.Close()
+
+	entry, err := v.findEntry(conn, login)
+	if err != nil {
+		return models.Identity{}, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return models.Identity{}, auth.ErrInvalidCredentials
+		}
+		log.Error("Failed to bind as user", prettylogger.Err(err))
+		return models.Identity{}, err
+	}
+
+	identity := v.toIdentity(entry)
+	if identity.Email == "" {
+		log.Warn("LDAP entry has no email", slog.String("dn", entry.DN))
+		return models.Identity{}, auth.ErrInvalidCredentials
+	}
+
+	return identity, nil
+}
+
+func (v *Verifier) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(v.cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: v.cfg.Timeout}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(v.cfg.Timeout)
+
+	if v.cfg.StartTLS {
+		u, err := url.Parse(v.cfg.URL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// findEntry binds with the service account and returns the single entry matching login.
+func (v *Verifier) findEntry(conn *ldap.Conn, login string) (*ldap.Entry, error) {
+	if v.cfg.BindDN != "" {
+		if err := conn.Bind(v.cfg.BindDN, string(v.cfg.BindPassword)); err != nil {
+			return nil, fmt.Errorf("service bind: %w", err)
+		}
+	}
+
+	attrs := v.cfg.Attributes
+	req := ldap.NewSearchRequest(
+		v.cfg.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(v.cfg.Timeout.Seconds()), false,
+		fmt.Sprintf(v.cfg.UserFilter, ldap.EscapeFilter(login)),
+		append([]string{attrs.Email, attrs.Username, attrs.Groups}, attrs.Extra...),
+		nil,
+	)
+
+	res, err := conn.Search(req)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	if len(res.Entries) != 1 {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	return res.Entries[0], nil
+}
+
+func (v *Verifier) toIdentity(entry *ldap.Entry) models.Identity {
+	attrs := v.cfg.Attributes
+
+	extra := make(map[string]string, len(attrs.Extra))
+	for _, name := range attrs.Extra {
+		if value := entry.GetAttributeValue(name); value != "" {
+			extra[name] = value
+		}
+	}
+
+	return models.Identity{
+		Provider:   Provider,
+		Subject:    entry.DN,
+		Email:      entry.GetAttributeValue(attrs.Email),
+		Username:   entry.GetAttributeValue(attrs.Username),
+		Attributes: extra,
+		Groups:     entry.GetAttributeValues(attrs.Groups),
+	}
+}
@@ -0,0 +1,159 @@
+package ldapauth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/ldaptest"
+	"sso/internal/services/auth"
+	"sso/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	serviceDN       = "cn=sso,ou=services,dc=corp,dc=example"
+	servicePassword = "service-secret"
+	aliceDN         = "uid=alice,ou=people,dc=corp,dc=example"
+	alicePassword   = "alice-password"
+)
+
+type identitySaverMock struct {
+	identities []models.Identity
+	links      map[string]int64
+	err        error
+}
+
+func (m *identitySaverMock) SaveIdentity(ctx context.Context, identity models.Identity) (models.User, error) {
+	if m.err != nil {
+		return models.User{}, m.err
+	}
+	m.identities = append(m.identities, identity)
+	return models.User{ID: int64(len(m.identities)), Email: identity.Email}, nil
+}
+
+func (m *identitySaverMock) LinkIdentity(ctx context.Context, userID int64, identity models.Identity) error {
+	if linked, ok := m.links[identity.Subject]; ok && linked != userID {
+		return storage.ErrIdentityLinked
+	}
+	if m.links == nil {
+		m.links = map[string]int64{}
+	}
+	m.links[identity.Subject] = userID
+	return nil
+}
+
+func newVerifier(t *testing.T) (*Verifier, *identitySaverMock) {
+	t.Helper()
+
+	server := ldaptest.NewServer(
+		ldaptest.Entry{DN: serviceDN, Password: servicePassword},
+		ldaptest.Entry{
+			DN:       aliceDN,
+			Password: alicePassword,
+			Attributes: map[string][]string{
+				"mail":      {"alice@corp.example"},
+				"uid":       {"alice"},
+				"cn":        {"Alice Liddell"},
+				"memberOf":  {"cn=admins,ou=groups,dc=corp,dc=example", "cn=staff,ou=groups,dc=corp,dc=example"},
+				"givenName": {"Alice"},
+			},
+		},
+	)
+	t.Cleanup(server.Close)
+
+	saver := &identitySaverMock{}
+	verifier := New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		config.LDAPConfig{
+			Enabled:      true,
+			URL:          server.URL,
+			Timeout:      time.Second,
+			BindDN:       serviceDN,
+			BindPassword: servicePassword,
+			BaseDN:       "ou=people,dc=corp,dc=example",
+			UserFilter:   "(&(objectClass=person)(|(mail=%[1]s)(uid=%[1]s)))",
+			Attributes: config.LDAPAttributeMap{
+				Email:    "mail",
+				Username: "uid",
+				Groups:   "memberOf",
+				Extra:    []string{"cn", "givenName"},
+			},
+		},
+		saver,
+	)
+
+	return verifier, saver
+}
+
+func TestVerify_HappyPath(t *testing.T) {
+	verifier, saver := newVerifier(t)
+
+	for _, login := range []string{"alice@corp.example", "alice"} {
+		user, err := verifier.Verify(context.Background(), login, alicePassword)
+		require.NoError(t, err)
+		assert.Equal(t, "alice@corp.example", user.Email)
+	}
+
+	require.Len(t, saver.identities, 2)
+	identity := saver.identities[0]
+	assert.Equal(t, Provider, identity.Provider)
+	assert.Equal(t, aliceDN, identity.Subject)
+	assert.Equal(t, "alice", identity.Username)
+	assert.Equal(t, map[string]string{"cn": "Alice Liddell", "givenName": "Alice"}, identity.Attributes)
+	assert.ElementsMatch(t, []string{
+		"cn=admins,ou=groups,dc=corp,dc=example",
+		"cn=staff,ou=groups,dc=corp,dc=example",
+	}, identity.Groups)
+}
+
+func TestVerify_FailCases(t *testing.T) {
+	verifier, saver := newVerifier(t)
+
+	cases := []struct {
+		name     string
+		login    string
+		password string
+	}{
+		{name: "Wrong password", login: "alice@corp.example", password: "wrong-password"},
+		{name: "Empty password", login: "alice", password: ""},
+		{name: "Unknown user", login: "bob@corp.example", password: alicePassword},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), c.login, c.password)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+		})
+	}
+
+	assert.Empty(t, saver.identities)
+}
+
+func TestVerify_NotLinked(t *testing.T) {
+	verifier, saver := newVerifier(t)
+	saver.err = storage.ErrIdentityNotLinked
+
+	_, err := verifier.Verify(context.Background(), "alice", alicePassword)
+	assert.ErrorIs(t, err, auth.ErrIdentityNotLinked)
+}
+
+func TestLink(t *testing.T) {
+	verifier, saver := newVerifier(t)
+
+	err := verifier.Link(context.Background(), 7, "alice", "wrong-password")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	assert.Empty(t, saver.links)
+
+	require.NoError(t, verifier.Link(context.Background(), 7, "alice", alicePassword))
+	assert.Equal(t, map[string]int64{aliceDN: 7}, saver.links)
+
+	err = verifier.Link(context.Background(), 8, "alice@corp.example", alicePassword)
+	assert.ErrorIs(t, err, auth.ErrIdentityLinked, "the entry is linked to another user")
+}
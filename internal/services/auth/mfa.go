@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/tokens"
+	"sso/internal/services/mfa"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// Authentication method references of the amr claim, RFC 8176.
+const (
+	amrPassword = "pwd"
+	amrOTP      = "otp"
+	amrHardware = "hwk"
+	amrMFA      = "mfa"
+)
+
+// methodAMR maps MFA methods to the amr value they add to the token.
+var methodAMR = map[string]string{
+	models.MFAMethodTOTP:     amrOTP,
+	models.MFAMethodWebAuthn: amrHardware,
+	// A recovery code is a one-time password as well.
+	models.MFAMethodRecoveryCode: amrOTP,
+}
+
+// MFAVerifier knows the second factors of users. Verify reports a wrong or reused code as
+// mfa.ErrInvalidCode and a method the user has not enabled as mfa.ErrMethodNotEnabled.
+type MFAVerifier interface {
+	Methods(ctx context.Context, userID int64) ([]string, error)
+	Verify(ctx context.Context, userID int64, method string, code string) error
+}
+
+type ChallengeStorage interface {
+	SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error
+	MFAChallenge(ctx context.Context, id string) (models.MFAChallenge, error)
+	CountMFAAttempt(ctx context.Context, id string) (int, error)
+	DeleteMFAChallenge(ctx context.Context, id string) error
+	DeleteExpiredMFAChallenges(ctx context.Context, before time.Time) (int64, error)
+}
+
+// newChallenge starts the second factor step of a login, throttleKey is the account counter of the login.
+func (a *Auth) newChallenge(ctx context.Context, user models.User, app models.App, throttleKey string) (models.MFAChallenge, error) {
+	id, err := tokens.ID()
+	if err != nil {
+		return models.MFAChallenge{}, err
+	}
+
+	challenge := models.MFAChallenge{
+		ID:          id,
+		UserID:      user.ID,
+		AppID:       app.ID,
+		ExpiresAt:   time.Now().Add(a.mfaCfg.ChallengeTTL),
+		ThrottleKey: throttleKey,
+	}
+	if err := a.challenges.SaveMFAChallenge(ctx, challenge); err != nil {
+		return models.MFAChallenge{}, err
+	}
+
+	return challenge, nil
+}
+
+// VerifyMFA finishes a login started by Login with a code of the second factor and returns the token.
+// After mfa.max_attempts wrong codes the challenge is dropped and the login has to start over.
+// Wrong codes are counted by the login throttle on the account and the IP of the login, so starting
+// over does not give fresh attempts: a blocked account gets a LoginThrottledError.
+func (a *Auth) VerifyMFA(ctx context.Context, challengeID string, method string, code string) (string, error) {
+	const op = "auth.VerifyMFA"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("method", method),
+	)
+	log.Info("Verifying second factor")
+
+	challenge, err := a.challenges.MFAChallenge(ctx, challengeID)
+	if err != nil {
+		if errors.Is(err, storage.ErrChallengeNotFound) {
+			log.Info("Challenge not found or expired")
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidChallenge)
+		}
+		log.Error("Failed to get challenge", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", challenge.UserID))
+
+	ip := clientip.FromContext(ctx)
+	attempt := newMFAAttempt(challenge, ip)
+	if err := a.throttle.Check(ctx, attempt); err != nil {
+		if errors.Is(err, ErrLoginThrottled) {
+			log.Warn("Login is throttled", slog.String("ip", ip), prettylogger.Err(err))
+			a.auditLogin(ctx, challenge.UserID, "", int32(challenge.AppID), nil, err)
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("Failed to check login throttle", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mfa.Verify(ctx, challenge.UserID, method, code); err != nil {
+		if errors.Is(err, mfa.ErrMethodNotEnabled) {
+			log.Info("MFA method is not enabled")
+			a.auditLogin(ctx, challenge.UserID, "", int32(challenge.AppID), nil, ErrMFAMethodDenied)
+			return "", fmt.Errorf("%s: %w", op, ErrMFAMethodDenied)
+		}
+		if !errors.Is(err, mfa.ErrInvalidCode) {
+			log.Error("Failed to verify second factor", prettylogger.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		attempts, err := a.challenges.CountMFAAttempt(ctx, challenge.ID)
+		if err != nil && !errors.Is(err, storage.ErrChallengeNotFound) {
+			log.Error("Failed to count attempt", prettylogger.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		if attempts >= a.mfaCfg.MaxAttempts {
+			log.Warn("Too many invalid codes, dropping the challenge", slog.Int("attempts", attempts))
+			if err := a.challenges.DeleteMFAChallenge(ctx, challenge.ID); err != nil && !errors.Is(err, storage.ErrChallengeNotFound) {
+				log.Error("Failed to delete challenge", prettylogger.Err(err))
+			}
+		}
+		if err := a.throttle.Fail(ctx, attempt); err != nil {
+			log.Error("Failed to count failed login", prettylogger.Err(err))
+		}
+		log.Info("Invalid code", slog.Int("attempts", attempts))
+		a.auditLogin(ctx, challenge.UserID, "", int32(challenge.AppID), nil, ErrInvalidMFACode)
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+	}
+
+	// Only one of concurrent verifications of the challenge gets past this.
+	if err := a.challenges.DeleteMFAChallenge(ctx, challenge.ID); err != nil {
+		if errors.Is(err, storage.ErrChallengeNotFound) {
+			log.Warn("Challenge was already used")
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidChallenge)
+		}
+		log.Error("Failed to delete challenge", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The account may have changed since the password check.
+	user, err := a.userProvider.UserByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("User is gone")
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidChallenge)
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if !user.DeletedAt.IsZero() {
+		log.Info("User is deleted")
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidChallenge)
+	}
+	if status := user.CurrentStatus(time.Now()); status != models.UserStatusActive {
+		log.Info("Account is not active", slog.String("status", string(status)))
+		a.auditLogin(ctx, user.ID, "", int32(challenge.AppID), nil, fmt.Errorf("%w: %s", ErrAccountInactive, status))
+		return "", fmt.Errorf("%s: %w: %s", op, ErrAccountInactive, status)
+	}
+
+	app, err := a.appProvider.App(ctx, int32(challenge.AppID))
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("App is gone")
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidChallenge)
+		}
+		log.Error("Failed to get app", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	amr := []string{amrPassword, methodAMR[method]}
+	token, err := a.issueToken(ctx, user, app, amr)
+	if err != nil {
+		log.Error("Failed to issue token", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.throttle.Succeed(ctx, attempt); err != nil {
+		log.Error("Failed to reset failed logins", prettylogger.Err(err))
+	}
+	log.Info("User logged in successfully")
+	a.auditLogin(ctx, user.ID, "", int32(app.ID), amr, nil)
+
+	return token, nil
+}
+
+// PurgeExpiredChallenges removes the challenges of logins that were never finished.
+func (a *Auth) PurgeExpiredChallenges(ctx context.Context) (int64, error) {
+	const op = "auth.PurgeExpiredChallenges"
+	log := a.log.With(slog.String("op", op))
+
+	deleted, err := a.challenges.DeleteExpiredMFAChallenges(ctx, time.Now())
+	if err != nil {
+		log.Error("Failed to delete expired challenges", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if deleted > 0 {
+		log.Info("Expired challenges deleted", slog.Int64("count", deleted))
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/services/mfa"
+	"sso/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testAppSecret = "test-secret"
+	validCode     = "123456"
+)
+
+type appProviderMock struct{}
+
+func (m *appProviderMock) App(ctx context.Context, appID int32) (models.App, error) {
+	if appID != 1 {
+		return models.App{}, storage.ErrAppNotFound
+	}
+	return models.App{ID: 1, Name: "test", Secret: testAppSecret}, nil
+}
+
+type sessionStorageMock struct {
+	sessions []models.Session
+}
+
+func (m *sessionStorageMock) SaveSession(ctx context.Context, session models.Session) error {
+	m.sessions = append(m.sessions, session)
+	return nil
+}
+
+func (m *sessionStorageMock) Session(ctx context.Context, id string) (models.Session, error) {
+	return models.Session{}, storage.ErrSessionNotFound
+}
+
+func (m *sessionStorageMock) RevokeSession(ctx context.Context, id string) error {
+	for i := range m.sessions {
+		if m.sessions[i].ID == id {
+			m.sessions[i].RevokedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (m *sessionStorageMock) RevokeSessions(ctx context.Context, userID int64, exceptID string) (int64, error) {
+	var revoked int64
+	for i := range m.sessions {
+		if m.sessions[i].UserID == userID && m.sessions[i].ID != exceptID && m.sessions[i].Active() {
+			m.sessions[i].RevokedAt = time.Now()
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+type roleProviderMock struct{}
+
+func (m *roleProviderMock) UserRoles(ctx context.Context, userID int64, orgID int64, appID int) ([]models.Role, error) {
+	return nil, nil
+}
+
+func (m *roleProviderMock) HasPermission(ctx context.Context, userID int64, orgID int64, appID int, permission string) (bool, error) {
+	return false, nil
+}
+
+// mfaMock accepts validCode for the users with TOTP enabled.
+type mfaMock struct {
+	enabled map[int64]bool
+}
+
+func (m *mfaMock) Methods(ctx context.Context, userID int64) ([]string, error) {
+	if m.enabled[userID] {
+		return []string{models.MFAMethodTOTP}, nil
+	}
+	return nil, nil
+}
+
+func (m *mfaMock) Verify(ctx context.Context, userID int64, method string, code string) error {
+	if method != models.MFAMethodTOTP || !m.enabled[userID] {
+		return mfa.ErrMethodNotEnabled
+	}
+	if code != validCode {
+		return mfa.ErrInvalidCode
+	}
+	return nil
+}
+
+type challengeStorageMock struct {
+	challenges map[string]models.MFAChallenge
+}
+
+func (m *challengeStorageMock) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	m.challenges[challenge.ID] = challenge
+	return nil
+}
+
+func (m *challengeStorageMock) MFAChallenge(ctx context.Context, id string) (models.MFAChallenge, error) {
+	challenge, ok := m.challenges[id]
+	if !ok || !challenge.ExpiresAt.After(time.Now()) {
+		return models.MFAChallenge{}, storage.ErrChallengeNotFound
+	}
+	return challenge, nil
+}
+
+func (m *challengeStorageMock) CountMFAAttempt(ctx context.Context, id string) (int, error) {
+	challenge, ok := m.challenges[id]
+	if !ok {
+		return 0, storage.ErrChallengeNotFound
+	}
+	challenge.Attempts++
+	m.challenges[id] = challenge
+	return challenge.Attempts, nil
+}
+
+func (m *challengeStorageMock) DeleteMFAChallenge(ctx context.Context, id string) error {
+	if _, ok := m.challenges[id]; !ok {
+		return storage.ErrChallengeNotFound
+	}
+	delete(m.challenges, id)
+	return nil
+}
+
+func (m *challengeStorageMock) DeleteExpiredMFAChallenges(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	for id, challenge := range m.challenges {
+		if !challenge.ExpiresAt.After(before) {
+			delete(m.challenges, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func newMFAAuth(t *testing.T, cfg config.MFAConfig) (*Auth, *challengeStorageMock) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(localPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	users := &userProviderMock{users: map[string]models.User{
+		"alice@example.com": {ID: 1, Email: "alice@example.com", PasswordHash: hash, Status: models.UserStatusActive},
+		"bob@example.com": {
+			ID: 2, Email: "bob@example.com", Username: "bob", Phone: "+15550100002",
+			PasswordHash: hash, Status: models.UserStatusActive,
+		},
+	}}
+	realms, err := NewRealms(config.RealmsConfig{}, nil)
+	require.NoError(t, err)
+	identifiers, err := NewIdentifierPolicy(config.IdentifiersConfig{Default: []string{"email"}})
+	require.NoError(t, err)
+	challenges := &challengeStorageMock{challenges: map[string]models.MFAChallenge{}}
+
+	auth := New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		&userSaverMock{}, users, &appProviderMock{}, &sessionStorageMock{}, nil, &roleProviderMock{}, nil,
+		time.Hour, realms, nil, identifiers,
+		&mfaMock{enabled: map[int64]bool{1: true}}, challenges, cfg, &passkeysMock{}, nil, nil, testHasher(t),
+		config.RegistrationConfig{}, nil, &auditLogMock{}, nil, config.NewDeviceConfig{}, nil,
+	)
+
+	return auth, challenges
+}
+
+func tokenAMR(t *testing.T, token string) []any {
+	t.Helper()
+
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return []byte(testAppSecret), nil
+	})
+	require.NoError(t, err)
+
+	amr, ok := parsed.Claims.(jwt.MapClaims)["amr"].([]any)
+	require.True(t, ok, "token has no amr claim")
+	return amr
+}
+
+func TestLogin_WithoutMFA(t *testing.T) {
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+
+	res, err := a.Login(context.Background(), "bob@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	assert.Empty(t, res.ChallengeID)
+	assert.Equal(t, []any{"pwd"}, tokenAMR(t, res.Token))
+}
+
+func TestLogin_MFAChallenge(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+
+	res, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	assert.Empty(t, res.Token, "the password alone must not be enough")
+	require.NotEmpty(t, res.ChallengeID)
+	assert.Equal(t, []string{models.MFAMethodTOTP}, res.MFAMethods)
+
+	_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, "000000")
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+
+	_, err = a.VerifyMFA(ctx, res.ChallengeID, "webauthn", validCode)
+	assert.ErrorIs(t, err, ErrMFAMethodDenied)
+
+	token, err := a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, validCode)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"pwd", "otp"}, tokenAMR(t, token))
+
+	_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, validCode)
+	assert.ErrorIs(t, err, ErrInvalidChallenge, "a challenge is finished only once")
+}
+
+func TestVerifyMFA_TooManyAttempts(t *testing.T) {
+	ctx := context.Background()
+	a, challenges := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 2})
+
+	res, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, "000000")
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+	}
+	assert.Empty(t, challenges.challenges)
+
+	_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, validCode)
+	assert.ErrorIs(t, err, ErrInvalidChallenge)
+}
+
+func TestVerifyMFA_ExpiredChallenge(t *testing.T) {
+	ctx := context.Background()
+	a, challenges := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+
+	res, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+
+	challenge := challenges.challenges[res.ChallengeID]
+	challenge.ExpiresAt = time.Now().Add(-time.Second)
+	challenges.challenges[res.ChallengeID] = challenge
+
+	_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, validCode)
+	assert.ErrorIs(t, err, ErrInvalidChallenge)
+
+	deleted, err := a.PurgeExpiredChallenges(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/services/mfa"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+// Passkeys runs the WebAuthn login ceremonies. BeginWebAuthnLogin with a user asks for one of
+// the user's credentials as a second factor, with userID 0 for any passkey. FinishWebAuthnLogin
+// reports a rejected assertion as mfa.ErrInvalidCredential.
+type Passkeys interface {
+	BeginWebAuthnLogin(ctx context.Context, userID int64, appID int) ([]byte, error)
+	FinishWebAuthnLogin(ctx context.Context, credential []byte) (userID int64, appID int, err error)
+}
+
+// BeginWebAuthnLogin returns the options for navigator.credentials.get(). With an MFA challenge
+// of Login it asks for a security key or passkey of the user to finish the challenge with VerifyMFA.
+// Without one it starts a passwordless login to the app, finished with LoginWithPasskey.
+func (a *Auth) BeginWebAuthnLogin(ctx context.Context, appID int32, challengeID string) ([]byte, error) {
+	const op = "auth.BeginWebAuthnLogin"
+	log := a.log.With(slog.String("op", op))
+
+	if challengeID != "" {
+		challenge, err := a.challenges.MFAChallenge(ctx, challengeID)
+		if err != nil {
+			if errors.Is(err, storage.ErrChallengeNotFound) {
+				log.Info("Challenge not found or expired")
+				return nil, fmt.Errorf("%s: %w", op, ErrInvalidChallenge)
+			}
+			log.Error("Failed to get challenge", prettylogger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		options, err := a.passkeys.BeginWebAuthnLogin(ctx, challenge.UserID, challenge.AppID)
+		if err != nil {
+			if errors.Is(err, mfa.ErrMethodNotEnabled) {
+				log.Info("User has no WebAuthn credentials", slog.Int64("user_id", challenge.UserID))
+				return nil, fmt.Errorf("%s: %w", op, ErrMFAMethodDenied)
+			}
+			log.Error("Failed to start WebAuthn login", prettylogger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return options, nil
+	}
+
+	if _, err := a.appProvider.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("Invalid app_id", prettylogger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+		log.Error("Failed to get app", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, err := a.passkeys.BeginWebAuthnLogin(ctx, 0, int(appID))
+	if err != nil {
+		log.Error("Failed to start WebAuthn login", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return options, nil
+}
+
+// LoginWithPasskey finishes a passwordless login started by BeginWebAuthnLogin and returns the token.
+// The authenticator has verified the user, so the passkey counts as two factors: the device and
+// the PIN or biometric that unlocked it. The user must still pass the checks of Login for the app.
+func (a *Auth) LoginWithPasskey(ctx context.Context, credential []byte) (string, error) {
+	const op = "auth.LoginWithPasskey"
+	log := a.log.With(slog.String("op", op))
+	log.Info("Attempting to login user with a passkey")
+
+	userID, appID, err := a.passkeys.FinishWebAuthnLogin(ctx, credential)
+	if err != nil {
+		if errors.Is(err, mfa.ErrInvalidCredential) {
+			log.Info("Passkey rejected", prettylogger.Err(err))
+			a.auditLogin(ctx, 0, "", 0, nil, ErrInvalidCredentials)
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		log.Error("Failed to verify passkey", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", userID), slog.Int("app_id", appID))
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("User is gone")
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	app, err := a.appProvider.App(ctx, int32(appID))
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("App is gone")
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+		log.Error("Failed to get app", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.admit(ctx, log, user, app); err != nil {
+		a.auditLogin(ctx, user.ID, "", int32(app.ID), nil, err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	amr := []string{amrHardware, amrMFA}
+	token, err := a.issueToken(ctx, user, app, amr)
+	if err != nil {
+		log.Error("Failed to issue token", prettylogger.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("User logged in successfully")
+	a.auditLogin(ctx, user.ID, "", int32(app.ID), amr, nil)
+
+	return token, nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"sso/internal/config"
+	"sso/internal/services/mfa"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validPasskey = "passkey-of-alice"
+
+// passkeysMock accepts validPasskey as an assertion of the user 1 for the app 1.
+type passkeysMock struct {
+	userID int64
+	appID  int
+}
+
+func (m *passkeysMock) BeginWebAuthnLogin(ctx context.Context, userID int64, appID int) ([]byte, error) {
+	if userID != 0 && userID != 1 {
+		return nil, mfa.ErrMethodNotEnabled
+	}
+	m.userID, m.appID = userID, appID
+	return []byte("{}"), nil
+}
+
+func (m *passkeysMock) FinishWebAuthnLogin(ctx context.Context, credential []byte) (int64, int, error) {
+	if string(credential) != validPasskey {
+		return 0, 0, mfa.ErrInvalidCredential
+	}
+	return 1, 1, nil
+}
+
+func TestLoginWithPasskey(t *testing.T) {
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+
+	token, err := a.LoginWithPasskey(context.Background(), []byte(validPasskey))
+	require.NoError(t, err)
+	assert.Equal(t, []any{"hwk", "mfa"}, tokenAMR(t, token))
+
+	_, err = a.LoginWithPasskey(context.Background(), []byte("forged"))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestBeginWebAuthnLogin(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	passkeys := a.passkeys.(*passkeysMock)
+
+	_, err := a.BeginWebAuthnLogin(ctx, 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), passkeys.userID, "a passwordless login is not bound to a user")
+
+	_, err = a.BeginWebAuthnLogin(ctx, 42, "")
+	assert.ErrorIs(t, err, ErrInvalidAppID)
+
+	res, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	_, err = a.BeginWebAuthnLogin(ctx, 0, res.ChallengeID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), passkeys.userID)
+
+	_, err = a.BeginWebAuthnLogin(ctx, 0, "unknown")
+	assert.ErrorIs(t, err, ErrInvalidChallenge)
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/identifiers"
+	"sso/internal/lib/passwords"
+	"sso/internal/storage"
+	"sync"
+
+	"github.com/jacute/prettylogger"
+)
+
+// PasswordVerifier checks credentials against the password hash stored in the local users table.
+// The login is an email, a username or a phone number, looked up among the ones the organization sees,
+// see storage User. Hashes imported from other systems or made with outdated parameters are
+// replaced with a hash of the current algorithm and parameters once the password matches.
+//
+// Unknown logins and users without a password are checked against a dummy hash, so the response
+// time does not tell them from registered users with a wrong password.
+type PasswordVerifier struct {
+	log          *slog.Logger
+	userProvider UserProvider
+	userSaver    UserSaver
+	hasher       PasswordHasher
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+func NewPasswordVerifier(
+	log *slog.Logger,
+	userProvider UserProvider,
+	userSaver UserSaver,
+	hasher PasswordHasher,
+) *PasswordVerifier {
+	return &PasswordVerifier{
+		log:          log,
+		userProvider: userProvider,
+		userSaver:    userSaver,
+		hasher:       hasher,
+	}
+}
+
+func (v *PasswordVerifier) Verify(ctx context.Context, orgID int64, login string, password string) (models.User, error) {
+	const op = "auth.PasswordVerifier.Verify"
+
+	id, err := identifiers.Parse(login)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	user, err := v.userProvider.UserByIdentifier(ctx, orgID, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			v.compareDummy(password)
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(user.PasswordHash) == 0 {
+		v.compareDummy(password)
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := v.hasher.Compare(user.PasswordHash, password); err != nil {
+		if !errors.Is(err, passwords.ErrMismatch) {
+			v.log.Error("Stored password hash is invalid", slog.Int64("user_id", user.ID), prettylogger.Err(err))
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if v.hasher.NeedsRehash(user.PasswordHash) {
+		user.PasswordHash = v.upgrade(ctx, user, password)
+	}
+
+	return user, nil
+}
+
+// compareDummy spends the time of a password check against a hash of the current algorithm.
+func (v *PasswordVerifier) compareDummy(password string) {
+	v.dummyOnce.Do(func() {
+		// The dummy hash belongs to no user, its password does not have to be secret.
+		hash, err := v.hasher.Hash("dummy password")
+		if err != nil {
+			v.log.Error("Failed to generate dummy password hash", prettylogger.Err(err))
+			return
+		}
+		v.dummyHash = hash
+	})
+
+	if v.dummyHash != nil {
+		_ = v.hasher.Compare(v.dummyHash, password)
+	}
+}
+
+// upgrade rehashes the password of the user with the current algorithm and parameters and returns the new hash.
+// A failed upgrade keeps the old hash, it is tried again on the next login.
+func (v *PasswordVerifier) upgrade(ctx context.Context, user models.User, password string) []byte {
+	log := v.log.With(slog.String("op", "auth.PasswordVerifier.upgrade"), slog.Int64("user_id", user.ID))
+
+	hash, err := v.hasher.Hash(password)
+	if err != nil {
+		log.Error("Failed to generate password hash", prettylogger.Err(err))
+		return user.PasswordHash
+	}
+	if err := v.userSaver.UpdatePassword(ctx, user.ID, hash); err != nil {
+		log.Error("Failed to upgrade password hash", prettylogger.Err(err))
+		return user.PasswordHash
+	}
+	log.Info("Password hash upgraded")
+
+	return hash
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/passwords"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type userSaverMock struct {
+	hashes map[int64][]byte
+	err    error
+}
+
+func (m *userSaverMock) SaveUser(ctx context.Context, user models.User) (int64, error) {
+	return 0, errors.New("not implemented")
+}
+
+func (m *userSaverMock) UpdatePassword(ctx context.Context, userID int64, passwordHash []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.hashes[userID] = passwordHash
+	return nil
+}
+
+// testHasher makes the bcrypt hashes of the test users, so their hashes are never rehashed.
+func testHasher(t *testing.T) *passwords.Hasher {
+	t.Helper()
+
+	h, err := passwords.NewHasher(config.PasswordHashingConfig{
+		Algorithm: passwords.AlgorithmBcrypt,
+		Bcrypt:    config.BcryptConfig{Cost: bcrypt.MinCost},
+	})
+	require.NoError(t, err)
+	return h
+}
+
+func importedHash(password string) []byte {
+	sum := sha256.Sum256([]byte("salt" + password))
+	return []byte("$sha256$salt$" + hex.EncodeToString(sum[:]))
+}
+
+func TestPasswordVerifier_UpgradesImportedHash(t *testing.T) {
+	users := &userProviderMock{users: map[string]models.User{
+		"carol@example.com": {ID: 3, Email: "carol@example.com", PasswordHash: importedHash(localPassword)},
+	}}
+	saver := &userSaverMock{hashes: map[int64][]byte{}}
+	verifier := NewPasswordVerifier(slog.New(slog.NewTextHandler(io.Discard, nil)), users, saver, testHasher(t))
+
+	_, err := verifier.Verify(context.Background(), 0, "carol@example.com", "wrong-password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Empty(t, saver.hashes, "a wrong password must not upgrade the hash")
+
+	user, err := verifier.Verify(context.Background(), 0, "carol@example.com", localPassword)
+	require.NoError(t, err)
+	require.Contains(t, saver.hashes, int64(3))
+	assert.Equal(t, saver.hashes[3], user.PasswordHash)
+	assert.False(t, testHasher(t).NeedsRehash(user.PasswordHash))
+	assert.NoError(t, passwords.Compare(user.PasswordHash, localPassword))
+}
+
+func TestPasswordVerifier_RehashesOutdatedParameters(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(localPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := &userProviderMock{users: map[string]models.User{
+		"carol@example.com": {ID: 3, Email: "carol@example.com", PasswordHash: hash},
+	}}
+	saver := &userSaverMock{hashes: map[int64][]byte{}}
+	hasher, err := passwords.NewHasher(config.PasswordHashingConfig{
+		Algorithm: passwords.AlgorithmArgon2id,
+		Argon2:    config.Argon2Config{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	})
+	require.NoError(t, err)
+	verifier := NewPasswordVerifier(slog.New(slog.NewTextHandler(io.Discard, nil)), users, saver, hasher)
+
+	user, err := verifier.Verify(context.Background(), 0, "carol@example.com", localPassword)
+	require.NoError(t, err)
+	require.Contains(t, saver.hashes, int64(3))
+	assert.True(t, strings.HasPrefix(string(user.PasswordHash), "$argon2id$"))
+	assert.False(t, hasher.NeedsRehash(user.PasswordHash))
+	assert.NoError(t, hasher.Compare(user.PasswordHash, localPassword))
+
+	delete(saver.hashes, 3)
+	users.users["carol@example.com"] = user
+	_, err = verifier.Verify(context.Background(), 0, "carol@example.com", localPassword)
+	require.NoError(t, err)
+	assert.Empty(t, saver.hashes, "a current hash is kept")
+}
+
+func TestPasswordVerifier_FailedUpgradeKeepsLogin(t *testing.T) {
+	hash := importedHash(localPassword)
+	users := &userProviderMock{users: map[string]models.User{
+		"carol@example.com": {ID: 3, Email: "carol@example.com", PasswordHash: hash},
+	}}
+	saver := &userSaverMock{err: errors.New("storage is down")}
+	verifier := NewPasswordVerifier(slog.New(slog.NewTextHandler(io.Discard, nil)), users, saver, testHasher(t))
+
+	user, err := verifier.Verify(context.Background(), 0, "carol@example.com", localPassword)
+	require.NoError(t, err)
+	assert.Equal(t, hash, user.PasswordHash)
+}
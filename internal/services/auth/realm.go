@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/lib/identifiers"
+	"strings"
+
+	"github.com/jacute/prettylogger"
+)
+
+const SourceLocal = "local"
+
+// Realms is the home realm discovery table: it routes a login to the identity
+// source that owns its email domain.
+type Realms struct {
+	sources      map[string]CredentialVerifier
+	defaultRealm realm
+	domains      map[string]realm
+}
+
+type realm struct {
+	source           string
+	passwordFallback bool
+}
+
+// NewRealms builds the routing table from config. Sources are credential verifiers by name,
+// the local password verifier is registered as SourceLocal by Auth itself.
+func NewRealms(cfg config.RealmsConfig, sources map[string]CredentialVerifier) (*Realms, error) {
+	const op = "auth.NewRealms"
+
+	r := &Realms{
+		sources:      sources,
+		defaultRealm: realm{source: cfg.Default},
+		domains:      make(map[string]realm, len(cfg.Domains)),
+	}
+	if r.defaultRealm.source == "" {
+		r.defaultRealm.source = SourceLocal
+	}
+
+	if err := r.checkSource(r.defaultRealm.source); err != nil {
+		return nil, fmt.Errorf("%s: default realm: %w", op, err)
+	}
+	for _, d := range cfg.Domains {
+		domain := strings.ToLower(strings.TrimSpace(d.Domain))
+		if domain == "" {
+			return nil, fmt.Errorf("%s: empty realm domain", op)
+		}
+		if _, ok := r.domains[domain]; ok {
+			return nil, fmt.Errorf("%s: duplicated realm domain %q", op, domain)
+		}
+		if err := r.checkSource(d.Source); err != nil {
+			return nil, fmt.Errorf("%s: realm %q: %w", op, domain, err)
+		}
+		r.domains[domain] = realm{source: d.Source, passwordFallback: d.PasswordFallback}
+	}
+
+	return r, nil
+}
+
+func (r *Realms) checkSource(source string) error {
+	if source == SourceLocal {
+		return nil
+	}
+	if _, ok := r.sources[source]; !ok {
+		return fmt.Errorf("unknown identity source %q", source)
+	}
+	return nil
+}
+
+// Source returns the name of the identity source that owns login, see lookup.
+func (r *Realms) Source(login string, hint string) string {
+	return r.lookup(login, hint).source
+}
+
+// lookup matches the email domain of login and then its parent domains,
+// so "eu.corp.example" is owned by a "corp.example" realm unless it has its own.
+// Logins without a domain, like usernames, are matched by the realm hint, a domain
+// the login page knows the user belongs to. The hint is ignored for email logins,
+// and logins that match no realm go to the default one.
+func (r *Realms) lookup(login string, hint string) realm {
+	if r == nil {
+		return r.defaultOrLocal()
+	}
+
+	domain := hint
+	if at := strings.LastIndexByte(login, '@'); at >= 0 {
+		domain = login[at+1:]
+	}
+
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	for domain != "" {
+		if realm, ok := r.domains[domain]; ok {
+			return realm
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+
+	return r.defaultOrLocal()
+}
+
+func (r *Realms) defaultOrLocal() realm {
+	if r == nil {
+		return realm{source: SourceLocal}
+	}
+	return r.defaultRealm
+}
+
+// DiscoverRealm returns the identity source that checks the password of login, so a login page
+// can tell a directory account from a local one before asking for the password. hint is the realm
+// domain for logins without one, see Realms.lookup.
+func (a *Auth) DiscoverRealm(login string, hint string) string {
+	return a.realms.Source(login, hint)
+}
+
+// verify checks credentials with the source that owns login, falling back to the local
+// password when the realm allows it. Local passwords are checked within the organization orgID,
+// external sources only know users of the shared email scope.
+func (a *Auth) verify(ctx context.Context, orgID int64, login string, hint string, password string) (models.User, error) {
+	realm := a.realms.lookup(login, hint)
+	if realm.source == SourceLocal {
+		return a.passwordVerifier.Verify(ctx, orgID, login, password)
+	}
+
+	user, err := a.realms.sources[realm.source].Verify(ctx, login, password)
+	if err == nil || !realm.passwordFallback || ctx.Err() != nil {
+		return user, err
+	}
+
+	user, fallbackErr := a.passwordVerifier.Verify(ctx, orgID, login, password)
+	if fallbackErr == nil {
+		return user, nil
+	}
+	if errors.Is(fallbackErr, ErrInvalidCredentials) && !errors.Is(err, ErrInvalidCredentials) {
+		// Do not hide an unavailable source behind the fallback rejection.
+		return models.User{}, err
+	}
+
+	return models.User{}, fallbackErr
+}
+
+// LinkIdentity links the identity login has in the external source to the signed-in user, after which
+// the user can log in through the source. Only this explicit step attaches a source to an existing
+// account, a login through the source never takes over a user by a matching email.
+// Wrong passwords are throttled like logins to the shared email scope, where directory users live.
+func (a *Auth) LinkIdentity(ctx context.Context, session models.Session, source string, login string, password string) error {
+	const op = "auth.LinkIdentity"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+		slog.String("source", source),
+	)
+
+	var verifier CredentialVerifier
+	if a.realms != nil {
+		verifier = a.realms.sources[source]
+	}
+	if verifier == nil {
+		return fmt.Errorf("%s: %w", op, ErrUnknownSource)
+	}
+
+	id, err := identifiers.Parse(login)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+	ip := clientip.FromContext(ctx)
+	attempt := newLoginAttempt(0, id, ip)
+	if err := a.throttle.Check(ctx, attempt); err != nil {
+		if errors.Is(err, ErrLoginThrottled) {
+			log.Warn("Identity linking is throttled", slog.String("ip", ip), prettylogger.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("Failed to check login throttle", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = verifier.Link(ctx, session.UserID, id.Value, password)
+	a.auditIdentityLink(ctx, session, source, err)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			log.Info("Invalid credentials", slog.String("ip", ip), prettylogger.Err(err))
+			if err := a.throttle.Fail(ctx, attempt); err != nil {
+				log.Error("Failed to count failed login", prettylogger.Err(err))
+			}
+			return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		if errors.Is(err, ErrIdentityLinked) {
+			log.Warn("Identity is linked to another user")
+			return fmt.Errorf("%s: %w", op, ErrIdentityLinked)
+		}
+		log.Error("Failed to link identity", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.throttle.Succeed(ctx, attempt); err != nil {
+		log.Error("Failed to reset failed logins", prettylogger.Err(err))
+	}
+	log.Info("Identity linked")
+
+	return nil
+}
+
+func (a *Auth) auditIdentityLink(ctx context.Context, session models.Session, source string, err error) {
+	event := models.AuditEvent{
+		Type:         models.AuditIdentityLink,
+		Outcome:      models.AuditSuccess,
+		ActorID:      session.UserID,
+		TargetUserID: session.UserID,
+		AppID:        int32(session.AppID),
+		Details:      source,
+	}
+	if err != nil {
+		event.Outcome = models.AuditFailure
+		event.Details = source + ": " + err.Error()
+	}
+	a.auditLog.Record(ctx, event)
+}
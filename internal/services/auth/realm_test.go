@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const localPassword = "local-password"
+
+type verifierMock struct {
+	calls int
+	err   error
+}
+
+func (m *verifierMock) Verify(ctx context.Context, login string, password string) (models.User, error) {
+	m.calls++
+	if m.err != nil {
+		return models.User{}, m.err
+	}
+	return models.User{ID: 42, Email: login}, nil
+}
+
+func (m *verifierMock) Link(ctx context.Context, userID int64, login string, password string) error {
+	m.calls++
+	return m.err
+}
+
+type userProviderMock struct {
+	users map[string]models.User
+}
+
+func (m *userProviderMock) User(ctx context.Context, orgID int64, email string) (models.User, error) {
+	user, ok := m.users[email]
+	if !ok {
+		return models.User{}, storage.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (m *userProviderMock) UserByIdentifier(ctx context.Context, orgID int64, id models.Identifier) (models.User, error) {
+	for _, user := range m.users {
+		if id.Type == models.IdentifierEmail && user.Email == id.Value ||
+			id.Type == models.IdentifierUsername && user.Username == id.Value ||
+			id.Type == models.IdentifierPhone && user.Phone == id.Value {
+			return user, nil
+		}
+	}
+	return models.User{}, storage.ErrUserNotFound
+}
+
+func (m *userProviderMock) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	for _, user := range m.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return models.User{}, storage.ErrUserNotFound
+}
+
+func (m *userProviderMock) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	return false, nil
+}
+
+func newRealmsAuth(t *testing.T, cfg config.RealmsConfig, sources map[string]CredentialVerifier) *Auth {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(localPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	realms, err := NewRealms(cfg, sources)
+	require.NoError(t, err)
+
+	users := &userProviderMock{users: map[string]models.User{
+		"alice@corp.example": {ID: 1, Email: "alice@corp.example", PasswordHash: hash},
+		"bob@example.com":    {ID: 2, Email: "bob@example.com", Username: "bob", PasswordHash: hash},
+	}}
+
+	return New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)), nil, users, nil, nil, nil, nil, nil, 0, realms, nil, nil,
+		nil, nil, config.MFAConfig{}, nil, nil, nil, testHasher(t), config.RegistrationConfig{}, nil,
+		&auditLogMock{}, nil, config.NewDeviceConfig{}, nil,
+	)
+}
+
+func TestRealms_Source(t *testing.T) {
+	realms, err := NewRealms(config.RealmsConfig{
+		Domains: []config.RealmConfig{
+			{Domain: "Corp.Example", Source: "ldap"},
+			{Domain: "partner.example", Source: "oidc"},
+		},
+	}, map[string]CredentialVerifier{"ldap": &verifierMock{}, "oidc": &verifierMock{}})
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"alice@corp.example":      "ldap",
+		"alice@CORP.EXAMPLE":      "ldap",
+		"alice@eu.corp.example":   "ldap",
+		"carol@partner.example":   "oidc",
+		"bob@example.com":         SourceLocal,
+		"alice@notcorp.example":   SourceLocal,
+		"alice":                   SourceLocal,
+		"weird@corp.example@evil": SourceLocal,
+	}
+	for login, want := range cases {
+		assert.Equal(t, want, realms.Source(login, ""), login)
+	}
+
+	assert.Equal(t, "ldap", realms.Source("alice", "corp.example"))
+	assert.Equal(t, SourceLocal, realms.Source("alice@example.com", "corp.example"), "the email domain wins")
+}
+
+func TestNewRealms_FailCases(t *testing.T) {
+	sources := map[string]CredentialVerifier{"ldap": &verifierMock{}}
+
+	cases := []struct {
+		name string
+		cfg  config.RealmsConfig
+	}{
+		{name: "Unknown default source", cfg: config.RealmsConfig{Default: "oidc"}},
+		{name: "Unknown domain source", cfg: config.RealmsConfig{Domains: []config.RealmConfig{{Domain: "corp.example", Source: "oidc"}}}},
+		{name: "Empty domain", cfg: config.RealmsConfig{Domains: []config.RealmConfig{{Source: "ldap"}}}},
+		{name: "Duplicated domain", cfg: config.RealmsConfig{Domains: []config.RealmConfig{
+			{Domain: "corp.example", Source: "ldap"},
+			{Domain: "CORP.example", Source: SourceLocal},
+		}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewRealms(c.cfg, sources)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerify_Routing(t *testing.T) {
+	cases := []struct {
+		name      string
+		fallback  bool
+		sourceErr error
+		login     string
+		hint      string
+		password  string
+		wantID    int64
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "Routed to source", login: "alice@corp.example", password: "any", wantID: 42, wantCalls: 1},
+		{name: "Default realm is local", login: "bob@example.com", password: localPassword, wantID: 2},
+		{name: "Username login is local", login: "bob", password: localPassword, wantID: 2},
+		{name: "Username login with realm hint", login: "alice", hint: "EU.corp.example", password: "any", wantID: 42, wantCalls: 1},
+		{name: "Unknown realm hint is local", login: "bob", hint: "example.com", password: localPassword, wantID: 2},
+		{name: "Hint is ignored for emails", login: "bob@example.com", hint: "corp.example", password: localPassword, wantID: 2},
+		{name: "Source rejects without fallback", sourceErr: ErrInvalidCredentials, login: "alice@corp.example", password: localPassword, wantErr: ErrInvalidCredentials, wantCalls: 1},
+		{name: "Source rejects with fallback", fallback: true, sourceErr: ErrInvalidCredentials, login: "alice@corp.example", password: localPassword, wantID: 1, wantCalls: 1},
+		{name: "Fallback rejects too", fallback: true, sourceErr: ErrInvalidCredentials, login: "alice@corp.example", password: "wrong-password", wantErr: ErrInvalidCredentials, wantCalls: 1},
+		{name: "Unavailable source is not hidden", fallback: true, sourceErr: errSourceDown, login: "alice@corp.example", password: "wrong-password", wantErr: errSourceDown, wantCalls: 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			source := &verifierMock{err: c.sourceErr}
+			a := newRealmsAuth(t, config.RealmsConfig{
+				Domains: []config.RealmConfig{{Domain: "corp.example", Source: "ldap", PasswordFallback: c.fallback}},
+			}, map[string]CredentialVerifier{"ldap": source})
+
+			user, err := a.verify(context.Background(), 0, c.login, c.hint, c.password)
+			if c.wantErr != nil {
+				assert.ErrorIs(t, err, c.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.wantID, user.ID)
+			}
+			assert.Equal(t, c.wantCalls, source.calls)
+		})
+	}
+}
+
+var errSourceDown = errors.New("source is down")
+
+func TestLinkIdentity(t *testing.T) {
+	source := &verifierMock{}
+	a := newRealmsAuth(t, config.RealmsConfig{}, map[string]CredentialVerifier{"ldap": source})
+	session := models.Session{UserID: 2, AppID: 1}
+
+	require.NoError(t, a.LinkIdentity(context.Background(), session, "ldap", "bob", "directory-password"))
+	assert.Equal(t, 1, source.calls)
+
+	err := a.LinkIdentity(context.Background(), session, "oidc", "bob", "directory-password")
+	assert.ErrorIs(t, err, ErrUnknownSource)
+
+	source.err = ErrIdentityLinked
+	err = a.LinkIdentity(context.Background(), session, "ldap", "bob", "directory-password")
+	assert.ErrorIs(t, err, ErrIdentityLinked)
+
+	audit := a.auditLog.(*auditLogMock)
+	require.Len(t, audit.events, 2)
+	assert.Equal(t, models.AuditIdentityLink, audit.events[0].Type)
+	assert.Equal(t, models.AuditSuccess, audit.events[0].Outcome)
+	assert.Equal(t, models.AuditFailure, audit.events[1].Outcome)
+}
+
+func TestVerify_IdentityNotLinked(t *testing.T) {
+	source := &verifierMock{err: ErrIdentityNotLinked}
+	a := newRealmsAuth(t, config.RealmsConfig{
+		Domains: []config.RealmConfig{{Domain: "corp.example", Source: "ldap"}},
+	}, map[string]CredentialVerifier{"ldap": source})
+
+	_, err := a.verify(context.Background(), 0, "alice@corp.example", "", "directory-password")
+	assert.ErrorIs(t, err, ErrIdentityNotLinked)
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// LoginThrottleStorage keeps the failed login counters of LoginThrottle.
+type LoginThrottleStorage interface {
+	LoginThrottle(ctx context.Context, key string) (models.LoginThrottle, error)
+	CountLoginFailure(ctx context.Context, key string, at time.Time, resetBefore time.Time) (int, error)
+	BlockLogin(ctx context.Context, key string, until time.Time) error
+	DeleteLoginThrottle(ctx context.Context, key string) error
+	DeleteExpiredLoginThrottles(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ErrLoginThrottled is wrapped by LoginThrottledError.
+var ErrLoginThrottled = errors.New("Too many failed login attempts")
+
+// LoginThrottledError is returned by Login while the account or the client IP is blocked.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrLoginThrottled, e.RetryAfter)
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrLoginThrottled
+}
+
+// LoginThrottle counts failed password checks per account and per client IP and blocks
+// further logins with exponential backoff, see config.LoginThrottleConfig. Accounts are counted
+// by the email of the user the login identifier belongs to, and unknown logins by the identifier,
+// so they are throttled the same way as existing ones.
+// A nil LoginThrottle lets every login through.
+type LoginThrottle struct {
+	storage LoginThrottleStorage
+	cfg     config.LoginThrottleConfig
+}
+
+func NewLoginThrottle(storage LoginThrottleStorage, cfg config.LoginThrottleConfig) *LoginThrottle {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &LoginThrottle{storage: storage, cfg: cfg}
+}
+
+// loginAttempt names the counters a login is checked against, ip is empty if unknown.
+type loginAttempt struct {
+	account string
+	ip      string
+}
+
+// newLoginAttempt keys the account by the identifier within its email scope, see OrgProvider.EmailScope,
+// so the apps of all organizations sharing the scope count the failures of a user together.
+func newLoginAttempt(scope int64, id models.Identifier, ip string) loginAttempt {
+	attempt := loginAttempt{account: fmt.Sprintf("account:%d:%s", scope, id.Value)}
+	if ip != "" {
+		attempt.ip = "ip:" + ip
+	}
+	return attempt
+}
+
+// loginAttempt is the attempt of a login with the identifier. A username or a phone number of a local
+// user is counted on the user's email, so that every identifier of the account does not bring its own
+// free attempts. Logins going to an external realm are not local users and keep their identifier.
+func (a *Auth) loginAttempt(
+	ctx context.Context,
+	orgID int64,
+	scope int64,
+	id models.Identifier,
+	realmHint string,
+	ip string,
+) (loginAttempt, error) {
+	if id.Type == models.IdentifierEmail || a.realms.lookup(id.Value, realmHint).source != SourceLocal {
+		return newLoginAttempt(scope, id, ip), nil
+	}
+
+	user, err := a.userProvider.UserByIdentifier(ctx, orgID, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return newLoginAttempt(scope, id, ip), nil
+		}
+		return loginAttempt{}, err
+	}
+
+	return newLoginAttempt(scope, models.Identifier{Type: models.IdentifierEmail, Value: user.Email}, ip), nil
+}
+
+// newMFAAttempt is the attempt of the second factor step: the account of the login
+// that started the challenge and the IP the code comes from.
+func newMFAAttempt(challenge models.MFAChallenge, ip string) loginAttempt {
+	attempt := loginAttempt{account: challenge.ThrottleKey}
+	if ip != "" {
+		attempt.ip = "ip:" + ip
+	}
+	return attempt
+}
+
+// Check returns a LoginThrottledError if the account or the IP of the attempt is blocked.
+func (t *LoginThrottle) Check(ctx context.Context, attempt loginAttempt) error {
+	if t == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, counter := range t.counters(attempt) {
+		throttle, err := t.storage.LoginThrottle(ctx, counter.key)
+		if err != nil {
+			if errors.Is(err, storage.ErrLoginThrottleNotFound) {
+				continue
+			}
+			return err
+		}
+		if throttle.Blocked(now) {
+			retryAfter = max(retryAfter, throttle.BlockedUntil.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// Fail counts a wrong password or second factor code for the account and the IP of the attempt and blocks them if needed.
+func (t *LoginThrottle) Fail(ctx context.Context, attempt loginAttempt) error {
+	if t == nil {
+		return nil
+	}
+
+	now := time.Now()
+	for _, counter := range t.counters(attempt) {
+		failures, err := t.storage.CountLoginFailure(ctx, counter.key, now, now.Add(-t.cfg.ResetAfter))
+		if err != nil {
+			return err
+		}
+		delay := t.delay(failures, counter.freeAttempts, counter.lockout)
+		if delay == 0 {
+			continue
+		}
+		if err := t.storage.BlockLogin(ctx, counter.key, now.Add(delay)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Succeed forgets the failures of the account after a finished login. The IP keeps its
+// failures, otherwise logging in to an own account would reset them for a password guesser.
+func (t *LoginThrottle) Succeed(ctx context.Context, attempt loginAttempt) error {
+	if t == nil {
+		return nil
+	}
+	if attempt.account == "" {
+		return nil
+	}
+	return t.storage.DeleteLoginThrottle(ctx, attempt.account)
+}
+
+// delay is how long logins are blocked after the given number of failures.
+func (t *LoginThrottle) delay(failures int, freeAttempts int, lockout int) time.Duration {
+	if lockout > 0 && failures >= lockout {
+		return t.cfg.LockoutDuration
+	}
+	if failures <= freeAttempts {
+		return 0
+	}
+
+	delay := t.cfg.BaseDelay
+	for i := freeAttempts + 1; i < failures && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, t.cfg.MaxDelay)
+}
+
+// PurgeExpired removes the counters that are neither blocked nor recent enough to count.
+func (t *LoginThrottle) PurgeExpired(ctx context.Context) (int64, error) {
+	if t == nil {
+		return 0, nil
+	}
+	return t.storage.DeleteExpiredLoginThrottles(ctx, time.Now().Add(-t.cfg.ResetAfter))
+}
+
+// PurgeExpiredLoginThrottles removes the failed login counters that no longer block or count,
+// it runs periodically in the background.
+func (a *Auth) PurgeExpiredLoginThrottles(ctx context.Context) (int64, error) {
+	const op = "auth.PurgeExpiredLoginThrottles"
+	log := a.log.With(slog.String("op", op))
+
+	deleted, err := a.throttle.PurgeExpired(ctx)
+	if err != nil {
+		log.Error("Failed to delete expired login throttles", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if deleted > 0 {
+		log.Info("Expired login throttles deleted", slog.Int64("count", deleted))
+	}
+
+	return deleted, nil
+}
+
+// failureCounter is a key of the attempt with the limits that apply to it.
+type failureCounter struct {
+	key          string
+	freeAttempts int
+	lockout      int
+}
+
+func (t *LoginThrottle) counters(attempt loginAttempt) []failureCounter {
+	var counters []failureCounter
+	// Challenges started before the key was stored have no account counter.
+	if attempt.account != "" {
+		counters = append(counters, failureCounter{attempt.account, t.cfg.AccountFreeAttempts, t.cfg.AccountLockout})
+	}
+	if attempt.ip != "" {
+		counters = append(counters, failureCounter{attempt.ip, t.cfg.IPFreeAttempts, t.cfg.IPLockout})
+	}
+	return counters
+}
+
+// CheckPassword, FailPassword and SucceedPassword throttle the password checks of a signed in user,
+// such as the old password of a password change, on the counters of a login with the user's email.
+func (t *LoginThrottle) CheckPassword(ctx context.Context, scope int64, email string, ip string) error {
+	return t.Check(ctx, newLoginAttempt(scope, models.Identifier{Type: models.IdentifierEmail, Value: email}, ip))
+}
+
+func (t *LoginThrottle) FailPassword(ctx context.Context, scope int64, email string, ip string) error {
+	return t.Fail(ctx, newLoginAttempt(scope, models.Identifier{Type: models.IdentifierEmail, Value: email}, ip))
+}
+
+func (t *LoginThrottle) SucceedPassword(ctx context.Context, scope int64, email string, ip string) error {
+	return t.Succeed(ctx, newLoginAttempt(scope, models.Identifier{Type: models.IdentifierEmail, Value: email}, ip))
+}
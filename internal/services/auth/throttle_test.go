@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"context"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientip"
+	"sso/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loginThrottleStorageMock struct {
+	throttles map[string]models.LoginThrottle
+}
+
+func (m *loginThrottleStorageMock) LoginThrottle(ctx context.Context, key string) (models.LoginThrottle, error) {
+	throttle, ok := m.throttles[key]
+	if !ok {
+		return models.LoginThrottle{}, storage.ErrLoginThrottleNotFound
+	}
+	return throttle, nil
+}
+
+func (m *loginThrottleStorageMock) CountLoginFailure(ctx context.Context, key string, at time.Time, resetBefore time.Time) (int, error) {
+	throttle := m.throttles[key]
+	throttle.Key = key
+	if throttle.LastFailureAt.Before(resetBefore) {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = at
+	m.throttles[key] = throttle
+	return throttle.Failures, nil
+}
+
+func (m *loginThrottleStorageMock) BlockLogin(ctx context.Context, key string, until time.Time) error {
+	throttle, ok := m.throttles[key]
+	if !ok {
+		return storage.ErrLoginThrottleNotFound
+	}
+	throttle.BlockedUntil = until
+	m.throttles[key] = throttle
+	return nil
+}
+
+func (m *loginThrottleStorageMock) DeleteLoginThrottle(ctx context.Context, key string) error {
+	delete(m.throttles, key)
+	return nil
+}
+
+func (m *loginThrottleStorageMock) DeleteExpiredLoginThrottles(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	for key, throttle := range m.throttles {
+		if throttle.LastFailureAt.Before(before) && !throttle.Blocked(before) {
+			delete(m.throttles, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func testThrottleConfig() config.LoginThrottleConfig {
+	return config.LoginThrottleConfig{
+		Enabled:             true,
+		AccountFreeAttempts: 2,
+		AccountLockout:      5,
+		IPFreeAttempts:      3,
+		IPLockout:           0,
+		BaseDelay:           time.Second,
+		MaxDelay:            3 * time.Second,
+		LockoutDuration:     time.Hour,
+		ResetAfter:          time.Hour,
+	}
+}
+
+func TestLoginThrottle_Delay(t *testing.T) {
+	throttle := NewLoginThrottle(nil, testThrottleConfig())
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Second},
+		{failures: 4, want: 2 * time.Second},
+		{failures: 5, want: time.Hour},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, throttle.delay(tt.failures, 2, 5), "failures: %d", tt.failures)
+	}
+
+	assert.Equal(t, 3*time.Second, throttle.delay(100, 3, 0), "capped by max_delay without a lockout")
+	assert.Equal(t, 3*time.Second, throttle.delay(1<<20, 3, 0), "no overflow")
+}
+
+func TestLoginThrottle_Disabled(t *testing.T) {
+	cfg := testThrottleConfig()
+	cfg.Enabled = false
+	assert.Nil(t, NewLoginThrottle(&loginThrottleStorageMock{}, cfg))
+}
+
+func TestLogin_Throttled(t *testing.T) {
+	ctx := clientip.NewContext(context.Background(), "198.51.100.1")
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	throttles := &loginThrottleStorageMock{throttles: map[string]models.LoginThrottle{}}
+	a.throttle = NewLoginThrottle(throttles, testThrottleConfig())
+
+	for i := 0; i < 3; i++ {
+		_, err := a.Login(ctx, "bob@example.com", "wrong password", 1, "")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	_, err := a.Login(ctx, "bob@example.com", localPassword, 1, "")
+	var throttled *LoginThrottledError
+	require.ErrorAs(t, err, &throttled, "the right password is blocked as well")
+	assert.ErrorIs(t, err, ErrLoginThrottled)
+	assert.Greater(t, throttled.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, throttled.RetryAfter, time.Second)
+
+	_, err = a.Login(clientip.NewContext(context.Background(), "203.0.113.7"), "alice@example.com", localPassword, 1, "")
+	require.NoError(t, err, "other accounts and IPs are not affected")
+
+	account := throttles.throttles["account:0:bob@example.com"]
+	account.BlockedUntil = time.Now().Add(-time.Second)
+	throttles.throttles[account.Key] = account
+
+	_, err = a.Login(ctx, "bob@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	assert.NotContains(t, throttles.throttles, "account:0:bob@example.com", "a correct password resets the account")
+	assert.Equal(t, 3, throttles.throttles["ip:198.51.100.1"].Failures, "but not the IP")
+}
+
+func TestLogin_ThrottledAcrossIdentifiers(t *testing.T) {
+	ctx := clientip.NewContext(context.Background(), "198.51.100.1")
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	throttles := &loginThrottleStorageMock{throttles: map[string]models.LoginThrottle{}}
+	a.throttle = NewLoginThrottle(throttles, testThrottleConfig())
+	policy, err := NewIdentifierPolicy(config.IdentifiersConfig{Default: []string{"email", "username", "phone"}})
+	require.NoError(t, err)
+	a.identifiers = policy
+
+	for _, login := range []string{"bob@example.com", "bob", "+15550100002"} {
+		_, err := a.Login(ctx, login, "wrong password", 1, "")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	assert.Equal(t, 3, throttles.throttles["account:0:bob@example.com"].Failures, "the identifiers share the account counter")
+
+	_, err = a.Login(clientip.NewContext(context.Background(), "203.0.113.7"), "bob", localPassword, 1, "")
+	assert.ErrorIs(t, err, ErrLoginThrottled, "another identifier brings no fresh attempts")
+
+	_, err = a.Login(ctx, "nobody", "wrong password", 1, "")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Equal(t, 1, throttles.throttles["account:0:nobody"].Failures, "unknown logins are counted by the identifier")
+}
+
+func TestLoginThrottle_Password(t *testing.T) {
+	ctx := clientip.NewContext(context.Background(), "198.51.100.1")
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	throttles := &loginThrottleStorageMock{throttles: map[string]models.LoginThrottle{}}
+	a.throttle = NewLoginThrottle(throttles, testThrottleConfig())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, a.throttle.FailPassword(ctx, 0, "bob@example.com", "198.51.100.7"))
+	}
+	assert.Equal(t, 3, throttles.throttles["account:0:bob@example.com"].Failures)
+
+	_, err := a.Login(ctx, "bob@example.com", localPassword, 1, "")
+	assert.ErrorIs(t, err, ErrLoginThrottled, "wrong passwords of a signed in user block the login")
+	assert.ErrorIs(t, a.throttle.CheckPassword(ctx, 0, "bob@example.com", ""), ErrLoginThrottled)
+
+	require.NoError(t, a.throttle.SucceedPassword(ctx, 0, "bob@example.com", "198.51.100.7"))
+	assert.NotContains(t, throttles.throttles, "account:0:bob@example.com")
+	assert.Equal(t, 3, throttles.throttles["ip:198.51.100.7"].Failures)
+}
+
+func TestLogin_ThrottledByIP(t *testing.T) {
+	ctx := clientip.NewContext(context.Background(), "198.51.100.1")
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	throttles := &loginThrottleStorageMock{throttles: map[string]models.LoginThrottle{}}
+	a.throttle = NewLoginThrottle(throttles, testThrottleConfig())
+
+	for _, login := range []string{"alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com"} {
+		_, err := a.Login(ctx, login, "wrong password", 1, "")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	_, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+	assert.ErrorIs(t, err, ErrLoginThrottled, "guessing across accounts is limited per IP")
+
+	_, err = a.Login(context.Background(), "alice@example.com", localPassword, 1, "")
+	assert.NoError(t, err)
+}
+
+// orgAppProviderMock serves app 1 without an organization and app N+1 in organization N.
+type orgAppProviderMock struct{}
+
+func (m *orgAppProviderMock) App(ctx context.Context, appID int32) (models.App, error) {
+	return models.App{ID: int(appID), OrgID: int64(appID - 1), Name: "test", Secret: testAppSecret}, nil
+}
+
+// orgProviderMock isolates the emails of the organizations in isolated.
+type orgProviderMock struct {
+	isolated map[int64]bool
+}
+
+func (m *orgProviderMock) IsMember(ctx context.Context, orgID int64, userID int64) (bool, error) {
+	return true, nil
+}
+
+func (m *orgProviderMock) EmailScope(ctx context.Context, orgID int64) (int64, error) {
+	if m.isolated[orgID] {
+		return orgID, nil
+	}
+	return 0, nil
+}
+
+func TestLogin_ThrottledAcrossOrganizations(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	a.appProvider = &orgAppProviderMock{}
+	a.orgProvider = &orgProviderMock{isolated: map[int64]bool{3: true}}
+	throttles := &loginThrottleStorageMock{throttles: map[string]models.LoginThrottle{}}
+	a.throttle = NewLoginThrottle(throttles, testThrottleConfig())
+
+	// The apps of organizations 1 and 2 and the app without one share the email scope.
+	for _, appID := range []int32{2, 3, 2} {
+		_, err := a.Login(ctx, "bob@example.com", "wrong password", appID, "")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	assert.Equal(t, 3, throttles.throttles["account:0:bob@example.com"].Failures)
+
+	_, err := a.Login(ctx, "bob@example.com", localPassword, 1, "")
+	assert.ErrorIs(t, err, ErrLoginThrottled, "the failures through other organizations count")
+
+	_, err = a.Login(ctx, "bob@example.com", localPassword, 4, "")
+	assert.NoError(t, err, "an isolated organization has users of its own")
+}
+
+func TestVerifyMFA_Throttled(t *testing.T) {
+	ctx := clientip.NewContext(context.Background(), "198.51.100.1")
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	throttles := &loginThrottleStorageMock{throttles: map[string]models.LoginThrottle{}}
+	cfg := testThrottleConfig()
+	cfg.BaseDelay, cfg.MaxDelay = 0, 0
+	a.throttle = NewLoginThrottle(throttles, cfg)
+
+	// Every login gets a fresh challenge, the wrong codes still add up on the account.
+	for i := 0; i < cfg.AccountLockout; i++ {
+		res, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+		require.NoError(t, err, "attempt %d", i)
+		require.NotEmpty(t, res.ChallengeID)
+		_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, "000000")
+		require.ErrorIs(t, err, ErrInvalidMFACode)
+	}
+
+	_, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+	var throttled *LoginThrottledError
+	require.ErrorAs(t, err, &throttled, "the account is locked out")
+	assert.Equal(t, cfg.LockoutDuration, throttled.RetryAfter.Round(time.Minute))
+
+	account := throttles.throttles["account:0:alice@example.com"]
+	account.BlockedUntil = time.Time{}
+	throttles.throttles[account.Key] = account
+
+	res, err := a.Login(ctx, "alice@example.com", localPassword, 1, "")
+	require.NoError(t, err)
+	assert.Contains(t, throttles.throttles, account.Key, "the password alone does not reset the account")
+	_, err = a.VerifyMFA(ctx, res.ChallengeID, models.MFAMethodTOTP, validCode)
+	require.NoError(t, err)
+	assert.NotContains(t, throttles.throttles, account.Key, "a finished login resets the account")
+}
+
+func TestPurgeExpiredLoginThrottles(t *testing.T) {
+	a, _ := newMFAAuth(t, config.MFAConfig{ChallengeTTL: time.Minute, MaxAttempts: 5})
+	throttles := &loginThrottleStorageMock{throttles: map[string]models.LoginThrottle{
+		"ip:old":     {Key: "ip:old", Failures: 3, LastFailureAt: time.Now().Add(-2 * time.Hour)},
+		"ip:recent":  {Key: "ip:recent", Failures: 3, LastFailureAt: time.Now()},
+		"ip:blocked": {Key: "ip:blocked", Failures: 50, LastFailureAt: time.Now().Add(-2 * time.Hour), BlockedUntil: time.Now().Add(time.Hour)},
+	}}
+	a.throttle = NewLoginThrottle(throttles, testThrottleConfig())
+
+	deleted, err := a.PurgeExpiredLoginThrottles(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.NotContains(t, throttles.throttles, "ip:old")
+}
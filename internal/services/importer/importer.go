@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/identifiers"
+	"sso/internal/lib/passwords"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+var (
+	ErrUnknownFormat    = errors.New("Unknown import format")
+	ErrInvalidHeader    = errors.New("Invalid CSV header")
+	ErrMalformedRecord  = errors.New("Malformed record")
+	ErrPasswordRequired = errors.New("Exactly one of password and password hash is required")
+	ErrUserExists       = errors.New("User already exists")
+	ErrUsernameTaken    = errors.New("Username already taken")
+	ErrPhoneTaken       = errors.New("Phone number already taken")
+	ErrOrgNotFound      = errors.New("Organization not found")
+)
+
+// maxErrors is how many failed records a Result keeps, the rest are only counted.
+const maxErrors = 100
+
+// Importer creates users in bulk from CSV or JSON Lines, as when moving over from another system.
+type Importer struct {
+	log            *slog.Logger
+	userSaver      UserSaver
+	passwordHasher PasswordHasher
+}
+
+type UserSaver interface {
+	SaveUser(ctx context.Context, user models.User) (int64, error)
+}
+
+// PasswordHasher hashes the plain text passwords of imported users, see passwords.Hasher.
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+}
+
+func New(log *slog.Logger, userSaver UserSaver, passwordHasher PasswordHasher) *Importer {
+	return &Importer{
+		log:            log,
+		userSaver:      userSaver,
+		passwordHasher: passwordHasher,
+	}
+}
+
+// RecordError is a record that could not be imported, Line is its line in the input.
+type RecordError struct {
+	Line  int
+	Email string
+	Err   error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+type Result struct {
+	Imported int
+	Failed   int
+	// Errors are the first failed records, at most maxErrors of them.
+	Errors []RecordError
+}
+
+// Import reads the records from r and creates a user for each of them. A bad record doesn't
+// stop the import, it is counted in the result, while an error reading the input or saving
+// a user does and is returned with the result so far.
+func (i *Importer) Import(ctx context.Context, r io.Reader, format Format) (Result, error) {
+	const op = "importer.Import"
+	log := i.log.With(
+		slog.String("op", op),
+		slog.String("format", string(format)),
+	)
+	log.Info("Importing users")
+
+	res := Result{}
+
+	reader, err := newRecordReader(r, format)
+	if err != nil {
+		log.Warn("Invalid input", prettylogger.Err(err))
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, fmt.Errorf("%s: %w", op, err)
+		}
+
+		line, rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			err = i.importRecord(ctx, line, rec)
+		}
+
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			log.Debug("Record is not imported", slog.Int("line", recErr.Line), prettylogger.Err(recErr.Err))
+			res.Failed++
+			if len(res.Errors) < maxErrors {
+				res.Errors = append(res.Errors, *recErr)
+			}
+			continue
+		}
+		if err != nil {
+			log.Error("Import stopped", slog.Int("line", line), prettylogger.Err(err))
+			return res, fmt.Errorf("%s: %w", op, err)
+		}
+
+		res.Imported++
+	}
+	log.Info("Users imported", slog.Int("imported", res.Imported), slog.Int("failed", res.Failed))
+
+	return res, nil
+}
+
+// importRecord saves the user of the record. The problems of the record itself are returned
+// as a *RecordError.
+func (i *Importer) importRecord(ctx context.Context, line int, rec Record) error {
+	user, err := i.toUser(rec)
+	if err != nil {
+		return &RecordError{Line: line, Email: rec.Email, Err: err}
+	}
+
+	if _, err := i.userSaver.SaveUser(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserExists):
+			err = ErrUserExists
+		case errors.Is(err, storage.ErrUsernameTaken):
+			err = ErrUsernameTaken
+		case errors.Is(err, storage.ErrPhoneTaken):
+			err = ErrPhoneTaken
+		case errors.Is(err, storage.ErrOrgNotFound):
+			err = ErrOrgNotFound
+		default:
+			return err
+		}
+		return &RecordError{Line: line, Email: user.Email, Err: err}
+	}
+
+	return nil
+}
+
+// toUser normalizes the identifiers of the record and prepares the password hash to store:
+// a foreign hash is kept as is to be upgraded on login, a plain password is hashed.
+func (i *Importer) toUser(rec Record) (models.User, error) {
+	email, err := identifiers.NormalizeEmail(rec.Email)
+	if err != nil {
+		return models.User{}, err
+	}
+	user := models.User{
+		Email:         email,
+		OrgID:         rec.OrgID,
+		EmailVerified: rec.EmailVerified,
+	}
+
+	if rec.Username != "" {
+		if user.Username, err = identifiers.NormalizeUsername(rec.Username); err != nil {
+			return models.User{}, err
+		}
+	}
+	if rec.Phone != "" {
+		if user.Phone, err = identifiers.NormalizePhone(rec.Phone); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	switch {
+	case rec.PasswordHash != "" && rec.Password == "":
+		user.PasswordHash, err = passwords.Import(passwords.HashType(rec.HashType), rec.PasswordHash)
+	case rec.Password != "" && rec.PasswordHash == "":
+		user.PasswordHash, err = i.passwordHasher.Hash(rec.Password)
+	default:
+		err = ErrPasswordRequired
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
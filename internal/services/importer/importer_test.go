@@ -0,0 +1,149 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/identifiers"
+	"sso/internal/lib/passwords"
+	"sso/internal/storage"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sha256("salt" + "secret")
+const secretHash = "$sha256$salt$bede90386d450cea8b77b822f8887065e4e5abf132c2f9dccfcc7fbd4cba5e35"
+
+type userSaverMock struct {
+	users []models.User
+	err   error
+}
+
+func (m *userSaverMock) SaveUser(ctx context.Context, user models.User) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	for _, u := range m.users {
+		if u.Email == user.Email {
+			return 0, storage.ErrUserExists
+		}
+	}
+	m.users = append(m.users, user)
+	return int64(len(m.users)), nil
+}
+
+// testHasher makes bcrypt hashes of the cost the imported test hashes have.
+var testHasher = func() *passwords.Hasher {
+	h, err := passwords.NewHasher(config.PasswordHashingConfig{
+		Algorithm: passwords.AlgorithmBcrypt,
+		Bcrypt:    config.BcryptConfig{Cost: bcrypt.MinCost},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return h
+}()
+
+func newImporter(saver *userSaverMock) *Importer {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), saver, testHasher)
+}
+
+func TestImport_CSV(t *testing.T) {
+	saver := &userSaverMock{}
+	input := strings.Join([]string{
+		"email,username,phone,org_id,email_verified,password,password_hash,hash_type",
+		"alice@Example.COM,Alice,+1 555 123 4567,2,true,,$2a$04$ZNNRsPb3iXeicDyqp9zgz.044nmrc0vydSxwCBnMXZ3EOoyyn1Aga,bcrypt",
+		"bob@example.com,,,,,,$sha256$salt$00,sha",
+		"alice@example.com,,,,,secret,,",
+		`"carol@example.com,,`,
+	}, "\n")
+
+	res, err := newImporter(saver).Import(context.Background(), strings.NewReader(input), FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Imported)
+	assert.Equal(t, 3, res.Failed)
+
+	require.Len(t, saver.users, 1)
+	alice := saver.users[0]
+	assert.Equal(t, "alice@example.com", alice.Email)
+	assert.Equal(t, "alice", alice.Username)
+	assert.Equal(t, "+15551234567", alice.Phone)
+	assert.Equal(t, int64(2), alice.OrgID)
+	assert.True(t, alice.EmailVerified)
+	assert.False(t, testHasher.NeedsRehash(alice.PasswordHash))
+
+	require.Len(t, res.Errors, 3)
+	assert.Equal(t, 3, res.Errors[0].Line)
+	assert.ErrorIs(t, res.Errors[0].Err, passwords.ErrInvalidHash)
+	assert.Equal(t, 4, res.Errors[1].Line)
+	assert.ErrorIs(t, res.Errors[1].Err, ErrUserExists)
+	assert.Equal(t, 5, res.Errors[2].Line)
+	assert.ErrorIs(t, res.Errors[2].Err, ErrMalformedRecord)
+}
+
+func TestImport_JSONL(t *testing.T) {
+	saver := &userSaverMock{}
+	input := strings.Join([]string{
+		`{"email": "alice@example.com", "password_hash": "` + secretHash + `", "hash_type": "sha"}`,
+		``,
+		`{"email": "bob@example.com", "password": "secret", "password_hash": "` + secretHash + `", "hash_type": "sha"}`,
+		`{"email": "carol@example.com", "password": "secret", "role": "admin"}`,
+		`{"email": "dave@example.com", "username": "1dave", "password": "secret"}`,
+		`{"email": "erin@example.com", "password_hash": "` + secretHash + `", "hash_type": "md5"}`,
+	}, "\n")
+
+	res, err := newImporter(saver).Import(context.Background(), strings.NewReader(input), FormatJSONL)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Imported)
+	require.Len(t, saver.users, 1)
+	assert.Equal(t, secretHash, string(saver.users[0].PasswordHash), "imported hashes are stored as is")
+
+	wantErrors := map[int]error{
+		3: ErrPasswordRequired,
+		4: ErrMalformedRecord,
+		5: identifiers.ErrInvalidUsername,
+		6: passwords.ErrUnknownHashType,
+	}
+	require.Len(t, res.Errors, len(wantErrors))
+	for _, recErr := range res.Errors {
+		assert.ErrorIs(t, recErr.Err, wantErrors[recErr.Line], recErr.Line)
+	}
+}
+
+func TestImport_FailCases(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		format Format
+		saver  *userSaverMock
+		err    error
+	}{
+		{name: "Unknown format", input: "", format: "xml", saver: &userSaverMock{}, err: ErrUnknownFormat},
+		{name: "Empty CSV", input: "", format: FormatCSV, saver: &userSaverMock{}, err: ErrInvalidHeader},
+		{name: "Unknown column", input: "email,role\n", format: FormatCSV, saver: &userSaverMock{}, err: ErrInvalidHeader},
+		{name: "Missing email column", input: "username,password\n", format: FormatCSV, saver: &userSaverMock{}, err: ErrInvalidHeader},
+		{
+			name:   "Storage error",
+			input:  "email,password\nalice@example.com,secret\n",
+			format: FormatCSV,
+			saver:  &userSaverMock{err: errors.New("storage is down")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := newImporter(c.saver).Import(context.Background(), strings.NewReader(c.input), c.format)
+			require.Error(t, err)
+			if c.err != nil {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
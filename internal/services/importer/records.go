@@ -0,0 +1,175 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+func (f Format) Valid() bool {
+	return f == FormatCSV || f == FormatJSONL
+}
+
+// maxLineSize limits a JSON Lines record, a user is far smaller than that.
+const maxLineSize = 64 * 1024
+
+// Record is a user to import. It comes either with a plain password, hashed on import,
+// or with a password hash of another system tagged with its type, see passwords.Import.
+type Record struct {
+	Email         string `json:"email"`
+	Username      string `json:"username"`
+	Phone         string `json:"phone"`
+	OrgID         int64  `json:"org_id"`
+	EmailVerified bool   `json:"email_verified"`
+	Password      string `json:"password"`
+	PasswordHash  string `json:"password_hash"`
+	HashType      string `json:"hash_type"`
+}
+
+// recordReader reads the records one by one. A malformed record is reported as a *RecordError
+// and the reading goes on with the next one, any other error stops it.
+type recordReader interface {
+	Read() (line int, rec Record, err error)
+}
+
+func newRecordReader(r io.Reader, format Format) (recordReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+		return &jsonlReader{scanner: scanner}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// csvColumns are the columns a CSV header may name, in any order. Only email is required.
+var csvColumns = map[string]func(rec *Record, value string) error{
+	"email":    func(rec *Record, value string) error { rec.Email = value; return nil },
+	"username": func(rec *Record, value string) error { rec.Username = value; return nil },
+	"phone":    func(rec *Record, value string) error { rec.Phone = value; return nil },
+	"org_id": func(rec *Record, value string) (err error) {
+		if value != "" {
+			rec.OrgID, err = strconv.ParseInt(value, 10, 64)
+		}
+		return err
+	},
+	"email_verified": func(rec *Record, value string) (err error) {
+		if value != "" {
+			rec.EmailVerified, err = strconv.ParseBool(value)
+		}
+		return err
+	},
+	"password":      func(rec *Record, value string) error { rec.Password = value; return nil },
+	"password_hash": func(rec *Record, value string) error { rec.PasswordHash = value; return nil },
+	"hash_type":     func(rec *Record, value string) error { rec.HashType = value; return nil },
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: empty input", ErrInvalidHeader)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := csvColumns[name]; !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidHeader, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: duplicated column %q", ErrInvalidHeader, name)
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+	if !seen["email"] {
+		return nil, fmt.Errorf("%w: missing column \"email\"", ErrInvalidHeader)
+	}
+
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvReader) Read() (int, Record, error) {
+	fields, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, Record{}, &RecordError{
+			Line: parseErr.StartLine,
+			Err:  fmt.Errorf("%w: %w", ErrMalformedRecord, parseErr.Err),
+		}
+	}
+	if err != nil {
+		return 0, Record{}, err
+	}
+	line, _ := r.reader.FieldPos(0)
+
+	rec := Record{}
+	for i, value := range fields {
+		if err := csvColumns[r.columns[i]](&rec, strings.TrimSpace(value)); err != nil {
+			return line, Record{}, &RecordError{
+				Line:  line,
+				Email: rec.Email,
+				Err:   fmt.Errorf("%w: invalid %s", ErrMalformedRecord, r.columns[i]),
+			}
+		}
+	}
+
+	return line, rec, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonlReader) Read() (int, Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		rec := Record{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rec); err != nil {
+			return r.line, Record{}, &RecordError{Line: r.line, Err: fmt.Errorf("%w: %w", ErrMalformedRecord, err)}
+		}
+
+		return r.line, rec, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return r.line + 1, Record{}, err
+	}
+
+	return r.line, Record{}, io.EOF
+}
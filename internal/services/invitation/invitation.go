@@ -0,0 +1,377 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/identifiers"
+	"sso/internal/lib/mailer"
+	"sso/internal/lib/passwordpolicy"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("Invitation not found")
+	ErrNotPending         = errors.New("Invitation is already accepted, revoked or expired")
+	ErrInvalidToken       = errors.New("Invalid or expired token")
+	ErrAppNotFound        = errors.New("App not found")
+	ErrOrgNotFound        = errors.New("Organization not found")
+	ErrRoleNotFound       = errors.New("Role not found")
+	ErrOrgMismatch        = errors.New("App belongs to another organization")
+	ErrRoleMismatch       = errors.New("Role belongs to another app or organization")
+	ErrUserNotFound       = errors.New("User not found")
+	ErrUserExists         = errors.New("User already exists, sign in to accept the invitation")
+	ErrPasswordRequired   = errors.New("Password is required to create the account")
+	ErrIsolatedOrg        = errors.New("Organization with isolated emails only has its own users")
+)
+
+// Invitation lets admins invite people by email to an organization or an app
+// instead of having them call Register.
+type Invitation struct {
+	log               *slog.Logger
+	invitationStorage InvitationStorage
+	userProvider      UserProvider
+	appProvider       AppProvider
+	orgProvider       OrgProvider
+	roleProvider      RoleProvider
+	mailer            mailer.Mailer
+	ttl               time.Duration
+	link              string
+	passwordPolicy    PasswordPolicy
+	passwordHasher    PasswordHasher
+}
+
+type InvitationStorage interface {
+	SaveInvitation(ctx context.Context, inv models.Invitation) (int64, error)
+	Invitation(ctx context.Context, invitationID int64) (models.Invitation, error)
+	InvitationByHash(ctx context.Context, hash []byte) (models.Invitation, error)
+	Invitations(ctx context.Context, filter models.InvitationFilter, now time.Time) ([]models.Invitation, error)
+	RevokeInvitation(ctx context.Context, invitationID int64) error
+	AcceptInvitation(ctx context.Context, invitationID int64, userID int64, passwordHash []byte) (int64, error)
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int32) (models.App, error)
+}
+
+type OrgProvider interface {
+	Organization(ctx context.Context, orgID int64) (models.Organization, error)
+}
+
+type RoleProvider interface {
+	Role(ctx context.Context, roleID int64) (models.Role, error)
+}
+
+// PasswordPolicy rejects weak passwords with a *passwordpolicy.ViolationError, see package passwordpolicy.
+type PasswordPolicy interface {
+	Check(appID int32, password string, userInputs ...string) error
+}
+
+// PasswordHasher makes the hashes of new passwords, see passwords.Hasher.
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+}
+
+// New creates the invitation service, invitations expire after ttl and are emailed
+// as link, a fmt template the token replaces %s in.
+func New(
+	log *slog.Logger,
+	invitationStorage InvitationStorage,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	orgProvider OrgProvider,
+	roleProvider RoleProvider,
+	mailer mailer.Mailer,
+	ttl time.Duration,
+	link string,
+	passwordPolicy PasswordPolicy,
+	passwordHasher PasswordHasher,
+) *Invitation {
+	return &Invitation{
+		log:               log,
+		invitationStorage: invitationStorage,
+		userProvider:      userProvider,
+		appProvider:       appProvider,
+		orgProvider:       orgProvider,
+		roleProvider:      roleProvider,
+		mailer:            mailer,
+		ttl:               ttl,
+		link:              link,
+		passwordPolicy:    passwordPolicy,
+		passwordHasher:    passwordHasher,
+	}
+}
+
+// CreateInvitation stores the invitation and emails its token to the invited address.
+// An invitation to an app of an organization is an invitation to the organization as well.
+// The roles must belong to the invitation app or be global, and to its organization or none.
+func (i *Invitation) CreateInvitation(ctx context.Context, inv models.Invitation) (models.Invitation, error) {
+	const op = "invitation.CreateInvitation"
+	if email, err := identifiers.NormalizeEmail(inv.Email); err == nil {
+		inv.Email = email
+	}
+	log := i.log.With(
+		slog.String("op", op),
+		slog.Int64("inviter_id", inv.InviterID),
+		slog.String("email", inv.Email),
+		slog.Int64("org_id", inv.OrgID),
+		slog.Int("app_id", inv.AppID),
+	)
+	log.Info("Creating invitation")
+
+	if err := i.resolveScope(ctx, &inv); err != nil {
+		log.Warn("Invalid invitation", prettylogger.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, hash, err := tokens.New()
+	if err != nil {
+		log.Error("Failed to generate token", prettylogger.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	inv.Hash = hash
+	inv.ExpiresAt = time.Now().Add(i.ttl)
+
+	id, err := i.invitationStorage.SaveInvitation(ctx, inv)
+	if err != nil {
+		log.Error("Failed to save invitation", prettylogger.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	inv, err = i.invitationStorage.Invitation(ctx, id)
+	if err != nil {
+		log.Error("Failed to get invitation", prettylogger.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = i.mailer.Send(ctx, mailer.Message{
+		To:      inv.Email,
+		Subject: "You are invited",
+		Body: fmt.Sprintf(
+			"You have been invited to sign up. Follow the link to accept the invitation:\n\n%s\n\nThe link expires in %s. If you did not expect it, ignore this message.\n",
+			fmt.Sprintf(i.link, token), i.ttl,
+		),
+	})
+	if err != nil {
+		log.Error("Failed to send invitation email", prettylogger.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Invitation created", slog.Int64("invitation_id", id))
+
+	return inv, nil
+}
+
+// resolveScope checks the app, organization and roles of the invitation
+// and fills its organization in from the app.
+func (i *Invitation) resolveScope(ctx context.Context, inv *models.Invitation) error {
+	if inv.AppID != models.GlobalAppID {
+		app, err := i.appProvider.App(ctx, int32(inv.AppID))
+		if err != nil {
+			if errors.Is(err, storage.ErrAppNotFound) {
+				return ErrAppNotFound
+			}
+			return err
+		}
+		if app.OrgID != 0 {
+			if inv.OrgID == 0 {
+				inv.OrgID = app.OrgID
+			}
+			if inv.OrgID != app.OrgID {
+				return ErrOrgMismatch
+			}
+		}
+	}
+
+	if inv.OrgID != 0 {
+		if _, err := i.orgProvider.Organization(ctx, inv.OrgID); err != nil {
+			if errors.Is(err, storage.ErrOrgNotFound) {
+				return ErrOrgNotFound
+			}
+			return err
+		}
+	}
+
+	for _, roleID := range inv.RoleIDs {
+		role, err := i.roleProvider.Role(ctx, roleID)
+		if err != nil {
+			if errors.Is(err, storage.ErrRoleNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+		if role.AppID != models.GlobalAppID && role.AppID != inv.AppID {
+			return ErrRoleMismatch
+		}
+		if role.OrgID != 0 && role.OrgID != inv.OrgID {
+			return ErrRoleMismatch
+		}
+	}
+
+	return nil
+}
+
+// ListInvitations returns the invitations matching the filter, newest first.
+func (i *Invitation) ListInvitations(ctx context.Context, filter models.InvitationFilter) ([]models.Invitation, error) {
+	const op = "invitation.ListInvitations"
+
+	invs, err := i.invitationStorage.Invitations(ctx, filter, time.Now())
+	if err != nil {
+		i.log.Error("Failed to list invitations", slog.String("op", op), prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invs, nil
+}
+
+// RevokeInvitation makes a pending invitation unusable.
+func (i *Invitation) RevokeInvitation(ctx context.Context, invitationID int64) error {
+	const op = "invitation.RevokeInvitation"
+	log := i.log.With(
+		slog.String("op", op),
+		slog.Int64("invitation_id", invitationID),
+	)
+	log.Info("Revoking invitation")
+
+	inv, err := i.invitationStorage.Invitation(ctx, invitationID)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			log.Warn("Invitation not found")
+			return fmt.Errorf("%s: %w", op, ErrInvitationNotFound)
+		}
+		log.Error("Failed to get invitation", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if inv.Status(time.Now()) != models.InvitationPending {
+		log.Warn("Invitation is not pending")
+		return fmt.Errorf("%s: %w", op, ErrNotPending)
+	}
+
+	if err := i.invitationStorage.RevokeInvitation(ctx, invitationID); err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			log.Warn("Invitation is not pending")
+			return fmt.Errorf("%s: %w", op, ErrNotPending)
+		}
+		log.Error("Failed to revoke invitation", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Invitation revoked")
+
+	return nil
+}
+
+// AcceptInvitation accepts the invitation the token was issued for. A signed-in caller passes
+// their userID and the invitation is applied to their account, otherwise userID is 0 and
+// a user with the invited email and the password is created, the password must meet the policy
+// of the invitation app. It returns the ID of the user.
+func (i *Invitation) AcceptInvitation(ctx context.Context, token string, userID int64, password string) (int64, error) {
+	const op = "invitation.AcceptInvitation"
+	log := i.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+	log.Info("Accepting invitation")
+
+	inv, err := i.invitationStorage.InvitationByHash(ctx, tokens.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			log.Warn("Invitation not found")
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("Failed to get invitation", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("invitation_id", inv.ID))
+	if inv.Status(time.Now()) != models.InvitationPending {
+		log.Warn("Invitation is not pending")
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	var passwordHash []byte
+	if userID != 0 {
+		if err := i.checkLink(ctx, inv, userID); err != nil {
+			log.Warn("Invitation can't be linked to the user", prettylogger.Err(err))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	} else {
+		if password == "" {
+			log.Warn("Password is required")
+			return 0, fmt.Errorf("%s: %w", op, ErrPasswordRequired)
+		}
+		if err := i.passwordPolicy.Check(int32(inv.AppID), password, inv.Email); err != nil {
+			if errors.Is(err, passwordpolicy.ErrViolated) {
+				log.Warn("Password does not meet the policy", prettylogger.Err(err))
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+			log.Error("Failed to check password policy", prettylogger.Err(err))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		passwordHash, err = i.passwordHasher.Hash(password)
+		if err != nil {
+			log.Error("Failed to generate password hash", prettylogger.Err(err))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	userID, err = i.invitationStorage.AcceptInvitation(ctx, inv.ID, userID, passwordHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			log.Warn("Invitation is not pending")
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Warn("User already exists")
+			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+		log.Error("Failed to accept invitation", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Invitation accepted", slog.Int64("user_id", userID))
+
+	return userID, nil
+}
+
+// checkLink reports whether the existing user can accept the invitation: it is only for the owner
+// of the invited email, who has verified it, and organizations with isolated emails only take their own users.
+func (i *Invitation) checkLink(ctx context.Context, inv models.Invitation, userID int64) error {
+	user, err := i.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if !user.DeletedAt.IsZero() {
+		return ErrUserNotFound
+	}
+	// The token may have leaked from the invited mailbox, it only works for the account of that email.
+	if !user.EmailVerified || user.Email != inv.Email {
+		return ErrInvalidToken
+	}
+
+	if inv.OrgID == 0 || user.OrgID == inv.OrgID {
+		return nil
+	}
+
+	org, err := i.orgProvider.Organization(ctx, inv.OrgID)
+	if err != nil {
+		return err
+	}
+	if org.IsolatedEmails {
+		return ErrIsolatedOrg
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package mfa manages the second factors of users, authenticator apps and WebAuthn credentials,
+// and the recovery codes for when they are lost: their enrollment and the check of a code
+// that finishes a login challenge.
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/webauthn"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+type MFA struct {
+	log             *slog.Logger
+	userProvider    UserProvider
+	totpStorage     TOTPStorage
+	cipher          Cipher
+	issuer          string
+	webAuthnStorage WebAuthnStorage
+	rp              *webauthn.RelyingParty
+	recoveryStorage RecoveryCodeStorage
+	recoveryCodes   int
+	now             func() time.Time
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+}
+
+type TOTPStorage interface {
+	SaveTOTP(ctx context.Context, totp models.TOTP) error
+	TOTP(ctx context.Context, userID int64) (models.TOTP, error)
+	ConfirmTOTP(ctx context.Context, userID int64, step int64) error
+	UseTOTPStep(ctx context.Context, userID int64, step int64) error
+	DeleteTOTP(ctx context.Context, userID int64) error
+}
+
+// Cipher encrypts the secrets of second factors at rest.
+type Cipher interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(sealed []byte) ([]byte, error)
+}
+
+var (
+	ErrUnavailable      = errors.New("MFA is not configured")
+	ErrUserNotFound     = errors.New("User not found")
+	ErrTOTPEnabled      = errors.New("TOTP is already enabled")
+	ErrTOTPNotEnrolled  = errors.New("TOTP is not enrolled")
+	ErrInvalidCode      = errors.New("Invalid code")
+	ErrMethodNotEnabled = errors.New("MFA method is not enabled")
+	ErrMFANotEnabled    = errors.New("MFA is not enabled")
+
+	ErrInvalidCredential  = errors.New("Invalid WebAuthn credential")
+	ErrCredentialExists   = errors.New("WebAuthn credential is already registered")
+	ErrCredentialNotFound = errors.New("WebAuthn credential not found")
+)
+
+// New creates the MFA service. Without a cipher no secrets can be stored, so TOTP enrollment
+// fails with ErrUnavailable. WebAuthn credentials are public keys and need no cipher.
+func New(
+	log *slog.Logger,
+	userProvider UserProvider,
+	totpStorage TOTPStorage,
+	cipher Cipher,
+	cfg config.MFAConfig,
+	webAuthnStorage WebAuthnStorage,
+	rp *webauthn.RelyingParty,
+	recoveryStorage RecoveryCodeStorage,
+) *MFA {
+	return &MFA{
+		log:             log,
+		userProvider:    userProvider,
+		totpStorage:     totpStorage,
+		cipher:          cipher,
+		issuer:          cfg.Issuer,
+		webAuthnStorage: webAuthnStorage,
+		rp:              rp,
+		recoveryStorage: recoveryStorage,
+		recoveryCodes:   cfg.RecoveryCodes,
+		now:             time.Now,
+	}
+}
+
+// Methods returns the second factors the user has enabled, a login of a user with none needs only the password.
+// Recovery codes are listed while some are left, but only along with a factor they stand in for.
+func (m *MFA) Methods(ctx context.Context, userID int64) ([]string, error) {
+	const op = "mfa.Methods"
+
+	methods, err := m.factors(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	remaining, err := m.recoveryStorage.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if remaining > 0 {
+		methods = append(methods, models.MFAMethodRecoveryCode)
+	}
+
+	return methods, nil
+}
+
+// factors returns the second factors of the user other than recovery codes.
+func (m *MFA) factors(ctx context.Context, userID int64) ([]string, error) {
+	var methods []string
+
+	totp, err := m.totpStorage.TOTP(ctx, userID)
+	if err != nil && !errors.Is(err, storage.ErrTOTPNotFound) {
+		return nil, err
+	}
+	if err == nil && totp.Confirmed() {
+		methods = append(methods, models.MFAMethodTOTP)
+	}
+
+	creds, err := m.webAuthnStorage.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) > 0 {
+		methods = append(methods, models.MFAMethodWebAuthn)
+	}
+
+	return methods, nil
+}
+
+// Verify checks a code of the second factor, it fails with ErrInvalidCode if the code is wrong
+// or was already used and with ErrMethodNotEnabled if the user has no such factor.
+// The code of webauthn is the JSON of an assertion to the options of BeginWebAuthnLogin.
+func (m *MFA) Verify(ctx context.Context, userID int64, method string, code string) error {
+	const op = "mfa.Verify"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.String("method", method),
+	)
+
+	var err error
+	switch method {
+	case models.MFAMethodTOTP:
+		err = m.verifyTOTP(ctx, userID, code)
+	case models.MFAMethodWebAuthn:
+		err = m.verifyWebAuthn(ctx, userID, code)
+	case models.MFAMethodRecoveryCode:
+		err = m.verifyRecoveryCode(ctx, userID, code)
+	default:
+		err = ErrMethodNotEnabled
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) || errors.Is(err, ErrMethodNotEnabled) {
+			log.Info("Second factor rejected", prettylogger.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("Failed to verify second factor", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Second factor verified")
+
+	return nil
+}
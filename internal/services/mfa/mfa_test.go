@@ -0,0 +1,192 @@
+package mfa
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/secretbox"
+	"sso/internal/lib/totp"
+	"sso/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userProviderMock struct{}
+
+func (m *userProviderMock) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	if userID != 1 {
+		return models.User{}, storage.ErrUserNotFound
+	}
+	return models.User{ID: 1, Email: "alice@example.com"}, nil
+}
+
+type totpStorageMock struct {
+	totps map[int64]models.TOTP
+}
+
+func (m *totpStorageMock) SaveTOTP(ctx context.Context, t models.TOTP) error {
+	if existing, ok := m.totps[t.UserID]; ok && existing.Confirmed() {
+		return storage.ErrTOTPExists
+	}
+	m.totps[t.UserID] = t
+	return nil
+}
+
+func (m *totpStorageMock) TOTP(ctx context.Context, userID int64) (models.TOTP, error) {
+	t, ok := m.totps[userID]
+	if !ok {
+		return models.TOTP{}, storage.ErrTOTPNotFound
+	}
+	return t, nil
+}
+
+func (m *totpStorageMock) ConfirmTOTP(ctx context.Context, userID int64, step int64) error {
+	t, ok := m.totps[userID]
+	if !ok || t.Confirmed() {
+		return storage.ErrTOTPNotFound
+	}
+	t.ConfirmedAt = time.Now()
+	t.LastStep = step
+	m.totps[userID] = t
+	return nil
+}
+
+func (m *totpStorageMock) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	t := m.totps[userID]
+	if t.LastStep >= step {
+		return storage.ErrTOTPStepUsed
+	}
+	t.LastStep = step
+	m.totps[userID] = t
+	return nil
+}
+
+func (m *totpStorageMock) DeleteTOTP(ctx context.Context, userID int64) error {
+	if _, ok := m.totps[userID]; !ok {
+		return storage.ErrTOTPNotFound
+	}
+	delete(m.totps, userID)
+	return nil
+}
+
+func newMFA(t *testing.T, cipher Cipher) (*MFA, *totpStorageMock) {
+	t.Helper()
+
+	totps := &totpStorageMock{totps: map[int64]models.TOTP{}}
+	m := New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)), &userProviderMock{}, totps, cipher, config.MFAConfig{Issuer: "SSO", RecoveryCodes: 10},
+		newWebAuthnStorageMock(), newRP(t), newRecoveryCodeStorageMock(),
+	)
+
+	return m, totps
+}
+
+func newBox(t *testing.T) *secretbox.Box {
+	t.Helper()
+
+	box, err := secretbox.New(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, secretbox.KeySize)))
+	require.NoError(t, err)
+	return box
+}
+
+func code(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	c, err := totp.Code(secret, totp.Step(at))
+	require.NoError(t, err)
+	return c
+}
+
+func TestTOTP_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	session := models.Session{ID: "s", UserID: 1}
+	m, totps := newMFA(t, newBox(t))
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	secret, uri, err := m.EnrollTOTP(ctx, session)
+	require.NoError(t, err)
+	assert.Contains(t, uri, "otpauth://totp/SSO:alice@example.com")
+	assert.NotContains(t, string(totps.totps[1].Secret), secret, "the secret is stored encrypted")
+
+	methods, err := m.Methods(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, methods, "an unconfirmed secret is not used at login")
+
+	_, err = m.ConfirmTOTP(ctx, session, "000000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	recoveryCodes, err := m.ConfirmTOTP(ctx, session, code(t, secret, now))
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, 10, "the first second factor comes with recovery codes")
+
+	methods, err = m.Methods(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.MFAMethodTOTP, models.MFAMethodRecoveryCode}, methods)
+
+	_, _, err = m.EnrollTOTP(ctx, session)
+	assert.ErrorIs(t, err, ErrTOTPEnabled)
+
+	// The code that confirmed the enrollment can't be used again.
+	err = m.Verify(ctx, 1, models.MFAMethodTOTP, code(t, secret, now))
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	now = now.Add(totp.Period)
+	require.NoError(t, m.Verify(ctx, 1, models.MFAMethodTOTP, code(t, secret, now)))
+	err = m.Verify(ctx, 1, models.MFAMethodTOTP, code(t, secret, now))
+	assert.ErrorIs(t, err, ErrInvalidCode, "a code works once")
+
+	err = m.DisableTOTP(ctx, session, "000000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	now = now.Add(totp.Period)
+	require.NoError(t, m.DisableTOTP(ctx, session, code(t, secret, now)))
+
+	err = m.Verify(ctx, 1, models.MFAMethodTOTP, code(t, secret, now))
+	assert.ErrorIs(t, err, ErrMethodNotEnabled)
+}
+
+func TestEnrollTOTP_ReplacesUnconfirmed(t *testing.T) {
+	ctx := context.Background()
+	session := models.Session{ID: "s", UserID: 1}
+	m, _ := newMFA(t, newBox(t))
+
+	first, _, err := m.EnrollTOTP(ctx, session)
+	require.NoError(t, err)
+	second, _, err := m.EnrollTOTP(ctx, session)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	_, err = m.ConfirmTOTP(ctx, session, code(t, first, time.Now()))
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	_, err = m.ConfirmTOTP(ctx, session, code(t, second, time.Now()))
+	require.NoError(t, err)
+}
+
+func TestMFA_FailCases(t *testing.T) {
+	ctx := context.Background()
+
+	m, _ := newMFA(t, nil)
+	_, _, err := m.EnrollTOTP(ctx, models.Session{UserID: 1})
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	m, _ = newMFA(t, newBox(t))
+	_, _, err = m.EnrollTOTP(ctx, models.Session{UserID: 2})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = m.ConfirmTOTP(ctx, models.Session{UserID: 1}, "123456")
+	assert.ErrorIs(t, err, ErrTOTPNotEnrolled)
+
+	err = m.DisableTOTP(ctx, models.Session{UserID: 1}, "123456")
+	assert.ErrorIs(t, err, ErrTOTPNotEnrolled)
+
+	err = m.Verify(ctx, 1, "sms", "123456")
+	assert.ErrorIs(t, err, ErrMethodNotEnabled)
+}
@@ -0,0 +1,169 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+	"strings"
+
+	"github.com/jacute/prettylogger"
+)
+
+// Recovery codes are 10 characters of an alphabet without look-alike letters, 50 bits of entropy,
+// shown as two groups of five. The dash and the case do not matter when a code is entered.
+const (
+	recoveryAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+	recoveryLength   = 10
+)
+
+type RecoveryCodeStorage interface {
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes [][]byte) error
+	CountRecoveryCodes(ctx context.Context, userID int64) (int, error)
+	UseRecoveryCode(ctx context.Context, userID int64, hash []byte) error
+}
+
+// RegenerateRecoveryCodes gives the authenticated user a new set of recovery codes and returns them,
+// the old ones stop working. The codes are shown only here, the user has to write them down.
+func (m *MFA) RegenerateRecoveryCodes(ctx context.Context, session models.Session) ([]string, error) {
+	const op = "mfa.RegenerateRecoveryCodes"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Regenerating recovery codes")
+
+	factors, err := m.factors(ctx, session.UserID)
+	if err != nil {
+		log.Error("Failed to get second factors", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(factors) == 0 {
+		log.Warn("User has no second factor")
+		return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnabled)
+	}
+
+	codes, err := m.newRecoveryCodes(ctx, session.UserID)
+	if err != nil {
+		log.Error("Failed to save recovery codes", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Recovery codes regenerated", slog.Int("count", len(codes)))
+
+	return codes, nil
+}
+
+// RecoveryCodesRemaining returns how many unused recovery codes the authenticated user has.
+func (m *MFA) RecoveryCodesRemaining(ctx context.Context, session models.Session) (int, error) {
+	const op = "mfa.RecoveryCodesRemaining"
+
+	remaining, err := m.recoveryStorage.CountRecoveryCodes(ctx, session.UserID)
+	if err != nil {
+		m.log.Error("Failed to count recovery codes", slog.String("op", op), prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return remaining, nil
+}
+
+// firstFactorRecoveryCodes returns a new set of recovery codes if the user has just enabled
+// their first second factor, and nil otherwise.
+func (m *MFA) firstFactorRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	enabled := 0
+
+	totp, err := m.totpStorage.TOTP(ctx, userID)
+	if err != nil && !errors.Is(err, storage.ErrTOTPNotFound) {
+		return nil, err
+	}
+	if err == nil && totp.Confirmed() {
+		enabled++
+	}
+	creds, err := m.webAuthnStorage.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	enabled += len(creds)
+
+	if enabled != 1 {
+		return nil, nil
+	}
+
+	return m.newRecoveryCodes(ctx, userID)
+}
+
+func (m *MFA) newRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes := make([]string, 0, m.recoveryCodes)
+	hashes := make([][]byte, 0, m.recoveryCodes)
+	for range m.recoveryCodes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, tokens.Hash(normalizeRecoveryCode(code)))
+	}
+
+	if err := m.recoveryStorage.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// verifyRecoveryCode spends a recovery code of the user. Every use is logged as a warning with
+// the number of codes left, a recovery code at login means the user lost their other factors.
+func (m *MFA) verifyRecoveryCode(ctx context.Context, userID int64, code string) error {
+	remaining, err := m.recoveryStorage.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return ErrMethodNotEnabled
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryLength {
+		return ErrInvalidCode
+	}
+	if err := m.recoveryStorage.UseRecoveryCode(ctx, userID, tokens.Hash(normalized)); err != nil {
+		if errors.Is(err, storage.ErrRecoveryCodeNotFound) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+
+	m.log.Warn("Recovery code used",
+		slog.String("op", "mfa.verifyRecoveryCode"),
+		slog.Int64("user_id", userID),
+		slog.Int("remaining", remaining-1),
+	)
+
+	return nil
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		// 256 is a multiple of the alphabet size, so every character is equally likely.
+		b[i] = recoveryAlphabet[int(b[i])%len(recoveryAlphabet)]
+	}
+
+	return string(b[:recoveryLength/2]) + "-" + string(b[recoveryLength/2:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
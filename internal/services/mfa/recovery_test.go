@@ -0,0 +1,113 @@
+package mfa
+
+import (
+	"bytes"
+	"context"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recoveryCodeStorageMock struct {
+	codes map[int64][]models.RecoveryCode
+}
+
+func newRecoveryCodeStorageMock() *recoveryCodeStorageMock {
+	return &recoveryCodeStorageMock{codes: map[int64][]models.RecoveryCode{}}
+}
+
+func (m *recoveryCodeStorageMock) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes [][]byte) error {
+	codes := make([]models.RecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	m.codes[userID] = codes
+	return nil
+}
+
+func (m *recoveryCodeStorageMock) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	count := 0
+	for _, code := range m.codes[userID] {
+		if !code.Used() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *recoveryCodeStorageMock) UseRecoveryCode(ctx context.Context, userID int64, hash []byte) error {
+	for i, code := range m.codes[userID] {
+		if !code.Used() && bytes.Equal(code.CodeHash, hash) {
+			m.codes[userID][i].UsedAt = time.Now()
+			return nil
+		}
+	}
+	return storage.ErrRecoveryCodeNotFound
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	session := models.Session{ID: "s", UserID: 1}
+	m, _ := newMFA(t, nil)
+
+	_, err := m.RegenerateRecoveryCodes(ctx, session)
+	assert.ErrorIs(t, err, ErrMFANotEnabled, "recovery codes stand in for a second factor")
+
+	registerPasskey(t, m)
+	remaining, err := m.RecoveryCodesRemaining(ctx, session)
+	require.NoError(t, err)
+	assert.Equal(t, 10, remaining)
+
+	codes, err := m.RegenerateRecoveryCodes(ctx, session)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	assert.Regexp(t, `^[0-9a-z]{5}-[0-9a-z]{5}$`, codes[0])
+
+	require.NoError(t, m.Verify(ctx, 1, models.MFAMethodRecoveryCode, strings.ToUpper(codes[0])))
+	err = m.Verify(ctx, 1, models.MFAMethodRecoveryCode, codes[0])
+	assert.ErrorIs(t, err, ErrInvalidCode, "a code works once")
+
+	require.NoError(t, m.Verify(ctx, 1, models.MFAMethodRecoveryCode, strings.ReplaceAll(codes[1], "-", " ")))
+
+	err = m.Verify(ctx, 1, models.MFAMethodRecoveryCode, "00000-00000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	err = m.Verify(ctx, 1, models.MFAMethodRecoveryCode, "short")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	remaining, err = m.RecoveryCodesRemaining(ctx, session)
+	require.NoError(t, err)
+	assert.Equal(t, 8, remaining)
+
+	t.Run("Second factor keeps the codes", func(t *testing.T) {
+		registerPasskey(t, m)
+
+		remaining, err := m.RecoveryCodesRemaining(ctx, session)
+		require.NoError(t, err)
+		assert.Equal(t, 8, remaining)
+	})
+
+	t.Run("Regenerated codes replace the old ones", func(t *testing.T) {
+		fresh, err := m.RegenerateRecoveryCodes(ctx, session)
+		require.NoError(t, err)
+
+		err = m.Verify(ctx, 1, models.MFAMethodRecoveryCode, codes[2])
+		assert.ErrorIs(t, err, ErrInvalidCode)
+		require.NoError(t, m.Verify(ctx, 1, models.MFAMethodRecoveryCode, fresh[2]))
+	})
+
+	t.Run("All codes used", func(t *testing.T) {
+		m.recoveryStorage.(*recoveryCodeStorageMock).codes[1] = nil
+
+		methods, err := m.Methods(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{models.MFAMethodWebAuthn}, methods)
+
+		err = m.Verify(ctx, 1, models.MFAMethodRecoveryCode, codes[3])
+		assert.ErrorIs(t, err, ErrMethodNotEnabled)
+	})
+}
@@ -0,0 +1,197 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/totp"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+// EnrollTOTP generates a TOTP secret for the authenticated user and returns it with its provisioning URI.
+// The secret is used at login only after ConfirmTOTP, enrolling again replaces an unconfirmed one.
+func (m *MFA) EnrollTOTP(ctx context.Context, session models.Session) (secret string, uri string, err error) {
+	const op = "mfa.EnrollTOTP"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Enrolling TOTP")
+
+	if m.cipher == nil {
+		log.Warn("MFA encryption key is not configured")
+		return "", "", fmt.Errorf("%s: %w", op, ErrUnavailable)
+	}
+
+	user, err := m.userProvider.UserByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return "", "", fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		log.Error("Failed to generate secret", prettylogger.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	sealed, err := m.cipher.Seal([]byte(secret))
+	if err != nil {
+		log.Error("Failed to encrypt secret", prettylogger.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.totpStorage.SaveTOTP(ctx, models.TOTP{UserID: user.ID, Secret: sealed}); err != nil {
+		if errors.Is(err, storage.ErrTOTPExists) {
+			log.Warn("TOTP is already enabled")
+			return "", "", fmt.Errorf("%s: %w", op, ErrTOTPEnabled)
+		}
+		log.Error("Failed to save secret", prettylogger.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("TOTP enrollment started")
+
+	return secret, totp.URI(m.issuer, user.Email, secret), nil
+}
+
+// ConfirmTOTP finishes the enrollment with a first code from the authenticator app,
+// from then on the user's logins require a code. If it is the first second factor of the user,
+// it returns the recovery codes generated along with it.
+func (m *MFA) ConfirmTOTP(ctx context.Context, session models.Session, code string) ([]string, error) {
+	const op = "mfa.ConfirmTOTP"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Confirming TOTP")
+
+	enrollment, secret, err := m.totpSecret(ctx, session.UserID)
+	if err != nil {
+		log.Warn("Failed to get TOTP secret", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if enrollment.Confirmed() {
+		log.Warn("TOTP is already enabled")
+		return nil, fmt.Errorf("%s: %w", op, ErrTOTPEnabled)
+	}
+
+	step, err := totp.Validate(secret, code, m.now())
+	if err != nil {
+		log.Info("Invalid code", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	if err := m.totpStorage.ConfirmTOTP(ctx, session.UserID, step); err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			// confirmed or removed concurrently
+			log.Warn("TOTP enrollment is gone")
+			return nil, fmt.Errorf("%s: %w", op, ErrTOTPNotEnrolled)
+		}
+		log.Error("Failed to confirm TOTP", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("TOTP enabled")
+
+	recoveryCodes, err := m.firstFactorRecoveryCodes(ctx, session.UserID)
+	if err != nil {
+		log.Error("Failed to generate recovery codes", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return recoveryCodes, nil
+}
+
+// DisableTOTP removes the authenticator app of the user, a confirmed one only with a current code.
+func (m *MFA) DisableTOTP(ctx context.Context, session models.Session, code string) error {
+	const op = "mfa.DisableTOTP"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Disabling TOTP")
+
+	enrollment, err := m.totpStorage.TOTP(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			log.Warn("TOTP is not enrolled")
+			return fmt.Errorf("%s: %w", op, ErrTOTPNotEnrolled)
+		}
+		log.Error("Failed to get TOTP", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if enrollment.Confirmed() {
+		if err := m.verifyTOTP(ctx, session.UserID, code); err != nil {
+			log.Warn("Failed to verify code", prettylogger.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := m.totpStorage.DeleteTOTP(ctx, session.UserID); err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrTOTPNotEnrolled)
+		}
+		log.Error("Failed to delete TOTP", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("TOTP disabled")
+
+	return nil
+}
+
+// verifyTOTP checks a code of a confirmed enrollment and marks its time step used.
+func (m *MFA) verifyTOTP(ctx context.Context, userID int64, code string) error {
+	enrollment, secret, err := m.totpSecret(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrTOTPNotEnrolled) {
+			return ErrMethodNotEnabled
+		}
+		return err
+	}
+	if !enrollment.Confirmed() {
+		return ErrMethodNotEnabled
+	}
+
+	step, err := totp.Validate(secret, code, m.now())
+	if err != nil {
+		return ErrInvalidCode
+	}
+	if err := m.totpStorage.UseTOTPStep(ctx, userID, step); err != nil {
+		if errors.Is(err, storage.ErrTOTPStepUsed) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// totpSecret returns the enrollment of the user with its decrypted secret.
+func (m *MFA) totpSecret(ctx context.Context, userID int64) (models.TOTP, string, error) {
+	enrollment, err := m.totpStorage.TOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			return models.TOTP{}, "", ErrTOTPNotEnrolled
+		}
+		return models.TOTP{}, "", err
+	}
+	if m.cipher == nil {
+		return models.TOTP{}, "", ErrUnavailable
+	}
+
+	secret, err := m.cipher.Open(enrollment.Secret)
+	if err != nil {
+		return models.TOTP{}, "", fmt.Errorf("decrypt secret: %w", err)
+	}
+
+	return enrollment, string(secret), nil
+}
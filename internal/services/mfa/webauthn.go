@@ -0,0 +1,412 @@
+package mfa
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/webauthn"
+	"sso/internal/storage"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+type WebAuthnStorage interface {
+	SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error
+	WebAuthnCredential(ctx context.Context, id []byte) (models.WebAuthnCredential, error)
+	WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	UseWebAuthnCredential(ctx context.Context, id []byte, signCount uint32) error
+	DeleteWebAuthnCredential(ctx context.Context, userID int64, id []byte) error
+	SaveWebAuthnChallenge(ctx context.Context, challenge models.WebAuthnChallenge) error
+	TakeWebAuthnChallenge(ctx context.Context, challenge string) (models.WebAuthnChallenge, error)
+	DeleteExpiredWebAuthnChallenges(ctx context.Context, before time.Time) (int64, error)
+}
+
+const defaultCredentialName = "Passkey"
+
+// userHandle is the WebAuthn user ID of a user, discoverable credentials return it at login.
+func userHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+// BeginWebAuthnRegistration starts the registration of a passkey or security key of the
+// authenticated user and returns the options for navigator.credentials.create().
+func (m *MFA) BeginWebAuthnRegistration(ctx context.Context, session models.Session) ([]byte, error) {
+	const op = "mfa.BeginWebAuthnRegistration"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Starting WebAuthn registration")
+
+	user, err := m.userProvider.UserByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	creds, err := m.webAuthnStorage.WebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		log.Error("Failed to get credentials", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := m.newWebAuthnChallenge(ctx, models.WebAuthnRegistration, user.ID, 0)
+	if err != nil {
+		log.Error("Failed to create challenge", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	options := m.rp.CreationOptions(challenge, webauthn.UserEntity{
+		ID:          webauthn.EncodeID(userHandle(user.ID)),
+		Name:        user.Email,
+		DisplayName: user.Email,
+	}, descriptors(creds))
+
+	b, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return b, nil
+}
+
+// FinishWebAuthnRegistration verifies the credential created with the options of BeginWebAuthnRegistration
+// and saves it under the given name. From then on the user's logins require a passkey or another second factor.
+// If it is the first second factor of the user, it returns the recovery codes generated along with it.
+func (m *MFA) FinishWebAuthnRegistration(ctx context.Context, session models.Session, name string, credential []byte) (models.WebAuthnCredential, []string, error) {
+	const op = "mfa.FinishWebAuthnRegistration"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Finishing WebAuthn registration")
+
+	reg, err := webauthn.ParseRegistration(credential)
+	if err != nil {
+		log.Info("Invalid registration", prettylogger.Err(err))
+		return models.WebAuthnCredential{}, nil, fmt.Errorf("%s: %w", op, ErrInvalidCredential)
+	}
+
+	challenge, err := m.takeWebAuthnChallenge(ctx, reg.ClientData.Challenge, models.WebAuthnRegistration, session.UserID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredential) {
+			log.Info("Unknown or expired challenge")
+			return models.WebAuthnCredential{}, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("Failed to get challenge", prettylogger.Err(err))
+		return models.WebAuthnCredential{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	verified, err := m.rp.VerifyRegistration(reg, challenge)
+	if err != nil {
+		log.Info("Registration rejected", prettylogger.Err(err))
+		return models.WebAuthnCredential{}, nil, fmt.Errorf("%s: %w", op, ErrInvalidCredential)
+	}
+
+	if name == "" {
+		name = defaultCredentialName
+	}
+	cred := models.WebAuthnCredential{
+		ID:                verified.ID,
+		UserID:            session.UserID,
+		Name:              name,
+		PublicKey:         verified.PublicKey,
+		SignCount:         verified.SignCount,
+		AAGUID:            verified.AAGUID,
+		AttestationFormat: verified.Format,
+		Transports:        verified.Transports,
+		CreatedAt:         m.now(),
+	}
+	if err := m.webAuthnStorage.SaveWebAuthnCredential(ctx, cred); err != nil {
+		if errors.Is(err, storage.ErrCredentialExists) {
+			log.Warn("Credential is already registered")
+			return models.WebAuthnCredential{}, nil, fmt.Errorf("%s: %w", op, ErrCredentialExists)
+		}
+		log.Error("Failed to save credential", prettylogger.Err(err))
+		return models.WebAuthnCredential{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("WebAuthn credential registered", slog.String("format", cred.AttestationFormat))
+
+	recoveryCodes, err := m.firstFactorRecoveryCodes(ctx, session.UserID)
+	if err != nil {
+		log.Error("Failed to generate recovery codes", prettylogger.Err(err))
+		return models.WebAuthnCredential{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cred, recoveryCodes, nil
+}
+
+// WebAuthnCredentials returns the passkeys and security keys of the authenticated user.
+func (m *MFA) WebAuthnCredentials(ctx context.Context, session models.Session) ([]models.WebAuthnCredential, error) {
+	const op = "mfa.WebAuthnCredentials"
+
+	creds, err := m.webAuthnStorage.WebAuthnCredentials(ctx, session.UserID)
+	if err != nil {
+		m.log.Error("Failed to get credentials", slog.String("op", op), prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return creds, nil
+}
+
+// DeleteWebAuthnCredential removes a credential of the authenticated user by its base64url ID.
+func (m *MFA) DeleteWebAuthnCredential(ctx context.Context, session models.Session, id string) error {
+	const op = "mfa.DeleteWebAuthnCredential"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", session.UserID),
+	)
+	log.Info("Deleting WebAuthn credential")
+
+	credID, err := webauthn.DecodeID(id)
+	if err != nil {
+		log.Info("Invalid credential ID", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrCredentialNotFound)
+	}
+
+	if err := m.webAuthnStorage.DeleteWebAuthnCredential(ctx, session.UserID, credID); err != nil {
+		if errors.Is(err, storage.ErrCredentialNotFound) {
+			log.Info("Credential not found")
+			return fmt.Errorf("%s: %w", op, ErrCredentialNotFound)
+		}
+		log.Error("Failed to delete credential", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("WebAuthn credential deleted")
+
+	return nil
+}
+
+// BeginWebAuthnLogin returns the options for navigator.credentials.get(). With a user it asks
+// for one of the user's credentials as a second factor, it fails with ErrMethodNotEnabled if
+// there are none. With userID 0 it starts a passwordless login to the app with any passkey.
+func (m *MFA) BeginWebAuthnLogin(ctx context.Context, userID int64, appID int) ([]byte, error) {
+	const op = "mfa.BeginWebAuthnLogin"
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", appID),
+	)
+
+	kind := models.WebAuthnLogin
+	var allow []webauthn.CredentialDescriptor
+	if userID != 0 {
+		creds, err := m.webAuthnStorage.WebAuthnCredentials(ctx, userID)
+		if err != nil {
+			log.Error("Failed to get credentials", prettylogger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(creds) == 0 {
+			log.Info("User has no WebAuthn credentials")
+			return nil, fmt.Errorf("%s: %w", op, ErrMethodNotEnabled)
+		}
+		kind = models.WebAuthnSecondFactor
+		allow = descriptors(creds)
+	}
+
+	challenge, err := m.newWebAuthnChallenge(ctx, kind, userID, appID)
+	if err != nil {
+		log.Error("Failed to create challenge", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	b, err := json.Marshal(m.rp.RequestOptions(challenge, allow, kind == models.WebAuthnLogin))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("WebAuthn login started", slog.String("kind", kind))
+
+	return b, nil
+}
+
+// FinishWebAuthnLogin verifies the assertion of a passwordless login started by BeginWebAuthnLogin
+// and returns the user it belongs to and the app the login was started for. The authenticator
+// must have verified the user, so the passkey alone is enough to log in.
+func (m *MFA) FinishWebAuthnLogin(ctx context.Context, credential []byte) (userID int64, appID int, err error) {
+	const op = "mfa.FinishWebAuthnLogin"
+	log := m.log.With(slog.String("op", op))
+
+	assertion, err := webauthn.ParseAssertion(credential)
+	if err != nil {
+		log.Info("Invalid assertion", prettylogger.Err(err))
+		return 0, 0, fmt.Errorf("%s: %w", op, ErrInvalidCredential)
+	}
+
+	challenge, err := m.takeWebAuthnChallengeOf(ctx, assertion.ClientData.Challenge, models.WebAuthnLogin)
+	if err != nil {
+		log.Info("Unknown or expired challenge", prettylogger.Err(err))
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	cred, err := m.verifyAssertion(ctx, assertion, challenge.value, 0, true)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredential) {
+			log.Info("Assertion rejected", prettylogger.Err(err))
+		} else {
+			log.Error("Failed to verify assertion", prettylogger.Err(err))
+		}
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("Passkey verified", slog.Int64("user_id", cred.UserID))
+
+	return cred.UserID, challenge.appID, nil
+}
+
+// verifyWebAuthn checks an assertion that finishes an MFA challenge of the user, the code is its JSON.
+func (m *MFA) verifyWebAuthn(ctx context.Context, userID int64, code string) error {
+	assertion, err := webauthn.ParseAssertion([]byte(code))
+	if err != nil {
+		return ErrInvalidCode
+	}
+
+	challenge, err := m.takeWebAuthnChallenge(ctx, assertion.ClientData.Challenge, models.WebAuthnSecondFactor, userID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredential) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+
+	if _, err := m.verifyAssertion(ctx, assertion, challenge, userID, false); err != nil {
+		if errors.Is(err, ErrInvalidCredential) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// verifyAssertion checks the assertion with the credential it names, which must belong to
+// the user unless userID is 0, and records the use of the credential.
+func (m *MFA) verifyAssertion(ctx context.Context, assertion *webauthn.Assertion, challenge []byte, userID int64, requireUV bool) (models.WebAuthnCredential, error) {
+	cred, err := m.webAuthnStorage.WebAuthnCredential(ctx, assertion.CredentialID)
+	if err != nil {
+		if errors.Is(err, storage.ErrCredentialNotFound) {
+			return models.WebAuthnCredential{}, fmt.Errorf("%w: unknown credential", ErrInvalidCredential)
+		}
+		return models.WebAuthnCredential{}, err
+	}
+	if userID != 0 && cred.UserID != userID {
+		return models.WebAuthnCredential{}, fmt.Errorf("%w: credential of another user", ErrInvalidCredential)
+	}
+	if len(assertion.UserHandle) != 0 && string(assertion.UserHandle) != string(userHandle(cred.UserID)) {
+		return models.WebAuthnCredential{}, fmt.Errorf("%w: user handle does not match", ErrInvalidCredential)
+	}
+
+	signCount, err := m.rp.VerifyAssertion(assertion, challenge, cred.PublicKey, cred.SignCount, requireUV)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCount) {
+			m.log.Warn("Signature counter went back, the authenticator may be cloned",
+				slog.Int64("user_id", cred.UserID),
+				slog.String("credential_id", webauthn.EncodeID(cred.ID)),
+			)
+		}
+		return models.WebAuthnCredential{}, fmt.Errorf("%w: %w", ErrInvalidCredential, err)
+	}
+
+	if err := m.webAuthnStorage.UseWebAuthnCredential(ctx, cred.ID, signCount); err != nil {
+		if errors.Is(err, storage.ErrCredentialNotFound) {
+			return models.WebAuthnCredential{}, fmt.Errorf("%w: credential was removed or used concurrently", ErrInvalidCredential)
+		}
+		return models.WebAuthnCredential{}, err
+	}
+
+	return cred, nil
+}
+
+func (m *MFA) newWebAuthnChallenge(ctx context.Context, kind string, userID int64, appID int) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.webAuthnStorage.SaveWebAuthnChallenge(ctx, models.WebAuthnChallenge{
+		Challenge: webauthn.EncodeID(challenge),
+		Kind:      kind,
+		UserID:    userID,
+		AppID:     appID,
+		ExpiresAt: m.now().Add(m.rp.Timeout()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// takeWebAuthnChallenge consumes a challenge of the kind started for the user and returns its value.
+func (m *MFA) takeWebAuthnChallenge(ctx context.Context, value string, kind string, userID int64) ([]byte, error) {
+	challenge, err := m.takeWebAuthnChallengeOf(ctx, value, kind)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.userID != userID {
+		return nil, fmt.Errorf("%w: challenge of another user", ErrInvalidCredential)
+	}
+
+	return challenge.value, nil
+}
+
+type takenChallenge struct {
+	value  []byte
+	userID int64
+	appID  int
+}
+
+// takeWebAuthnChallengeOf consumes a challenge of the kind, a challenge is answered only once.
+func (m *MFA) takeWebAuthnChallengeOf(ctx context.Context, value string, kind string) (takenChallenge, error) {
+	challenge, err := m.webAuthnStorage.TakeWebAuthnChallenge(ctx, value)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebAuthnChallengeNotFound) {
+			return takenChallenge{}, fmt.Errorf("%w: unknown challenge", ErrInvalidCredential)
+		}
+		return takenChallenge{}, err
+	}
+	if challenge.Kind != kind {
+		return takenChallenge{}, fmt.Errorf("%w: challenge of a %s ceremony", ErrInvalidCredential, challenge.Kind)
+	}
+
+	raw, err := webauthn.DecodeID(challenge.Challenge)
+	if err != nil {
+		return takenChallenge{}, fmt.Errorf("decode challenge: %w", err)
+	}
+
+	return takenChallenge{value: raw, userID: challenge.UserID, appID: challenge.AppID}, nil
+}
+
+// PurgeExpiredWebAuthnChallenges removes the challenges of ceremonies that were never finished.
+func (m *MFA) PurgeExpiredWebAuthnChallenges(ctx context.Context) (int64, error) {
+	const op = "mfa.PurgeExpiredWebAuthnChallenges"
+	log := m.log.With(slog.String("op", op))
+
+	deleted, err := m.webAuthnStorage.DeleteExpiredWebAuthnChallenges(ctx, m.now())
+	if err != nil {
+		log.Error("Failed to delete expired challenges", prettylogger.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if deleted > 0 {
+		log.Info("Expired challenges deleted", slog.Int64("count", deleted))
+	}
+
+	return deleted, nil
+}
+
+func descriptors(creds []models.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descs := make([]webauthn.CredentialDescriptor, 0, len(creds))
+	for _, cred := range creds {
+		descs = append(descs, webauthn.Descriptor(cred.ID, cred.Transports))
+	}
+	return descs
+}
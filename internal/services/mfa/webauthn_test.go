@@ -0,0 +1,256 @@
+package mfa
+
+import (
+	"context"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/webauthn"
+	"sso/internal/lib/webauthntest"
+	"sso/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const origin = "https://login.example.com"
+
+type webAuthnStorageMock struct {
+	creds      map[string]models.WebAuthnCredential
+	challenges map[string]models.WebAuthnChallenge
+}
+
+func newWebAuthnStorageMock() *webAuthnStorageMock {
+	return &webAuthnStorageMock{
+		creds:      map[string]models.WebAuthnCredential{},
+		challenges: map[string]models.WebAuthnChallenge{},
+	}
+}
+
+func (m *webAuthnStorageMock) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error {
+	if _, ok := m.creds[string(cred.ID)]; ok {
+		return storage.ErrCredentialExists
+	}
+	m.creds[string(cred.ID)] = cred
+	return nil
+}
+
+func (m *webAuthnStorageMock) WebAuthnCredential(ctx context.Context, id []byte) (models.WebAuthnCredential, error) {
+	cred, ok := m.creds[string(id)]
+	if !ok {
+		return models.WebAuthnCredential{}, storage.ErrCredentialNotFound
+	}
+	return cred, nil
+}
+
+func (m *webAuthnStorageMock) WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	var creds []models.WebAuthnCredential
+	for _, cred := range m.creds {
+		if cred.UserID == userID {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
+
+func (m *webAuthnStorageMock) UseWebAuthnCredential(ctx context.Context, id []byte, signCount uint32) error {
+	cred, ok := m.creds[string(id)]
+	if !ok {
+		return storage.ErrCredentialNotFound
+	}
+	cred.SignCount = signCount
+	cred.LastUsedAt = time.Now()
+	m.creds[string(id)] = cred
+	return nil
+}
+
+func (m *webAuthnStorageMock) DeleteWebAuthnCredential(ctx context.Context, userID int64, id []byte) error {
+	cred, ok := m.creds[string(id)]
+	if !ok || cred.UserID != userID {
+		return storage.ErrCredentialNotFound
+	}
+	delete(m.creds, string(id))
+	return nil
+}
+
+func (m *webAuthnStorageMock) SaveWebAuthnChallenge(ctx context.Context, challenge models.WebAuthnChallenge) error {
+	m.challenges[challenge.Challenge] = challenge
+	return nil
+}
+
+func (m *webAuthnStorageMock) TakeWebAuthnChallenge(ctx context.Context, challenge string) (models.WebAuthnChallenge, error) {
+	c, ok := m.challenges[challenge]
+	if !ok {
+		return models.WebAuthnChallenge{}, storage.ErrWebAuthnChallengeNotFound
+	}
+	delete(m.challenges, challenge)
+	return c, nil
+}
+
+func (m *webAuthnStorageMock) DeleteExpiredWebAuthnChallenges(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newRP(t *testing.T) *webauthn.RelyingParty {
+	t.Helper()
+
+	rp, err := webauthn.New(config.WebAuthnConfig{
+		RPID:             "example.com",
+		RPName:           "SSO",
+		Origins:          []string{origin},
+		UserVerification: webauthn.UserVerificationPreferred,
+		Attestation:      "none",
+		ChallengeTTL:     time.Minute,
+	})
+	require.NoError(t, err)
+	return rp
+}
+
+// registerPasskey registers a new software authenticator for the user 1.
+func registerPasskey(t *testing.T, m *MFA) *webauthntest.Authenticator {
+	t.Helper()
+
+	ctx := context.Background()
+	session := models.Session{ID: "s", UserID: 1}
+
+	options, err := m.BeginWebAuthnRegistration(ctx, session)
+	require.NoError(t, err)
+
+	authn := webauthntest.New(origin)
+	resp, err := authn.Register(options)
+	require.NoError(t, err)
+
+	cred, _, err := m.FinishWebAuthnRegistration(ctx, session, "", resp)
+	require.NoError(t, err)
+	assert.Equal(t, defaultCredentialName, cred.Name)
+
+	return authn
+}
+
+func TestWebAuthn_SecondFactor(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newMFA(t, nil)
+
+	authn := registerPasskey(t, m)
+
+	methods, err := m.Methods(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.MFAMethodWebAuthn, models.MFAMethodRecoveryCode}, methods)
+
+	options, err := m.BeginWebAuthnLogin(ctx, 1, 1)
+	require.NoError(t, err)
+	resp, err := authn.Assert(options)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Verify(ctx, 1, models.MFAMethodWebAuthn, string(resp)))
+	err = m.Verify(ctx, 1, models.MFAMethodWebAuthn, string(resp))
+	assert.ErrorIs(t, err, ErrInvalidCode, "an assertion works once")
+
+	// A second factor of the user is not a passwordless login.
+	options, err = m.BeginWebAuthnLogin(ctx, 1, 1)
+	require.NoError(t, err)
+	resp, err = authn.Assert(options)
+	require.NoError(t, err)
+	_, _, err = m.FinishWebAuthnLogin(ctx, resp)
+	assert.ErrorIs(t, err, ErrInvalidCredential)
+
+	_, err = m.BeginWebAuthnLogin(ctx, 2, 1)
+	assert.ErrorIs(t, err, ErrMethodNotEnabled)
+}
+
+func TestWebAuthn_PasswordlessLogin(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newMFA(t, nil)
+
+	authn := registerPasskey(t, m)
+
+	options, err := m.BeginWebAuthnLogin(ctx, 0, 3)
+	require.NoError(t, err)
+	resp, err := authn.Assert(options)
+	require.NoError(t, err)
+
+	userID, appID, err := m.FinishWebAuthnLogin(ctx, resp)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), userID)
+	assert.Equal(t, 3, appID)
+
+	t.Run("User not verified", func(t *testing.T) {
+		authn.UserVerified = false
+		defer func() { authn.UserVerified = true }()
+
+		options, err := m.BeginWebAuthnLogin(ctx, 0, 3)
+		require.NoError(t, err)
+		resp, err := authn.Assert(options)
+		require.NoError(t, err)
+
+		_, _, err = m.FinishWebAuthnLogin(ctx, resp)
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+	})
+
+	t.Run("Cloned authenticator", func(t *testing.T) {
+		authn.SignCount = 0
+
+		options, err := m.BeginWebAuthnLogin(ctx, 0, 3)
+		require.NoError(t, err)
+		resp, err := authn.Assert(options)
+		require.NoError(t, err)
+
+		_, _, err = m.FinishWebAuthnLogin(ctx, resp)
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+	})
+
+	t.Run("Deleted credential", func(t *testing.T) {
+		session := models.Session{ID: "s", UserID: 1}
+		require.NoError(t, m.DeleteWebAuthnCredential(ctx, session, webauthn.EncodeID(authn.CredentialID)))
+
+		options, err := m.BeginWebAuthnLogin(ctx, 0, 3)
+		require.NoError(t, err)
+		resp, err := authn.Assert(options)
+		require.NoError(t, err)
+
+		_, _, err = m.FinishWebAuthnLogin(ctx, resp)
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+	})
+}
+
+func TestWebAuthn_RegistrationFailCases(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newMFA(t, nil)
+	session := models.Session{ID: "s", UserID: 1}
+
+	t.Run("Challenge of another user", func(t *testing.T) {
+		options, err := m.BeginWebAuthnRegistration(ctx, session)
+		require.NoError(t, err)
+		resp, err := webauthntest.New(origin).Register(options)
+		require.NoError(t, err)
+
+		_, _, err = m.FinishWebAuthnRegistration(ctx, models.Session{ID: "other", UserID: 2}, "Key", resp)
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+	})
+
+	t.Run("Foreign origin", func(t *testing.T) {
+		options, err := m.BeginWebAuthnRegistration(ctx, session)
+		require.NoError(t, err)
+		resp, err := webauthntest.New("https://evil.example.net").Register(options)
+		require.NoError(t, err)
+
+		_, _, err = m.FinishWebAuthnRegistration(ctx, session, "Key", resp)
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+	})
+
+	t.Run("Garbage", func(t *testing.T) {
+		_, _, err := m.FinishWebAuthnRegistration(ctx, session, "Key", []byte("{}"))
+		assert.ErrorIs(t, err, ErrInvalidCredential)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		_, err := m.BeginWebAuthnRegistration(ctx, models.Session{UserID: 2})
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+
+	t.Run("Delete unknown", func(t *testing.T) {
+		err := m.DeleteWebAuthnCredential(ctx, session, "AAAA")
+		assert.ErrorIs(t, err, ErrCredentialNotFound)
+	})
+}
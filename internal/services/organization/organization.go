@@ -0,0 +1,235 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+var (
+	ErrOrgExists      = errors.New("Organization already exists")
+	ErrOrgNotFound    = errors.New("Organization not found")
+	ErrOrgNotEmpty    = errors.New("Organization still has users or apps")
+	ErrUserNotFound   = errors.New("User not found")
+	ErrAlreadyMember  = errors.New("User is already a member")
+	ErrMemberNotFound = errors.New("User is not a member")
+	ErrIsolatedOrg    = errors.New("Organization with isolated emails only has its own users")
+	ErrHomeOrg        = errors.New("User can't leave the organization they were registered in")
+)
+
+// Organization manages the tenants and their members.
+type Organization struct {
+	log          *slog.Logger
+	orgStorage   OrgStorage
+	userProvider UserProvider
+}
+
+type OrgStorage interface {
+	SaveOrganization(ctx context.Context, org models.Organization) (int64, error)
+	Organization(ctx context.Context, orgID int64) (models.Organization, error)
+	Organizations(ctx context.Context) ([]models.Organization, error)
+	DeleteOrganization(ctx context.Context, orgID int64) error
+	AddMember(ctx context.Context, orgID int64, userID int64) error
+	RemoveMember(ctx context.Context, orgID int64, userID int64) error
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+}
+
+func New(
+	log *slog.Logger,
+	orgStorage OrgStorage,
+	userProvider UserProvider,
+) *Organization {
+	return &Organization{
+		log:          log,
+		orgStorage:   orgStorage,
+		userProvider: userProvider,
+	}
+}
+
+func (o *Organization) CreateOrganization(ctx context.Context, org models.Organization) (models.Organization, error) {
+	const op = "organization.CreateOrganization"
+	log := o.log.With(
+		slog.String("op", op),
+		slog.String("slug", org.Slug),
+	)
+	log.Info("Creating organization")
+
+	id, err := o.orgStorage.SaveOrganization(ctx, org)
+	if err != nil {
+		if errors.Is(err, storage.ErrOrgExists) {
+			log.Warn("Organization already exists")
+			return models.Organization{}, fmt.Errorf("%s: %w", op, ErrOrgExists)
+		}
+		log.Error("Failed to save organization", prettylogger.Err(err))
+		return models.Organization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	org, err = o.orgStorage.Organization(ctx, id)
+	if err != nil {
+		log.Error("Failed to get organization", prettylogger.Err(err))
+		return models.Organization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Organization created", slog.Int64("org_id", id))
+
+	return org, nil
+}
+
+func (o *Organization) GetOrganization(ctx context.Context, orgID int64) (models.Organization, error) {
+	const op = "organization.GetOrganization"
+
+	org, err := o.organization(ctx, orgID)
+	if err != nil {
+		if !errors.Is(err, ErrOrgNotFound) {
+			o.log.Error("Failed to get organization", slog.String("op", op), prettylogger.Err(err))
+		}
+		return models.Organization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return org, nil
+}
+
+func (o *Organization) ListOrganizations(ctx context.Context) ([]models.Organization, error) {
+	const op = "organization.ListOrganizations"
+
+	orgs, err := o.orgStorage.Organizations(ctx)
+	if err != nil {
+		o.log.Error("Failed to list organizations", slog.String("op", op), prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orgs, nil
+}
+
+// DeleteOrganization deletes an organization without users registered in it and without apps,
+// its members stay as users outside of it.
+func (o *Organization) DeleteOrganization(ctx context.Context, orgID int64) error {
+	const op = "organization.DeleteOrganization"
+	log := o.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", orgID),
+	)
+	log.Info("Deleting organization")
+
+	if err := o.orgStorage.DeleteOrganization(ctx, orgID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrOrgNotFound):
+			log.Warn("Organization not found")
+			return fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+		case errors.Is(err, storage.ErrOrgNotEmpty):
+			log.Warn("Organization is not empty")
+			return fmt.Errorf("%s: %w", op, ErrOrgNotEmpty)
+		}
+		log.Error("Failed to delete organization", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Organization deleted")
+
+	return nil
+}
+
+// AddMember lets a user registered outside of the organization into it.
+// Organizations with isolated emails can't take users from elsewhere.
+func (o *Organization) AddMember(ctx context.Context, orgID int64, userID int64) error {
+	const op = "organization.AddMember"
+	log := o.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", orgID),
+		slog.Int64("user_id", userID),
+	)
+	log.Info("Adding member")
+
+	org, err := o.organization(ctx, orgID)
+	if err != nil {
+		log.Warn("Failed to get organization", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := o.user(ctx, userID)
+	if err != nil {
+		log.Warn("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if org.IsolatedEmails && user.OrgID != org.ID {
+		log.Warn("Organization isolates emails")
+		return fmt.Errorf("%s: %w", op, ErrIsolatedOrg)
+	}
+
+	if err := o.orgStorage.AddMember(ctx, orgID, userID); err != nil {
+		if errors.Is(err, storage.ErrAlreadyMember) {
+			log.Warn("User is already a member")
+			return fmt.Errorf("%s: %w", op, ErrAlreadyMember)
+		}
+		log.Error("Failed to add member", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Member added")
+
+	return nil
+}
+
+// RemoveMember takes the user out of the organization along with the organization roles.
+// Users stay members of the organization they were registered in.
+func (o *Organization) RemoveMember(ctx context.Context, orgID int64, userID int64) error {
+	const op = "organization.RemoveMember"
+	log := o.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", orgID),
+		slog.Int64("user_id", userID),
+	)
+	log.Info("Removing member")
+
+	user, err := o.user(ctx, userID)
+	if err != nil {
+		log.Warn("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if user.OrgID == orgID {
+		log.Warn("Refusing to remove the user from their organization")
+		return fmt.Errorf("%s: %w", op, ErrHomeOrg)
+	}
+
+	if err := o.orgStorage.RemoveMember(ctx, orgID, userID); err != nil {
+		if errors.Is(err, storage.ErrMemberNotFound) {
+			log.Warn("User is not a member")
+			return fmt.Errorf("%s: %w", op, ErrMemberNotFound)
+		}
+		log.Error("Failed to remove member", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Member removed")
+
+	return nil
+}
+
+func (o *Organization) organization(ctx context.Context, orgID int64) (models.Organization, error) {
+	org, err := o.orgStorage.Organization(ctx, orgID)
+	if err != nil {
+		if errors.Is(err, storage.ErrOrgNotFound) {
+			return models.Organization{}, ErrOrgNotFound
+		}
+		return models.Organization{}, err
+	}
+	return org, nil
+}
+
+func (o *Organization) user(ctx context.Context, userID int64) (models.User, error) {
+	user, err := o.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
@@ -0,0 +1,202 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+// Field names accepted in an update mask.
+const (
+	FieldDisplayName  = "display_name"
+	FieldGivenName    = "given_name"
+	FieldFamilyName   = "family_name"
+	FieldLocale       = "locale"
+	FieldTimezone     = "timezone"
+	FieldAvatarURL    = "avatar_url"
+	FieldAppMetadata  = "app_metadata"
+	FieldUserMetadata = "user_metadata"
+)
+
+// maxMetadataSize limits each metadata document, profiles are not meant to be a blob storage.
+const maxMetadataSize = 16 << 10
+
+var (
+	ErrUserNotFound    = errors.New("User not found")
+	ErrUnknownField    = errors.New("Unknown profile field")
+	ErrInvalidMetadata = errors.New("Metadata must be a JSON object of at most 16KB")
+)
+
+type Profile struct {
+	log             *slog.Logger
+	profileProvider ProfileProvider
+	profileSaver    ProfileSaver
+}
+
+type ProfileProvider interface {
+	Profile(ctx context.Context, userID int64) (models.Profile, error)
+}
+
+type ProfileSaver interface {
+	SaveProfile(ctx context.Context, profile models.Profile) error
+}
+
+func New(
+	log *slog.Logger,
+	profileProvider ProfileProvider,
+	profileSaver ProfileSaver,
+) *Profile {
+	return &Profile{
+		log:             log,
+		profileProvider: profileProvider,
+		profileSaver:    profileSaver,
+	}
+}
+
+func (p *Profile) GetProfile(ctx context.Context, userID int64) (models.Profile, error) {
+	const op = "profile.GetProfile"
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	profile, err := p.profileProvider.Profile(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return models.Profile{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to get profile", prettylogger.Err(err))
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return profile, nil
+}
+
+// UpdateProfile copies the fields listed in the mask from update to the stored profile.
+// An empty mask updates every field that is set in update.
+func (p *Profile) UpdateProfile(
+	ctx context.Context,
+	userID int64,
+	update models.Profile,
+	mask []string,
+) (models.Profile, error) {
+	const op = "profile.UpdateProfile"
+	log := p.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+	log.Info("Updating profile", slog.Any("mask", mask))
+
+	profile, err := p.profileProvider.Profile(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return models.Profile{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to get profile", prettylogger.Err(err))
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(mask) == 0 {
+		mask = SetFields(update)
+	}
+	for _, field := range mask {
+		if err := apply(&profile, update, field); err != nil {
+			log.Info("Invalid profile update", slog.String("field", field), prettylogger.Err(err))
+			return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := p.profileSaver.SaveProfile(ctx, profile); err != nil {
+		log.Error("Failed to save profile", prettylogger.Err(err))
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Profile updated")
+
+	return profile, nil
+}
+
+// SetFields returns the names of the non-empty fields of the profile.
+func SetFields(profile models.Profile) []string {
+	var fields []string
+	for field, value := range map[string]bool{
+		FieldDisplayName:  profile.DisplayName != "",
+		FieldGivenName:    profile.GivenName != "",
+		FieldFamilyName:   profile.FamilyName != "",
+		FieldLocale:       profile.Locale != "",
+		FieldTimezone:     profile.Timezone != "",
+		FieldAvatarURL:    profile.AvatarURL != "",
+		FieldAppMetadata:  len(profile.AppMetadata) > 0,
+		FieldUserMetadata: len(profile.UserMetadata) > 0,
+	} {
+		if value {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+func apply(profile *models.Profile, update models.Profile, field string) error {
+	switch field {
+	case FieldDisplayName:
+		profile.DisplayName = update.DisplayName
+	case FieldGivenName:
+		profile.GivenName = update.GivenName
+	case FieldFamilyName:
+		profile.FamilyName = update.FamilyName
+	case FieldLocale:
+		profile.Locale = update.Locale
+	case FieldTimezone:
+		profile.Timezone = update.Timezone
+	case FieldAvatarURL:
+		profile.AvatarURL = update.AvatarURL
+	case FieldAppMetadata:
+		metadata, err := metadataObject(update.AppMetadata)
+		if err != nil {
+			return err
+		}
+		profile.AppMetadata = metadata
+	case FieldUserMetadata:
+		metadata, err := metadataObject(update.UserMetadata)
+		if err != nil {
+			return err
+		}
+		profile.UserMetadata = metadata
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownField, field)
+	}
+
+	return nil
+}
+
+// metadataObject checks the metadata is a JSON object and compacts it, empty metadata clears it.
+func metadataObject(metadata json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(metadata)) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+	if len(metadata) > maxMetadataSize {
+		return nil, ErrInvalidMetadata
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(metadata, &object); err != nil || object == nil {
+		return nil, ErrInvalidMetadata
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, metadata); err != nil {
+		return nil, ErrInvalidMetadata
+	}
+
+	return compacted.Bytes(), nil
+}
@@ -0,0 +1,439 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+
+	"github.com/jacute/prettylogger"
+)
+
+var (
+	ErrRoleExists          = errors.New("Role already exists")
+	ErrRoleNotFound        = errors.New("Role not found")
+	ErrPermissionExists    = errors.New("Permission already exists")
+	ErrPermissionNotFound  = errors.New("Permission not found")
+	ErrRoleAlreadyAssigned = errors.New("Role is already assigned")
+	ErrUserNotFound        = errors.New("User not found")
+	ErrAppNotFound         = errors.New("App not found")
+	ErrOrgNotFound         = errors.New("Organization not found")
+	ErrNotMember           = errors.New("User is not a member of the role organization")
+	ErrAppMismatch         = errors.New("Role belongs to another app")
+	ErrBuiltIn             = errors.New("Built-in admin role and permission can't be deleted")
+)
+
+// RBAC manages roles, permissions and their assignment to users.
+type RBAC struct {
+	log          *slog.Logger
+	roleStorage  RoleStorage
+	userProvider UserProvider
+	appProvider  AppProvider
+	orgProvider  OrgProvider
+}
+
+type RoleStorage interface {
+	SaveRole(ctx context.Context, role models.Role) (int64, error)
+	Role(ctx context.Context, roleID int64) (models.Role, error)
+	Roles(ctx context.Context, orgID int64, appID int) ([]models.Role, error)
+	DeleteRole(ctx context.Context, roleID int64) error
+	SavePermission(ctx context.Context, permission models.Permission) (int64, error)
+	Permission(ctx context.Context, permissionID int64) (models.Permission, error)
+	Permissions(ctx context.Context, appID int) ([]models.Permission, error)
+	DeletePermission(ctx context.Context, permissionID int64) error
+	GrantPermission(ctx context.Context, roleID int64, permissionID int64) error
+	RevokePermission(ctx context.Context, roleID int64, permissionID int64) error
+	AssignRole(ctx context.Context, userID int64, roleID int64, appID int) error
+	UnassignRole(ctx context.Context, userID int64, roleID int64, appID int) error
+	RoleAssignments(ctx context.Context, userID int64) ([]models.RoleAssignment, error)
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int32) (models.App, error)
+}
+
+type OrgProvider interface {
+	Organization(ctx context.Context, orgID int64) (models.Organization, error)
+	IsMember(ctx context.Context, orgID int64, userID int64) (bool, error)
+}
+
+func New(
+	log *slog.Logger,
+	roleStorage RoleStorage,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	orgProvider OrgProvider,
+) *RBAC {
+	return &RBAC{
+		log:          log,
+		roleStorage:  roleStorage,
+		userProvider: userProvider,
+		appProvider:  appProvider,
+		orgProvider:  orgProvider,
+	}
+}
+
+func (r *RBAC) CreateRole(ctx context.Context, role models.Role) (models.Role, error) {
+	const op = "rbac.CreateRole"
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", role.OrgID),
+		slog.Int("app_id", role.AppID),
+		slog.String("role", role.Name),
+	)
+	log.Info("Creating role")
+
+	if err := r.checkOrg(ctx, role.OrgID); err != nil {
+		log.Warn("Failed to check organization", prettylogger.Err(err))
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := r.checkApp(ctx, role.AppID); err != nil {
+		log.Warn("Failed to check app", prettylogger.Err(err))
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := r.roleStorage.SaveRole(ctx, role)
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleExists) {
+			log.Warn("Role already exists")
+			return models.Role{}, fmt.Errorf("%s: %w", op, ErrRoleExists)
+		}
+		log.Error("Failed to save role", prettylogger.Err(err))
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+	role.ID = id
+
+	log.Info("Role created", slog.Int64("role_id", id))
+
+	return role, nil
+}
+
+// ListRoles returns the roles of the organization and the app along with the global ones.
+func (r *RBAC) ListRoles(ctx context.Context, orgID int64, appID int) ([]models.Role, error) {
+	const op = "rbac.ListRoles"
+
+	roles, err := r.roleStorage.Roles(ctx, orgID, appID)
+	if err != nil {
+		r.log.Error("Failed to list roles", slog.String("op", op), prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+func (r *RBAC) DeleteRole(ctx context.Context, roleID int64) error {
+	const op = "rbac.DeleteRole"
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Int64("role_id", roleID),
+	)
+	log.Info("Deleting role")
+
+	role, err := r.role(ctx, roleID)
+	if err != nil {
+		log.Warn("Failed to get role", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if role.OrgID == 0 && role.AppID == models.GlobalAppID && role.Name == models.PermissionAdmin {
+		log.Warn("Refusing to delete the admin role")
+		return fmt.Errorf("%s: %w", op, ErrBuiltIn)
+	}
+
+	if err := r.roleStorage.DeleteRole(ctx, roleID); err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+		log.Error("Failed to delete role", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Role deleted")
+
+	return nil
+}
+
+func (r *RBAC) CreatePermission(ctx context.Context, permission models.Permission) (models.Permission, error) {
+	const op = "rbac.CreatePermission"
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", permission.AppID),
+		slog.String("permission", permission.Name),
+	)
+	log.Info("Creating permission")
+
+	if err := r.checkApp(ctx, permission.AppID); err != nil {
+		log.Warn("Failed to check app", prettylogger.Err(err))
+		return models.Permission{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := r.roleStorage.SavePermission(ctx, permission)
+	if err != nil {
+		if errors.Is(err, storage.ErrPermissionExists) {
+			log.Warn("Permission already exists")
+			return models.Permission{}, fmt.Errorf("%s: %w", op, ErrPermissionExists)
+		}
+		log.Error("Failed to save permission", prettylogger.Err(err))
+		return models.Permission{}, fmt.Errorf("%s: %w", op, err)
+	}
+	permission.ID = id
+
+	log.Info("Permission created", slog.Int64("permission_id", id))
+
+	return permission, nil
+}
+
+// ListPermissions returns the permissions of the app along with the global ones.
+func (r *RBAC) ListPermissions(ctx context.Context, appID int) ([]models.Permission, error) {
+	const op = "rbac.ListPermissions"
+
+	permissions, err := r.roleStorage.Permissions(ctx, appID)
+	if err != nil {
+		r.log.Error("Failed to list permissions", slog.String("op", op), prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permissions, nil
+}
+
+func (r *RBAC) DeletePermission(ctx context.Context, permissionID int64) error {
+	const op = "rbac.DeletePermission"
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Int64("permission_id", permissionID),
+	)
+	log.Info("Deleting permission")
+
+	permission, err := r.permission(ctx, permissionID)
+	if err != nil {
+		log.Warn("Failed to get permission", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if permission.AppID == models.GlobalAppID && permission.Name == models.PermissionAdmin {
+		log.Warn("Refusing to delete the admin permission")
+		return fmt.Errorf("%s: %w", op, ErrBuiltIn)
+	}
+
+	if err := r.roleStorage.DeletePermission(ctx, permissionID); err != nil {
+		if errors.Is(err, storage.ErrPermissionNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrPermissionNotFound)
+		}
+		log.Error("Failed to delete permission", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Permission deleted")
+
+	return nil
+}
+
+// GrantPermission adds the permission to the role. An app role can only get
+// permissions of its own app or global ones.
+func (r *RBAC) GrantPermission(ctx context.Context, roleID int64, permissionID int64) error {
+	const op = "rbac.GrantPermission"
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Int64("role_id", roleID),
+		slog.Int64("permission_id", permissionID),
+	)
+	log.Info("Granting permission")
+
+	role, err := r.role(ctx, roleID)
+	if err != nil {
+		log.Warn("Failed to get role", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	permission, err := r.permission(ctx, permissionID)
+	if err != nil {
+		log.Warn("Failed to get permission", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if permission.AppID != models.GlobalAppID && permission.AppID != role.AppID {
+		log.Warn("Permission belongs to another app")
+		return fmt.Errorf("%s: %w", op, ErrAppMismatch)
+	}
+
+	if err := r.roleStorage.GrantPermission(ctx, roleID, permissionID); err != nil {
+		log.Error("Failed to grant permission", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Permission granted")
+
+	return nil
+}
+
+func (r *RBAC) RevokePermission(ctx context.Context, roleID int64, permissionID int64) error {
+	const op = "rbac.RevokePermission"
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Int64("role_id", roleID),
+		slog.Int64("permission_id", permissionID),
+	)
+	log.Info("Revoking permission")
+
+	if err := r.roleStorage.RevokePermission(ctx, roleID, permissionID); err != nil {
+		log.Error("Failed to revoke permission", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Permission revoked")
+
+	return nil
+}
+
+// AssignRole gives the role to the user in the app. A global role can be assigned in one app
+// or in every app with models.GlobalAppID, an app role only in its own app.
+// Roles of an organization are only given to its members.
+func (r *RBAC) AssignRole(ctx context.Context, userID int64, roleID int64, appID int) error {
+	const op = "rbac.AssignRole"
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("role_id", roleID),
+		slog.Int("app_id", appID),
+	)
+	log.Info("Assigning role")
+
+	role, err := r.role(ctx, roleID)
+	if err != nil {
+		log.Warn("Failed to get role", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if role.AppID != models.GlobalAppID && role.AppID != appID {
+		log.Warn("Role belongs to another app")
+		return fmt.Errorf("%s: %w", op, ErrAppMismatch)
+	}
+	if err := r.checkApp(ctx, appID); err != nil {
+		log.Warn("Failed to check app", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := r.userProvider.UserByID(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("User not found")
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		log.Error("Failed to get user", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if role.OrgID != 0 {
+		member, err := r.orgProvider.IsMember(ctx, role.OrgID, userID)
+		if err != nil {
+			log.Error("Failed to check membership", prettylogger.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !member {
+			log.Warn("User is not a member of the role organization")
+			return fmt.Errorf("%s: %w", op, ErrNotMember)
+		}
+	}
+
+	if err := r.roleStorage.AssignRole(ctx, userID, roleID, appID); err != nil {
+		if errors.Is(err, storage.ErrRoleAlreadyAssigned) {
+			log.Warn("Role is already assigned")
+			return fmt.Errorf("%s: %w", op, ErrRoleAlreadyAssigned)
+		}
+		log.Error("Failed to assign role", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Role assigned")
+
+	return nil
+}
+
+func (r *RBAC) UnassignRole(ctx context.Context, userID int64, roleID int64, appID int) error {
+	const op = "rbac.UnassignRole"
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("role_id", roleID),
+		slog.Int("app_id", appID),
+	)
+	log.Info("Unassigning role")
+
+	if err := r.roleStorage.UnassignRole(ctx, userID, roleID, appID); err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("Role is not assigned")
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+		log.Error("Failed to unassign role", prettylogger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Role unassigned")
+
+	return nil
+}
+
+// ListUserRoles returns every role assignment of the user.
+func (r *RBAC) ListUserRoles(ctx context.Context, userID int64) ([]models.RoleAssignment, error) {
+	const op = "rbac.ListUserRoles"
+
+	assignments, err := r.roleStorage.RoleAssignments(ctx, userID)
+	if err != nil {
+		r.log.Error("Failed to list role assignments", slog.String("op", op), prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return assignments, nil
+}
+
+func (r *RBAC) role(ctx context.Context, roleID int64) (models.Role, error) {
+	role, err := r.roleStorage.Role(ctx, roleID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			return models.Role{}, ErrRoleNotFound
+		}
+		return models.Role{}, err
+	}
+	return role, nil
+}
+
+func (r *RBAC) permission(ctx context.Context, permissionID int64) (models.Permission, error) {
+	permission, err := r.roleStorage.Permission(ctx, permissionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrPermissionNotFound) {
+			return models.Permission{}, ErrPermissionNotFound
+		}
+		return models.Permission{}, err
+	}
+	return permission, nil
+}
+
+// checkOrg makes sure the organization exists, 0 stands for no organization.
+func (r *RBAC) checkOrg(ctx context.Context, orgID int64) error {
+	if orgID == 0 {
+		return nil
+	}
+
+	if _, err := r.orgProvider.Organization(ctx, orgID); err != nil {
+		if errors.Is(err, storage.ErrOrgNotFound) {
+			return ErrOrgNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkApp makes sure the app exists, the global scope always does.
+func (r *RBAC) checkApp(ctx context.Context, appID int) error {
+	if appID == models.GlobalAppID {
+		return nil
+	}
+
+	if _, err := r.appProvider.App(ctx, int32(appID)); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return ErrAppNotFound
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,469 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// The records are the archive form of the models. Fields are only ever added to them,
+// a change of meaning needs a new Version.
+
+type orgRecord struct {
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	Slug           string    `json:"slug"`
+	IsolatedEmails bool      `json:"isolated_emails"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func toOrgRecord(org models.Organization) orgRecord {
+	return orgRecord{
+		ID:             org.ID,
+		Name:           org.Name,
+		Slug:           org.Slug,
+		IsolatedEmails: org.IsolatedEmails,
+		CreatedAt:      org.CreatedAt,
+	}
+}
+
+func (r orgRecord) model() models.Organization {
+	return models.Organization{
+		ID:             r.ID,
+		Name:           r.Name,
+		Slug:           r.Slug,
+		IsolatedEmails: r.IsolatedEmails,
+		CreatedAt:      r.CreatedAt,
+	}
+}
+
+type appRecord struct {
+	ID                   int    `json:"id"`
+	Name                 string `json:"name"`
+	Secret               string `json:"secret"`
+	RequireVerifiedEmail bool   `json:"require_verified_email"`
+	OrgID                int64  `json:"org_id"`
+}
+
+func toAppRecord(app models.App) appRecord {
+	return appRecord{
+		ID:                   app.ID,
+		Name:                 app.Name,
+		Secret:               app.Secret,
+		RequireVerifiedEmail: app.RequireVerifiedEmail,
+		OrgID:                app.OrgID,
+	}
+}
+
+func (r appRecord) model() models.App {
+	return models.App{
+		ID:                   r.ID,
+		Name:                 r.Name,
+		Secret:               r.Secret,
+		RequireVerifiedEmail: r.RequireVerifiedEmail,
+		OrgID:                r.OrgID,
+	}
+}
+
+type permissionRecord struct {
+	ID          int64  `json:"id"`
+	AppID       int    `json:"app_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func toPermissionRecord(permission models.Permission) permissionRecord {
+	return permissionRecord{
+		ID:          permission.ID,
+		AppID:       permission.AppID,
+		Name:        permission.Name,
+		Description: permission.Description,
+	}
+}
+
+func (r permissionRecord) model() models.Permission {
+	return models.Permission{
+		ID:          r.ID,
+		AppID:       r.AppID,
+		Name:        r.Name,
+		Description: r.Description,
+	}
+}
+
+type roleRecord struct {
+	ID          int64  `json:"id"`
+	OrgID       int64  `json:"org_id"`
+	AppID       int    `json:"app_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func toRoleRecord(role models.Role) roleRecord {
+	return roleRecord{
+		ID:          role.ID,
+		OrgID:       role.OrgID,
+		AppID:       role.AppID,
+		Name:        role.Name,
+		Description: role.Description,
+	}
+}
+
+func (r roleRecord) model() models.Role {
+	return models.Role{
+		ID:          r.ID,
+		OrgID:       r.OrgID,
+		AppID:       r.AppID,
+		Name:        r.Name,
+		Description: r.Description,
+	}
+}
+
+type rolePermissionRecord struct {
+	RoleID       int64 `json:"role_id"`
+	PermissionID int64 `json:"permission_id"`
+}
+
+func toRolePermissionRecord(grant models.RolePermission) rolePermissionRecord {
+	return rolePermissionRecord{RoleID: grant.RoleID, PermissionID: grant.PermissionID}
+}
+
+func (r rolePermissionRecord) model() models.RolePermission {
+	return models.RolePermission{RoleID: r.RoleID, PermissionID: r.PermissionID}
+}
+
+type userRecord struct {
+	ID              int64      `json:"id"`
+	Email           string     `json:"email"`
+	PasswordHash    []byte     `json:"password_hash"`
+	EmailVerified   bool       `json:"email_verified"`
+	Username        string     `json:"username,omitempty"`
+	Phone           string     `json:"phone,omitempty"`
+	OrgID           int64      `json:"org_id"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	LockedUntil     *time.Time `json:"locked_until,omitempty"`
+}
+
+func toUserRecord(user models.User) userRecord {
+	return userRecord{
+		ID:              user.ID,
+		Email:           user.Email,
+		PasswordHash:    user.PasswordHash,
+		EmailVerified:   user.EmailVerified,
+		Username:        user.Username,
+		Phone:           user.Phone,
+		OrgID:           user.OrgID,
+		CreatedAt:       optionalTime(user.CreatedAt),
+		DeletedAt:       optionalTime(user.DeletedAt),
+		Status:          string(user.Status),
+		StatusReason:    user.StatusReason,
+		StatusChangedAt: optionalTime(user.StatusChangedAt),
+		LockedUntil:     optionalTime(user.LockedUntil),
+	}
+}
+
+func (r userRecord) model() models.User {
+	passwordHash := r.PasswordHash
+	if passwordHash == nil {
+		passwordHash = []byte{}
+	}
+
+	return models.User{
+		ID:              r.ID,
+		Email:           r.Email,
+		PasswordHash:    passwordHash,
+		EmailVerified:   r.EmailVerified,
+		Username:        r.Username,
+		Phone:           r.Phone,
+		OrgID:           r.OrgID,
+		CreatedAt:       timeOrZero(r.CreatedAt),
+		DeletedAt:       timeOrZero(r.DeletedAt),
+		Status:          models.UserStatus(r.Status),
+		StatusReason:    r.StatusReason,
+		StatusChangedAt: timeOrZero(r.StatusChangedAt),
+		LockedUntil:     timeOrZero(r.LockedUntil),
+	}
+}
+
+type membershipRecord struct {
+	OrgID     int64     `json:"org_id"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toMembershipRecord(membership models.Membership) membershipRecord {
+	return membershipRecord{
+		OrgID:     membership.OrgID,
+		UserID:    membership.UserID,
+		CreatedAt: membership.CreatedAt,
+	}
+}
+
+func (r membershipRecord) model() models.Membership {
+	return models.Membership{
+		OrgID:     r.OrgID,
+		UserID:    r.UserID,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+type profileRecord struct {
+	UserID       int64           `json:"user_id"`
+	DisplayName  string          `json:"display_name,omitempty"`
+	GivenName    string          `json:"given_name,omitempty"`
+	FamilyName   string          `json:"family_name,omitempty"`
+	Locale       string          `json:"locale,omitempty"`
+	Timezone     string          `json:"timezone,omitempty"`
+	AvatarURL    string          `json:"avatar_url,omitempty"`
+	AppMetadata  json.RawMessage `json:"app_metadata"`
+	UserMetadata json.RawMessage `json:"user_metadata"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+func toProfileRecord(profile models.Profile) profileRecord {
+	return profileRecord{
+		UserID:       profile.UserID,
+		DisplayName:  profile.DisplayName,
+		GivenName:    profile.GivenName,
+		FamilyName:   profile.FamilyName,
+		Locale:       profile.Locale,
+		Timezone:     profile.Timezone,
+		AvatarURL:    profile.AvatarURL,
+		AppMetadata:  jsonOrEmpty(profile.AppMetadata),
+		UserMetadata: jsonOrEmpty(profile.UserMetadata),
+		UpdatedAt:    profile.UpdatedAt,
+	}
+}
+
+func (r profileRecord) model() models.Profile {
+	return models.Profile{
+		UserID:       r.UserID,
+		DisplayName:  r.DisplayName,
+		GivenName:    r.GivenName,
+		FamilyName:   r.FamilyName,
+		Locale:       r.Locale,
+		Timezone:     r.Timezone,
+		AvatarURL:    r.AvatarURL,
+		AppMetadata:  jsonOrEmpty(r.AppMetadata),
+		UserMetadata: jsonOrEmpty(r.UserMetadata),
+		UpdatedAt:    r.UpdatedAt,
+	}
+}
+
+type identityRecord struct {
+	UserID     int64             `json:"user_id"`
+	Provider   string            `json:"provider"`
+	Subject    string            `json:"subject"`
+	Username   string            `json:"username,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Groups     []string          `json:"groups,omitempty"`
+}
+
+func toIdentityRecord(identity models.Identity) identityRecord {
+	return identityRecord{
+		UserID:     identity.UserID,
+		Provider:   identity.Provider,
+		Subject:    identity.Subject,
+		Username:   identity.Username,
+		Attributes: identity.Attributes,
+		Groups:     identity.Groups,
+	}
+}
+
+func (r identityRecord) model() models.Identity {
+	return models.Identity{
+		UserID:     r.UserID,
+		Provider:   r.Provider,
+		Subject:    r.Subject,
+		Username:   r.Username,
+		Attributes: r.Attributes,
+		Groups:     r.Groups,
+	}
+}
+
+type roleAssignmentRecord struct {
+	UserID int64 `json:"user_id"`
+	RoleID int64 `json:"role_id"`
+	AppID  int   `json:"app_id"`
+}
+
+func toRoleAssignmentRecord(assignment models.RoleAssignment) roleAssignmentRecord {
+	return roleAssignmentRecord{
+		UserID: assignment.UserID,
+		RoleID: assignment.Role.ID,
+		AppID:  assignment.AppID,
+	}
+}
+
+func (r roleAssignmentRecord) model() models.RoleAssignment {
+	return models.RoleAssignment{
+		UserID: r.UserID,
+		Role:   models.Role{ID: r.RoleID},
+		AppID:  r.AppID,
+	}
+}
+
+type totpRecord struct {
+	UserID      int64      `json:"user_id"`
+	Secret      []byte     `json:"secret"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	LastStep    int64      `json:"last_step"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func toTOTPRecord(totp models.TOTP) totpRecord {
+	return totpRecord{
+		UserID:      totp.UserID,
+		Secret:      totp.Secret,
+		ConfirmedAt: optionalTime(totp.ConfirmedAt),
+		LastStep:    totp.LastStep,
+		CreatedAt:   totp.CreatedAt,
+	}
+}
+
+func (r totpRecord) model() models.TOTP {
+	return models.TOTP{
+		UserID:      r.UserID,
+		Secret:      r.Secret,
+		ConfirmedAt: timeOrZero(r.ConfirmedAt),
+		LastStep:    r.LastStep,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+type webAuthnRecord struct {
+	ID                []byte     `json:"id"`
+	UserID            int64      `json:"user_id"`
+	Name              string     `json:"name"`
+	PublicKey         []byte     `json:"public_key"`
+	SignCount         uint32     `json:"sign_count"`
+	AAGUID            []byte     `json:"aaguid,omitempty"`
+	AttestationFormat string     `json:"attestation_format"`
+	Transports        []string   `json:"transports,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+}
+
+func toWebAuthnRecord(cred models.WebAuthnCredential) webAuthnRecord {
+	return webAuthnRecord{
+		ID:                cred.ID,
+		UserID:            cred.UserID,
+		Name:              cred.Name,
+		PublicKey:         cred.PublicKey,
+		SignCount:         cred.SignCount,
+		AAGUID:            cred.AAGUID,
+		AttestationFormat: cred.AttestationFormat,
+		Transports:        cred.Transports,
+		CreatedAt:         cred.CreatedAt,
+		LastUsedAt:        optionalTime(cred.LastUsedAt),
+	}
+}
+
+func (r webAuthnRecord) model() models.WebAuthnCredential {
+	return models.WebAuthnCredential{
+		ID:                r.ID,
+		UserID:            r.UserID,
+		Name:              r.Name,
+		PublicKey:         r.PublicKey,
+		SignCount:         r.SignCount,
+		AAGUID:            r.AAGUID,
+		AttestationFormat: r.AttestationFormat,
+		Transports:        r.Transports,
+		CreatedAt:         r.CreatedAt,
+		LastUsedAt:        timeOrZero(r.LastUsedAt),
+	}
+}
+
+type recoveryCodeRecord struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	CodeHash  []byte     `json:"code_hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+func toRecoveryCodeRecord(code models.RecoveryCode) recoveryCodeRecord {
+	return recoveryCodeRecord{
+		ID:        code.ID,
+		UserID:    code.UserID,
+		CodeHash:  code.CodeHash,
+		CreatedAt: code.CreatedAt,
+		UsedAt:    optionalTime(code.UsedAt),
+	}
+}
+
+func (r recoveryCodeRecord) model() models.RecoveryCode {
+	return models.RecoveryCode{
+		ID:        r.ID,
+		UserID:    r.UserID,
+		CodeHash:  r.CodeHash,
+		CreatedAt: r.CreatedAt,
+		UsedAt:    timeOrZero(r.UsedAt),
+	}
+}
+
+// anonymizeApp replaces the app secret, tokens of the source instance must not be valid in the copy.
+func anonymizeApp(app models.App, secret string) models.App {
+	app.Secret = secret
+	return app
+}
+
+// anonymizeUser drops everything that identifies the user. The password hash is dropped as well,
+// so the account can only be used after a password reset.
+func anonymizeUser(user models.User) models.User {
+	user.Email = fmt.Sprintf("user%d@anonymized.invalid", user.ID)
+	user.Username = ""
+	user.Phone = ""
+	user.PasswordHash = []byte{}
+	user.StatusReason = ""
+	return user
+}
+
+func anonymizeProfile(profile models.Profile) models.Profile {
+	return models.Profile{
+		UserID:       profile.UserID,
+		Locale:       profile.Locale,
+		Timezone:     profile.Timezone,
+		AppMetadata:  json.RawMessage("{}"),
+		UserMetadata: json.RawMessage("{}"),
+		UpdatedAt:    profile.UpdatedAt,
+	}
+}
+
+// anonymizeIdentity replaces the subject with its hash, which keeps subjects unique within the provider.
+func anonymizeIdentity(identity models.Identity) models.Identity {
+	sum := sha256.Sum256([]byte(identity.Subject))
+	return models.Identity{
+		UserID:   identity.UserID,
+		Provider: identity.Provider,
+		Subject:  hex.EncodeToString(sum[:]),
+		Groups:   identity.Groups,
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func jsonOrEmpty(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 || string(raw) == "null" {
+		return json.RawMessage("{}")
+	}
+	return raw
+}
@@ -0,0 +1,414 @@
+// Package snapshot moves an instance between databases as a versioned JSON Lines archive.
+//
+// The first line of an archive is a header with its version, the last one a footer with
+// the number of records of each kind, so a truncated archive is detected. In between,
+// each line is a record {"kind": ..., "data": {...}}, grouped by kind in the order of kinds.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/tokens"
+	"time"
+
+	"github.com/jacute/prettylogger"
+)
+
+// Version is the archive version written by Export. Restore reads this and any earlier version.
+const Version = 1
+
+const (
+	kindHeader = "header"
+	kindFooter = "footer"
+
+	kindOrganization   = "organization"
+	kindApp            = "app"
+	kindPermission     = "permission"
+	kindRole           = "role"
+	kindRolePermission = "role_permission"
+	kindUser           = "user"
+	kindMembership     = "membership"
+	kindProfile        = "profile"
+	kindIdentity       = "identity"
+	kindRoleAssignment = "role_assignment"
+	kindTOTP           = "totp"
+	kindWebAuthn       = "webauthn_credential"
+	kindRecoveryCode   = "recovery_code"
+)
+
+// kinds is the order records are written in: everything a record refers to comes before it.
+var kinds = []string{
+	kindOrganization, kindApp, kindPermission, kindRole, kindRolePermission,
+	kindUser, kindMembership, kindProfile, kindIdentity, kindRoleAssignment, kindTOTP,
+	kindWebAuthn, kindRecoveryCode,
+}
+
+const (
+	// batchSize is how many records Restore writes at once.
+	batchSize = 1000
+	// maxLineSize limits an archive line, profiles with large metadata are the longest ones.
+	maxLineSize = 4 * 1024 * 1024
+)
+
+var (
+	ErrNotEmpty           = errors.New("Target database already has users")
+	ErrInvalidArchive     = errors.New("Invalid snapshot archive")
+	ErrUnsupportedVersion = errors.New("Unsupported snapshot version")
+)
+
+// Snapshot exports an instance from the source storage and restores it into the target one,
+// which may be a different storage backend.
+type Snapshot struct {
+	log    *slog.Logger
+	source Source
+	target Target
+}
+
+type Source interface {
+	ExportOrganizations(ctx context.Context, fn func(models.Organization) error) error
+	ExportApps(ctx context.Context, fn func(models.App) error) error
+	ExportPermissions(ctx context.Context, fn func(models.Permission) error) error
+	ExportRoles(ctx context.Context, fn func(models.Role) error) error
+	ExportRolePermissions(ctx context.Context, fn func(models.RolePermission) error) error
+	ExportUsers(ctx context.Context, fn func(models.User) error) error
+	ExportMemberships(ctx context.Context, fn func(models.Membership) error) error
+	ExportProfiles(ctx context.Context, fn func(models.Profile) error) error
+	ExportIdentities(ctx context.Context, fn func(models.Identity) error) error
+	ExportRoleAssignments(ctx context.Context, fn func(models.RoleAssignment) error) error
+	ExportTOTPs(ctx context.Context, fn func(models.TOTP) error) error
+	ExportWebAuthnCredentials(ctx context.Context, fn func(models.WebAuthnCredential) error) error
+	ExportRecoveryCodes(ctx context.Context, fn func(models.RecoveryCode) error) error
+}
+
+type Target interface {
+	HasUsers(ctx context.Context) (bool, error)
+	RestoreOrganizations(ctx context.Context, orgs []models.Organization) error
+	RestoreApps(ctx context.Context, apps []models.App) error
+	RestorePermissions(ctx context.Context, permissions []models.Permission) error
+	RestoreRoles(ctx context.Context, roles []models.Role) error
+	RestoreRolePermissions(ctx context.Context, grants []models.RolePermission) error
+	RestoreUsers(ctx context.Context, users []models.User) error
+	RestoreMemberships(ctx context.Context, memberships []models.Membership) error
+	RestoreProfiles(ctx context.Context, profiles []models.Profile) error
+	RestoreIdentities(ctx context.Context, identities []models.Identity) error
+	RestoreRoleAssignments(ctx context.Context, assignments []models.RoleAssignment) error
+	RestoreTOTPs(ctx context.Context, totps []models.TOTP) error
+	RestoreWebAuthnCredentials(ctx context.Context, creds []models.WebAuthnCredential) error
+	RestoreRecoveryCodes(ctx context.Context, codes []models.RecoveryCode) error
+}
+
+// New creates the snapshot service, source is only needed to export and target to restore.
+func New(log *slog.Logger, source Source, target Target) *Snapshot {
+	return &Snapshot{
+		log:    log,
+		source: source,
+		target: target,
+	}
+}
+
+// Counts is the number of records of each kind.
+type Counts map[string]int
+
+// line is a line of the archive: a header, a record or a footer.
+type line struct {
+	Kind       string          `json:"kind"`
+	Version    int             `json:"version,omitempty"`
+	CreatedAt  *time.Time      `json:"created_at,omitempty"`
+	Anonymized bool            `json:"anonymized,omitempty"`
+	Counts     Counts          `json:"counts,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+type archiveWriter struct {
+	w      *bufio.Writer
+	enc    *json.Encoder
+	counts Counts
+}
+
+func (a *archiveWriter) write(kind string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := a.enc.Encode(line{Kind: kind, Data: b}); err != nil {
+		return err
+	}
+	a.counts[kind]++
+	return nil
+}
+
+// writeKind writes every record each walks through, converted to its archive form.
+func writeKind[T any, R any](
+	ctx context.Context,
+	a *archiveWriter,
+	kind string,
+	each func(context.Context, func(T) error) error,
+	toRecord func(T) R,
+) error {
+	return each(ctx, func(item T) error {
+		return a.write(kind, toRecord(item))
+	})
+}
+
+// Export writes users, apps, organizations, roles with their permissions and assignments,
+// profiles and identities to w. An anonymized archive has no personal data, password hashes
+// or app secrets, to seed a staging instance from production.
+func (s *Snapshot) Export(ctx context.Context, w io.Writer, anonymize bool) (Counts, error) {
+	const op = "snapshot.Export"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Bool("anonymize", anonymize),
+	)
+	log.Info("Exporting snapshot")
+
+	bw := bufio.NewWriter(w)
+	a := &archiveWriter{w: bw, enc: json.NewEncoder(bw), counts: Counts{}}
+
+	now := time.Now().UTC()
+	if err := a.enc.Encode(line{Kind: kindHeader, Version: Version, CreatedAt: &now, Anonymized: anonymize}); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	toUser := toUserRecord
+	toProfile := toProfileRecord
+	toIdentity := toIdentityRecord
+	if anonymize {
+		toUser = func(user models.User) userRecord { return toUserRecord(anonymizeUser(user)) }
+		toProfile = func(profile models.Profile) profileRecord { return toProfileRecord(anonymizeProfile(profile)) }
+		toIdentity = func(identity models.Identity) identityRecord { return toIdentityRecord(anonymizeIdentity(identity)) }
+	}
+	writeApps := func() error {
+		return s.source.ExportApps(ctx, func(app models.App) error {
+			if anonymize {
+				secret, err := tokens.ID()
+				if err != nil {
+					return err
+				}
+				app = anonymizeApp(app, secret)
+			}
+			return a.write(kindApp, toAppRecord(app))
+		})
+	}
+
+	for _, write := range []func() error{
+		func() error { return writeKind(ctx, a, kindOrganization, s.source.ExportOrganizations, toOrgRecord) },
+		writeApps,
+		func() error { return writeKind(ctx, a, kindPermission, s.source.ExportPermissions, toPermissionRecord) },
+		func() error { return writeKind(ctx, a, kindRole, s.source.ExportRoles, toRoleRecord) },
+		func() error {
+			return writeKind(ctx, a, kindRolePermission, s.source.ExportRolePermissions, toRolePermissionRecord)
+		},
+		func() error { return writeKind(ctx, a, kindUser, s.source.ExportUsers, toUser) },
+		func() error { return writeKind(ctx, a, kindMembership, s.source.ExportMemberships, toMembershipRecord) },
+		func() error { return writeKind(ctx, a, kindProfile, s.source.ExportProfiles, toProfile) },
+		func() error { return writeKind(ctx, a, kindIdentity, s.source.ExportIdentities, toIdentity) },
+		func() error {
+			return writeKind(ctx, a, kindRoleAssignment, s.source.ExportRoleAssignments, toRoleAssignmentRecord)
+		},
+		func() error {
+			// Anonymized users log in only after a password reset, their second factors are left out.
+			// Otherwise the secrets stay encrypted, the target instance needs the same mfa.encryption_key.
+			if anonymize {
+				return nil
+			}
+			return writeKind(ctx, a, kindTOTP, s.source.ExportTOTPs, toTOTPRecord)
+		},
+		func() error {
+			// Passkeys are bound to webauthn.rp_id, they work in a copy served on the same domain.
+			if anonymize {
+				return nil
+			}
+			return writeKind(ctx, a, kindWebAuthn, s.source.ExportWebAuthnCredentials, toWebAuthnRecord)
+		},
+		func() error {
+			if anonymize {
+				return nil
+			}
+			return writeKind(ctx, a, kindRecoveryCode, s.source.ExportRecoveryCodes, toRecoveryCodeRecord)
+		},
+	} {
+		if err := write(); err != nil {
+			log.Error("Failed to export snapshot", prettylogger.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := a.enc.Encode(line{Kind: kindFooter, Counts: a.counts}); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("Snapshot exported", slog.Any("counts", a.counts))
+
+	return a.counts, nil
+}
+
+// kindRestorer collects the records of a kind and saves them in batches.
+type kindRestorer struct {
+	add   func(ctx context.Context, data json.RawMessage) error
+	flush func(ctx context.Context) error
+}
+
+func restorerFor[R interface{ model() T }, T any](save func(context.Context, []T) error) *kindRestorer {
+	items := make([]T, 0, batchSize)
+	r := &kindRestorer{}
+
+	r.flush = func(ctx context.Context) error {
+		if len(items) == 0 {
+			return nil
+		}
+		if err := save(ctx, items); err != nil {
+			return err
+		}
+		items = items[:0]
+		return nil
+	}
+	r.add = func(ctx context.Context, data json.RawMessage) error {
+		var rec R
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rec); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		items = append(items, rec.model())
+		if len(items) >= batchSize {
+			return r.flush(ctx)
+		}
+		return nil
+	}
+
+	return r
+}
+
+// Restore writes the archive from r into the target storage, which must have no users yet.
+// The records are written in batches, so a failed restore leaves a partly filled database
+// to drop before trying again.
+func (s *Snapshot) Restore(ctx context.Context, r io.Reader) (Counts, error) {
+	const op = "snapshot.Restore"
+	log := s.log.With(slog.String("op", op))
+	log.Info("Restoring snapshot")
+
+	hasUsers, err := s.target.HasUsers(ctx)
+	if err != nil {
+		log.Error("Failed to check target", prettylogger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if hasUsers {
+		log.Warn("Target database is not empty")
+		return nil, fmt.Errorf("%s: %w", op, ErrNotEmpty)
+	}
+
+	counts, err := s.restore(ctx, r)
+	if err != nil {
+		log.Error("Failed to restore snapshot", prettylogger.Err(err))
+		return counts, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("Snapshot restored", slog.Any("counts", counts))
+
+	return counts, nil
+}
+
+func (s *Snapshot) restore(ctx context.Context, r io.Reader) (Counts, error) {
+	restorers := map[string]*kindRestorer{
+		kindOrganization:   restorerFor[orgRecord](s.target.RestoreOrganizations),
+		kindApp:            restorerFor[appRecord](s.target.RestoreApps),
+		kindPermission:     restorerFor[permissionRecord](s.target.RestorePermissions),
+		kindRole:           restorerFor[roleRecord](s.target.RestoreRoles),
+		kindRolePermission: restorerFor[rolePermissionRecord](s.target.RestoreRolePermissions),
+		kindUser:           restorerFor[userRecord](s.target.RestoreUsers),
+		kindMembership:     restorerFor[membershipRecord](s.target.RestoreMemberships),
+		kindProfile:        restorerFor[profileRecord](s.target.RestoreProfiles),
+		kindIdentity:       restorerFor[identityRecord](s.target.RestoreIdentities),
+		kindRoleAssignment: restorerFor[roleAssignmentRecord](s.target.RestoreRoleAssignments),
+		kindTOTP:           restorerFor[totpRecord](s.target.RestoreTOTPs),
+		kindWebAuthn:       restorerFor[webAuthnRecord](s.target.RestoreWebAuthnCredentials),
+		kindRecoveryCode:   restorerFor[recoveryCodeRecord](s.target.RestoreRecoveryCodes),
+	}
+	order := make(map[string]int, len(kinds))
+	for i, kind := range kinds {
+		order[kind] = i
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	counts := Counts{}
+	var current *kindRestorer
+	currentOrder := -1
+	headerSeen := false
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		if err := ctx.Err(); err != nil {
+			return counts, err
+		}
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return counts, fmt.Errorf("%w: line %d: %w", ErrInvalidArchive, lineNo, err)
+		}
+
+		if !headerSeen {
+			if l.Kind != kindHeader {
+				return counts, fmt.Errorf("%w: missing header", ErrInvalidArchive)
+			}
+			if l.Version < 1 || l.Version > Version {
+				return counts, fmt.Errorf("%w: %d", ErrUnsupportedVersion, l.Version)
+			}
+			headerSeen = true
+			continue
+		}
+
+		if l.Kind == kindFooter {
+			if current != nil {
+				if err := current.flush(ctx); err != nil {
+					return counts, err
+				}
+			}
+			for _, kind := range kinds {
+				if counts[kind] != l.Counts[kind] {
+					return counts, fmt.Errorf("%w: %d of %d %s records", ErrInvalidArchive, counts[kind], l.Counts[kind], kind)
+				}
+			}
+			return counts, nil
+		}
+
+		restorer, ok := restorers[l.Kind]
+		if !ok {
+			return counts, fmt.Errorf("%w: line %d: unknown kind %q", ErrInvalidArchive, lineNo, l.Kind)
+		}
+		if order[l.Kind] != currentOrder {
+			if order[l.Kind] < currentOrder {
+				return counts, fmt.Errorf("%w: line %d: %s records out of order", ErrInvalidArchive, lineNo, l.Kind)
+			}
+			if current != nil {
+				if err := current.flush(ctx); err != nil {
+					return counts, err
+				}
+			}
+			current, currentOrder = restorer, order[l.Kind]
+		}
+
+		if err := restorer.add(ctx, l.Data); err != nil {
+			return counts, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		counts[l.Kind]++
+	}
+	if err := scanner.Err(); err != nil {
+		return counts, err
+	}
+
+	return counts, fmt.Errorf("%w: missing footer, the archive is truncated", ErrInvalidArchive)
+}
@@ -0,0 +1,343 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sso/internal/domain/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storageMock keeps an instance in memory and is both the source and the target of a snapshot.
+type storageMock struct {
+	orgs            []models.Organization
+	apps            []models.App
+	permissions     []models.Permission
+	roles           []models.Role
+	rolePermissions []models.RolePermission
+	users           []models.User
+	memberships     []models.Membership
+	profiles        []models.Profile
+	identities      []models.Identity
+	assignments     []models.RoleAssignment
+	totps           []models.TOTP
+	webAuthn        []models.WebAuthnCredential
+	recoveryCodes   []models.RecoveryCode
+	restoreCalls    int
+}
+
+func each[T any](items []T, fn func(T) error) error {
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *storageMock) ExportOrganizations(ctx context.Context, fn func(models.Organization) error) error {
+	return each(m.orgs, fn)
+}
+
+func (m *storageMock) ExportApps(ctx context.Context, fn func(models.App) error) error {
+	return each(m.apps, fn)
+}
+
+func (m *storageMock) ExportPermissions(ctx context.Context, fn func(models.Permission) error) error {
+	return each(m.permissions, fn)
+}
+
+func (m *storageMock) ExportRoles(ctx context.Context, fn func(models.Role) error) error {
+	return each(m.roles, fn)
+}
+
+func (m *storageMock) ExportRolePermissions(ctx context.Context, fn func(models.RolePermission) error) error {
+	return each(m.rolePermissions, fn)
+}
+
+func (m *storageMock) ExportUsers(ctx context.Context, fn func(models.User) error) error {
+	return each(m.users, fn)
+}
+
+func (m *storageMock) ExportMemberships(ctx context.Context, fn func(models.Membership) error) error {
+	return each(m.memberships, fn)
+}
+
+func (m *storageMock) ExportProfiles(ctx context.Context, fn func(models.Profile) error) error {
+	return each(m.profiles, fn)
+}
+
+func (m *storageMock) ExportIdentities(ctx context.Context, fn func(models.Identity) error) error {
+	return each(m.identities, fn)
+}
+
+func (m *storageMock) ExportRoleAssignments(ctx context.Context, fn func(models.RoleAssignment) error) error {
+	return each(m.assignments, fn)
+}
+
+func (m *storageMock) ExportTOTPs(ctx context.Context, fn func(models.TOTP) error) error {
+	return each(m.totps, fn)
+}
+
+func (m *storageMock) HasUsers(ctx context.Context) (bool, error) {
+	return len(m.users) > 0, nil
+}
+
+func restoreInto[T any](m *storageMock, dst *[]T, items []T) error {
+	m.restoreCalls++
+	*dst = append(*dst, items...)
+	return nil
+}
+
+func (m *storageMock) RestoreOrganizations(ctx context.Context, orgs []models.Organization) error {
+	return restoreInto(m, &m.orgs, orgs)
+}
+
+func (m *storageMock) RestoreApps(ctx context.Context, apps []models.App) error {
+	return restoreInto(m, &m.apps, apps)
+}
+
+func (m *storageMock) RestorePermissions(ctx context.Context, permissions []models.Permission) error {
+	return restoreInto(m, &m.permissions, permissions)
+}
+
+func (m *storageMock) RestoreRoles(ctx context.Context, roles []models.Role) error {
+	return restoreInto(m, &m.roles, roles)
+}
+
+func (m *storageMock) RestoreRolePermissions(ctx context.Context, grants []models.RolePermission) error {
+	return restoreInto(m, &m.rolePermissions, grants)
+}
+
+func (m *storageMock) RestoreUsers(ctx context.Context, users []models.User) error {
+	return restoreInto(m, &m.users, users)
+}
+
+func (m *storageMock) RestoreMemberships(ctx context.Context, memberships []models.Membership) error {
+	return restoreInto(m, &m.memberships, memberships)
+}
+
+func (m *storageMock) RestoreProfiles(ctx context.Context, profiles []models.Profile) error {
+	return restoreInto(m, &m.profiles, profiles)
+}
+
+func (m *storageMock) RestoreIdentities(ctx context.Context, identities []models.Identity) error {
+	return restoreInto(m, &m.identities, identities)
+}
+
+func (m *storageMock) RestoreRoleAssignments(ctx context.Context, assignments []models.RoleAssignment) error {
+	return restoreInto(m, &m.assignments, assignments)
+}
+
+func (m *storageMock) ExportWebAuthnCredentials(ctx context.Context, fn func(models.WebAuthnCredential) error) error {
+	return each(m.webAuthn, fn)
+}
+
+func (m *storageMock) RestoreTOTPs(ctx context.Context, totps []models.TOTP) error {
+	return restoreInto(m, &m.totps, totps)
+}
+
+func (m *storageMock) RestoreWebAuthnCredentials(ctx context.Context, creds []models.WebAuthnCredential) error {
+	return restoreInto(m, &m.webAuthn, creds)
+}
+
+func (m *storageMock) ExportRecoveryCodes(ctx context.Context, fn func(models.RecoveryCode) error) error {
+	return each(m.recoveryCodes, fn)
+}
+
+func (m *storageMock) RestoreRecoveryCodes(ctx context.Context, codes []models.RecoveryCode) error {
+	return restoreInto(m, &m.recoveryCodes, codes)
+}
+
+func newInstance() *storageMock {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	return &storageMock{
+		orgs: []models.Organization{{ID: 2, Name: "Acme", Slug: "acme", CreatedAt: created}},
+		apps: []models.App{{ID: 1, Name: "test", Secret: "app-secret", OrgID: 2}},
+		permissions: []models.Permission{
+			{ID: 1, AppID: 1, Name: "posts:write"},
+		},
+		roles:           []models.Role{{ID: 3, OrgID: 2, AppID: 1, Name: "editor"}},
+		rolePermissions: []models.RolePermission{{RoleID: 3, PermissionID: 1}},
+		users: []models.User{{
+			ID:            7,
+			Email:         "alice@example.com",
+			PasswordHash:  []byte("$2a$10$hash"),
+			EmailVerified: true,
+			Username:      "alice",
+			Phone:         "+15551234567",
+			OrgID:         2,
+			CreatedAt:     created,
+			Status:        models.UserStatusActive,
+		}},
+		memberships: []models.Membership{{OrgID: 2, UserID: 7, CreatedAt: created}},
+		profiles: []models.Profile{{
+			UserID:       7,
+			DisplayName:  "Alice",
+			Locale:       "en",
+			AppMetadata:  json.RawMessage(`{"plan":"pro"}`),
+			UserMetadata: json.RawMessage(`{}`),
+			UpdatedAt:    created,
+		}},
+		identities:  []models.Identity{{UserID: 7, Provider: "github", Subject: "12345", Username: "alice-gh"}},
+		assignments: []models.RoleAssignment{{UserID: 7, Role: models.Role{ID: 3}, AppID: 1}},
+		totps:       []models.TOTP{{UserID: 7, Secret: []byte("sealed-secret"), ConfirmedAt: created, LastStep: 42, CreatedAt: created}},
+		webAuthn: []models.WebAuthnCredential{{
+			ID:                []byte{1, 2, 3},
+			UserID:            7,
+			Name:              "Laptop",
+			PublicKey:         []byte("cose-key"),
+			SignCount:         5,
+			AttestationFormat: "none",
+			Transports:        []string{"internal", "hybrid"},
+			CreatedAt:         created,
+			LastUsedAt:        created,
+		}},
+		recoveryCodes: []models.RecoveryCode{
+			{ID: 1, UserID: 7, CodeHash: []byte("hash-1"), CreatedAt: created, UsedAt: created},
+			{ID: 2, UserID: 7, CodeHash: []byte("hash-2"), CreatedAt: created},
+		},
+	}
+}
+
+func newSnapshot(source Source, target Target) *Snapshot {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), source, target)
+}
+
+func export(t *testing.T, source *storageMock, anonymize bool) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	_, err := newSnapshot(source, nil).Export(context.Background(), &buf, anonymize)
+	require.NoError(t, err)
+	return &buf
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	source := newInstance()
+	var buf bytes.Buffer
+	counts, err := newSnapshot(source, nil).Export(context.Background(), &buf, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts[kindUser])
+	assert.Equal(t, 1, counts[kindRoleAssignment])
+
+	target := &storageMock{}
+	restored, err := newSnapshot(nil, target).Restore(context.Background(), &buf)
+	require.NoError(t, err)
+	assert.Equal(t, counts, restored)
+
+	target.restoreCalls = 0
+	assert.Equal(t, source, target)
+}
+
+func TestSnapshot_Anonymize(t *testing.T) {
+	buf := export(t, newInstance(), true)
+	assert.NotContains(t, buf.String(), "alice")
+	assert.NotContains(t, buf.String(), "app-secret")
+	assert.NotContains(t, buf.String(), "12345")
+
+	target := &storageMock{}
+	_, err := newSnapshot(nil, target).Restore(context.Background(), buf)
+	require.NoError(t, err)
+
+	require.Len(t, target.users, 1)
+	assert.Equal(t, "user7@anonymized.invalid", target.users[0].Email)
+	assert.Empty(t, target.users[0].PasswordHash)
+	assert.Equal(t, int64(2), target.users[0].OrgID)
+	assert.Empty(t, target.profiles[0].DisplayName)
+	assert.Equal(t, "en", target.profiles[0].Locale)
+	assert.NotEmpty(t, target.apps[0].Secret)
+	assert.Len(t, target.assignments, 1)
+	assert.Empty(t, target.totps, "second factors are not exported anonymized")
+	assert.Empty(t, target.webAuthn)
+	assert.Empty(t, target.recoveryCodes)
+}
+
+func TestSnapshot_RestoreBatches(t *testing.T) {
+	source := &storageMock{}
+	for i := 1; i <= batchSize+1; i++ {
+		source.users = append(source.users, models.User{ID: int64(i), Email: "user@example.com", Status: models.UserStatusActive})
+	}
+	buf := export(t, source, false)
+
+	target := &storageMock{}
+	_, err := newSnapshot(nil, target).Restore(context.Background(), buf)
+	require.NoError(t, err)
+	assert.Len(t, target.users, batchSize+1)
+	assert.Equal(t, 2, target.restoreCalls)
+}
+
+func TestSnapshot_RestoreFailCases(t *testing.T) {
+	archive := export(t, newInstance(), false).String()
+	lines := strings.Split(strings.TrimSpace(archive), "\n")
+
+	tests := []struct {
+		name    string
+		archive string
+		target  *storageMock
+		err     error
+	}{
+		{
+			name:    "not empty target",
+			archive: archive,
+			target:  newInstance(),
+			err:     ErrNotEmpty,
+		},
+		{
+			name:    "truncated",
+			archive: strings.Join(lines[:len(lines)-2], "\n"),
+			err:     ErrInvalidArchive,
+		},
+		{
+			name:    "missing header",
+			archive: strings.Join(lines[1:], "\n"),
+			err:     ErrInvalidArchive,
+		},
+		{
+			name:    "newer version",
+			archive: `{"kind":"header","version":2}` + "\n" + strings.Join(lines[1:], "\n"),
+			err:     ErrUnsupportedVersion,
+		},
+		{
+			name:    "out of order",
+			archive: strings.Join([]string{lines[0], lines[6], lines[1], lines[len(lines)-1]}, "\n"),
+			err:     ErrInvalidArchive,
+		},
+		{
+			name:    "count mismatch",
+			archive: strings.Join(append(append([]string{}, lines[:6]...), lines[7:]...), "\n"),
+			err:     ErrInvalidArchive,
+		},
+		{
+			name:    "unknown kind",
+			archive: lines[0] + "\n" + `{"kind":"session","data":{}}`,
+			err:     ErrInvalidArchive,
+		},
+		{
+			name:    "unknown field",
+			archive: lines[0] + "\n" + `{"kind":"organization","data":{"id":1,"owner":"alice"}}`,
+			err:     ErrInvalidArchive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target == nil {
+				target = &storageMock{}
+			}
+
+			_, err := newSnapshot(nil, target).Restore(context.Background(), strings.NewReader(tt.archive))
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// userTables lists the tables holding rows owned by a user, they are cleaned up with the user.
+var userTables = []string{
+	"sessions",
+	"verification_tokens",
+	"identities",
+	"profiles",
+	"user_roles",
+	"organization_members",
+	"totp_secrets",
+	"mfa_challenges",
+	"webauthn_credentials",
+	"webauthn_challenges",
+	"recovery_codes",
+	"known_devices",
+}
+
+// invitedUserCond matches the invitations accepted by the user, bound first, or sent to its email,
+// bound second, in its email scope, bound third.
+const invitedUserCond = `accepted_by = ? OR (email = ? AND
+	COALESCE((SELECT id FROM organizations WHERE id = invitations.org_id AND isolated_emails), 0) = ?)`
+
+// auditEmailScopeExpr is the email scope of the app an audit_log row was recorded in.
+const auditEmailScopeExpr = `COALESCE((SELECT o.id FROM apps a JOIN organizations o ON o.id = a.org_id
+	WHERE a.id = audit_log.app_id AND o.isolated_emails), 0)`
+
+// ScheduleUserDeletion marks the user as deleted, the data stays until PurgeUser is called.
+func (s *Storage) ScheduleUserDeletion(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.ScheduleUserDeletion"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE users SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now().UTC(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// UsersToPurge returns IDs of users whose deletion was requested before the given time.
+func (s *Storage) UsersToPurge(ctx context.Context, deletedBefore time.Time) ([]int64, error) {
+	const op = "storage.sqlite.UsersToPurge"
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at <= ? AND email NOT LIKE 'deleted-%@deleted.invalid'",
+		deletedBefore.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// PurgeUser removes everything stored about the user, its audit events are pseudonymized. With anonymize
+// the users row is kept under a placeholder email, so IDs referenced elsewhere stay valid, but holds
+// no personal data.
+func (s *Storage) PurgeUser(ctx context.Context, userID int64, anonymize bool) error {
+	const op = "storage.sqlite.PurgeUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		scope           int64
+		email           string
+		username, phone sql.NullString
+	)
+	err = tx.QueryRowContext(
+		ctx, "SELECT email_scope, email, username, phone FROM users WHERE id = ?", userID,
+	).Scan(&scope, &email, &username, &phone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	identifiers := []string{email}
+	for _, id := range []sql.NullString{username, phone} {
+		if id.String != "" {
+			identifiers = append(identifiers, id.String)
+		}
+	}
+
+	for _, table := range userTables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("%s: %s: %w", op, table, err)
+		}
+	}
+
+	// Invitations name the invited user by email, the same email in another email scope is someone
+	// else. The pending invitations the user sent stay valid, they are left without an inviter.
+	for _, q := range []string{
+		"DELETE FROM invitation_roles WHERE invitation_id IN (SELECT id FROM invitations WHERE " + invitedUserCond + ")",
+		"DELETE FROM invitations WHERE " + invitedUserCond,
+	} {
+		if _, err := tx.ExecContext(ctx, q, userID, email, scope); err != nil {
+			return fmt.Errorf("%s: invitations: %w", op, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE invitations SET inviter_id = 0 WHERE inviter_id = ?", userID); err != nil {
+		return fmt.Errorf("%s: invitations: %w", op, err)
+	}
+
+	// Login throttles are keyed by the identifiers the user logged in with.
+	for _, id := range identifiers {
+		key := fmt.Sprintf("account:%d:%s", scope, id)
+		if _, err := tx.ExecContext(ctx, "DELETE FROM login_throttles WHERE key = ?", key); err != nil {
+			return fmt.Errorf("%s: login_throttles: %w", op, err)
+		}
+	}
+
+	// The audit log is append-only, the events are kept but pseudonymized: the identifiers are
+	// replaced by the placeholder email and the user IDs are negated, so they still link the events
+	// of the account but never a user registered later under the same ID.
+	placeholder := fmt.Sprintf("deleted-%d@deleted.invalid", userID)
+	for _, id := range identifiers {
+		_, err := tx.ExecContext(
+			ctx,
+			"UPDATE audit_log SET target = ? WHERE target_user_id = 0 AND target = ? AND "+auditEmailScopeExpr+" = ?",
+			placeholder, id, scope,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: audit_log: %w", op, err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE audit_log SET target = ? WHERE target_user_id = ? AND target <> ''", placeholder, userID)
+	if err != nil {
+		return fmt.Errorf("%s: audit_log: %w", op, err)
+	}
+	for _, column := range []string{"actor_id", "target_user_id"} {
+		_, err := tx.ExecContext(ctx, "UPDATE audit_log SET "+column+" = -"+column+" WHERE "+column+" = ?", userID)
+		if err != nil {
+			return fmt.Errorf("%s: audit_log: %w", op, err)
+		}
+	}
+
+	var res sql.Result
+	if anonymize {
+		res, err = tx.ExecContext(
+			ctx,
+			`UPDATE users SET email = 'deleted-' || id || '@deleted.invalid', password = x'', email_verified = FALSE,
+			username = NULL, phone = NULL,
+			deleted_at = COALESCE(deleted_at, ?) WHERE id = ?`,
+			time.Now().UTC(), userID,
+		)
+	} else {
+		res, err = tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", userID)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserData collects every row stored about the user.
+func (s *Storage) UserData(ctx context.Context, userID int64) (models.UserData, error) {
+	const op = "storage.sqlite.UserData"
+
+	data := models.UserData{}
+
+	user, err := s.UserByID(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+	data.User = user
+
+	data.IsAdmin, err = s.IsAdmin(ctx, userID)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Roles, err = s.RoleAssignments(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Orgs, err = s.UserOrganizations(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Profile, err = s.Profile(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Identities, err = s.userIdentities(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Sessions, err = s.userSessions(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.Tokens, err = s.userTokens(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.TOTP, err = s.TOTP(ctx, userID)
+	if err != nil && !errors.Is(err, storage.ErrTOTPNotFound) {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.WebAuthnCredentials, err = s.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.RecoveryCodes, err = s.RecoveryCodes(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.KnownDevices, err = s.KnownDevices(ctx, userID)
+	if err != nil {
+		return models.UserData{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+func (s *Storage) userIdentities(ctx context.Context, userID int64) ([]models.Identity, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT provider, subject, username, attributes, member_of FROM identities WHERE user_id = ? ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []models.Identity
+	for rows.Next() {
+		identity := models.Identity{UserID: userID}
+		var attributes, groups string
+
+		if err := rows.Scan(&identity.Provider, &identity.Subject, &identity.Username, &attributes, &groups); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(attributes), &identity.Attributes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(groups), &identity.Groups); err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+func (s *Storage) userSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT id, user_id, app_id, expires_at, revoked_at FROM sessions WHERE user_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session := models.Session{}
+		var revokedAt sql.NullTime
+
+		if err := rows.Scan(&session.ID, &session.UserID, &session.AppID, &session.ExpiresAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		session.RevokedAt = revokedAt.Time
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// userTokens returns the one-time tokens of the user without their hashes.
+func (s *Storage) userTokens(ctx context.Context, userID int64) ([]models.VerificationToken, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT purpose, email, expires_at FROM verification_tokens WHERE user_id = ? ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.VerificationToken
+	for rows.Next() {
+		token := models.VerificationToken{UserID: userID}
+
+		if err := rows.Scan(&token.Purpose, &token.Email, &token.ExpiresAt); err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// SetUserStatus changes the status of the user, lockedUntil is only kept for locked users.
+func (s *Storage) SetUserStatus(
+	ctx context.Context,
+	userID int64,
+	status models.UserStatus,
+	reason string,
+	lockedUntil time.Time,
+) error {
+	const op = "storage.sqlite.SetUserStatus"
+
+	var until sql.NullTime
+	if status == models.UserStatusLocked && !lockedUntil.IsZero() {
+		until = sql.NullTime{Time: lockedUntil.UTC(), Valid: true}
+	}
+
+	res, err := s.db.ExecContext(
+		ctx,
+		`UPDATE users SET status = ?, status_reason = ?, status_changed_at = ?, locked_until = ?
+		WHERE id = ? AND deleted_at IS NULL`,
+		string(status), reason, time.Now().UTC(), until, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
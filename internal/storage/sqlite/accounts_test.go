@@ -0,0 +1,205 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sso/internal/domain/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+	m, err := migrate.New("file://../../../migrations", fmt.Sprintf("sqlite3://%s?x-migrations-table=migrations", path))
+	require.NoError(t, err)
+	require.NoError(t, m.Up())
+	srcErr, dbErr := m.Close()
+	require.NoError(t, srcErr)
+	require.NoError(t, dbErr)
+
+	s, err := New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop() })
+
+	return s
+}
+
+// seedUser stores the user with a row in every place the purge has to clean up.
+func seedUser(t *testing.T, s *Storage, email string, username string) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, models.User{Email: email, PasswordHash: []byte("hash"), Username: username})
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.NoError(t, s.SaveSession(ctx, models.Session{ID: "session-" + email, UserID: userID, AppID: 1, ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, s.SaveMFAChallenge(ctx, models.MFAChallenge{
+		ID: "challenge-" + email, UserID: userID, AppID: 1, ExpiresAt: now.Add(time.Hour),
+		ThrottleKey: "account:0:" + email,
+	}))
+	_, err = s.db.ExecContext(ctx, "INSERT INTO user_roles (user_id, role_id, app_id) SELECT ?, id, 0 FROM roles", userID)
+	require.NoError(t, err)
+
+	for _, key := range []string{"account:0:" + email, "account:0:" + username} {
+		_, err = s.CountLoginFailure(ctx, key, now, now.Add(-time.Hour))
+		require.NoError(t, err)
+	}
+
+	for _, event := range []models.AuditEvent{
+		{Type: models.AuditLogin, Outcome: models.AuditFailure, Target: email},
+		{Type: models.AuditLogin, Outcome: models.AuditFailure, Target: username},
+		{Type: models.AuditLogin, Outcome: models.AuditSuccess, ActorID: userID, TargetUserID: userID, IP: "198.51.100.7"},
+	} {
+		event.Time = now
+		_, err = s.SaveAuditEvent(ctx, event)
+		require.NoError(t, err)
+	}
+
+	for i, inv := range []models.Invitation{
+		{InviterID: userID, Email: fmt.Sprintf("invitee-%d@example.org", userID)},
+		{InviterID: 0, Email: email},
+	} {
+		inv.Hash = []byte(fmt.Sprintf("token-%d-%d", userID, i))
+		inv.ExpiresAt = now.Add(time.Hour)
+		_, err = s.SaveInvitation(ctx, inv)
+		require.NoError(t, err)
+	}
+
+	return userID
+}
+
+// mentions returns the rows of all tables that hold any of the values or the user ID in a column
+// referencing a user.
+func mentions(t *testing.T, s *Storage, userID int64, values ...string) []string {
+	t.Helper()
+	ctx := context.Background()
+
+	userColumns := map[string]bool{
+		"user_id": true, "actor_id": true, "target_user_id": true, "inviter_id": true, "accepted_by": true,
+	}
+
+	tables, err := s.db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	require.NoError(t, err)
+	var names []string
+	for tables.Next() {
+		var name string
+		require.NoError(t, tables.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, tables.Err())
+	tables.Close()
+
+	var found []string
+	for _, table := range names {
+		rows, err := s.db.QueryContext(ctx, "SELECT * FROM "+table)
+		require.NoError(t, err)
+		columns, err := rows.Columns()
+		require.NoError(t, err)
+
+		for rows.Next() {
+			cells := make([]any, len(columns))
+			ptrs := make([]any, len(columns))
+			for i := range cells {
+				ptrs[i] = &cells[i]
+			}
+			require.NoError(t, rows.Scan(ptrs...))
+
+			for i, cell := range cells {
+				text := fmt.Sprint(cell)
+				if b, ok := cell.([]byte); ok {
+					text = string(b)
+				}
+				hit := userColumns[columns[i]] && text == fmt.Sprint(userID)
+				for _, v := range values {
+					hit = hit || strings.Contains(text, v)
+				}
+				if hit {
+					found = append(found, fmt.Sprintf("%s.%s = %s", table, columns[i], text))
+				}
+			}
+		}
+		require.NoError(t, rows.Err())
+		rows.Close()
+	}
+
+	return found
+}
+
+func TestPurgeUser(t *testing.T) {
+	for _, anonymize := range []bool{false, true} {
+		t.Run(fmt.Sprintf("anonymize=%t", anonymize), func(t *testing.T) {
+			s := newTestStorage(t)
+			ctx := context.Background()
+
+			userID := seedUser(t, s, "purged@example.com", "purged")
+			otherID := seedUser(t, s, "kept@example.com", "kept")
+
+			require.NoError(t, s.PurgeUser(ctx, userID, anonymize))
+
+			assert.Empty(t, mentions(t, s, userID, "purged@example.com", "purged"))
+			assert.NotEmpty(t, mentions(t, s, otherID, "kept@example.com"), "other users are kept")
+
+			var events, pseudonymized int
+			require.NoError(t, s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log").Scan(&events))
+			assert.Equal(t, 6, events, "audit events are kept")
+			require.NoError(t, s.db.QueryRowContext(
+				ctx, "SELECT COUNT(*) FROM audit_log WHERE target = ? OR actor_id = ?",
+				fmt.Sprintf("deleted-%d@deleted.invalid", userID), -userID,
+			).Scan(&pseudonymized))
+			assert.Equal(t, 3, pseudonymized)
+			_, err := s.db.ExecContext(ctx, "UPDATE audit_log SET details = 'changed'")
+			assert.ErrorContains(t, err, "append-only", "other updates are still rejected")
+
+			sent, err := s.Invitations(ctx, models.InvitationFilter{Email: fmt.Sprintf("invitee-%d@example.org", userID)}, time.Now())
+			require.NoError(t, err)
+			require.Len(t, sent, 1, "sent invitations are kept")
+			assert.Zero(t, sent[0].InviterID)
+		})
+	}
+}
+
+func TestPurgeUser_OtherEmailScope(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	orgID, err := s.SaveOrganization(ctx, models.Organization{Name: "Isolated", Slug: "isolated", IsolatedEmails: true})
+	require.NoError(t, err)
+	_, err = s.db.ExecContext(ctx, "INSERT INTO apps (id, name, secret, org_id) VALUES (7, 'isolated', 'secret', ?)", orgID)
+	require.NoError(t, err)
+
+	userID := seedUser(t, s, "same@example.com", "same")
+	_, err = s.SaveUser(ctx, models.User{Email: "same@example.com", PasswordHash: []byte("hash"), OrgID: orgID})
+	require.NoError(t, err)
+
+	_, err = s.SaveAuditEvent(ctx, models.AuditEvent{
+		Time: time.Now(), Type: models.AuditLogin, Outcome: models.AuditFailure, Target: "same@example.com", AppID: 7,
+	})
+	require.NoError(t, err)
+	_, err = s.SaveInvitation(ctx, models.Invitation{
+		Email: "same@example.com", OrgID: orgID, Hash: []byte("isolated"), ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.PurgeUser(ctx, userID, false))
+
+	var events, invitations int
+	require.NoError(t, s.db.QueryRowContext(
+		ctx, "SELECT COUNT(*) FROM audit_log WHERE target = 'same@example.com' AND app_id = 7",
+	).Scan(&events))
+	assert.Equal(t, 1, events, "the same email in another scope is someone else")
+	require.NoError(t, s.db.QueryRowContext(
+		ctx, "SELECT COUNT(*) FROM invitations WHERE email = 'same@example.com' AND org_id = ?", orgID,
+	).Scan(&invitations))
+	assert.Equal(t, 1, invitations)
+}
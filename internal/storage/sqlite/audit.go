@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"strings"
+	"time"
+)
+
+const auditColumns = "id, created_at, type, outcome, actor_id, target_user_id, target, app_id, ip, user_agent, details"
+
+// SaveAuditEvent appends the event to the audit log and returns its ID.
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error) {
+	const op = "storage.sqlite.SaveAuditEvent"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO audit_log (created_at, type, outcome, actor_id, target_user_id, target, app_id, ip, user_agent, details)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.Time.UTC(), event.Type, event.Outcome, event.ActorID, event.TargetUserID,
+		event.Target, event.AppID, event.IP, event.UserAgent, event.Details,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// AuditEvents returns up to limit events matching the filter, newest first. A non-zero beforeID
+// keeps the events older than the event of this ID, so the last ID of a page gets the next one.
+func (s *Storage) AuditEvents(
+	ctx context.Context,
+	filter models.AuditFilter,
+	beforeID int64,
+	limit int,
+) ([]models.AuditEvent, error) {
+	const op = "storage.sqlite.AuditEvents"
+
+	where := []string{"1 = 1"}
+	var args []any
+
+	if beforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, beforeID)
+	}
+	if filter.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, filter.Type)
+	}
+	if filter.Outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, filter.Outcome)
+	}
+	if filter.ActorID != 0 {
+		where = append(where, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.TargetUserID != 0 {
+		where = append(where, "target_user_id = ?")
+		args = append(args, filter.TargetUserID)
+	}
+	if filter.AppID != 0 {
+		where = append(where, "app_id = ?")
+		args = append(args, filter.AppID)
+	}
+	if filter.IP != "" {
+		where = append(where, "ip = ?")
+		args = append(args, filter.IP)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT "+auditColumns+" FROM audit_log WHERE "+strings.Join(where, " AND ")+" ORDER BY id DESC LIMIT ?",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		err := rows.Scan(
+			&event.ID, &event.Time, &event.Type, &event.Outcome, &event.ActorID, &event.TargetUserID,
+			&event.Target, &event.AppID, &event.IP, &event.UserAgent, &event.Details,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// DeleteAuditEventsBefore removes the events older than before, it is the only way events leave the log.
+func (s *Storage) DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteAuditEventsBefore"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM audit_log WHERE created_at < ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
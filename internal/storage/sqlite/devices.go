@@ -0,0 +1,191 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const knownDeviceColumns = `id, user_id, device_id_hash, user_agent_hash, ip_prefix, user_agent, last_ip,
+	first_seen_at, last_seen_at, session_id, revoke_token_hash, revoke_expires_at`
+
+func scanKnownDevice(row scanner) (models.KnownDevice, error) {
+	var (
+		device          models.KnownDevice
+		revokeExpiresAt sql.NullTime
+	)
+	err := row.Scan(
+		&device.ID, &device.UserID, &device.DeviceIDHash, &device.UserAgentHash, &device.IPPrefix,
+		&device.UserAgent, &device.LastIP, &device.FirstSeenAt, &device.LastSeenAt, &device.SessionID,
+		&device.RevokeTokenHash, &revokeExpiresAt,
+	)
+	if err != nil {
+		return models.KnownDevice{}, err
+	}
+	device.RevokeExpiresAt = revokeExpiresAt.Time
+
+	return device, nil
+}
+
+// TouchKnownDevice updates the last login from the device of the user, the device is matched
+// by its hashes and IP prefix. It returns storage.ErrKnownDeviceNotFound for a new device.
+func (s *Storage) TouchKnownDevice(ctx context.Context, device models.KnownDevice) error {
+	const op = "storage.sqlite.TouchKnownDevice"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		`UPDATE known_devices SET last_seen_at = ?, last_ip = ?, user_agent = ?
+		WHERE user_id = ? AND device_id_hash = ? AND user_agent_hash = ? AND ip_prefix = ?`,
+		device.LastSeenAt.UTC(), device.LastIP, device.UserAgent,
+		device.UserID, device.DeviceIDHash, device.UserAgentHash, device.IPPrefix,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrKnownDeviceNotFound)
+	}
+
+	return nil
+}
+
+// SaveKnownDevice adds the device to the known devices of the user. It returns
+// storage.ErrKnownDeviceExists if a concurrent login from the device added it first.
+func (s *Storage) SaveKnownDevice(ctx context.Context, device models.KnownDevice) error {
+	const op = "storage.sqlite.SaveKnownDevice"
+
+	var revokeExpiresAt any
+	if !device.RevokeExpiresAt.IsZero() {
+		revokeExpiresAt = device.RevokeExpiresAt.UTC()
+	}
+
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO known_devices (user_id, device_id_hash, user_agent_hash, ip_prefix, user_agent, last_ip,
+			first_seen_at, last_seen_at, session_id, revoke_token_hash, revoke_expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, device_id_hash, user_agent_hash, ip_prefix) DO NOTHING`,
+		device.UserID, device.DeviceIDHash, device.UserAgentHash, device.IPPrefix, device.UserAgent, device.LastIP,
+		device.FirstSeenAt.UTC(), device.LastSeenAt.UTC(), device.SessionID, device.RevokeTokenHash, revokeExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrKnownDeviceExists)
+	}
+
+	return nil
+}
+
+func (s *Storage) CountKnownDevices(ctx context.Context, userID int64) (int, error) {
+	const op = "storage.sqlite.CountKnownDevices"
+
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM known_devices WHERE user_id = ?", userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+func (s *Storage) KnownDevices(ctx context.Context, userID int64) ([]models.KnownDevice, error) {
+	const op = "storage.sqlite.KnownDevices"
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT "+knownDeviceColumns+" FROM known_devices WHERE user_id = ? ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var devices []models.KnownDevice
+	for rows.Next() {
+		device, err := scanKnownDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return devices, nil
+}
+
+// UseDeviceRevokeToken removes the device with the unexpired revoke token and returns it,
+// the token works once. It returns storage.ErrKnownDeviceNotFound for an unknown or expired token.
+func (s *Storage) UseDeviceRevokeToken(ctx context.Context, hash []byte) (models.KnownDevice, error) {
+	const op = "storage.sqlite.UseDeviceRevokeToken"
+
+	row := s.db.QueryRowContext(
+		ctx,
+		"DELETE FROM known_devices WHERE revoke_token_hash = ? AND revoke_expires_at > ? RETURNING "+knownDeviceColumns,
+		hash, time.Now().UTC(),
+	)
+	device, err := scanKnownDevice(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.KnownDevice{}, fmt.Errorf("%s: %w", op, storage.ErrKnownDeviceNotFound)
+		}
+		return models.KnownDevice{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return device, nil
+}
+
+// ForgetDevice removes the device ID of the user in every network and user agent
+// and returns how many entries were removed.
+func (s *Storage) ForgetDevice(ctx context.Context, userID int64, deviceIDHash []byte) (int64, error) {
+	const op = "storage.sqlite.ForgetDevice"
+
+	res, err := s.db.ExecContext(
+		ctx, "DELETE FROM known_devices WHERE user_id = ? AND device_id_hash = ?", userID, deviceIDHash,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// DeleteStaleKnownDevices forgets the devices not used since before, a login from them is reported again.
+func (s *Storage) DeleteStaleKnownDevices(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteStaleKnownDevices"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM known_devices WHERE last_seen_at < ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+)
+
+// userEntryQuery selects userColumns of users u followed by the directory details.
+var userEntryQuery = "SELECT u." + strings.ReplaceAll(userColumns, ", ", ", u.") +
+	", " + isAdminExpr + ", COALESCE(p.display_name, '')" +
+	" FROM users u LEFT JOIN profiles p ON p.user_id = u.id"
+
+func scanUserEntry(row scanner) (models.UserEntry, error) {
+	entry := models.UserEntry{}
+
+	user, err := scanUser(entryScanner{row: row, extra: []any{&entry.IsAdmin, &entry.DisplayName}})
+	if err != nil {
+		return models.UserEntry{}, err
+	}
+	entry.User = user
+
+	return entry, nil
+}
+
+// entryScanner appends the directory columns to the destinations of scanUser.
+type entryScanner struct {
+	row   scanner
+	extra []any
+}
+
+func (s entryScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+// UserEntry returns the user with its directory details.
+func (s *Storage) UserEntry(ctx context.Context, userID int64) (models.UserEntry, error) {
+	const op = "storage.sqlite.UserEntry"
+
+	row := s.db.QueryRowContext(ctx, userEntryQuery+" WHERE u.id = ?", userID)
+	entry, err := scanUserEntry(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserEntry{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.UserEntry{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entry, nil
+}
+
+// ListUsers returns up to limit users matching the filter with an ID greater than afterID, ordered by ID.
+func (s *Storage) ListUsers(
+	ctx context.Context,
+	filter models.UserFilter,
+	afterID int64,
+	limit int,
+) ([]models.UserEntry, error) {
+	const op = "storage.sqlite.ListUsers"
+
+	where := []string{"u.id > ?"}
+	args := []any{afterID}
+
+	if filter.Status != "" {
+		where = append(where, "u.status = ?")
+		args = append(args, string(filter.Status))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		where = append(where, "u.created_at >= ?")
+		args = append(args, filter.CreatedAfter.UTC())
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, "u.created_at < ?")
+		args = append(args, filter.CreatedBefore.UTC())
+	}
+	if filter.IsAdmin != nil {
+		where = append(where, isAdminExpr+" = ?")
+		args = append(args, *filter.IsAdmin)
+	}
+	if filter.OrgID != 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM organization_members m WHERE m.org_id = ? AND m.user_id = u.id)")
+		args = append(args, filter.OrgID)
+	}
+	if filter.EmailPrefix != "" {
+		// a range instead of LIKE, so the email index is used
+		where = append(where, "u.email >= ? AND u.email < ?")
+		args = append(args, filter.EmailPrefix, filter.EmailPrefix+"\U0010FFFF")
+	}
+	if filter.Query != "" {
+		pattern := "%" + escapeLike(filter.Query) + "%"
+		where = append(where, `(u.email LIKE ? ESCAPE '\' OR u.username LIKE ? ESCAPE '\' OR u.phone LIKE ? ESCAPE '\'
+			OR p.display_name LIKE ? ESCAPE '\' OR p.given_name LIKE ? ESCAPE '\' OR p.family_name LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern, pattern, pattern, pattern)
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		userEntryQuery+" WHERE "+strings.Join(where, " AND ")+" ORDER BY u.id LIMIT ?",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var entries []models.UserEntry
+	for rows.Next() {
+		entry, err := scanUserEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
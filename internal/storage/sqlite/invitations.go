@@ -0,0 +1,297 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const invitationColumns = "id, inviter_id, email, org_id, app_id, token_hash, expires_at, accepted_by, accepted_at, revoked_at, created_at"
+
+// pendingInvitation matches invitations that can still be accepted, it takes the current time.
+const pendingInvitation = "accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?"
+
+func scanInvitation(row scanner) (models.Invitation, error) {
+	inv := models.Invitation{}
+	var acceptedBy sql.NullInt64
+	var acceptedAt, revokedAt sql.NullTime
+
+	if err := row.Scan(
+		&inv.ID, &inv.InviterID, &inv.Email, &inv.OrgID, &inv.AppID, &inv.Hash, &inv.ExpiresAt,
+		&acceptedBy, &acceptedAt, &revokedAt, &inv.CreatedAt,
+	); err != nil {
+		return models.Invitation{}, err
+	}
+	inv.AcceptedBy = acceptedBy.Int64
+	inv.AcceptedAt = acceptedAt.Time
+	inv.RevokedAt = revokedAt.Time
+
+	return inv, nil
+}
+
+// SaveInvitation stores the invitation with the roles it grants.
+func (s *Storage) SaveInvitation(ctx context.Context, inv models.Invitation) (int64, error) {
+	const op = "storage.sqlite.SaveInvitation"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO invitations (token_hash, inviter_id, email, org_id, app_id, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		inv.Hash, inv.InviterID, inv.Email, inv.OrgID, inv.AppID, inv.ExpiresAt.UTC(), time.Now().UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, roleID := range inv.RoleIDs {
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO invitation_roles (invitation_id, role_id) VALUES (?, ?)", id, roleID)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) Invitation(ctx context.Context, invitationID int64) (models.Invitation, error) {
+	const op = "storage.sqlite.Invitation"
+
+	inv, err := s.invitationWhere(ctx, "id = ?", invitationID)
+	if err != nil {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return inv, nil
+}
+
+// InvitationByHash returns the invitation the token with the hash was issued for.
+func (s *Storage) InvitationByHash(ctx context.Context, hash []byte) (models.Invitation, error) {
+	const op = "storage.sqlite.InvitationByHash"
+
+	inv, err := s.invitationWhere(ctx, "token_hash = ?", hash)
+	if err != nil {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return inv, nil
+}
+
+func (s *Storage) invitationWhere(ctx context.Context, cond string, arg any) (models.Invitation, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+invitationColumns+" FROM invitations WHERE "+cond, arg)
+	inv, err := scanInvitation(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invitation{}, storage.ErrInvitationNotFound
+		}
+		return models.Invitation{}, err
+	}
+
+	inv.RoleIDs, err = s.invitationRoles(ctx, inv.ID)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	return inv, nil
+}
+
+func (s *Storage) invitationRoles(ctx context.Context, invitationID int64) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT role_id FROM invitation_roles WHERE invitation_id = ? ORDER BY role_id", invitationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Invitations returns the invitations matching the filter at the moment now, newest first.
+func (s *Storage) Invitations(ctx context.Context, filter models.InvitationFilter, now time.Time) ([]models.Invitation, error) {
+	const op = "storage.sqlite.Invitations"
+
+	query := "SELECT " + invitationColumns + " FROM invitations WHERE 1 = 1"
+	var args []any
+
+	if filter.OrgID != 0 {
+		query += " AND org_id = ?"
+		args = append(args, filter.OrgID)
+	}
+	if filter.Email != "" {
+		query += " AND email = ?"
+		args = append(args, filter.Email)
+	}
+	switch filter.Status {
+	case models.InvitationPending:
+		query += " AND " + pendingInvitation
+		args = append(args, now.UTC())
+	case models.InvitationAccepted:
+		query += " AND accepted_at IS NOT NULL"
+	case models.InvitationRevoked:
+		query += " AND accepted_at IS NULL AND revoked_at IS NOT NULL"
+	case models.InvitationExpired:
+		query += " AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?"
+		args = append(args, now.UTC())
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var invs []models.Invitation
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		invs = append(invs, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	rows.Close()
+
+	for i := range invs {
+		invs[i].RoleIDs, err = s.invitationRoles(ctx, invs[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return invs, nil
+}
+
+// RevokeInvitation revokes a pending invitation, others report storage.ErrInvitationNotFound.
+func (s *Storage) RevokeInvitation(ctx context.Context, invitationID int64) error {
+	const op = "storage.sqlite.RevokeInvitation"
+
+	now := time.Now().UTC()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE invitations SET revoked_at = ? WHERE id = ? AND "+pendingInvitation,
+		now, invitationID, now,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	return nil
+}
+
+// AcceptInvitation applies a pending invitation in one transaction: the user is created with a verified
+// email and the password hash when userID is 0, then becomes a member of the invitation organization and
+// gets its roles. It returns the ID of the user, storage.ErrInvitationNotFound if the invitation
+// is no longer pending and storage.ErrUserExists if the email is already taken.
+func (s *Storage) AcceptInvitation(ctx context.Context, invitationID int64, userID int64, passwordHash []byte) (int64, error) {
+	const op = "storage.sqlite.AcceptInvitation"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	var email string
+	var orgID int64
+	var appID int
+	err = tx.QueryRowContext(
+		ctx,
+		"UPDATE invitations SET accepted_at = ? WHERE id = ? AND "+pendingInvitation+" RETURNING email, org_id, app_id",
+		now, invitationID, now,
+	).Scan(&email, &orgID, &appID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userID == 0 {
+		res, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO users (email, password, email_verified, org_id, email_scope, created_at)
+			VALUES (?, ?, TRUE, ?, `+emailScopeExpr+`, ?)`,
+			email, passwordHash, orgID, orgID, now,
+		)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+			}
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		userID, err = res.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if orgID != 0 {
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO organization_members (org_id, user_id) VALUES (?, ?)", orgID, userID)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT OR IGNORE INTO user_roles (user_id, role_id, app_id)
+		SELECT ?, ir.role_id, ? FROM invitation_roles ir JOIN roles r ON r.id = ir.role_id
+		WHERE ir.invitation_id = ?`,
+		userID, appID, invitationID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE invitations SET accepted_by = ? WHERE id = ?", userID, invitationID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
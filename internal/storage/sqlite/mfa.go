@@ -0,0 +1,225 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SaveTOTP starts a TOTP enrollment, replacing an unconfirmed one.
+// A confirmed enrollment is kept and storage.ErrTOTPExists returned.
+func (s *Storage) SaveTOTP(ctx context.Context, totp models.TOTP) error {
+	const op = "storage.sqlite.SaveTOTP"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO totp_secrets (user_id, secret, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			last_step = 0,
+			created_at = excluded.created_at
+		WHERE totp_secrets.confirmed_at IS NULL`,
+		totp.UserID, totp.Secret, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPExists)
+	}
+
+	return nil
+}
+
+func (s *Storage) TOTP(ctx context.Context, userID int64) (models.TOTP, error) {
+	const op = "storage.sqlite.TOTP"
+
+	totp := models.TOTP{UserID: userID}
+	var confirmedAt sql.NullTime
+
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT secret, confirmed_at, last_step, created_at FROM totp_secrets WHERE user_id = ?",
+		userID,
+	).Scan(&totp.Secret, &confirmedAt, &totp.LastStep, &totp.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.TOTP{}, fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+		}
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, err)
+	}
+	totp.ConfirmedAt = confirmedAt.Time
+
+	return totp, nil
+}
+
+// ConfirmTOTP finishes the enrollment with the step of its first code.
+func (s *Storage) ConfirmTOTP(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.sqlite.ConfirmTOTP"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE totp_secrets SET confirmed_at = ?, last_step = ? WHERE user_id = ? AND confirmed_at IS NULL",
+		time.Now().UTC(), step, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records that a code of the step was accepted. It fails with storage.ErrTOTPStepUsed
+// if a code of this or a later step was accepted before, so every code works only once.
+func (s *Storage) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.sqlite.UseTOTPStep"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE totp_secrets SET last_step = ? WHERE user_id = ? AND last_step < ?",
+		step, userID, step,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPStepUsed)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteTOTP(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DeleteTOTP"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM totp_secrets WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	const op = "storage.sqlite.SaveMFAChallenge"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO mfa_challenges (id, user_id, app_id, expires_at, throttle_key) VALUES (?, ?, ?, ?, ?)",
+		challenge.ID, challenge.UserID, challenge.AppID, challenge.ExpiresAt.UTC(), challenge.ThrottleKey,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MFAChallenge returns a challenge that has not expired yet.
+func (s *Storage) MFAChallenge(ctx context.Context, id string) (models.MFAChallenge, error) {
+	const op = "storage.sqlite.MFAChallenge"
+
+	challenge := models.MFAChallenge{ID: id}
+
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT user_id, app_id, attempts, expires_at, throttle_key FROM mfa_challenges WHERE id = ? AND expires_at > ?",
+		id, time.Now().UTC(),
+	).Scan(&challenge.UserID, &challenge.AppID, &challenge.Attempts, &challenge.ExpiresAt, &challenge.ThrottleKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrChallengeNotFound)
+		}
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
+
+// CountMFAAttempt records a failed attempt to finish the challenge and returns the number of attempts so far.
+func (s *Storage) CountMFAAttempt(ctx context.Context, id string) (int, error) {
+	const op = "storage.sqlite.CountMFAAttempt"
+
+	var attempts int
+	err := s.db.QueryRowContext(
+		ctx,
+		"UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = ? RETURNING attempts",
+		id,
+	).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrChallengeNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attempts, nil
+}
+
+// DeleteMFAChallenge removes the challenge. Only one of concurrent calls succeeds,
+// the others get storage.ErrChallengeNotFound, so a challenge is finished once.
+func (s *Storage) DeleteMFAChallenge(ctx context.Context, id string) error {
+	const op = "storage.sqlite.DeleteMFAChallenge"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM mfa_challenges WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrChallengeNotFound)
+	}
+
+	return nil
+}
+
+// DeleteExpiredMFAChallenges removes the challenges that expired before the given time.
+func (s *Storage) DeleteExpiredMFAChallenges(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredMFAChallenges"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM mfa_challenges WHERE expires_at <= ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affected, nil
+}
@@ -0,0 +1,250 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const orgColumns = "id, name, slug, isolated_emails, created_at"
+
+func scanOrganization(row scanner) (models.Organization, error) {
+	org := models.Organization{}
+	if err := row.Scan(&org.ID, &org.Name, &org.Slug, &org.IsolatedEmails, &org.CreatedAt); err != nil {
+		return models.Organization{}, err
+	}
+	return org, nil
+}
+
+// orgExists reports storage.ErrOrgNotFound for a missing organization.
+func orgExists(ctx context.Context, tx *sql.Tx, orgID int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM organizations WHERE id = ?)", orgID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrOrgNotFound
+	}
+	return nil
+}
+
+func (s *Storage) SaveOrganization(ctx context.Context, org models.Organization) (int64, error) {
+	const op = "storage.sqlite.SaveOrganization"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO organizations (name, slug, isolated_emails, created_at) VALUES (?, ?, ?, ?)",
+		org.Name, org.Slug, org.IsolatedEmails, time.Now().UTC(),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) Organization(ctx context.Context, orgID int64) (models.Organization, error) {
+	const op = "storage.sqlite.Organization"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+orgColumns+" FROM organizations WHERE id = ?", orgID)
+	org, err := scanOrganization(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Organization{}, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return models.Organization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return org, nil
+}
+
+func (s *Storage) Organizations(ctx context.Context) ([]models.Organization, error) {
+	const op = "storage.sqlite.Organizations"
+
+	orgs, err := s.queryOrganizations(ctx, "SELECT "+orgColumns+" FROM organizations ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orgs, nil
+}
+
+// UserOrganizations returns the organizations the user is a member of.
+func (s *Storage) UserOrganizations(ctx context.Context, userID int64) ([]models.Organization, error) {
+	const op = "storage.sqlite.UserOrganizations"
+
+	orgs, err := s.queryOrganizations(
+		ctx,
+		`SELECT o.id, o.name, o.slug, o.isolated_emails, o.created_at
+		FROM organization_members m JOIN organizations o ON o.id = m.org_id
+		WHERE m.user_id = ? ORDER BY o.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orgs, nil
+}
+
+func (s *Storage) queryOrganizations(ctx context.Context, query string, args ...any) ([]models.Organization, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgs []models.Organization
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
+
+// DeleteOrganization deletes the organization with its memberships, roles and invitations.
+// An organization that still has users registered in it or apps reports storage.ErrOrgNotEmpty.
+func (s *Storage) DeleteOrganization(ctx context.Context, orgID int64) error {
+	const op = "storage.sqlite.DeleteOrganization"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := orgExists(ctx, tx, orgID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var used bool
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM users WHERE org_id = ?) OR EXISTS (SELECT 1 FROM apps WHERE org_id = ?)",
+		orgID, orgID,
+	).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if used {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrgNotEmpty)
+	}
+
+	for _, q := range []string{
+		"DELETE FROM user_roles WHERE role_id IN (SELECT id FROM roles WHERE org_id = ?)",
+		"DELETE FROM role_permissions WHERE role_id IN (SELECT id FROM roles WHERE org_id = ?)",
+		"DELETE FROM roles WHERE org_id = ?",
+		"DELETE FROM invitation_roles WHERE invitation_id IN (SELECT id FROM invitations WHERE org_id = ?)",
+		"DELETE FROM invitations WHERE org_id = ?",
+		"DELETE FROM organization_members WHERE org_id = ?",
+		"DELETE FROM organizations WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, q, orgID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) AddMember(ctx context.Context, orgID int64, userID int64) error {
+	const op = "storage.sqlite.AddMember"
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO organization_members (org_id, user_id) VALUES (?, ?)", orgID, userID)
+	if err != nil {
+		if isPrimaryKeyViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAlreadyMember)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveMember takes the user out of the organization together with the organization roles.
+func (s *Storage) RemoveMember(ctx context.Context, orgID int64, userID int64) error {
+	const op = "storage.sqlite.RemoveMember"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM organization_members WHERE org_id = ? AND user_id = ?", orgID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMemberNotFound)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"DELETE FROM user_roles WHERE user_id = ? AND role_id IN (SELECT id FROM roles WHERE org_id = ?)",
+		userID, orgID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) IsMember(ctx context.Context, orgID int64, userID int64) (bool, error) {
+	const op = "storage.sqlite.IsMember"
+
+	var member bool
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM organization_members WHERE org_id = ? AND user_id = ?)",
+		orgID, userID,
+	).Scan(&member)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return member, nil
+}
+
+// EmailScope returns the scope the emails and login identifiers of the organization are unique in:
+// the organization itself when it isolates emails, 0 for the shared scope otherwise.
+func (s *Storage) EmailScope(ctx context.Context, orgID int64) (int64, error) {
+	const op = "storage.sqlite.EmailScope"
+
+	var scope int64
+	if err := s.db.QueryRowContext(ctx, "SELECT "+emailScopeExpr, orgID).Scan(&scope); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return scope, nil
+}
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// Profile returns the profile of the user, users that never saved one get an empty profile.
+func (s *Storage) Profile(ctx context.Context, userID int64) (models.Profile, error) {
+	const op = "storage.sqlite.Profile"
+
+	profile := models.Profile{UserID: userID}
+	var appMetadata, userMetadata string
+	var updatedAt sql.NullTime
+
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(p.display_name, ''), COALESCE(p.given_name, ''), COALESCE(p.family_name, ''),
+			COALESCE(p.locale, ''), COALESCE(p.timezone, ''), COALESCE(p.avatar_url, ''),
+			COALESCE(p.app_metadata, '{}'), COALESCE(p.user_metadata, '{}'), p.updated_at
+		FROM users u LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE u.id = ?`,
+		userID,
+	).Scan(
+		&profile.DisplayName, &profile.GivenName, &profile.FamilyName,
+		&profile.Locale, &profile.Timezone, &profile.AvatarURL,
+		&appMetadata, &userMetadata, &updatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Profile{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.Profile{}, fmt.Errorf("%s: %w", op, err)
+	}
+	profile.AppMetadata = []byte(appMetadata)
+	profile.UserMetadata = []byte(userMetadata)
+	profile.UpdatedAt = updatedAt.Time
+
+	return profile, nil
+}
+
+// SaveProfile creates or replaces the profile of the user.
+func (s *Storage) SaveProfile(ctx context.Context, profile models.Profile) error {
+	const op = "storage.sqlite.SaveProfile"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO profiles (user_id, display_name, given_name, family_name, locale, timezone, avatar_url, app_metadata, user_metadata, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			display_name = excluded.display_name,
+			given_name = excluded.given_name,
+			family_name = excluded.family_name,
+			locale = excluded.locale,
+			timezone = excluded.timezone,
+			avatar_url = excluded.avatar_url,
+			app_metadata = excluded.app_metadata,
+			user_metadata = excluded.user_metadata,
+			updated_at = excluded.updated_at`,
+		profile.UserID, profile.DisplayName, profile.GivenName, profile.FamilyName,
+		profile.Locale, profile.Timezone, profile.AvatarURL,
+		string(profile.AppMetadata), string(profile.UserMetadata), time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
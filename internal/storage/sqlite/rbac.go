@@ -0,0 +1,440 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// hasPermissionQuery checks that the user holds the permission in the app through any of their roles.
+// Global assignments and permissions (app_id 0) count in every app, roles of an organization
+// only count in it.
+const hasPermissionQuery = `SELECT EXISTS (
+	SELECT 1 FROM user_roles ur
+	JOIN roles r ON r.id = ur.role_id
+	JOIN role_permissions rp ON rp.role_id = ur.role_id
+	JOIN permissions p ON p.id = rp.permission_id
+	WHERE ur.user_id = ? AND r.org_id IN (0, ?) AND ur.app_id IN (0, ?) AND p.app_id IN (0, ?) AND p.name = ?
+)`
+
+// isAdminExpr is hasPermissionQuery for the global admin permission of the user u.
+const isAdminExpr = `EXISTS (
+	SELECT 1 FROM user_roles ur
+	JOIN roles r ON r.id = ur.role_id
+	JOIN role_permissions rp ON rp.role_id = ur.role_id
+	JOIN permissions p ON p.id = rp.permission_id
+	WHERE ur.user_id = u.id AND r.org_id = 0 AND ur.app_id = 0 AND p.app_id = 0 AND p.name = 'admin'
+)`
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+func isPrimaryKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
+
+func (s *Storage) HasPermission(ctx context.Context, userID int64, orgID int64, appID int, permission string) (bool, error) {
+	const op = "storage.sqlite.HasPermission"
+
+	var has bool
+	err := s.db.QueryRowContext(ctx, hasPermissionQuery, userID, orgID, appID, appID, permission).Scan(&has)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return has, nil
+}
+
+func (s *Storage) SaveRole(ctx context.Context, role models.Role) (int64, error) {
+	const op = "storage.sqlite.SaveRole"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO roles (org_id, app_id, name, description) VALUES (?, ?, ?, ?)",
+		role.OrgID, role.AppID, role.Name, role.Description,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) Role(ctx context.Context, roleID int64) (models.Role, error) {
+	const op = "storage.sqlite.Role"
+
+	role := models.Role{ID: roleID}
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT org_id, app_id, name, description FROM roles WHERE id = ?",
+		roleID,
+	).Scan(&role.OrgID, &role.AppID, &role.Name, &role.Description)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	role.Permissions, err = s.rolePermissions(ctx, roleID)
+	if err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return role, nil
+}
+
+// Roles returns the roles of the organization and the app together with the global roles.
+func (s *Storage) Roles(ctx context.Context, orgID int64, appID int) ([]models.Role, error) {
+	const op = "storage.sqlite.Roles"
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT id, org_id, app_id, name, description FROM roles WHERE org_id IN (0, ?) AND app_id IN (0, ?) ORDER BY org_id, app_id, name",
+		orgID, appID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := s.scanRoles(ctx, rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// DeleteRole deletes the role together with its permission grants, assignments and invitation grants.
+func (s *Storage) DeleteRole(ctx context.Context, roleID int64) error {
+	const op = "storage.sqlite.DeleteRole"
+
+	err := s.deleteWith(ctx, "DELETE FROM roles WHERE id = ?", roleID,
+		"DELETE FROM role_permissions WHERE role_id = ?",
+		"DELETE FROM user_roles WHERE role_id = ?",
+		"DELETE FROM invitation_roles WHERE role_id = ?",
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SavePermission(ctx context.Context, permission models.Permission) (int64, error) {
+	const op = "storage.sqlite.SavePermission"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO permissions (app_id, name, description) VALUES (?, ?, ?)",
+		permission.AppID, permission.Name, permission.Description,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrPermissionExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) Permission(ctx context.Context, permissionID int64) (models.Permission, error) {
+	const op = "storage.sqlite.Permission"
+
+	permission := models.Permission{ID: permissionID}
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT app_id, name, description FROM permissions WHERE id = ?",
+		permissionID,
+	).Scan(&permission.AppID, &permission.Name, &permission.Description)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Permission{}, fmt.Errorf("%s: %w", op, storage.ErrPermissionNotFound)
+		}
+		return models.Permission{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permission, nil
+}
+
+// Permissions returns the permissions of the app together with the global permissions.
+func (s *Storage) Permissions(ctx context.Context, appID int) ([]models.Permission, error) {
+	const op = "storage.sqlite.Permissions"
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT id, app_id, name, description FROM permissions WHERE app_id IN (0, ?) ORDER BY app_id, name",
+		appID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		permission := models.Permission{}
+		if err := rows.Scan(&permission.ID, &permission.AppID, &permission.Name, &permission.Description); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		permissions = append(permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permissions, nil
+}
+
+// DeletePermission deletes the permission and takes it away from every role.
+func (s *Storage) DeletePermission(ctx context.Context, permissionID int64) error {
+	const op = "storage.sqlite.DeletePermission"
+
+	err := s.deleteWith(ctx, "DELETE FROM permissions WHERE id = ?", permissionID,
+		"DELETE FROM role_permissions WHERE permission_id = ?",
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrPermissionNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GrantPermission adds the permission to the role, granting it twice is a no-op.
+func (s *Storage) GrantPermission(ctx context.Context, roleID int64, permissionID int64) error {
+	const op = "storage.sqlite.GrantPermission"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO role_permissions (role_id, permission_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		roleID, permissionID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokePermission(ctx context.Context, roleID int64, permissionID int64) error {
+	const op = "storage.sqlite.RevokePermission"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"DELETE FROM role_permissions WHERE role_id = ? AND permission_id = ?",
+		roleID, permissionID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) AssignRole(ctx context.Context, userID int64, roleID int64, appID int) error {
+	const op = "storage.sqlite.AssignRole"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO user_roles (user_id, role_id, app_id) VALUES (?, ?, ?)",
+		userID, roleID, appID,
+	)
+	if err != nil {
+		if isPrimaryKeyViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleAlreadyAssigned)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnassignRole takes the role away from the user, it reports ErrRoleNotFound if it wasn't assigned.
+func (s *Storage) UnassignRole(ctx context.Context, userID int64, roleID int64, appID int) error {
+	const op = "storage.sqlite.UnassignRole"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"DELETE FROM user_roles WHERE user_id = ? AND role_id = ? AND app_id = ?",
+		userID, roleID, appID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+
+	return nil
+}
+
+// RoleAssignments returns every role assigned to the user in any app.
+func (s *Storage) RoleAssignments(ctx context.Context, userID int64) ([]models.RoleAssignment, error) {
+	const op = "storage.sqlite.RoleAssignments"
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT ur.app_id, r.id, r.org_id, r.app_id, r.name, r.description
+		FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = ? ORDER BY r.org_id, ur.app_id, r.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var assignments []models.RoleAssignment
+	for rows.Next() {
+		assignment := models.RoleAssignment{UserID: userID}
+		role := &assignment.Role
+		if err := rows.Scan(&assignment.AppID, &role.ID, &role.OrgID, &role.AppID, &role.Name, &role.Description); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		assignments = append(assignments, assignment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return assignments, nil
+}
+
+// UserRoles returns the roles the user has in the app, including the globally assigned ones.
+// Roles of other organizations than orgID are left out.
+func (s *Storage) UserRoles(ctx context.Context, userID int64, orgID int64, appID int) ([]models.Role, error) {
+	const op = "storage.sqlite.UserRoles"
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT DISTINCT r.id, r.org_id, r.app_id, r.name, r.description
+		FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = ? AND r.org_id IN (0, ?) AND ur.app_id IN (0, ?)
+		ORDER BY r.name`,
+		userID, orgID, appID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := s.scanRoles(ctx, rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// scanRoles reads id, org_id, app_id, name and description rows and loads the permissions of each role.
+func (s *Storage) scanRoles(ctx context.Context, rows *sql.Rows) ([]models.Role, error) {
+	var roles []models.Role
+	for rows.Next() {
+		role := models.Role{}
+		if err := rows.Scan(&role.ID, &role.OrgID, &role.AppID, &role.Name, &role.Description); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range roles {
+		permissions, err := s.rolePermissions(ctx, roles[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		roles[i].Permissions = permissions
+	}
+
+	return roles, nil
+}
+
+func (s *Storage) rolePermissions(ctx context.Context, roleID int64) ([]string, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT p.name FROM role_permissions rp JOIN permissions p ON p.id = rp.permission_id
+		WHERE rp.role_id = ? ORDER BY p.name`,
+		roleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+
+	return permissions, rows.Err()
+}
+
+// deleteWith runs the dependent deletes and then the main one in a transaction,
+// all of them take the same id. sql.ErrNoRows means the main delete matched nothing.
+func (s *Storage) deleteWith(ctx context.Context, query string, id int64, dependent ...string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, q := range dependent {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
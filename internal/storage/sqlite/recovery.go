@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const recoveryCodeColumns = "id, user_id, code_hash, created_at, used_at"
+
+// ReplaceRecoveryCodes gives the user a new set of recovery codes, the old ones stop working.
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes [][]byte) error {
+	const op = "storage.sqlite.ReplaceRecoveryCodes"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now().UTC()
+	for _, hash := range hashes {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)",
+			userID, hash, now,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RecoveryCodes returns the recovery codes of the user, used ones included.
+func (s *Storage) RecoveryCodes(ctx context.Context, userID int64) ([]models.RecoveryCode, error) {
+	const op = "storage.sqlite.RecoveryCodes"
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT "+recoveryCodeColumns+" FROM recovery_codes WHERE user_id = ? ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var codes []models.RecoveryCode
+	for rows.Next() {
+		code, err := scanRecoveryCode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return codes, nil
+}
+
+func scanRecoveryCode(row scanner) (models.RecoveryCode, error) {
+	var (
+		code   models.RecoveryCode
+		usedAt sql.NullTime
+	)
+
+	if err := row.Scan(&code.ID, &code.UserID, &code.CodeHash, &code.CreatedAt, &usedAt); err != nil {
+		return models.RecoveryCode{}, err
+	}
+	code.UsedAt = usedAt.Time
+
+	return code, nil
+}
+
+// CountRecoveryCodes returns how many recovery codes of the user are left unused.
+func (s *Storage) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	const op = "storage.sqlite.CountRecoveryCodes"
+
+	var count int
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL",
+		userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// UseRecoveryCode marks the unused code of the user with the hash as used. It fails with
+// storage.ErrRecoveryCodeNotFound if there is none, so of concurrent logins only one gets it.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, hash []byte) error {
+	const op = "storage.sqlite.UseRecoveryCode"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now().UTC(), userID, hash,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRecoveryCodeNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,581 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sso/internal/domain/models"
+	"strings"
+	"time"
+)
+
+// The Export methods walk every row of a table in primary key order, the Restore methods write
+// the rows back with their IDs, each call in one transaction. Together they move an instance
+// between databases, see services/snapshot.
+
+// HasUsers reports whether any user is stored, a snapshot is only restored into a database without users.
+func (s *Storage) HasUsers(ctx context.Context) (bool, error) {
+	const op = "storage.sqlite.HasUsers"
+
+	var has bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users)").Scan(&has); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return has, nil
+}
+
+// eachRow calls scan for every row of the query.
+func (s *Storage) eachRow(ctx context.Context, query string, scan func(row scanner) error) error {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// restore runs the statement for every item in one transaction.
+func restore[T any](ctx context.Context, s *Storage, query string, items []T, args func(item T) []any) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, item := range items {
+		if _, err := stmt.ExecContext(ctx, args(item)...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func (s *Storage) ExportOrganizations(ctx context.Context, fn func(models.Organization) error) error {
+	const op = "storage.sqlite.ExportOrganizations"
+
+	err := s.eachRow(ctx, "SELECT "+orgColumns+" FROM organizations ORDER BY id", func(row scanner) error {
+		org, err := scanOrganization(row)
+		if err != nil {
+			return err
+		}
+		return fn(org)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreOrganizations(ctx context.Context, orgs []models.Organization) error {
+	const op = "storage.sqlite.RestoreOrganizations"
+
+	err := restore(ctx, s,
+		"INSERT INTO organizations (id, name, slug, isolated_emails, created_at) VALUES (?, ?, ?, ?, ?)",
+		orgs, func(org models.Organization) []any {
+			return []any{org.ID, org.Name, org.Slug, org.IsolatedEmails, org.CreatedAt.UTC()}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ExportApps(ctx context.Context, fn func(models.App) error) error {
+	const op = "storage.sqlite.ExportApps"
+
+	err := s.eachRow(ctx, "SELECT id, name, secret, require_verified_email, org_id FROM apps ORDER BY id", func(row scanner) error {
+		app := models.App{}
+		if err := row.Scan(&app.ID, &app.Name, &app.Secret, &app.RequireVerifiedEmail, &app.OrgID); err != nil {
+			return err
+		}
+		return fn(app)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RestoreApps replaces the apps with the same IDs, such as the ones created by the migrations.
+func (s *Storage) RestoreApps(ctx context.Context, apps []models.App) error {
+	const op = "storage.sqlite.RestoreApps"
+
+	err := restore(ctx, s,
+		`INSERT INTO apps (id, name, secret, require_verified_email, org_id) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			secret = excluded.secret,
+			require_verified_email = excluded.require_verified_email,
+			org_id = excluded.org_id`,
+		apps, func(app models.App) []any {
+			return []any{app.ID, app.Name, app.Secret, app.RequireVerifiedEmail, app.OrgID}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ExportPermissions(ctx context.Context, fn func(models.Permission) error) error {
+	const op = "storage.sqlite.ExportPermissions"
+
+	err := s.eachRow(ctx, "SELECT id, app_id, name, description FROM permissions ORDER BY id", func(row scanner) error {
+		permission := models.Permission{}
+		if err := row.Scan(&permission.ID, &permission.AppID, &permission.Name, &permission.Description); err != nil {
+			return err
+		}
+		return fn(permission)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RestorePermissions replaces the permissions with the same IDs, such as the admin one.
+func (s *Storage) RestorePermissions(ctx context.Context, permissions []models.Permission) error {
+	const op = "storage.sqlite.RestorePermissions"
+
+	err := restore(ctx, s,
+		`INSERT INTO permissions (id, app_id, name, description) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			app_id = excluded.app_id,
+			name = excluded.name,
+			description = excluded.description`,
+		permissions, func(permission models.Permission) []any {
+			return []any{permission.ID, permission.AppID, permission.Name, permission.Description}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ExportRoles walks the roles without their permissions, see ExportRolePermissions.
+func (s *Storage) ExportRoles(ctx context.Context, fn func(models.Role) error) error {
+	const op = "storage.sqlite.ExportRoles"
+
+	err := s.eachRow(ctx, "SELECT id, org_id, app_id, name, description FROM roles ORDER BY id", func(row scanner) error {
+		role := models.Role{}
+		if err := row.Scan(&role.ID, &role.OrgID, &role.AppID, &role.Name, &role.Description); err != nil {
+			return err
+		}
+		return fn(role)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RestoreRoles replaces the roles with the same IDs, such as the admin one.
+func (s *Storage) RestoreRoles(ctx context.Context, roles []models.Role) error {
+	const op = "storage.sqlite.RestoreRoles"
+
+	err := restore(ctx, s,
+		`INSERT INTO roles (id, org_id, app_id, name, description) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			org_id = excluded.org_id,
+			app_id = excluded.app_id,
+			name = excluded.name,
+			description = excluded.description`,
+		roles, func(role models.Role) []any {
+			return []any{role.ID, role.OrgID, role.AppID, role.Name, role.Description}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ExportRolePermissions(ctx context.Context, fn func(models.RolePermission) error) error {
+	const op = "storage.sqlite.ExportRolePermissions"
+
+	err := s.eachRow(ctx, "SELECT role_id, permission_id FROM role_permissions ORDER BY role_id, permission_id", func(row scanner) error {
+		grant := models.RolePermission{}
+		if err := row.Scan(&grant.RoleID, &grant.PermissionID); err != nil {
+			return err
+		}
+		return fn(grant)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreRolePermissions(ctx context.Context, grants []models.RolePermission) error {
+	const op = "storage.sqlite.RestoreRolePermissions"
+
+	err := restore(ctx, s,
+		"INSERT OR IGNORE INTO role_permissions (role_id, permission_id) VALUES (?, ?)",
+		grants, func(grant models.RolePermission) []any {
+			return []any{grant.RoleID, grant.PermissionID}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ExportUsers(ctx context.Context, fn func(models.User) error) error {
+	const op = "storage.sqlite.ExportUsers"
+
+	err := s.eachRow(ctx, "SELECT "+userColumns+" FROM users ORDER BY id", func(row scanner) error {
+		user, err := scanUser(row)
+		if err != nil {
+			return err
+		}
+		return fn(user)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RestoreUsers creates the users in the email scopes of their organizations,
+// so the organizations are restored first.
+func (s *Storage) RestoreUsers(ctx context.Context, users []models.User) error {
+	const op = "storage.sqlite.RestoreUsers"
+
+	err := restore(ctx, s,
+		`INSERT INTO users (
+			id, email, password, email_verified, username, phone, org_id, email_scope, created_at,
+			deleted_at, status, status_reason, status_changed_at, locked_until
+		) VALUES (?, ?, ?, ?, ?, ?, ?, `+emailScopeExpr+`, ?, ?, ?, ?, ?, ?)`,
+		users, func(user models.User) []any {
+			return []any{
+				user.ID, user.Email, user.PasswordHash, user.EmailVerified,
+				nullString(user.Username), nullString(user.Phone), user.OrgID, user.OrgID, nullTime(user.CreatedAt),
+				nullTime(user.DeletedAt), user.Status, user.StatusReason, nullTime(user.StatusChangedAt), nullTime(user.LockedUntil),
+			}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ExportMemberships(ctx context.Context, fn func(models.Membership) error) error {
+	const op = "storage.sqlite.ExportMemberships"
+
+	err := s.eachRow(ctx, "SELECT org_id, user_id, created_at FROM organization_members ORDER BY org_id, user_id", func(row scanner) error {
+		membership := models.Membership{}
+		if err := row.Scan(&membership.OrgID, &membership.UserID, &membership.CreatedAt); err != nil {
+			return err
+		}
+		return fn(membership)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreMemberships(ctx context.Context, memberships []models.Membership) error {
+	const op = "storage.sqlite.RestoreMemberships"
+
+	err := restore(ctx, s,
+		"INSERT OR IGNORE INTO organization_members (org_id, user_id, created_at) VALUES (?, ?, ?)",
+		memberships, func(membership models.Membership) []any {
+			return []any{membership.OrgID, membership.UserID, membership.CreatedAt.UTC()}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ExportProfiles(ctx context.Context, fn func(models.Profile) error) error {
+	const op = "storage.sqlite.ExportProfiles"
+
+	err := s.eachRow(ctx,
+		`SELECT user_id, COALESCE(display_name, ''), COALESCE(given_name, ''), COALESCE(family_name, ''),
+			COALESCE(locale, ''), COALESCE(timezone, ''), COALESCE(avatar_url, ''),
+			COALESCE(app_metadata, '{}'), COALESCE(user_metadata, '{}'), updated_at
+		FROM profiles ORDER BY user_id`,
+		func(row scanner) error {
+			profile := models.Profile{}
+			var appMetadata, userMetadata string
+			if err := row.Scan(
+				&profile.UserID, &profile.DisplayName, &profile.GivenName, &profile.FamilyName,
+				&profile.Locale, &profile.Timezone, &profile.AvatarURL,
+				&appMetadata, &userMetadata, &profile.UpdatedAt,
+			); err != nil {
+				return err
+			}
+			profile.AppMetadata = []byte(appMetadata)
+			profile.UserMetadata = []byte(userMetadata)
+			return fn(profile)
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreProfiles(ctx context.Context, profiles []models.Profile) error {
+	const op = "storage.sqlite.RestoreProfiles"
+
+	err := restore(ctx, s,
+		`INSERT INTO profiles (user_id, display_name, given_name, family_name, locale, timezone, avatar_url, app_metadata, user_metadata, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		profiles, func(profile models.Profile) []any {
+			return []any{
+				profile.UserID, profile.DisplayName, profile.GivenName, profile.FamilyName,
+				profile.Locale, profile.Timezone, profile.AvatarURL,
+				string(profile.AppMetadata), string(profile.UserMetadata), profile.UpdatedAt.UTC(),
+			}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ExportIdentities(ctx context.Context, fn func(models.Identity) error) error {
+	const op = "storage.sqlite.ExportIdentities"
+
+	err := s.eachRow(ctx,
+		"SELECT user_id, provider, subject, username, attributes, member_of FROM identities ORDER BY id",
+		func(row scanner) error {
+			identity := models.Identity{}
+			var attributes, groups string
+			if err := row.Scan(&identity.UserID, &identity.Provider, &identity.Subject, &identity.Username, &attributes, &groups); err != nil {
+				return err
+			}
+			if err := json.Unmarshal([]byte(attributes), &identity.Attributes); err != nil {
+				return err
+			}
+			if err := json.Unmarshal([]byte(groups), &identity.Groups); err != nil {
+				return err
+			}
+			return fn(identity)
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreIdentities(ctx context.Context, identities []models.Identity) error {
+	const op = "storage.sqlite.RestoreIdentities"
+
+	args := make([][]any, 0, len(identities))
+	for _, identity := range identities {
+		attributes, err := json.Marshal(identity.Attributes)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		groups, err := json.Marshal(identity.Groups)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		args = append(args, []any{
+			identity.UserID, identity.Provider, identity.Subject, identity.Username, string(attributes), string(groups),
+		})
+	}
+
+	err := restore(ctx, s,
+		"INSERT INTO identities (user_id, provider, subject, username, attributes, member_of) VALUES (?, ?, ?, ?, ?, ?)",
+		args, func(a []any) []any { return a },
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ExportRoleAssignments walks the role assignments, only the ID of the assignment role is set.
+func (s *Storage) ExportRoleAssignments(ctx context.Context, fn func(models.RoleAssignment) error) error {
+	const op = "storage.sqlite.ExportRoleAssignments"
+
+	err := s.eachRow(ctx, "SELECT user_id, role_id, app_id FROM user_roles ORDER BY user_id, role_id, app_id", func(row scanner) error {
+		assignment := models.RoleAssignment{}
+		if err := row.Scan(&assignment.UserID, &assignment.Role.ID, &assignment.AppID); err != nil {
+			return err
+		}
+		return fn(assignment)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreRoleAssignments(ctx context.Context, assignments []models.RoleAssignment) error {
+	const op = "storage.sqlite.RestoreRoleAssignments"
+
+	err := restore(ctx, s,
+		"INSERT OR IGNORE INTO user_roles (user_id, role_id, app_id) VALUES (?, ?, ?)",
+		assignments, func(assignment models.RoleAssignment) []any {
+			return []any{assignment.UserID, assignment.Role.ID, assignment.AppID}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ExportTOTPs walks the authenticator app enrollments, the secrets stay encrypted.
+func (s *Storage) ExportTOTPs(ctx context.Context, fn func(models.TOTP) error) error {
+	const op = "storage.sqlite.ExportTOTPs"
+
+	err := s.eachRow(ctx, "SELECT user_id, secret, confirmed_at, last_step, created_at FROM totp_secrets ORDER BY user_id", func(row scanner) error {
+		totp := models.TOTP{}
+		var confirmedAt sql.NullTime
+		if err := row.Scan(&totp.UserID, &totp.Secret, &confirmedAt, &totp.LastStep, &totp.CreatedAt); err != nil {
+			return err
+		}
+		totp.ConfirmedAt = confirmedAt.Time
+		return fn(totp)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreTOTPs(ctx context.Context, totps []models.TOTP) error {
+	const op = "storage.sqlite.RestoreTOTPs"
+
+	err := restore(ctx, s,
+		"INSERT INTO totp_secrets (user_id, secret, confirmed_at, last_step, created_at) VALUES (?, ?, ?, ?, ?)",
+		totps, func(totp models.TOTP) []any {
+			return []any{totp.UserID, totp.Secret, nullTime(totp.ConfirmedAt), totp.LastStep, totp.CreatedAt.UTC()}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ExportWebAuthnCredentials walks the passkeys and security keys of all users.
+func (s *Storage) ExportWebAuthnCredentials(ctx context.Context, fn func(models.WebAuthnCredential) error) error {
+	const op = "storage.sqlite.ExportWebAuthnCredentials"
+
+	err := s.eachRow(ctx, "SELECT "+webAuthnCredentialColumns+" FROM webauthn_credentials ORDER BY id", func(row scanner) error {
+		cred, err := scanWebAuthnCredential(row)
+		if err != nil {
+			return err
+		}
+		return fn(cred)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreWebAuthnCredentials(ctx context.Context, creds []models.WebAuthnCredential) error {
+	const op = "storage.sqlite.RestoreWebAuthnCredentials"
+
+	err := restore(ctx, s,
+		`INSERT INTO webauthn_credentials (id, user_id, name, public_key, sign_count, aaguid, attestation_format, transports, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		creds, func(cred models.WebAuthnCredential) []any {
+			return []any{
+				cred.ID, cred.UserID, cred.Name, cred.PublicKey, cred.SignCount, cred.AAGUID,
+				cred.AttestationFormat, strings.Join(cred.Transports, ","), cred.CreatedAt.UTC(), nullTime(cred.LastUsedAt),
+			}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ExportRecoveryCodes walks the recovery codes of all users, used ones included.
+func (s *Storage) ExportRecoveryCodes(ctx context.Context, fn func(models.RecoveryCode) error) error {
+	const op = "storage.sqlite.ExportRecoveryCodes"
+
+	err := s.eachRow(ctx, "SELECT "+recoveryCodeColumns+" FROM recovery_codes ORDER BY id", func(row scanner) error {
+		code, err := scanRecoveryCode(row)
+		if err != nil {
+			return err
+		}
+		return fn(code)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreRecoveryCodes(ctx context.Context, codes []models.RecoveryCode) error {
+	const op = "storage.sqlite.RestoreRecoveryCodes"
+
+	err := restore(ctx, s,
+		"INSERT INTO recovery_codes (id, user_id, code_hash, created_at, used_at) VALUES (?, ?, ?, ?, ?)",
+		codes, func(code models.RecoveryCode) []any {
+			return []any{code.ID, code.UserID, code.CodeHash, code.CreatedAt.UTC(), nullTime(code.UsedAt)}
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
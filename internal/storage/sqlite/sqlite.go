@@ -3,10 +3,13 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sso/internal/domain/models"
 	"sso/internal/storage"
+	"strings"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 	_ "github.com/mattn/go-sqlite3"
@@ -16,11 +19,52 @@ type Storage struct {
 	db *sql.DB
 }
 
+const userColumns = "id, email, password, email_verified, org_id, created_at, deleted_at, status, status_reason, status_changed_at, locked_until, username, phone"
+
+// emailScopeExpr is the email scope of the organization bound to it: the organization itself
+// when it isolates emails, 0 for the shared scope otherwise.
+const emailScopeExpr = "COALESCE((SELECT id FROM organizations WHERE id = ? AND isolated_emails), 0)"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// scanUser scans a row selected with userColumns.
+func scanUser(row scanner) (models.User, error) {
+	user := models.User{}
+	var createdAt, deletedAt, statusChangedAt, lockedUntil sql.NullTime
+	var username, phone sql.NullString
+
+	if err := row.Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.EmailVerified, &user.OrgID, &createdAt, &deletedAt,
+		&user.Status, &user.StatusReason, &statusChangedAt, &lockedUntil, &username, &phone,
+	); err != nil {
+		return models.User{}, err
+	}
+	user.CreatedAt = createdAt.Time
+	user.DeletedAt = deletedAt.Time
+	user.StatusChangedAt = statusChangedAt.Time
+	user.LockedUntil = lockedUntil.Time
+	user.Username = username.String
+	user.Phone = phone.String
+
+	return user, nil
+}
+
 // New creates a new instance of the sqlite Storage
 func New(storagePath string) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
-	db, err := sql.Open("sqlite3", storagePath)
+	// Foreign keys are off by default in SQLite, without them ON DELETE CASCADE does nothing.
+	sep := "?"
+	if strings.Contains(storagePath, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite3", storagePath+sep+"_foreign_keys=on")
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -32,18 +76,38 @@ func (s *Storage) Stop() error {
 	return s.db.Close()
 }
 
-func (s *Storage) SaveUser(ctx context.Context, email string, passwordHash []byte) (int64, error) {
+// SaveUser creates a user with the email, password hash and optional username and phone
+// in the organization user.OrgID, or outside of any with 0, and makes the user its member.
+// Imported users may come with the email already verified.
+func (s *Storage) SaveUser(ctx context.Context, user models.User) (int64, error) {
 	const op = "storage.sqlite.SaveUser"
 
-	stmt, err := s.db.Prepare("INSERT INTO users (email, password) VALUES (?, ?)")
+	orgID := user.OrgID
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-	res, err := stmt.ExecContext(ctx, email, passwordHash)
-	if err != nil {
-		var sqliteErr sqlite3.Error
+	defer tx.Rollback()
 
-		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+	if orgID != 0 {
+		if err := orgExists(ctx, tx, orgID); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO users (email, password, email_verified, username, phone, org_id, email_scope, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, `+emailScopeExpr+`, ?)`,
+		user.Email, user.PasswordHash, user.EmailVerified, nullString(user.Username), nullString(user.Phone),
+		orgID, orgID, time.Now().UTC(),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			if taken := identifierTaken(err); taken != nil {
+				return 0, fmt.Errorf("%s: %w", op, taken)
+			}
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
 		}
 
@@ -55,16 +119,64 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passwordHash []byt
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if orgID != 0 {
+		_, err = tx.ExecContext(ctx, "INSERT INTO organization_members (org_id, user_id) VALUES (?, ?)", orgID, id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return id, nil
 }
 
-func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+// User returns the user with the email as seen from the organization: emails of an organization
+// with isolated emails are looked up among its own users, the others in the shared scope.
+func (s *Storage) User(ctx context.Context, orgID int64, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	user := models.User{}
+	row := s.db.QueryRowContext(
+		ctx,
+		"SELECT "+userColumns+" FROM users WHERE email_scope = "+emailScopeExpr+" AND email = ?",
+		orgID, email,
+	)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// identifierColumns maps login identifier types to the users columns holding them.
+var identifierColumns = map[models.IdentifierType]string{
+	models.IdentifierEmail:    "email",
+	models.IdentifierUsername: "username",
+	models.IdentifierPhone:    "phone",
+}
+
+// UserByIdentifier returns the user with the normalized login identifier as seen from the organization, see User.
+func (s *Storage) UserByIdentifier(ctx context.Context, orgID int64, id models.Identifier) (models.User, error) {
+	const op = "storage.sqlite.UserByIdentifier"
+
+	column, ok := identifierColumns[id.Type]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: unknown identifier type %q", op, id.Type)
+	}
 
-	row := s.db.QueryRowContext(ctx, "SELECT id, email, password FROM users WHERE email = ?", email)
-	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash)
+	row := s.db.QueryRowContext(
+		ctx,
+		"SELECT "+userColumns+" FROM users WHERE email_scope = "+emailScopeExpr+" AND "+column+" = ?",
+		orgID, id.Value,
+	)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -76,12 +188,59 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
+// UpdateIdentifiers replaces the username and phone of the user, empty values remove them.
+func (s *Storage) UpdateIdentifiers(ctx context.Context, userID int64, username string, phone string) error {
+	const op = "storage.sqlite.UpdateIdentifiers"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE users SET username = ?, phone = ? WHERE id = ?",
+		nullString(username), nullString(phone), userID,
+	)
+	if err != nil {
+		if taken := identifierTaken(err); taken != nil {
+			return fmt.Errorf("%s: %w", op, taken)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// identifierTaken tells which identifier a unique constraint violation on users is about,
+// nil means it is not about the username or phone.
+func identifierTaken(err error) error {
+	if !isUniqueViolation(err) {
+		return nil
+	}
+	switch {
+	case strings.Contains(err.Error(), "users.username"):
+		return storage.ErrUsernameTaken
+	case strings.Contains(err.Error(), "users.phone"):
+		return storage.ErrPhoneTaken
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// IsAdmin reports whether the user holds the global admin permission.
 func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
 
 	var isAdmin bool
 
-	row := s.db.QueryRowContext(ctx, "SELECT is_admin FROM admins WHERE user_id = ?", userID)
+	row := s.db.QueryRowContext(ctx, "SELECT "+isAdminExpr+" FROM users u WHERE u.id = ?", userID)
 	err := row.Scan(&isAdmin)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -99,8 +258,8 @@ func (s *Storage) App(ctx context.Context, appID int32) (models.App, error) {
 
 	app := models.App{}
 
-	row := s.db.QueryRowContext(ctx, "SELECT id, name, secret FROM apps WHERE id = ?", appID)
-	err := row.Scan(&app.ID, &app.Name, &app.Secret)
+	row := s.db.QueryRowContext(ctx, "SELECT id, name, secret, require_verified_email, org_id FROM apps WHERE id = ?", appID)
+	err := row.Scan(&app.ID, &app.Name, &app.Secret, &app.RequireVerifiedEmail, &app.OrgID)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -112,3 +271,373 @@ func (s *Storage) App(ctx context.Context, appID int32) (models.App, error) {
 
 	return app, nil
 }
+
+// SaveIdentity refreshes the synced attributes of an external identity and returns its local user,
+// the user is created for a new identity. An existing user with the same email is never taken over:
+// storage.ErrIdentityNotLinked is returned until the user links the identity, see LinkIdentity.
+// The email follows the directory only for users without a local password.
+// Directory users live in the shared email scope.
+func (s *Storage) SaveIdentity(ctx context.Context, identity models.Identity) (models.User, error) {
+	const op = "storage.sqlite.SaveIdentity"
+
+	attributes, err := json.Marshal(identity.Attributes)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	groups, err := json.Marshal(identity.Groups)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(
+		ctx,
+		"SELECT "+userColumns+" FROM users WHERE id = (SELECT user_id FROM identities WHERE provider = ? AND subject = ?)",
+		identity.Provider, identity.Subject,
+	)
+	user, err := scanUser(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Emails coming from the directory are trusted, so the user starts verified.
+		res, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO users (email, password, email_verified, created_at) VALUES (?, ?, TRUE, ?)",
+			identity.Email, []byte{}, time.Now().UTC(),
+		)
+		if err != nil {
+			var sqliteErr sqlite3.Error
+
+			if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+				return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrIdentityNotLinked)
+			}
+
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		user.ID, err = res.LastInsertId()
+		if err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		user.Email = identity.Email
+		user.EmailVerified = true
+	case err != nil:
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	case user.Email != identity.Email && len(user.PasswordHash) == 0:
+		_, err = tx.ExecContext(ctx, "UPDATE users SET email = ?, email_verified = TRUE WHERE id = ?", identity.Email, user.ID)
+		if err != nil {
+			var sqliteErr sqlite3.Error
+
+			if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+				return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+			}
+
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		user.Email = identity.Email
+		user.EmailVerified = true
+	}
+
+	if err := upsertIdentity(ctx, tx, user.ID, identity, string(attributes), string(groups)); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// LinkIdentity links the external identity to the user, whose email stays as it is.
+// It returns storage.ErrIdentityLinked if the identity already belongs to another user.
+func (s *Storage) LinkIdentity(ctx context.Context, userID int64, identity models.Identity) error {
+	const op = "storage.sqlite.LinkIdentity"
+
+	attributes, err := json.Marshal(identity.Attributes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	groups, err := json.Marshal(identity.Groups)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := upsertIdentity(ctx, s.db, userID, identity, string(attributes), string(groups)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// upsertIdentity stores the identity of the user or refreshes it, an identity of another user is left alone.
+func upsertIdentity(ctx context.Context, db execer, userID int64, identity models.Identity, attributes string, groups string) error {
+	res, err := db.ExecContext(
+		ctx,
+		`INSERT INTO identities (user_id, provider, subject, username, attributes, member_of, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (provider, subject) DO UPDATE SET
+			username = excluded.username,
+			attributes = excluded.attributes,
+			member_of = excluded.member_of,
+			synced_at = excluded.synced_at
+		WHERE identities.user_id = excluded.user_id`,
+		userID, identity.Provider, identity.Subject, identity.Username, attributes, groups,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrIdentityLinked
+	}
+
+	return nil
+}
+
+// SaveVerificationToken stores a one-time token and drops the unused tokens
+// the user had for the same purpose.
+func (s *Storage) SaveVerificationToken(ctx context.Context, token models.VerificationToken) error {
+	const op = "storage.sqlite.SaveVerificationToken"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		"DELETE FROM verification_tokens WHERE user_id = ? AND purpose = ? AND used_at IS NULL",
+		token.UserID, token.Purpose,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO verification_tokens (user_id, purpose, token_hash, email, expires_at) VALUES (?, ?, ?, ?, ?)",
+		token.UserID, token.Purpose, token.Hash, token.Email, token.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// VerificationToken returns an unused and unexpired token without using it.
+func (s *Storage) VerificationToken(ctx context.Context, purpose string, hash []byte) (models.VerificationToken, error) {
+	const op = "storage.sqlite.VerificationToken"
+
+	token := models.VerificationToken{}
+
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT user_id, purpose, token_hash, email, expires_at FROM verification_tokens
+		WHERE purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		purpose, hash, time.Now().UTC(),
+	).Scan(&token.UserID, &token.Purpose, &token.Hash, &token.Email, &token.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.VerificationToken{}, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+		}
+		return models.VerificationToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// UseVerificationToken marks an unused and unexpired token as used and returns it.
+func (s *Storage) UseVerificationToken(ctx context.Context, purpose string, hash []byte) (models.VerificationToken, error) {
+	const op = "storage.sqlite.UseVerificationToken"
+
+	token := models.VerificationToken{}
+	now := time.Now().UTC()
+
+	row := s.db.QueryRowContext(
+		ctx,
+		`UPDATE verification_tokens SET used_at = ?
+		WHERE purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?
+		RETURNING user_id, purpose, token_hash, email, expires_at`,
+		now, purpose, hash, now,
+	)
+	err := row.Scan(&token.UserID, &token.Purpose, &token.Hash, &token.Email, &token.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.VerificationToken{}, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+		}
+
+		return models.VerificationToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// SetEmailVerified marks the email of the user as verified if it is still the given one.
+func (s *Storage) SetEmailVerified(ctx context.Context, userID int64, email string) error {
+	const op = "storage.sqlite.SetEmailVerified"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ?", userID, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "storage.sqlite.SaveSession"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO sessions (id, user_id, app_id, expires_at) VALUES (?, ?, ?, ?)",
+		session.ID, session.UserID, session.AppID, session.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeSessions revokes every active session of the user except the given one
+// and returns how many were revoked. Pass an empty exceptID to revoke them all.
+func (s *Storage) RevokeSessions(ctx context.Context, userID int64, exceptID string) (int64, error) {
+	const op = "storage.sqlite.RevokeSessions"
+
+	now := time.Now().UTC()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id != ? AND revoked_at IS NULL AND expires_at > ?",
+		now, userID, exceptID, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// RevokeSession revokes the session, an already revoked or expired one is left as is.
+func (s *Storage) RevokeSession(ctx context.Context, id string) error {
+	const op = "storage.sqlite.RevokeSession"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID int64, passwordHash []byte) error {
+	const op = "storage.sqlite.UpdatePassword"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) Session(ctx context.Context, id string) (models.Session, error) {
+	const op = "storage.sqlite.Session"
+
+	session := models.Session{}
+	var revokedAt sql.NullTime
+
+	row := s.db.QueryRowContext(ctx, "SELECT id, user_id, app_id, expires_at, revoked_at FROM sessions WHERE id = ?", id)
+	err := row.Scan(&session.ID, &session.UserID, &session.AppID, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	session.RevokedAt = revokedAt.Time
+
+	return session, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", userID)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UpdateEmail switches the email of the user to an already verified address.
+func (s *Storage) UpdateEmail(ctx context.Context, userID int64, email string) error {
+	const op = "storage.sqlite.UpdateEmail"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET email = ?, email_verified = TRUE WHERE id = ?", email, userID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveIdentity_NoTakeover(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	localID, err := s.SaveUser(ctx, models.User{Email: "alice@corp.example", PasswordHash: []byte("hash")})
+	require.NoError(t, err)
+
+	identity := models.Identity{Provider: "ldap", Subject: "uid=alice,dc=corp", Email: "alice@corp.example"}
+	_, err = s.SaveIdentity(ctx, identity)
+	assert.ErrorIs(t, err, storage.ErrIdentityNotLinked, "a matching email does not link the identity")
+
+	require.NoError(t, s.LinkIdentity(ctx, localID, identity))
+	user, err := s.SaveIdentity(ctx, identity)
+	require.NoError(t, err)
+	assert.Equal(t, localID, user.ID)
+
+	identity.Email = "mallory@corp.example"
+	user, err = s.SaveIdentity(ctx, identity)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@corp.example", user.Email, "the email of a user with a local password stays")
+
+	user, err = s.User(ctx, 0, "alice@corp.example")
+	require.NoError(t, err)
+	assert.False(t, user.EmailVerified)
+
+	otherID, err := s.SaveUser(ctx, models.User{Email: "bob@corp.example", PasswordHash: []byte("hash")})
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.LinkIdentity(ctx, otherID, identity), storage.ErrIdentityLinked)
+}
+
+func TestSaveIdentity_DirectoryUser(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	identity := models.Identity{Provider: "ldap", Subject: "uid=carol,dc=corp", Email: "carol@corp.example"}
+	created, err := s.SaveIdentity(ctx, identity)
+	require.NoError(t, err)
+	assert.True(t, created.EmailVerified)
+
+	identity.Email = "carol.smith@corp.example"
+	user, err := s.SaveIdentity(ctx, identity)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, user.ID)
+	assert.Equal(t, "carol.smith@corp.example", user.Email, "the email of a directory user follows the directory")
+}
@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) LoginThrottle(ctx context.Context, key string) (models.LoginThrottle, error) {
+	const op = "storage.sqlite.LoginThrottle"
+
+	throttle := models.LoginThrottle{Key: key}
+	var blockedUntil sql.NullTime
+
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT failures, last_failure_at, blocked_until FROM login_throttles WHERE key = ?",
+		key,
+	).Scan(&throttle.Failures, &throttle.LastFailureAt, &blockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginThrottle{}, fmt.Errorf("%s: %w", op, storage.ErrLoginThrottleNotFound)
+		}
+		return models.LoginThrottle{}, fmt.Errorf("%s: %w", op, err)
+	}
+	throttle.BlockedUntil = blockedUntil.Time
+
+	return throttle, nil
+}
+
+// CountLoginFailure records a failed login at the time at and returns the number of failures so far.
+// Failures before resetBefore are forgotten, the count starts over from this one.
+func (s *Storage) CountLoginFailure(ctx context.Context, key string, at time.Time, resetBefore time.Time) (int, error) {
+	const op = "storage.sqlite.CountLoginFailure"
+
+	var failures int
+	err := s.db.QueryRowContext(
+		ctx,
+		`INSERT INTO login_throttles (key, failures, last_failure_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = excluded.last_failure_at
+		RETURNING failures`,
+		key, at.UTC(), resetBefore.UTC(),
+	).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return failures, nil
+}
+
+// BlockLogin blocks logins of the key until the given time.
+func (s *Storage) BlockLogin(ctx context.Context, key string, until time.Time) error {
+	const op = "storage.sqlite.BlockLogin"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE login_throttles SET blocked_until = ? WHERE key = ?",
+		until.UTC(), key,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrLoginThrottleNotFound)
+	}
+
+	return nil
+}
+
+// DeleteLoginThrottle forgets the failures of the key, a key without failures is not an error.
+func (s *Storage) DeleteLoginThrottle(ctx context.Context, key string) error {
+	const op = "storage.sqlite.DeleteLoginThrottle"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM login_throttles WHERE key = ?", key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredLoginThrottles removes the keys without failures since before and not blocked after it.
+func (s *Storage) DeleteExpiredLoginThrottles(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredLoginThrottles"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"DELETE FROM login_throttles WHERE last_failure_at < ? AND (blocked_until IS NULL OR blocked_until < ?)",
+		before.UTC(), before.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
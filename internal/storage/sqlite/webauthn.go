@@ -0,0 +1,205 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+const webAuthnCredentialColumns = "id, user_id, name, public_key, sign_count, aaguid, attestation_format, transports, created_at, last_used_at"
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error {
+	const op = "storage.sqlite.SaveWebAuthnCredential"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO webauthn_credentials (id, user_id, name, public_key, sign_count, aaguid, attestation_format, transports, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		cred.ID, cred.UserID, cred.Name, cred.PublicKey, cred.SignCount, cred.AAGUID,
+		cred.AttestationFormat, strings.Join(cred.Transports, ","), time.Now().UTC(),
+	)
+	if err != nil {
+		if isPrimaryKeyViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrCredentialExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) WebAuthnCredential(ctx context.Context, id []byte) (models.WebAuthnCredential, error) {
+	const op = "storage.sqlite.WebAuthnCredential"
+
+	cred, err := scanWebAuthnCredential(s.db.QueryRowContext(
+		ctx,
+		"SELECT "+webAuthnCredentialColumns+" FROM webauthn_credentials WHERE id = ?",
+		id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, storage.ErrCredentialNotFound)
+		}
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cred, nil
+}
+
+// WebAuthnCredentials returns the credentials of the user, oldest first.
+func (s *Storage) WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	const op = "storage.sqlite.WebAuthnCredentials"
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT "+webAuthnCredentialColumns+" FROM webauthn_credentials WHERE user_id = ? ORDER BY created_at, id",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var creds []models.WebAuthnCredential
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		creds = append(creds, cred)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return creds, nil
+}
+
+func scanWebAuthnCredential(row scanner) (models.WebAuthnCredential, error) {
+	var (
+		cred       models.WebAuthnCredential
+		transports string
+		lastUsedAt sql.NullTime
+	)
+
+	err := row.Scan(
+		&cred.ID, &cred.UserID, &cred.Name, &cred.PublicKey, &cred.SignCount, &cred.AAGUID,
+		&cred.AttestationFormat, &transports, &cred.CreatedAt, &lastUsedAt,
+	)
+	if err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+	if transports != "" {
+		cred.Transports = strings.Split(transports, ",")
+	}
+	cred.LastUsedAt = lastUsedAt.Time
+
+	return cred, nil
+}
+
+// UseWebAuthnCredential records a login with the credential and the new value of its counter.
+// It fails with storage.ErrCredentialNotFound if the credential is gone or a concurrent
+// login already moved the counter to signCount or past it.
+func (s *Storage) UseWebAuthnCredential(ctx context.Context, id []byte, signCount uint32) error {
+	const op = "storage.sqlite.UseWebAuthnCredential"
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"UPDATE webauthn_credentials SET sign_count = ?, last_used_at = ? WHERE id = ? AND (sign_count < ? OR ? = 0)",
+		signCount, time.Now().UTC(), id, signCount, signCount,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrCredentialNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userID int64, id []byte) error {
+	const op = "storage.sqlite.DeleteWebAuthnCredential"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrCredentialNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveWebAuthnChallenge(ctx context.Context, challenge models.WebAuthnChallenge) error {
+	const op = "storage.sqlite.SaveWebAuthnChallenge"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO webauthn_challenges (challenge, kind, user_id, app_id, expires_at) VALUES (?, ?, ?, ?, ?)",
+		challenge.Challenge, challenge.Kind, challenge.UserID, challenge.AppID, challenge.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TakeWebAuthnChallenge removes the challenge and returns it if it has not expired.
+// Only one of concurrent calls gets it, so every challenge is answered once.
+func (s *Storage) TakeWebAuthnChallenge(ctx context.Context, challenge string) (models.WebAuthnChallenge, error) {
+	const op = "storage.sqlite.TakeWebAuthnChallenge"
+
+	c := models.WebAuthnChallenge{Challenge: challenge}
+
+	err := s.db.QueryRowContext(
+		ctx,
+		"DELETE FROM webauthn_challenges WHERE challenge = ? RETURNING kind, user_id, app_id, expires_at",
+		challenge,
+	).Scan(&c.Kind, &c.UserID, &c.AppID, &c.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebAuthnChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnChallengeNotFound)
+		}
+		return models.WebAuthnChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !c.ExpiresAt.After(time.Now()) {
+		return models.WebAuthnChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnChallengeNotFound)
+	}
+
+	return c, nil
+}
+
+// DeleteExpiredWebAuthnChallenges removes the challenges of ceremonies that were never finished.
+func (s *Storage) DeleteExpiredWebAuthnChallenges(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredWebAuthnChallenges"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM webauthn_challenges WHERE expires_at <= ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
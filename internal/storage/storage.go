@@ -3,7 +3,44 @@ package storage
 import "errors"
 
 var (
-	ErrUserExists   = errors.New("User already exists")
-	ErrUserNotFound = errors.New("User not found")
-	ErrAppNotFound  = errors.New("App not found")
+	ErrUserExists      = errors.New("User already exists")
+	ErrUserNotFound    = errors.New("User not found")
+	ErrAppNotFound     = errors.New("App not found")
+	ErrTokenNotFound   = errors.New("Token not found")
+	ErrSessionNotFound = errors.New("Session not found")
+	ErrUsernameTaken   = errors.New("Username already taken")
+	ErrPhoneTaken      = errors.New("Phone number already taken")
+
+	ErrIdentityNotLinked = errors.New("User exists but the identity is not linked to it")
+	ErrIdentityLinked    = errors.New("Identity is linked to another user")
+
+	ErrRoleExists          = errors.New("Role already exists")
+	ErrRoleNotFound        = errors.New("Role not found")
+	ErrPermissionExists    = errors.New("Permission already exists")
+	ErrPermissionNotFound  = errors.New("Permission not found")
+	ErrRoleAlreadyAssigned = errors.New("Role is already assigned")
+
+	ErrOrgExists      = errors.New("Organization already exists")
+	ErrOrgNotFound    = errors.New("Organization not found")
+	ErrAlreadyMember  = errors.New("User is already a member")
+	ErrMemberNotFound = errors.New("User is not a member")
+	ErrOrgNotEmpty    = errors.New("Organization still has users or apps")
+
+	ErrInvitationNotFound = errors.New("Invitation not found")
+
+	ErrTOTPExists        = errors.New("TOTP is already enabled")
+	ErrTOTPNotFound      = errors.New("TOTP is not enrolled")
+	ErrTOTPStepUsed      = errors.New("TOTP code was already used")
+	ErrChallengeNotFound = errors.New("MFA challenge not found")
+
+	ErrCredentialExists          = errors.New("WebAuthn credential already exists")
+	ErrCredentialNotFound        = errors.New("WebAuthn credential not found")
+	ErrWebAuthnChallengeNotFound = errors.New("WebAuthn challenge not found")
+
+	ErrRecoveryCodeNotFound = errors.New("Recovery code not found or already used")
+
+	ErrLoginThrottleNotFound = errors.New("Login throttle not found")
+
+	ErrKnownDeviceNotFound = errors.New("Known device not found")
+	ErrKnownDeviceExists   = errors.New("Known device already exists")
 )
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_admins_is_admin;
+DROP INDEX IF EXISTS idx_users_status;
+DROP INDEX IF EXISTS idx_users_created_at;
+
+ALTER TABLE users DROP COLUMN created_at;
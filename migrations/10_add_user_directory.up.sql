@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN created_at TIMESTAMP;
+UPDATE users SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_status ON users (status, id);
+CREATE INDEX IF NOT EXISTS idx_admins_is_admin ON admins (is_admin, user_id);
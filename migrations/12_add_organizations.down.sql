@@ -0,0 +1,43 @@
+DELETE FROM user_roles WHERE role_id IN (SELECT id FROM roles WHERE org_id != 0);
+DELETE FROM role_permissions WHERE role_id IN (SELECT id FROM roles WHERE org_id != 0);
+
+CREATE TABLE roles_old (
+    id INTEGER PRIMARY KEY,
+    app_id INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (app_id, name)
+);
+INSERT INTO roles_old (id, app_id, name, description, created_at)
+SELECT id, app_id, name, description, created_at FROM roles WHERE org_id = 0;
+DROP TABLE roles;
+ALTER TABLE roles_old RENAME TO roles;
+
+ALTER TABLE apps DROP COLUMN org_id;
+
+CREATE TABLE users_old (
+    id INTEGER PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    password BLOB NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    deleted_at TIMESTAMP,
+    status TEXT NOT NULL DEFAULT 'active',
+    status_reason TEXT NOT NULL DEFAULT '',
+    status_changed_at TIMESTAMP,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP
+);
+INSERT INTO users_old (id, email, password, email_verified, deleted_at, status, status_reason, status_changed_at, locked_until, created_at)
+SELECT id, email, password, email_verified, deleted_at, status, status_reason, status_changed_at, locked_until, created_at FROM users;
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_status ON users (status, id);
+
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,69 @@
+-- isolated_emails makes emails unique within the organization instead of across the SSO.
+-- It is fixed when the organization is created, since it decides the email_scope of its users.
+CREATE TABLE IF NOT EXISTS organizations (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL UNIQUE,
+    isolated_emails BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (org_id, user_id),
+    FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members (user_id);
+
+-- org_id is the organization the user was registered in, 0 for none.
+-- email_scope is the organization with isolated emails the email belongs to, 0 for the shared scope.
+-- SQLite cannot drop the old UNIQUE (email) constraint in place, so the table is rebuilt.
+CREATE TABLE users_new (
+    id INTEGER PRIMARY KEY,
+    email TEXT NOT NULL,
+    password BLOB NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    deleted_at TIMESTAMP,
+    status TEXT NOT NULL DEFAULT 'active',
+    status_reason TEXT NOT NULL DEFAULT '',
+    status_changed_at TIMESTAMP,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP,
+    org_id INTEGER NOT NULL DEFAULT 0,
+    email_scope INTEGER NOT NULL DEFAULT 0,
+
+    UNIQUE (email_scope, email)
+);
+INSERT INTO users_new (id, email, password, email_verified, deleted_at, status, status_reason, status_changed_at, locked_until, created_at)
+SELECT id, email, password, email_verified, deleted_at, status, status_reason, status_changed_at, locked_until, created_at FROM users;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_status ON users (status, id);
+CREATE INDEX IF NOT EXISTS idx_users_org_id ON users (org_id, id);
+
+-- An app with org_id is only open to the members of that organization.
+ALTER TABLE apps ADD COLUMN org_id INTEGER NOT NULL DEFAULT 0;
+
+-- Roles with org_id belong to the organization and can only be assigned to its members.
+CREATE TABLE roles_new (
+    id INTEGER PRIMARY KEY,
+    org_id INTEGER NOT NULL DEFAULT 0,
+    app_id INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (org_id, app_id, name)
+);
+INSERT INTO roles_new (id, app_id, name, description, created_at)
+SELECT id, app_id, name, description, created_at FROM roles;
+DROP TABLE roles;
+ALTER TABLE roles_new RENAME TO roles;
@@ -0,0 +1,26 @@
+-- An invitation lets the holder of the emailed token join the organization and app with the roles it grants.
+-- Only the token hash is stored. accepted_by is the user who accepted it, a new or an existing one.
+CREATE TABLE IF NOT EXISTS invitations (
+    id INTEGER PRIMARY KEY,
+    token_hash BLOB NOT NULL UNIQUE,
+    inviter_id INTEGER NOT NULL,
+    email TEXT NOT NULL,
+    org_id INTEGER NOT NULL DEFAULT 0,
+    app_id INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_by INTEGER,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations (email);
+CREATE INDEX IF NOT EXISTS idx_invitations_org_id ON invitations (org_id);
+
+CREATE TABLE IF NOT EXISTS invitation_roles (
+    invitation_id INTEGER NOT NULL,
+    role_id INTEGER NOT NULL,
+
+    PRIMARY KEY (invitation_id, role_id),
+    FOREIGN KEY (invitation_id) REFERENCES invitations(id) ON DELETE CASCADE,
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+-- Usernames and E.164 phone numbers are optional login identifiers, unique within the
+-- email scope just like emails. They are stored normalized, see internal/lib/identifiers.
+ALTER TABLE users ADD COLUMN username TEXT;
+ALTER TABLE users ADD COLUMN phone TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (email_scope, username) WHERE username IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone ON users (email_scope, phone) WHERE phone IS NOT NULL;
+
+-- Emails are normalized by lowercasing their domain. Addresses that would collide
+-- with an existing one once normalized are left as they are.
+UPDATE users SET email = substr(email, 1, instr(email, '@')) || lower(substr(email, instr(email, '@') + 1))
+WHERE instr(email, '@') > 0
+    AND email != substr(email, 1, instr(email, '@')) || lower(substr(email, instr(email, '@') + 1))
+    AND NOT EXISTS (
+        SELECT 1 FROM users u
+        WHERE u.email_scope = users.email_scope
+            AND u.email = substr(users.email, 1, instr(users.email, '@')) || lower(substr(users.email, instr(users.email, '@') + 1))
+    );
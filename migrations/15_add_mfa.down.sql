@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_mfa_challenges_user_id;
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS totp_secrets;
@@ -0,0 +1,20 @@
+-- TOTP secrets are stored encrypted with mfa.encryption_key, see internal/lib/secretbox.
+-- A secret is used at login only once confirmed with a first code. last_step is the time
+-- step of the last accepted code, codes of it and earlier steps are rejected as replays.
+CREATE TABLE IF NOT EXISTS totp_secrets (
+    user_id INTEGER PRIMARY KEY,
+    secret BLOB NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_step INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A challenge is a login that passed the password check and waits for the second factor.
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    app_id INTEGER NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_mfa_challenges_user_id ON mfa_challenges (user_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_webauthn_challenges_user_id;
+DROP TABLE IF EXISTS webauthn_challenges;
+DROP INDEX IF EXISTS idx_webauthn_credentials_user_id;
+DROP TABLE IF EXISTS webauthn_credentials;
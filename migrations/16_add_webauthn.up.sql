@@ -0,0 +1,26 @@
+-- WebAuthn credentials (passkeys and security keys). public_key is the COSE_Key the
+-- credential signs with, sign_count the last value of its signature counter.
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id BLOB PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    public_key BLOB NOT NULL,
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    aaguid BLOB,
+    attestation_format TEXT NOT NULL,
+    transports TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+-- A challenge of a started ceremony, keyed by its base64url value from the client data.
+-- user_id is 0 for passwordless logins, the user is known only from the credential.
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+    challenge TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    app_id INTEGER NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webauthn_challenges_user_id ON webauthn_challenges (user_id);
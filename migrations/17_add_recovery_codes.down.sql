@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_recovery_codes_user_id;
+DROP TABLE IF EXISTS recovery_codes;
@@ -0,0 +1,11 @@
+-- Recovery codes finish an MFA challenge when the user has lost the other second factors.
+-- Each code works once, used_at records when it was used. Only SHA-256 hashes are stored,
+-- the codes are random enough that a fast hash does not make them guessable.
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    code_hash BLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
@@ -0,0 +1,9 @@
+-- Failed logins counted per account identifier and per client IP, see auth.LoginThrottle.
+-- The key is "account:<email_scope>:<identifier>" or "ip:<address>". failures start over once
+-- last_failure_at is older than login_throttle.reset_after, blocked_until is set after too many.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    blocked_until TIMESTAMP
+);
@@ -0,0 +1,25 @@
+-- Security events of the users and the admins, see models.AuditEvent. The log is append-only:
+-- rows are never updated, they are only deleted once older than audit.retention.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL,
+    type TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    actor_id INTEGER NOT NULL DEFAULT 0,
+    target_user_id INTEGER NOT NULL DEFAULT 0,
+    target TEXT NOT NULL DEFAULT '',
+    app_id INTEGER NOT NULL DEFAULT 0,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target_user_id ON audit_log (target_user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_app_id ON audit_log (app_id);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_append_only
+BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
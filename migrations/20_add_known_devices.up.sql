@@ -0,0 +1,22 @@
+-- Devices the users logged in from, see auth.Auth.checkDevice. A device is the combination of
+-- the device ID the client sent, the user agent and the network of the client IP, a login
+-- from a new combination is reported to the user. The device ID and the user agent are hashed.
+-- revoke_token_hash is the "this wasn't me" token of the login that added the device, it revokes
+-- the session session_id of that login.
+CREATE TABLE IF NOT EXISTS known_devices (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    device_id_hash BLOB NOT NULL,
+    user_agent_hash BLOB NOT NULL,
+    ip_prefix TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    last_ip TEXT NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    session_id TEXT NOT NULL DEFAULT '',
+    revoke_token_hash BLOB,
+    revoke_expires_at TIMESTAMP,
+    UNIQUE (user_id, device_id_hash, user_agent_hash, ip_prefix)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_known_devices_revoke_token_hash ON known_devices (revoke_token_hash);
+CREATE INDEX IF NOT EXISTS idx_known_devices_last_seen_at ON known_devices (last_seen_at);
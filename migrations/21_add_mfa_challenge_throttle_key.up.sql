@@ -0,0 +1,3 @@
+-- The login_throttles account key of the login that started the challenge, wrong MFA codes
+-- are counted on it so that restarting the login does not give fresh attempts.
+ALTER TABLE mfa_challenges ADD COLUMN throttle_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS audit_log_append_only;
+CREATE TRIGGER IF NOT EXISTS audit_log_append_only
+BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
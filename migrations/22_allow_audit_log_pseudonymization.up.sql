@@ -0,0 +1,19 @@
+-- The only update allowed on audit_log is the pseudonymization of a purged user: the user IDs
+-- are negated and the target is replaced by the placeholder email of the deleted user.
+DROP TRIGGER IF EXISTS audit_log_append_only;
+CREATE TRIGGER IF NOT EXISTS audit_log_append_only
+BEFORE UPDATE ON audit_log
+WHEN NEW.id IS NOT OLD.id
+    OR NEW.created_at IS NOT OLD.created_at
+    OR NEW.type IS NOT OLD.type
+    OR NEW.outcome IS NOT OLD.outcome
+    OR NEW.app_id IS NOT OLD.app_id
+    OR NEW.ip IS NOT OLD.ip
+    OR NEW.user_agent IS NOT OLD.user_agent
+    OR NEW.details IS NOT OLD.details
+    OR NEW.actor_id NOT IN (OLD.actor_id, -abs(OLD.actor_id))
+    OR NEW.target_user_id NOT IN (OLD.target_user_id, -abs(OLD.target_user_id))
+    OR (NEW.target IS NOT OLD.target AND NEW.target NOT LIKE 'deleted-%@deleted.invalid')
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
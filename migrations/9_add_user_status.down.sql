@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN locked_until;
+ALTER TABLE users DROP COLUMN status_changed_at;
+ALTER TABLE users DROP COLUMN status_reason;
+ALTER TABLE users DROP COLUMN status;
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_changed_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN locked_until TIMESTAMP;
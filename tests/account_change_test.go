@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"context"
+	"sso/tests/suite"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChangePassword_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	current := login(ctx, st, email, password)
+	other := login(ctx, st, email, password)
+
+	newPassword := gofakeit.Password(true, true, true, true, true, passwordDefaultLen)
+	_, err := st.AuthClient.ChangePassword(suite.WithToken(ctx, current), &ssov1.ChangePasswordRequest{
+		OldPassword: password,
+		NewPassword: newPassword,
+	})
+	require.NoError(t, err)
+
+	// The other session is revoked, the current one keeps working.
+	_, err = st.AuthClient.ChangePassword(suite.WithToken(ctx, other), &ssov1.ChangePasswordRequest{
+		OldPassword: newPassword,
+		NewPassword: password,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.NotEmpty(t, login(ctx, st, email, newPassword))
+}
+
+func TestChangePassword_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+
+	_, err := st.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+		OldPassword: password,
+		NewPassword: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.ChangePassword(suite.WithToken(ctx, token), &ssov1.ChangePasswordRequest{
+		OldPassword: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+		NewPassword: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid password")
+}
+
+func TestChangePassword_Throttled(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	authCtx := suite.WithToken(ctx, login(ctx, st, email, password))
+
+	for i := 0; i <= st.Config.LoginThrottle.AccountFreeAttempts; i++ {
+		_, err := st.AuthClient.ChangePassword(authCtx, &ssov1.ChangePasswordRequest{
+			OldPassword: "wrong-password",
+			NewPassword: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+		})
+		require.Error(t, err)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), "attempt %d", i+1)
+	}
+
+	// Wrong old passwords block the account for the right one and for logins alike.
+	_, err := st.AuthClient.ChangePassword(authCtx, &ssov1.ChangePasswordRequest{
+		OldPassword: password,
+		NewPassword: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestChangeEmail_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+	authCtx := suite.WithToken(ctx, token)
+
+	newEmail := gofakeit.Email()
+	_, err := st.AuthClient.ChangeEmail(authCtx, &ssov1.ChangeEmailRequest{
+		NewEmail: newEmail,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	// Nothing changes until the new address is confirmed.
+	assert.NotEmpty(t, login(ctx, st, email, password))
+
+	_, err = st.AuthClient.ConfirmEmailChange(authCtx, &ssov1.ConfirmEmailChangeRequest{
+		Token: mailToken(st, newEmail),
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, st.LastMail(email), newEmail)
+	assert.NotEmpty(t, login(ctx, st, newEmail, password))
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid credentials")
+}
+
+func TestConfirmEmailChange_OtherUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	authCtx := suite.WithToken(ctx, login(ctx, st, email, password))
+	otherEmail, otherPassword := registerUser(ctx, st)
+	otherCtx := suite.WithToken(ctx, login(ctx, st, otherEmail, otherPassword))
+
+	newEmail := gofakeit.Email()
+	_, err := st.AuthClient.ChangeEmail(authCtx, &ssov1.ChangeEmailRequest{
+		NewEmail: newEmail,
+		Password: password,
+	})
+	require.NoError(t, err)
+	token := mailToken(st, newEmail)
+
+	_, err = st.AuthClient.ConfirmEmailChange(otherCtx, &ssov1.ConfirmEmailChangeRequest{Token: token})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The token is left for its owner.
+	_, err = st.AuthClient.ConfirmEmailChange(authCtx, &ssov1.ConfirmEmailChangeRequest{Token: token})
+	require.NoError(t, err)
+	assert.NotEmpty(t, login(ctx, st, newEmail, password))
+}
+
+func TestChangeEmail_EmailTaken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	takenEmail, _ := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+
+	_, err := st.AuthClient.ChangeEmail(suite.WithToken(ctx, token), &ssov1.ChangeEmailRequest{
+		NewEmail: takenEmail,
+		Password: password,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func registerUser(ctx context.Context, st *suite.Suite) (string, string) {
+	st.Helper()
+
+	email, password := randomCredentials()
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(st, err)
+
+	return email, password
+}
+
+func login(ctx context.Context, st *suite.Suite, email string, password string) string {
+	st.Helper()
+
+	res, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(st, err)
+
+	return res.GetToken()
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"encoding/json"
+	"sso/tests/suite"
+	"testing"
+	"time"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExportUserData_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+
+	resp, err := st.AuthClient.ExportUserData(suite.WithToken(ctx, token), &ssov1.ExportUserDataRequest{})
+	require.NoError(t, err)
+
+	var export struct {
+		User struct {
+			ID    int64  `json:"id"`
+			Email string `json:"email"`
+		} `json:"user"`
+		Sessions []struct {
+			AppID int `json:"app_id"`
+		} `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(resp.GetData(), &export))
+	assert.Equal(t, email, export.User.Email)
+	require.Len(t, export.Sessions, 1)
+	assert.Equal(t, appID, int32(export.Sessions[0].AppID))
+	assert.NotContains(t, string(resp.GetData()), "\"password\"")
+}
+
+func TestDeleteAccount_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+	authCtx := suite.WithToken(ctx, token)
+
+	resp, err := st.AuthClient.DeleteAccount(authCtx, &ssov1.DeleteAccountRequest{Password: password})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, resp.GetPurgeAt(), time.Now().Unix())
+
+	// The session is revoked and the user can't log in anymore.
+	_, err = st.AuthClient.ExportUserData(authCtx, &ssov1.ExportUserDataRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid credentials")
+}
+
+func TestDeleteAccount_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+	authCtx := suite.WithToken(ctx, token)
+
+	otherEmail, otherPassword := registerUser(ctx, st)
+	other := login(ctx, st, otherEmail, otherPassword)
+
+	_, err := st.AuthClient.DeleteAccount(ctx, &ssov1.DeleteAccountRequest{Password: password})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.DeleteAccount(authCtx, &ssov1.DeleteAccountRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AuthClient.DeleteAccount(authCtx, &ssov1.DeleteAccountRequest{Password: otherPassword})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid password")
+
+	// Only admins can delete or export other accounts.
+	otherResp, err := st.AuthClient.ExportUserData(suite.WithToken(ctx, other), &ssov1.ExportUserDataRequest{})
+	require.NoError(t, err)
+	var export struct {
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(otherResp.GetData(), &export))
+
+	_, err = st.AuthClient.DeleteAccount(authCtx, &ssov1.DeleteAccountRequest{UserId: export.User.ID})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.AuthClient.ExportUserData(authCtx, &ssov1.ExportUserDataRequest{UserId: export.User.ID})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"sso/tests/suite"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/golang-jwt/jwt"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func randomRBACName(prefix string) string {
+	return prefix + "-" + strings.ToLower(gofakeit.LetterN(10))
+}
+
+func TestRBAC_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	role, err := st.AdminClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+		AppId: appID,
+		Name:  randomRBACName("billing-viewer"),
+	})
+	require.NoError(t, err)
+	permissionName := randomRBACName("invoices") + ":read"
+	permission, err := st.AdminClient.CreatePermission(adminCtx, &ssov1.CreatePermissionRequest{
+		AppId: appID,
+		Name:  permissionName,
+	})
+	require.NoError(t, err)
+
+	_, err = st.AdminClient.GrantPermission(adminCtx, &ssov1.GrantPermissionRequest{
+		RoleId:       role.GetRole().GetId(),
+		PermissionId: permission.GetPermission().GetId(),
+	})
+	require.NoError(t, err)
+
+	userID, email, password := registerUserWithID(ctx, st)
+	_, err = st.AdminClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{
+		UserId: userID,
+		RoleId: role.GetRole().GetId(),
+		AppId:  appID,
+	})
+	require.NoError(t, err)
+
+	has, err := st.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		UserId:     userID,
+		AppId:      appID,
+		Permission: permissionName,
+	})
+	require.NoError(t, err)
+	assert.True(t, has.GetHasPermission())
+
+	// The role is scoped to its app.
+	has, err = st.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		UserId:     userID,
+		AppId:      verifiedAppID,
+		Permission: permissionName,
+	})
+	require.NoError(t, err)
+	assert.False(t, has.GetHasPermission())
+
+	tokenParsed, err := jwt.Parse(login(ctx, st, email, password), func(token *jwt.Token) (interface{}, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+	claims := tokenParsed.Claims.(jwt.MapClaims)
+	assert.Equal(t, []any{role.GetRole().GetName()}, claims["roles"])
+
+	roles, err := st.AdminClient.ListUserRoles(adminCtx, &ssov1.ListUserRolesRequest{UserId: userID})
+	require.NoError(t, err)
+	require.Len(t, roles.GetAssignments(), 1)
+	assert.Equal(t, appID, roles.GetAssignments()[0].GetAppId())
+
+	_, err = st.AdminClient.DeleteRole(adminCtx, &ssov1.DeleteRoleRequest{RoleId: role.GetRole().GetId()})
+	require.NoError(t, err)
+
+	has, err = st.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		UserId:     userID,
+		AppId:      appID,
+		Permission: permissionName,
+	})
+	require.NoError(t, err)
+	assert.False(t, has.GetHasPermission())
+}
+
+func TestRBAC_IsAdminShim(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, _, _ := registerUserWithID(ctx, st)
+
+	isAdmin, err := st.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.False(t, isAdmin.GetIsAdmin())
+
+	roles, err := st.AdminClient.ListRoles(adminCtx, &ssov1.ListRolesRequest{})
+	require.NoError(t, err)
+	var adminRoleID int64
+	for _, role := range roles.GetRoles() {
+		if role.GetName() == "admin" && role.GetAppId() == 0 {
+			adminRoleID = role.GetId()
+		}
+	}
+	require.NotZero(t, adminRoleID)
+
+	_, err = st.AdminClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{UserId: userID, RoleId: adminRoleID})
+	require.NoError(t, err)
+
+	isAdmin, err = st.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.True(t, isAdmin.GetIsAdmin())
+
+	_, err = st.AdminClient.DeleteRole(adminCtx, &ssov1.DeleteRoleRequest{RoleId: adminRoleID})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestRBAC_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	name := randomRBACName("editor")
+	role, err := st.AdminClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{AppId: appID, Name: name})
+	require.NoError(t, err)
+
+	_, err = st.AdminClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{AppId: appID, Name: name})
+	require.Error(t, err)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = st.AdminClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{AppId: appID, Name: "Not A Name"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	userID, _, _ := registerUserWithID(ctx, st)
+	_, err = st.AdminClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{
+		UserId: userID,
+		RoleId: role.GetRole().GetId(),
+		AppId:  verifiedAppID,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AdminClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{
+		UserId: 1 << 40,
+		RoleId: role.GetRole().GetId(),
+		AppId:  appID,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
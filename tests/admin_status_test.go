@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"context"
+	"sso/tests/suite"
+	"testing"
+	"time"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The admin is created by tests/migrations/3_add_admin.up.sql.
+const (
+	adminEmail    = "admin@sso.test"
+	adminPassword = "admin-password"
+)
+
+func adminContext(ctx context.Context, st *suite.Suite) context.Context {
+	st.Helper()
+
+	return suite.WithToken(ctx, login(ctx, st, adminEmail, adminPassword))
+}
+
+func registerUserWithID(ctx context.Context, st *suite.Suite) (int64, string, string) {
+	st.Helper()
+
+	email, password := randomCredentials()
+	res, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(st, err)
+
+	return res.GetUserId(), email, password
+}
+
+func TestSetUserStatus_Disable(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, password := registerUserWithID(ctx, st)
+	userCtx := suite.WithToken(ctx, login(ctx, st, email, password))
+
+	_, err := st.AdminClient.SetUserStatus(adminCtx, &ssov1.SetUserStatusRequest{
+		UserId: userID,
+		Status: "disabled",
+		Reason: "Left the company",
+	})
+	require.NoError(t, err)
+
+	// The sessions are revoked and new logins are refused.
+	_, err = st.AuthClient.GetProfile(userCtx, &ssov1.GetProfileRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.AdminClient.SetUserStatus(adminCtx, &ssov1.SetUserStatusRequest{UserId: userID, Status: "active"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, login(ctx, st, email, password))
+}
+
+func TestSetUserStatus_LockExpires(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, password := registerUserWithID(ctx, st)
+
+	_, err := st.AdminClient.SetUserStatus(adminCtx, &ssov1.SetUserStatusRequest{
+		UserId:      userID,
+		Status:      "locked",
+		LockedUntil: time.Now().Add(-time.Second).Unix(),
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, login(ctx, st, email, password))
+
+	_, err = st.AdminClient.SetUserStatus(adminCtx, &ssov1.SetUserStatusRequest{UserId: userID, Status: "locked"})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestSetUserStatus_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, password := registerUserWithID(ctx, st)
+
+	_, err := st.AdminClient.SetUserStatus(
+		suite.WithToken(ctx, login(ctx, st, email, password)),
+		&ssov1.SetUserStatusRequest{UserId: userID, Status: "active"},
+	)
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.AdminClient.SetUserStatus(adminCtx, &ssov1.SetUserStatusRequest{UserId: userID, Status: "banned"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AdminClient.SetUserStatus(adminCtx, &ssov1.SetUserStatusRequest{UserId: 1 << 40, Status: "disabled"})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"sso/tests/suite"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetUser_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, _ := registerUserWithID(ctx, st)
+
+	resp, err := st.AdminClient.GetUser(adminCtx, &ssov1.GetUserRequest{UserId: userID})
+	require.NoError(t, err)
+
+	user := resp.GetUser()
+	assert.Equal(t, email, user.GetEmail())
+	assert.Equal(t, "active", user.GetStatus())
+	assert.False(t, user.GetIsAdmin())
+	assert.NotZero(t, user.GetCreatedAt())
+}
+
+func TestListUsers_Pagination(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	// A prefix unique to this test keeps users of parallel tests out of the listing.
+	prefix := strings.ToLower(gofakeit.LetterN(12))
+	var registered []string
+	for range 5 {
+		email := prefix + "." + gofakeit.Email()
+		_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+			Email:    email,
+			Password: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+		})
+		require.NoError(t, err)
+		registered = append(registered, email)
+	}
+
+	var listed []string
+	pageToken := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+
+		resp, err := st.AdminClient.ListUsers(adminCtx, &ssov1.ListUsersRequest{
+			PageSize:    2,
+			PageToken:   pageToken,
+			EmailPrefix: prefix,
+		})
+		require.NoError(t, err)
+		for _, user := range resp.GetUsers() {
+			listed = append(listed, user.GetEmail())
+		}
+
+		pageToken = resp.GetNextPageToken()
+		if pageToken == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, registered, listed)
+}
+
+func TestListUsers_Filters(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	isAdmin := true
+	resp, err := st.AdminClient.ListUsers(adminCtx, &ssov1.ListUsersRequest{IsAdmin: &isAdmin})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.GetUsers())
+	for _, user := range resp.GetUsers() {
+		assert.True(t, user.GetIsAdmin())
+	}
+
+	userID, _, _ := registerUserWithID(ctx, st)
+	_, err = st.AdminClient.SetUserStatus(adminCtx, &ssov1.SetUserStatusRequest{UserId: userID, Status: "disabled"})
+	require.NoError(t, err)
+
+	resp, err = st.AdminClient.ListUsers(adminCtx, &ssov1.ListUsersRequest{Status: "disabled", PageSize: 500})
+	require.NoError(t, err)
+	var ids []int64
+	for _, user := range resp.GetUsers() {
+		assert.Equal(t, "disabled", user.GetStatus())
+		ids = append(ids, user.GetId())
+	}
+	assert.Contains(t, ids, userID)
+}
+
+func TestSearchUsers_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	_, email, password := registerUserWithID(ctx, st)
+	name := gofakeit.LetterN(16)
+	_, err := st.AuthClient.UpdateProfile(
+		suite.WithToken(ctx, login(ctx, st, email, password)),
+		&ssov1.UpdateProfileRequest{Profile: &ssov1.Profile{DisplayName: "Dr " + name}},
+	)
+	require.NoError(t, err)
+
+	resp, err := st.AdminClient.SearchUsers(adminCtx, &ssov1.SearchUsersRequest{Query: name})
+	require.NoError(t, err)
+	require.Len(t, resp.GetUsers(), 1)
+	assert.Equal(t, email, resp.GetUsers()[0].GetEmail())
+}
+
+func TestAdminService_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	_, err := st.AdminClient.ListUsers(ctx, &ssov1.ListUsersRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	email, password := registerUser(ctx, st)
+	_, err = st.AdminClient.ListUsers(suite.WithToken(ctx, login(ctx, st, email, password)), &ssov1.ListUsersRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.AdminClient.ListUsers(adminCtx, &ssov1.ListUsersRequest{PageToken: "not a token"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AdminClient.GetUser(adminCtx, &ssov1.GetUserRequest{UserId: 1 << 40})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"sso/tests/suite"
+	"testing"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQueryAuditLog_Logins(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, password := registerUserWithID(ctx, st)
+	_, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: "wrong-password", AppId: appID})
+	require.Error(t, err)
+	login(ctx, st, email, password)
+
+	res, err := st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{TargetUserId: userID})
+	require.NoError(t, err)
+	require.Len(t, res.GetEvents(), 2)
+
+	loggedIn, registered := res.GetEvents()[0], res.GetEvents()[1]
+	assert.Equal(t, "login", loggedIn.GetType(), "newest first")
+	assert.Equal(t, "success", loggedIn.GetOutcome())
+	assert.Equal(t, userID, loggedIn.GetActorId())
+	assert.Equal(t, appID, loggedIn.GetAppId())
+	assert.NotEmpty(t, loggedIn.GetIp())
+	assert.Contains(t, loggedIn.GetUserAgent(), "grpc-go")
+	assert.Equal(t, "registration", registered.GetType())
+
+	// The user of a failed login is not known, the login identifier is.
+	res, err = st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{
+		Type:     "login",
+		Outcome:  "failure",
+		Since:    registered.GetTime(),
+		PageSize: 500,
+	})
+	require.NoError(t, err)
+	found := false
+	for _, event := range res.GetEvents() {
+		found = found || event.GetTarget() == email
+	}
+	assert.True(t, found, "failed login is recorded")
+}
+
+func TestQueryAuditLog_AdminActions(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, _, _ := registerUserWithID(ctx, st)
+	_, err := st.AdminClient.SetUserStatus(adminCtx, &ssov1.SetUserStatusRequest{UserId: userID, Status: "disabled"})
+	require.NoError(t, err)
+
+	res, err := st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{TargetUserId: userID, Type: "admin_action"})
+	require.NoError(t, err)
+	require.Len(t, res.GetEvents(), 1)
+	assert.Equal(t, "SetUserStatus", res.GetEvents()[0].GetDetails())
+	assert.NotZero(t, res.GetEvents()[0].GetActorId())
+
+	res, err = st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{TargetUserId: userID, Type: "session_revocation"})
+	require.NoError(t, err)
+	assert.Len(t, res.GetEvents(), 1)
+}
+
+func TestQueryAuditLog_Pagination(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, password := registerUserWithID(ctx, st)
+	login(ctx, st, email, password)
+
+	first, err := st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{TargetUserId: userID, PageSize: 1})
+	require.NoError(t, err)
+	require.Len(t, first.GetEvents(), 1)
+	require.NotEmpty(t, first.GetNextPageToken())
+
+	second, err := st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{
+		TargetUserId: userID,
+		PageSize:     1,
+		PageToken:    first.GetNextPageToken(),
+	})
+	require.NoError(t, err)
+	require.Len(t, second.GetEvents(), 1)
+	assert.Less(t, second.GetEvents()[0].GetId(), first.GetEvents()[0].GetId())
+	assert.Empty(t, second.GetNextPageToken())
+}
+
+func TestQueryAuditLog_InvalidFilter(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	_, err := st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{Outcome: "maybe"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{PageToken: "not a token"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
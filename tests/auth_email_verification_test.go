@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"regexp"
+	"sso/tests/suite"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const verifiedAppID int32 = 2
+
+var mailTokenRe = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+func TestVerifyEmail_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := randomCredentials()
+	resRegister, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    verifiedAppID,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = st.AuthClient.SendVerification(ctx, &ssov1.SendVerificationRequest{Email: email})
+	require.NoError(t, err)
+
+	token := waitMailToken(st, email, "Confirm your email")
+
+	resVerify, err := st.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: token})
+	require.NoError(t, err)
+	assert.Equal(t, resRegister.GetUserId(), resVerify.GetUserId())
+
+	resLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    verifiedAppID,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resLogin.GetToken())
+
+	_, err = st.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: token})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid or expired token")
+}
+
+func TestVerifyEmail_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AuthClient.SendVerification(ctx, &ssov1.SendVerificationRequest{Email: gofakeit.Email()})
+	require.NoError(t, err, "unknown emails must not be revealed")
+
+	_, err = st.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: ""})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Field 'Token' is required")
+
+	_, err = st.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: gofakeit.UUID()})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid or expired token")
+}
+
+func mailToken(st *suite.Suite, email string) string {
+	st.Helper()
+
+	match := mailTokenRe.FindStringSubmatch(st.LastMail(email))
+	require.Len(st, match, 2, "no token in mail")
+
+	return match[1]
+}
+
+// waitMailToken is mailToken for the mails sent in the background: it waits for the mail with the subject.
+func waitMailToken(st *suite.Suite, email string, subject string) string {
+	st.Helper()
+
+	match := mailTokenRe.FindStringSubmatch(st.WaitMail(email, subject))
+	require.Len(st, match, 2, "no token in mail")
+
+	return match[1]
+}
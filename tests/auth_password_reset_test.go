@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"sso/tests/suite"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPassword_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := randomCredentials()
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+
+	token := waitMailToken(st, email, "Reset your password")
+	newPassword := gofakeit.Password(true, true, true, true, true, passwordDefaultLen)
+
+	_, err = st.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{
+		Token:    token,
+		Password: newPassword,
+	})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid credentials")
+
+	resLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: newPassword,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resLogin.GetToken())
+
+	_, err = st.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{
+		Token:    token,
+		Password: newPassword,
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid or expired token")
+}
+
+func TestResetPassword_AfterEmailChange(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	_, err := st.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+	resetToken := waitMailToken(st, email, "Reset your password")
+
+	authCtx := suite.WithToken(ctx, login(ctx, st, email, password))
+	newEmail := gofakeit.Email()
+	_, err = st.AuthClient.ChangeEmail(authCtx, &ssov1.ChangeEmailRequest{NewEmail: newEmail, Password: password})
+	require.NoError(t, err)
+	_, err = st.AuthClient.ConfirmEmailChange(authCtx, &ssov1.ConfirmEmailChangeRequest{Token: mailToken(st, newEmail)})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{
+		Token:    resetToken,
+		Password: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+	})
+	require.Error(t, err, "the link sent to the former email no longer works")
+	assert.ErrorContains(t, err, "Invalid or expired token")
+	assert.NotEmpty(t, login(ctx, st, newEmail, password))
+}
+
+func TestResetPassword_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: gofakeit.Email()})
+	require.NoError(t, err, "unknown emails must not be revealed")
+
+	cases := []struct {
+		name     string
+		token    string
+		password string
+		want     string
+	}{
+		{
+			name:     "Empty token",
+			token:    "",
+			password: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+			want:     "Field 'Token' is required",
+		},
+		{
+			name:     "Unknown token",
+			token:    gofakeit.UUID(),
+			password: gofakeit.Password(true, true, true, true, true, passwordDefaultLen),
+			want:     "Invalid or expired token",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := st.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{
+				Token:    c.token,
+				Password: c.password,
+			})
+			require.Error(t, err)
+			assert.ErrorContains(t, err, c.want)
+		})
+	}
+}
@@ -109,12 +109,6 @@ func TestRegister_FailCases(t *testing.T) {
 			password: "",
 			want:     "Field 'Email' is required",
 		},
-		{
-			name:     "Short password",
-			email:    gofakeit.Email(),
-			password: gofakeit.Password(true, true, true, true, true, 7),
-			want:     "Field 'Password' require minimum 8 characters",
-		},
 		{
 			name:     "Invalid Email",
 			email:    gofakeit.Word(),
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"fmt"
+	"sso/tests/suite"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func randomUsername() string {
+	return fmt.Sprintf("user_%d", gofakeit.Uint32())
+}
+
+func randomPhone() string {
+	return fmt.Sprintf("+7 (9%02d) %03d-%02d-%02d", gofakeit.Number(0, 99), gofakeit.Number(0, 999), gofakeit.Number(0, 99), gofakeit.Number(0, 99))
+}
+
+func TestIdentifiers_Login(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := randomCredentials()
+	username := randomUsername()
+	phone := randomPhone()
+
+	res, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+		Username: username,
+		Phone:    phone,
+	})
+	require.NoError(t, err)
+
+	for _, identifier := range []string{email, username, phone} {
+		token, err := loginApp(ctx, st, identifier, password, appID)
+		require.NoError(t, err, identifier)
+		assert.Equal(t, res.GetUserId(), int64(tokenClaims(t, token, appSecret)["userID"].(float64)))
+	}
+
+	_, err = loginApp(ctx, st, username, password, verifiedAppID)
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "the app only allows email logins")
+}
+
+func TestIdentifiers_Update(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	userCtx := suite.WithToken(ctx, login(ctx, st, email, password))
+
+	username := randomUsername()
+	_, err := st.AuthClient.UpdateIdentifiers(userCtx, &ssov1.UpdateIdentifiersRequest{Username: username})
+	require.NoError(t, err)
+
+	_, err = loginApp(ctx, st, username, password, appID)
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.UpdateIdentifiers(userCtx, &ssov1.UpdateIdentifiersRequest{})
+	require.NoError(t, err)
+
+	_, err = loginApp(ctx, st, username, password, appID)
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestIdentifiers_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	username := randomUsername()
+	phone := randomPhone()
+	email, password := randomCredentials()
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password, Username: username, Phone: phone})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		username string
+		phone    string
+		code     codes.Code
+	}{
+		{name: "Username taken", username: username, code: codes.AlreadyExists},
+		{name: "Phone taken", phone: phone, code: codes.AlreadyExists},
+		{name: "Invalid username", username: "1user", code: codes.InvalidArgument},
+		{name: "Invalid phone", phone: "12345", code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email, password := randomCredentials()
+			_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+				Email:    email,
+				Password: password,
+				Username: tt.username,
+				Phone:    tt.phone,
+			})
+			require.Error(t, err)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
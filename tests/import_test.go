@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sso/tests/suite"
+	"strings"
+	"testing"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func pbkdf2Hash(password string) string {
+	salt := []byte("import-salt")
+	key := pbkdf2.Key([]byte(password), salt, 1000, 32, sha256.New)
+
+	return fmt.Sprintf("$pbkdf2-sha256$i=1000$%s$%s",
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func saltedSHAHash(password string) string {
+	sum := sha256.Sum256([]byte("salt" + password))
+	return "$sha256$salt$" + hex.EncodeToString(sum[:])
+}
+
+func importUsers(ctx context.Context, st *suite.Suite, format string, chunks ...string) (*ssov1.ImportUsersResponse, error) {
+	st.Helper()
+
+	stream, err := st.AdminClient.ImportUsers(adminContext(ctx, st))
+	require.NoError(st, err)
+	for i, chunk := range chunks {
+		req := &ssov1.ImportUsersRequest{Data: []byte(chunk)}
+		if i == 0 {
+			req.Format = format
+		}
+		// A failed send means the server has ended the call, CloseAndRecv returns why.
+		if err := stream.Send(req); err != nil {
+			break
+		}
+	}
+
+	return stream.CloseAndRecv()
+}
+
+func TestImportUsers_ForeignHashes(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	pbkdf2Email, pbkdf2Password := randomCredentials()
+	shaEmail, shaPassword := randomCredentials()
+	plainEmail, plainPassword := randomCredentials()
+	takenEmail, _ := registerUser(ctx, st)
+
+	csv := strings.Join([]string{
+		"email,password,password_hash,hash_type",
+		fmt.Sprintf("%s,,%s,pbkdf2", pbkdf2Email, pbkdf2Hash(pbkdf2Password)),
+		fmt.Sprintf("%s,,%s,sha", shaEmail, saltedSHAHash(shaPassword)),
+		fmt.Sprintf("%s,%s,,", plainEmail, plainPassword),
+		fmt.Sprintf("%s,%s,,", takenEmail, plainPassword),
+		fmt.Sprintf("%s,,%s,scrypt", plainEmail, pbkdf2Hash(plainPassword)),
+	}, "\n")
+
+	// The records are split between messages anywhere, even inside a line.
+	res, err := importUsers(ctx, st, "csv", csv[:50], csv[50:])
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), res.GetImported())
+	assert.Equal(t, int64(2), res.GetFailed())
+	require.Len(t, res.GetErrors(), 2)
+	assert.Equal(t, int64(5), res.GetErrors()[0].GetLine())
+	assert.Equal(t, takenEmail, res.GetErrors()[0].GetEmail())
+	assert.Equal(t, int64(6), res.GetErrors()[1].GetLine())
+
+	for email, password := range map[string]string{
+		pbkdf2Email: pbkdf2Password,
+		shaEmail:    shaPassword,
+		plainEmail:  plainPassword,
+	} {
+		_, err := loginApp(ctx, st, email, password, appID)
+		require.NoError(t, err, email)
+		// The second login checks the hash the first one upgraded to.
+		_, err = loginApp(ctx, st, email, password, appID)
+		require.NoError(t, err, email)
+
+		_, err = loginApp(ctx, st, email, "wrong-password", appID)
+		require.Error(t, err)
+	}
+}
+
+func TestImportUsers_JSONL(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := randomCredentials()
+	jsonl := fmt.Sprintf(`{"email": %q, "password_hash": %q, "hash_type": "sha", "email_verified": true}`+"\n",
+		email, saltedSHAHash(password))
+
+	res, err := importUsers(ctx, st, "jsonl", jsonl)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.GetImported())
+
+	_, err = loginApp(ctx, st, email, password, verifiedAppID)
+	require.NoError(t, err, "imported emails can be verified already")
+}
+
+func TestImportUsers_ShortLegacyPassword(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, _ := randomCredentials()
+	const password = "qwe123"
+	csv := fmt.Sprintf("email,password_hash,hash_type\n%s,%s,sha\n", email, saltedSHAHash(password))
+
+	res, err := importUsers(ctx, st, "csv", csv)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.GetImported())
+
+	_, err = loginApp(ctx, st, email, password, appID)
+	require.NoError(t, err, "passwords shorter than the policy allows still log in")
+}
+
+func TestImportUsers_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := importUsers(ctx, st, "xml", "<users/>")
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = importUsers(ctx, st, "csv", "email,role\nalice@example.com,admin\n")
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	email, password := registerUser(ctx, st)
+	stream, err := st.AdminClient.ImportUsers(suite.WithToken(ctx, login(ctx, st, email, password)))
+	require.NoError(t, err)
+	_ = stream.Send(&ssov1.ImportUsersRequest{Format: "csv", Data: []byte("email\n")})
+	_, err = stream.CloseAndRecv()
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"sso/tests/suite"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestInvitations_NewUser(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	role, err := st.AdminClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+		OrgId: sharedOrgID,
+		Name:  randomRBACName("invited"),
+	})
+	require.NoError(t, err)
+
+	email, password := randomCredentials()
+	inv, err := st.AdminClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{
+		Email:   email,
+		AppId:   sharedOrgAppID,
+		RoleIds: []int64{role.GetRole().GetId()},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sharedOrgID, inv.GetInvitation().GetOrgId(), "the organization comes from the app")
+	assert.Equal(t, "pending", inv.GetInvitation().GetStatus())
+
+	token := mailToken(st, email)
+
+	res, err := st.AuthClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: token, Password: password})
+	require.NoError(t, err)
+	assert.NotZero(t, res.GetUserId())
+
+	loginToken, err := loginApp(ctx, st, email, password, sharedOrgAppID)
+	require.NoError(t, err)
+	assert.Equal(t, []any{role.GetRole().GetName()}, tokenClaims(t, loginToken, sharedOrgSecret)["roles"])
+
+	_, err = st.AuthClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: token, Password: password})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	list, err := st.AdminClient.ListInvitations(adminCtx, &ssov1.ListInvitationsRequest{Email: email})
+	require.NoError(t, err)
+	require.Len(t, list.GetInvitations(), 1)
+	assert.Equal(t, "accepted", list.GetInvitations()[0].GetStatus())
+	assert.Equal(t, res.GetUserId(), list.GetInvitations()[0].GetAcceptedBy())
+}
+
+func TestInvitations_ExistingUser(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, password := registerUserWithID(ctx, st)
+
+	_, err := st.AdminClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{Email: email, OrgId: sharedOrgID})
+	require.NoError(t, err)
+	token := mailToken(st, email)
+
+	_, err = st.AuthClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: token, Password: password})
+	require.Error(t, err)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	userCtx := suite.WithToken(ctx, login(ctx, st, email, password))
+	_, err = st.AuthClient.AcceptInvitation(userCtx, &ssov1.AcceptInvitationRequest{Token: token})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "the email must be verified")
+
+	otherEmail, otherPassword := registerUser(ctx, st)
+	verifyEmail(ctx, st, otherEmail)
+	otherCtx := suite.WithToken(ctx, login(ctx, st, otherEmail, otherPassword))
+	_, err = st.AuthClient.AcceptInvitation(otherCtx, &ssov1.AcceptInvitationRequest{Token: token})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "the invitation is for another email")
+
+	verifyEmail(ctx, st, email)
+	res, err := st.AuthClient.AcceptInvitation(userCtx, &ssov1.AcceptInvitationRequest{Token: token})
+	require.NoError(t, err)
+	assert.Equal(t, userID, res.GetUserId())
+
+	_, err = loginApp(ctx, st, email, password, sharedOrgAppID)
+	require.NoError(t, err)
+}
+
+func TestInvitations_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	email := gofakeit.Email()
+	inv, err := st.AdminClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{Email: email, OrgId: sharedOrgID})
+	require.NoError(t, err)
+	token := mailToken(st, email)
+
+	_, err = st.AdminClient.RevokeInvitation(adminCtx, &ssov1.RevokeInvitationRequest{InvitationId: inv.GetInvitation().GetId()})
+	require.NoError(t, err)
+
+	_, err = st.AdminClient.RevokeInvitation(adminCtx, &ssov1.RevokeInvitationRequest{InvitationId: inv.GetInvitation().GetId()})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = st.AuthClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: token, Password: "password123"})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid or expired token")
+
+	_, err = st.AdminClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{Email: "not-an-email"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AdminClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{
+		Email: gofakeit.Email(),
+		OrgId: isolatedOrgID,
+		AppId: sharedOrgAppID,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AdminClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{Email: gofakeit.Email(), RoleIds: []int64{1 << 40}})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	email = gofakeit.Email()
+	_, err = st.AdminClient.CreateInvitation(adminCtx, &ssov1.CreateInvitationRequest{Email: email})
+	require.NoError(t, err)
+	_, err = st.AuthClient.AcceptInvitation(ctx, &ssov1.AcceptInvitationRequest{Token: mailToken(st, email)})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// verifyEmail confirms the email of a registered user with the link from the verification mail.
+func verifyEmail(ctx context.Context, st *suite.Suite, email string) {
+	st.Helper()
+
+	_, err := st.AuthClient.SendVerification(ctx, &ssov1.SendVerificationRequest{Email: email})
+	require.NoError(st, err)
+	_, err = st.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: waitMailToken(st, email, "Confirm your email")})
+	require.NoError(st, err)
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"sso/tests/suite"
+	"testing"
+	"time"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLogin_Throttled(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+
+	for i := 0; i <= st.Config.LoginThrottle.AccountFreeAttempts; i++ {
+		_, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: "wrong-password", AppId: appID})
+		require.Error(t, err)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), "attempt %d", i+1)
+	}
+
+	_, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err, "the account is blocked even for the right password")
+	s, _ := status.FromError(err)
+	assert.Equal(t, codes.ResourceExhausted, s.Code())
+
+	var retryAfter time.Duration
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryAfter = info.GetRetryDelay().AsDuration()
+		}
+	}
+	require.Greater(t, retryAfter, time.Duration(0), "RetryInfo detail is set")
+	require.LessOrEqual(t, retryAfter, st.Config.LoginThrottle.BaseDelay+time.Second)
+
+	time.Sleep(retryAfter)
+
+	token := login(ctx, st, email, password)
+	assert.NotEmpty(t, token)
+}
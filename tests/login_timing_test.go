@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"slices"
+	"sso/tests/suite"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestLogin_UnknownEmailTiming checks that a login with an unknown email takes as long as one with
+// a wrong password of a registered email, so the response time does not reveal registered emails.
+func TestLogin_UnknownEmailTiming(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	const samples = 21
+
+	// Every registered email fails once, so the login throttle stays out of the measurement.
+	registered := make([]string, samples)
+	for i := range registered {
+		registered[i], _ = registerUser(ctx, st)
+	}
+
+	loginTime := func(email string) time.Duration {
+		start := time.Now()
+		_, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: "wrong-password", AppId: appID})
+		elapsed := time.Since(start)
+		require.Error(t, err)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		return elapsed
+	}
+
+	var known, unknown []time.Duration
+	for i := 0; i < samples; i++ {
+		// Interleaved, so a slowdown of the machine affects both samples alike.
+		known = append(known, loginTime(registered[i]))
+		unknown = append(unknown, loginTime(gofakeit.Email()))
+	}
+
+	// Mann-Whitney U: the share of pairs in which the unknown email is faster is about 1/2 when both
+	// come from the same distribution and close to 1 when unknown emails skip the password check.
+	faster := 0.0
+	for _, u := range unknown {
+		for _, k := range known {
+			switch {
+			case u < k:
+				faster++
+			case u == k:
+				faster += 0.5
+			}
+		}
+	}
+	share := faster / float64(samples*samples)
+	assert.Less(t, share, 0.85, "unknown emails are answered faster: known %v, unknown %v", known, unknown)
+
+	ratio := float64(median(unknown)) / float64(median(known))
+	assert.InDelta(t, 1, ratio, 0.5, "median login time: known %v, unknown %v", median(known), median(unknown))
+}
+
+func median(durations []time.Duration) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
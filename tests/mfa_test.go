@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"context"
+	"sso/internal/lib/totp"
+	"sso/tests/suite"
+	"testing"
+	"time"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// totpCode returns the authenticator app code of the secret, steps away from the current time step.
+func totpCode(t *testing.T, secret string, steps int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, totp.Step(time.Now())+steps)
+	require.NoError(t, err)
+	return code
+}
+
+// enableTOTP enrolls an authenticator app for the user with the given token and returns its secret.
+func enableTOTP(ctx context.Context, st *suite.Suite, token string) string {
+	st.Helper()
+
+	enrolled, err := st.AuthClient.EnrollTOTP(suite.WithToken(ctx, token), &ssov1.EnrollTOTPRequest{})
+	require.NoError(st, err)
+	require.NotEmpty(st, enrolled.GetSecret())
+	assert.Contains(st, enrolled.GetUri(), "otpauth://totp/")
+
+	confirmed, err := st.AuthClient.ConfirmTOTP(suite.WithToken(ctx, token), &ssov1.ConfirmTOTPRequest{
+		Code: totpCode(st.T, enrolled.GetSecret(), 0),
+	})
+	require.NoError(st, err)
+	assert.NotEmpty(st, confirmed.GetRecoveryCodes())
+
+	return enrolled.GetSecret()
+}
+
+func TestMFA_TOTPLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	secret := enableTOTP(ctx, st, login(ctx, st, email, password))
+
+	res, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	assert.Empty(t, res.GetToken())
+	require.NotEmpty(t, res.GetMfaChallengeId())
+	assert.Equal(t, []string{"totp", "recovery_code"}, res.GetMfaMethods())
+
+	_, err = st.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		ChallengeId: res.GetMfaChallengeId(),
+		Code:        "000000",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	verified, err := st.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		ChallengeId: res.GetMfaChallengeId(),
+		Method:      "totp",
+		Code:        totpCode(t, secret, 1),
+	})
+	require.NoError(t, err)
+
+	claims := tokenClaims(t, verified.GetToken(), appSecret)
+	assert.Equal(t, []any{"pwd", "otp"}, claims["amr"])
+
+	_, err = st.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		ChallengeId: res.GetMfaChallengeId(),
+		Code:        totpCode(t, secret, 1),
+	})
+	require.Error(t, err, "a challenge is finished only once")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestMFA_PasswordOnlyAMR(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	claims := tokenClaims(t, login(ctx, st, email, password), appSecret)
+	assert.Equal(t, []any{"pwd"}, claims["amr"])
+}
+
+func TestMFA_DisableTOTP(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+	secret := enableTOTP(ctx, st, token)
+
+	_, err := st.AuthClient.EnrollTOTP(suite.WithToken(ctx, token), &ssov1.EnrollTOTPRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = st.AuthClient.DisableTOTP(suite.WithToken(ctx, token), &ssov1.DisableTOTPRequest{Code: "000000"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AuthClient.DisableTOTP(suite.WithToken(ctx, token), &ssov1.DisableTOTPRequest{Code: totpCode(t, secret, 1)})
+	require.NoError(t, err)
+
+	res, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	assert.NotEmpty(t, res.GetToken())
+	assert.Empty(t, res.GetMfaChallengeId())
+}
+
+func TestMFA_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AuthClient.EnrollTOTP(ctx, &ssov1.EnrollTOTPRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+
+	_, err = st.AuthClient.ConfirmTOTP(suite.WithToken(ctx, token), &ssov1.ConfirmTOTPRequest{Code: "123456"})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = st.AuthClient.ConfirmTOTP(suite.WithToken(ctx, token), &ssov1.ConfirmTOTPRequest{Code: "12ab"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{ChallengeId: "unknown", Code: "123456"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{ChallengeId: "unknown", Method: "sms", Code: "123456"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,8 @@
+-- password: admin-password
+INSERT INTO users (id, email, password, email_verified, created_at)
+VALUES (1000000, 'admin@sso.test', '$2a$10$EYyaX09S.kZ60mOYd0SKzOWdTfORFPqnNtrje8tZ4dy2K54zWBMl6', TRUE, CURRENT_TIMESTAMP)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id, app_id)
+SELECT 1000000, id, 0 FROM roles WHERE app_id = 0 AND name = 'admin'
+ON CONFLICT DO NOTHING;
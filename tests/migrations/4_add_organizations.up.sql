@@ -0,0 +1,7 @@
+INSERT INTO organizations (id, name, slug, isolated_emails)
+VALUES (1000000, 'Test isolated', 'test-isolated', TRUE), (1000001, 'Test shared', 'test-shared', FALSE)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO apps (id, name, secret, org_id)
+VALUES (3, 'test-isolated-org', 'test-isolated-org-secret', 1000000), (4, 'test-shared-org', 'test-shared-org-secret', 1000001)
+ON CONFLICT DO NOTHING;
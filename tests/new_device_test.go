@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"sso/tests/suite"
+	"testing"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func withDeviceID(ctx context.Context, deviceID string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-device-id", deviceID)
+}
+
+func TestLogin_NewDeviceRevoked(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, password := registerUserWithID(ctx, st)
+	known := login(withDeviceID(ctx, "laptop"), st, email, password)
+	login(withDeviceID(ctx, "laptop"), st, email, password)
+	unrecognized := login(withDeviceID(ctx, "stolen-phone"), st, email, password)
+
+	mail := st.WaitMail(email, "New sign-in")
+	assert.Contains(t, mail, "revoke-login?token=")
+
+	_, err := st.AuthClient.RevokeUnrecognizedLogin(ctx, &ssov1.RevokeUnrecognizedLoginRequest{Token: mailToken(st, email)})
+	require.NoError(t, err)
+
+	for _, token := range []string{unrecognized, known} {
+		_, err = st.AuthClient.GetProfile(suite.WithToken(ctx, token), &ssov1.GetProfileRequest{})
+		require.Error(t, err)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "all sessions of the user are revoked")
+	}
+
+	_, err = st.AuthClient.RevokeUnrecognizedLogin(ctx, &ssov1.RevokeUnrecognizedLoginRequest{Token: mailToken(st, email)})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "the token works once")
+
+	login(withDeviceID(ctx, "laptop"), st, email, password)
+	login(withDeviceID(ctx, "stolen-phone"), st, email, password)
+
+	res, err := st.AdminClient.QueryAuditLog(adminCtx, &ssov1.QueryAuditLogRequest{TargetUserId: userID, Type: "new_device"})
+	require.NoError(t, err)
+	assert.Len(t, res.GetEvents(), 2, "the first device is not reported, the forgotten one is reported again")
+}
+
+func TestRevokeUnrecognizedLogin_InvalidToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AuthClient.RevokeUnrecognizedLogin(ctx, &ssov1.RevokeUnrecognizedLoginRequest{Token: "not-a-token"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AuthClient.RevokeUnrecognizedLogin(ctx, &ssov1.RevokeUnrecognizedLoginRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"context"
+	"sso/tests/suite"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/golang-jwt/jwt"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The organizations and their apps are created by tests/migrations/4_add_organizations.up.sql.
+const (
+	isolatedOrgID     int64 = 1000000
+	isolatedOrgAppID  int32 = 3
+	isolatedOrgSecret       = "test-isolated-org-secret"
+	sharedOrgID       int64 = 1000001
+	sharedOrgAppID    int32 = 4
+	sharedOrgSecret         = "test-shared-org-secret"
+)
+
+func tokenClaims(t *testing.T, token string, secret string) jwt.MapClaims {
+	t.Helper()
+
+	tokenParsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	})
+	require.NoError(t, err)
+
+	return tokenParsed.Claims.(jwt.MapClaims)
+}
+
+func loginApp(ctx context.Context, st *suite.Suite, email string, password string, appID int32) (string, error) {
+	st.Helper()
+
+	res, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	return res.GetToken(), err
+}
+
+func TestOrganizations_IsolatedEmails(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, sharedPassword := randomCredentials()
+	_, orgPassword := randomCredentials()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: sharedPassword})
+	require.NoError(t, err)
+	orgUser, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: orgPassword, OrgId: isolatedOrgID})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: orgPassword, OrgId: isolatedOrgID})
+	require.Error(t, err)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	// The same email signs in to different accounts depending on the app.
+	token, err := loginApp(ctx, st, email, orgPassword, isolatedOrgAppID)
+	require.NoError(t, err)
+	claims := tokenClaims(t, token, isolatedOrgSecret)
+	assert.Equal(t, orgUser.GetUserId(), int64(claims["userID"].(float64)))
+	assert.Equal(t, isolatedOrgID, int64(claims["org_id"].(float64)))
+
+	_, err = loginApp(ctx, st, email, sharedPassword, isolatedOrgAppID)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid credentials")
+
+	token, err = loginApp(ctx, st, email, sharedPassword, appID)
+	require.NoError(t, err)
+	claims = tokenClaims(t, token, appSecret)
+	assert.NotContains(t, claims, "org_id")
+
+	_, err = loginApp(ctx, st, email, orgPassword, appID)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "Invalid credentials")
+}
+
+func TestOrganizations_Membership(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	userID, email, password := registerUserWithID(ctx, st)
+
+	_, err := loginApp(ctx, st, email, password, sharedOrgAppID)
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.AdminClient.AddMember(adminCtx, &ssov1.AddMemberRequest{OrgId: sharedOrgID, UserId: userID})
+	require.NoError(t, err)
+
+	role, err := st.AdminClient.CreateRole(adminCtx, &ssov1.CreateRoleRequest{
+		OrgId: sharedOrgID,
+		Name:  randomRBACName("org-editor"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sharedOrgID, role.GetRole().GetOrgId())
+	_, err = st.AdminClient.AssignRole(adminCtx, &ssov1.AssignRoleRequest{UserId: userID, RoleId: role.GetRole().GetId()})
+	require.NoError(t, err)
+
+	token, err := loginApp(ctx, st, email, password, sharedOrgAppID)
+	require.NoError(t, err)
+	claims := tokenClaims(t, token, sharedOrgSecret)
+	assert.Equal(t, sharedOrgID, int64(claims["org_id"].(float64)))
+	assert.Equal(t, []any{role.GetRole().GetName()}, claims["roles"])
+
+	// Organization roles don't follow the user to the shared apps.
+	token, err = loginApp(ctx, st, email, password, appID)
+	require.NoError(t, err)
+	assert.NotContains(t, tokenClaims(t, token, appSecret), "roles")
+
+	members, err := st.AdminClient.ListUsers(adminCtx, &ssov1.ListUsersRequest{OrgId: sharedOrgID, EmailPrefix: email})
+	require.NoError(t, err)
+	require.Len(t, members.GetUsers(), 1)
+	assert.Equal(t, userID, members.GetUsers()[0].GetId())
+
+	_, err = st.AdminClient.RemoveMember(adminCtx, &ssov1.RemoveMemberRequest{OrgId: sharedOrgID, UserId: userID})
+	require.NoError(t, err)
+
+	_, err = loginApp(ctx, st, email, password, sharedOrgAppID)
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	roles, err := st.AdminClient.ListUserRoles(adminCtx, &ssov1.ListUserRolesRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.Empty(t, roles.GetAssignments())
+}
+
+func TestOrganizations_Admin(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx := adminContext(ctx, st)
+
+	slug := strings.ToLower(gofakeit.LetterN(12))
+	org, err := st.AdminClient.CreateOrganization(adminCtx, &ssov1.CreateOrganizationRequest{Name: "Acme", Slug: slug})
+	require.NoError(t, err)
+	assert.Equal(t, slug, org.GetOrganization().GetSlug())
+
+	_, err = st.AdminClient.CreateOrganization(adminCtx, &ssov1.CreateOrganizationRequest{Name: "Acme", Slug: slug})
+	require.Error(t, err)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = st.AdminClient.CreateOrganization(adminCtx, &ssov1.CreateOrganizationRequest{Name: "Acme", Slug: "Not a slug"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	got, err := st.AdminClient.GetOrganization(adminCtx, &ssov1.GetOrganizationRequest{OrgId: org.GetOrganization().GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", got.GetOrganization().GetName())
+
+	_, err = st.AdminClient.DeleteOrganization(adminCtx, &ssov1.DeleteOrganizationRequest{OrgId: org.GetOrganization().GetId()})
+	require.NoError(t, err)
+
+	_, err = st.AdminClient.GetOrganization(adminCtx, &ssov1.GetOrganizationRequest{OrgId: org.GetOrganization().GetId()})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// The fixture organizations have apps.
+	_, err = st.AdminClient.DeleteOrganization(adminCtx, &ssov1.DeleteOrganizationRequest{OrgId: sharedOrgID})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	userID, _, _ := registerUserWithID(ctx, st)
+	_, err = st.AdminClient.AddMember(adminCtx, &ssov1.AddMemberRequest{OrgId: isolatedOrgID, UserId: userID})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: gofakeit.Email(), Password: "password123", OrgId: 1 << 40})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
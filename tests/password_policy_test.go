@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"sso/tests/suite"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// requirePolicyViolation checks that err rejects field by the password policy.
+func requirePolicyViolation(t *testing.T, err error, field string) {
+	t.Helper()
+
+	require.Error(t, err)
+	s, _ := status.FromError(err)
+	require.Equal(t, codes.InvalidArgument, s.Code())
+	assert.Contains(t, s.Message(), "Password does not meet the policy")
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range s.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = append(violations, badRequest.GetFieldViolations()...)
+		}
+	}
+	require.NotEmpty(t, violations, "BadRequest detail is set")
+	for _, violation := range violations {
+		assert.Equal(t, field, violation.GetField())
+		assert.NotEmpty(t, violation.GetDescription())
+	}
+}
+
+func TestRegister_PasswordPolicy(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	local, _, _ := strings.Cut(email, "@")
+
+	cases := []struct {
+		name     string
+		password string
+	}{
+		{name: "Common password", password: "password123"},
+		{name: "Keyboard walk", password: "qwertyuiop"},
+		{name: "Email local part", password: local + "2024!"},
+		{name: "Banned word", password: "My-sso-Pa55"},
+		{name: "Short password", password: "x7#Kq!"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+				Email:    email,
+				Password: tt.password,
+				AppId:    appID,
+			})
+			requirePolicyViolation(t, err, "password")
+		})
+	}
+
+	_, password := randomCredentials()
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestChangePassword_PasswordPolicy(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+
+	_, err := st.AuthClient.ChangePassword(suite.WithToken(ctx, token), &ssov1.ChangePasswordRequest{
+		OldPassword: password,
+		NewPassword: "password123",
+	})
+	requirePolicyViolation(t, err, "new_password")
+
+	_, err = st.AuthClient.ChangePassword(suite.WithToken(ctx, token), &ssov1.ChangePasswordRequest{
+		OldPassword: password,
+		NewPassword: "x7#Kq!",
+	})
+	requirePolicyViolation(t, err, "new_password")
+
+	login(ctx, st, email, password)
+}
+
+func TestResetPassword_PasswordPolicy(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, _ := registerUser(ctx, st)
+	_, err := st.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+	token := waitMailToken(st, email, "Reset your password")
+
+	_, err = st.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{
+		Token:    token,
+		Password: "iloveyou1",
+		AppId:    appID,
+	})
+	requirePolicyViolation(t, err, "password")
+
+	// A rejected password does not use the token up.
+	newPassword := gofakeit.Password(true, true, true, true, true, passwordDefaultLen)
+	_, err = st.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{
+		Token:    token,
+		Password: newPassword,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	login(ctx, st, email, newPassword)
+}
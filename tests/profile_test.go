@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"sso/tests/suite"
+	"testing"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestProfile_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	authCtx := suite.WithToken(ctx, login(ctx, st, email, password))
+
+	resp, err := st.AuthClient.GetProfile(authCtx, &ssov1.GetProfileRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, resp.GetProfile().GetDisplayName())
+	assert.JSONEq(t, "{}", resp.GetProfile().GetUserMetadata())
+
+	updated, err := st.AuthClient.UpdateProfile(authCtx, &ssov1.UpdateProfileRequest{
+		Profile: &ssov1.Profile{
+			DisplayName:  "Jane Doe",
+			Locale:       "en-US",
+			Timezone:     "Europe/Berlin",
+			UserMetadata: `{"theme": "dark"}`,
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", updated.GetProfile().GetDisplayName())
+
+	// Only the fields in the mask change, so a field can be cleared.
+	_, err = st.AuthClient.UpdateProfile(authCtx, &ssov1.UpdateProfileRequest{
+		Profile:    &ssov1.Profile{GivenName: "Jane"},
+		UpdateMask: []string{"given_name", "locale"},
+	})
+	require.NoError(t, err)
+
+	resp, err = st.AuthClient.GetProfile(authCtx, &ssov1.GetProfileRequest{})
+	require.NoError(t, err)
+	profile := resp.GetProfile()
+	assert.Equal(t, "Jane Doe", profile.GetDisplayName())
+	assert.Equal(t, "Jane", profile.GetGivenName())
+	assert.Empty(t, profile.GetLocale())
+	assert.Equal(t, "Europe/Berlin", profile.GetTimezone())
+	assert.JSONEq(t, `{"theme": "dark"}`, profile.GetUserMetadata())
+}
+
+func TestUpdateProfile_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	authCtx := suite.WithToken(ctx, login(ctx, st, email, password))
+
+	tests := []struct {
+		name        string
+		req         *ssov1.UpdateProfileRequest
+		expectedErr codes.Code
+	}{
+		{
+			name:        "Invalid timezone",
+			req:         &ssov1.UpdateProfileRequest{Profile: &ssov1.Profile{Timezone: "Mars/Olympus"}},
+			expectedErr: codes.InvalidArgument,
+		},
+		{
+			name:        "Metadata is not an object",
+			req:         &ssov1.UpdateProfileRequest{Profile: &ssov1.Profile{UserMetadata: `["a"]`}},
+			expectedErr: codes.InvalidArgument,
+		},
+		{
+			name: "Unknown field in mask",
+			req: &ssov1.UpdateProfileRequest{
+				Profile:    &ssov1.Profile{DisplayName: "Jane"},
+				UpdateMask: []string{"email"},
+			},
+			expectedErr: codes.InvalidArgument,
+		},
+		{
+			name:        "App metadata by user",
+			req:         &ssov1.UpdateProfileRequest{Profile: &ssov1.Profile{AppMetadata: `{"plan": "pro"}`}},
+			expectedErr: codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.UpdateProfile(authCtx, tt.req)
+			require.Error(t, err)
+			assert.Equal(t, tt.expectedErr, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package tests
+
+import (
+	"sso/tests/suite"
+	"testing"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The test config has no realms, every login is checked by the local password.
+func TestDiscoverRealm(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	for _, req := range []*ssov1.DiscoverRealmRequest{
+		{Login: "alice@corp.example"},
+		{Login: "alice", Realm: "corp.example"},
+	} {
+		res, err := st.AuthClient.DiscoverRealm(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, "local", res.GetSource())
+	}
+
+	_, err := st.AuthClient.DiscoverRealm(ctx, &ssov1.DiscoverRealmRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"sso/tests/suite"
+	"testing"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// verifyRecoveryCode logs the user in with the password and finishes the MFA challenge with the recovery code.
+func verifyRecoveryCode(ctx context.Context, st *suite.Suite, email, password, code string) (*ssov1.VerifyMFAResponse, error) {
+	st.Helper()
+
+	res, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(st, err)
+	require.NotEmpty(st, res.GetMfaChallengeId())
+	assert.Contains(st, res.GetMfaMethods(), "recovery_code")
+
+	return st.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		ChallengeId: res.GetMfaChallengeId(),
+		Method:      "recovery_code",
+		Code:        code,
+	})
+}
+
+func TestRecoveryCodes_Login(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+
+	enrolled, err := st.AuthClient.EnrollTOTP(suite.WithToken(ctx, token), &ssov1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	confirmed, err := st.AuthClient.ConfirmTOTP(suite.WithToken(ctx, token), &ssov1.ConfirmTOTPRequest{
+		Code: totpCode(t, enrolled.GetSecret(), 0),
+	})
+	require.NoError(t, err)
+	recoveryCodes := confirmed.GetRecoveryCodes()
+	require.Len(t, recoveryCodes, st.Config.MFA.RecoveryCodes)
+
+	verified, err := verifyRecoveryCode(ctx, st, email, password, recoveryCodes[0])
+	require.NoError(t, err)
+	claims := tokenClaims(t, verified.GetToken(), appSecret)
+	assert.Equal(t, []any{"pwd", "otp"}, claims["amr"])
+
+	_, err = verifyRecoveryCode(ctx, st, email, password, recoveryCodes[0])
+	require.Error(t, err, "a recovery code is used only once")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	count, err := st.AuthClient.CountRecoveryCodes(suite.WithToken(ctx, token), &ssov1.CountRecoveryCodesRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(recoveryCodes)-1), count.GetRemaining())
+}
+
+func TestRecoveryCodes_Regenerate(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+
+	_, err := st.AuthClient.RegenerateRecoveryCodes(suite.WithToken(ctx, token), &ssov1.RegenerateRecoveryCodesRequest{})
+	require.Error(t, err, "recovery codes need a second factor")
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	enableTOTP(ctx, st, token)
+
+	regenerated, err := st.AuthClient.RegenerateRecoveryCodes(suite.WithToken(ctx, token), &ssov1.RegenerateRecoveryCodesRequest{})
+	require.NoError(t, err)
+	require.Len(t, regenerated.GetRecoveryCodes(), st.Config.MFA.RecoveryCodes)
+
+	verified, err := verifyRecoveryCode(ctx, st, email, password, regenerated.GetRecoveryCodes()[1])
+	require.NoError(t, err)
+	assert.NotEmpty(t, verified.GetToken())
+
+	count, err := st.AuthClient.CountRecoveryCodes(suite.WithToken(ctx, token), &ssov1.CountRecoveryCodesRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(st.Config.MFA.RecoveryCodes-1), count.GetRemaining())
+}
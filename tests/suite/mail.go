@@ -0,0 +1,72 @@
+package suite
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LastMail returns the latest message the outbox mailer wrote for the recipient.
+// Relative outbox paths are resolved from the repository root, where the server runs.
+func (s *Suite) LastMail(to string) string {
+	s.Helper()
+
+	mail, ok := s.lastMail(to)
+	if !ok {
+		s.Fatalf("no mail for %s in outbox", to)
+	}
+
+	return mail
+}
+
+// WaitMail is LastMail for the messages the server sends in the background: it waits
+// a few seconds for the latest message to the recipient to contain the text.
+func (s *Suite) WaitMail(to string, contains string) string {
+	s.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mail, ok := s.lastMail(to)
+		if ok && strings.Contains(mail, contains) {
+			return mail
+		}
+		if time.Now().After(deadline) {
+			s.Fatalf("no mail for %s containing %q in outbox", to, contains)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (s *Suite) lastMail(to string) (string, bool) {
+	s.Helper()
+
+	dir := s.Config.Mailer.OutboxPath
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join("..", dir)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		s.Fatalf("failed to read outbox: %v", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "-"+to+"-") {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	sort.Strings(names)
+
+	b, err := os.ReadFile(filepath.Join(dir, names[len(names)-1]))
+	if err != nil {
+		s.Fatalf("failed to read mail: %v", err)
+	}
+
+	return string(b), true
+}
@@ -11,12 +11,14 @@ import (
 	ssov1 "github.com/jacute/protos/gen/go/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 type Suite struct {
 	*testing.T
-	Config     *config.Config
-	AuthClient ssov1.AuthClient
+	Config      *config.Config
+	AuthClient  ssov1.AuthClient
+	AdminClient ssov1.AdminServiceClient
 }
 
 const (
@@ -52,12 +54,18 @@ func New(t *testing.T) (context.Context, *Suite) {
 	}
 
 	return ctx, &Suite{
-		T:          t,
-		Config:     cfg,
-		AuthClient: ssov1.NewAuthClient(cc),
+		T:           t,
+		Config:      cfg,
+		AuthClient:  ssov1.NewAuthClient(cc),
+		AdminClient: ssov1.NewAdminServiceClient(cc),
 	}
 }
 
 func grpcAddress(cfg *config.Config) string {
 	return net.JoinHostPort(grpcHost, strconv.Itoa(cfg.GRPC.Port))
 }
+
+// WithToken returns a context that authenticates requests with the token issued by Login.
+func WithToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
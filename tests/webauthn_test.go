@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"sso/internal/lib/webauthntest"
+	"sso/tests/suite"
+	"testing"
+
+	ssov1 "github.com/jacute/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// registerPasskey registers a software authenticator for the user with the given token.
+func registerPasskey(ctx context.Context, st *suite.Suite, token string) *webauthntest.Authenticator {
+	st.Helper()
+
+	begun, err := st.AuthClient.BeginWebAuthnRegistration(suite.WithToken(ctx, token), &ssov1.BeginWebAuthnRegistrationRequest{})
+	require.NoError(st, err)
+
+	authn := webauthntest.New(st.Config.WebAuthn.Origins[0])
+	credential, err := authn.Register(begun.GetOptions())
+	require.NoError(st, err)
+
+	finished, err := st.AuthClient.FinishWebAuthnRegistration(suite.WithToken(ctx, token), &ssov1.FinishWebAuthnRegistrationRequest{
+		Name:       "Laptop",
+		Credential: credential,
+	})
+	require.NoError(st, err)
+	assert.Equal(st, "Laptop", finished.GetCredential().GetName())
+
+	return authn
+}
+
+func TestWebAuthn_SecondFactor(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	authn := registerPasskey(ctx, st, login(ctx, st, email, password))
+
+	res, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	assert.Empty(t, res.GetToken())
+	assert.Equal(t, []string{"webauthn", "recovery_code"}, res.GetMfaMethods())
+
+	begun, err := st.AuthClient.BeginWebAuthnLogin(ctx, &ssov1.BeginWebAuthnLoginRequest{MfaChallengeId: res.GetMfaChallengeId()})
+	require.NoError(t, err)
+	assertion, err := authn.Assert(begun.GetOptions())
+	require.NoError(t, err)
+
+	verified, err := st.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		ChallengeId: res.GetMfaChallengeId(),
+		Method:      "webauthn",
+		Code:        string(assertion),
+	})
+	require.NoError(t, err)
+
+	claims := tokenClaims(t, verified.GetToken(), appSecret)
+	assert.Equal(t, []any{"pwd", "hwk"}, claims["amr"])
+}
+
+func TestWebAuthn_PasswordlessLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	authn := registerPasskey(ctx, st, login(ctx, st, email, password))
+
+	begun, err := st.AuthClient.BeginWebAuthnLogin(ctx, &ssov1.BeginWebAuthnLoginRequest{AppId: appID})
+	require.NoError(t, err)
+	assertion, err := authn.Assert(begun.GetOptions())
+	require.NoError(t, err)
+
+	finished, err := st.AuthClient.FinishWebAuthnLogin(ctx, &ssov1.FinishWebAuthnLoginRequest{Credential: assertion})
+	require.NoError(t, err)
+
+	claims := tokenClaims(t, finished.GetToken(), appSecret)
+	assert.Equal(t, []any{"hwk", "mfa"}, claims["amr"])
+	assert.Equal(t, email, claims["email"])
+
+	_, err = st.AuthClient.FinishWebAuthnLogin(ctx, &ssov1.FinishWebAuthnLoginRequest{Credential: assertion})
+	require.Error(t, err, "an assertion is accepted only once")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWebAuthn_ListAndDelete(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, password := registerUser(ctx, st)
+	token := login(ctx, st, email, password)
+	registerPasskey(ctx, st, token)
+
+	list, err := st.AuthClient.ListWebAuthnCredentials(suite.WithToken(ctx, token), &ssov1.ListWebAuthnCredentialsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetCredentials(), 1)
+	id := list.GetCredentials()[0].GetId()
+
+	_, err = st.AuthClient.DeleteWebAuthnCredential(suite.WithToken(ctx, token), &ssov1.DeleteWebAuthnCredentialRequest{Id: id})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.DeleteWebAuthnCredential(suite.WithToken(ctx, token), &ssov1.DeleteWebAuthnCredentialRequest{Id: id})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	res, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	assert.NotEmpty(t, res.GetToken(), "without credentials the password is enough again")
+}
+
+func TestWebAuthn_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	tests := []struct {
+		name string
+		req  *ssov1.BeginWebAuthnLoginRequest
+	}{
+		{name: "No app and no challenge", req: &ssov1.BeginWebAuthnLoginRequest{}},
+		{name: "Unknown app", req: &ssov1.BeginWebAuthnLoginRequest{AppId: 9999}},
+		{name: "Unknown challenge", req: &ssov1.BeginWebAuthnLoginRequest{MfaChallengeId: "unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.BeginWebAuthnLogin(ctx, tt.req)
+			require.Error(t, err)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+
+	_, err := st.AuthClient.BeginWebAuthnRegistration(ctx, &ssov1.BeginWebAuthnRegistrationRequest{})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.FinishWebAuthnLogin(ctx, &ssov1.FinishWebAuthnLoginRequest{Credential: []byte("{}")})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}